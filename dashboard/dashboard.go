@@ -0,0 +1,90 @@
+// Package dashboard serve a small web UI over the scan history. The assets are embedded so the binary is all you need.
+package dashboard
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/sunshine69/automation-go/history"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// TrendPoint is the number of findings of the scans of one day
+type TrendPoint struct {
+	Date     string
+	Scans    int
+	Findings int // findings of the last scan of the day
+}
+
+// Handler return the dashboard: the UI at / and the json api at /api/scans, /api/scans/{id} and /api/trend
+func Handler(store history.Store) http.Handler {
+	mux := http.NewServeMux()
+	static, _ := fs.Sub(assets, "assets")
+	mux.Handle("GET /", http.FileServer(http.FS(static)))
+	mux.HandleFunc("GET /api/scans", func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		records, err := store.List(history.KindScan, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range records { // the list is a summary; get the findings with /api/scans/{id}
+			records[i].Findings = nil
+		}
+		writeJson(w, records)
+	})
+	mux.HandleFunc("GET /api/scans/{id}", func(w http.ResponseWriter, r *http.Request) {
+		record, err := store.Get(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJson(w, record)
+	})
+	mux.HandleFunc("GET /api/trend", func(w http.ResponseWriter, r *http.Request) {
+		records, err := store.List(history.KindScan, 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, Trend(records))
+	})
+	return mux
+}
+
+// Trend group the records per day, oldest first
+func Trend(records []history.Record) []TrendPoint {
+	days := map[string]*TrendPoint{}
+	last := map[string]int64{}
+	for _, r := range records {
+		day := r.Time.Format("2006-01-02")
+		p, ok := days[day]
+		if !ok {
+			p = &TrendPoint{Date: day}
+			days[day] = p
+		}
+		p.Scans++
+		if ts := r.Time.UnixNano(); ts >= last[day] {
+			last[day], p.Findings = ts, r.FindingCount
+		}
+	}
+	o := []TrendPoint{}
+	for _, p := range days {
+		o = append(o, *p)
+	}
+	sort.Slice(o, func(i, j int) bool { return o[i].Date < o[j].Date })
+	return o
+}
+
+func writeJson(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	je := json.NewEncoder(w)
+	je.SetEscapeHTML(false)
+	je.Encode(v)
+}
@@ -0,0 +1,194 @@
+// Package history records the cred-detect scans so they can be compared over time and shown in the dashboard.
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunshine69/automation-go/scanner"
+)
+
+// Record kinds
+const (
+	KindScan = "scan"
+)
+
+// FindingKey identify a finding across scans
+type FindingKey struct {
+	File      string
+	Signature string
+}
+
+// Record is one scan. Findings are always stored masked.
+type Record struct {
+	ID             string
+	Kind           string
+	Time           time.Time
+	Root           string // the scanned path
+	ConfigHash     string
+	FindingCount   int
+	NewCount       int          // findings not in the previous scan of the same root, see Classify
+	RecurringCount int          // findings already in the previous scan of the same root
+	New            []FindingKey `json:",omitempty"`
+	Findings       scanner.ProjectOutputFmt
+}
+
+// Store keeps the records. List return the newest first and may leave the Findings out; limit <= 0 means all.
+type Store interface {
+	Add(r *Record) error
+	List(kind string, limit int) ([]Record, error)
+	Get(id string) (Record, error)
+	Close() error
+}
+
+// Open a store. A path ending with .json is a JSONStore, anything else a SQLiteStore.
+func Open(path string) (Store, error) {
+	if strings.HasSuffix(path, ".json") {
+		return OpenJSONStore(path)
+	}
+	return OpenSQLiteStore(path)
+}
+
+// Classify mark the findings of r as new or recurring by comparing with the previous scan of the same root in the
+// store. Without a previous scan everything is new.
+func Classify(store Store, r *Record) error {
+	records, err := store.List(r.Kind, 0)
+	if err != nil {
+		return err
+	}
+	var prev Record
+	for _, rec := range records {
+		if rec.Root == r.Root && rec.ID != r.ID {
+			if prev, err = store.Get(rec.ID); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	r.New, r.NewCount, r.RecurringCount = []FindingKey{}, 0, 0
+	for file, matches := range r.Findings {
+		for sig := range matches {
+			if _, ok := prev.Findings[file][sig]; ok {
+				r.RecurringCount++
+			} else {
+				r.New = append(r.New, FindingKey{file, sig})
+				r.NewCount++
+			}
+		}
+	}
+	sort.Slice(r.New, func(i, j int) bool {
+		if r.New[i].File != r.New[j].File {
+			return r.New[i].File < r.New[j].File
+		}
+		return r.New[i].Signature < r.New[j].Signature
+	})
+	return nil
+}
+
+// NewScanRecord create a record of a scan. The finding values are masked if they are not already.
+func NewScanRecord(root string, cfg scanner.Config, findings scanner.ProjectOutputFmt) *Record {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	r := &Record{Kind: KindScan, Time: time.Now(), Root: root, ConfigHash: cfg.Hash(), Findings: scanner.ProjectOutputFmt{}}
+	for file, matches := range findings {
+		r.Findings[file] = map[string]scanner.OutputFmt{}
+		for sig, o := range matches {
+			o.Matches = append([]string{}, o.Matches...)
+			for idx := range o.Matches {
+				if idx%2 == 1 {
+					o.Matches[idx] = "*****"
+				}
+			}
+			r.Findings[file][sig] = o
+			r.FindingCount++
+		}
+	}
+	return r
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
+// JSONStore keep all records in a single json file. Fine for a project history; use the sqlite store for more.
+type JSONStore struct {
+	path    string
+	lock    sync.Mutex
+	records []Record
+}
+
+// OpenJSONStore load the records from the file, which is created on the first Add if it does not exist
+func OpenJSONStore(path string) (*JSONStore, error) {
+	s := &JSONStore{path: path}
+	datab, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(datab, &s.records); err != nil {
+		return nil, fmt.Errorf("invalid history file %s - %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *JSONStore) Add(r *Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if r.ID == "" {
+		r.ID = newID()
+	}
+	s.records = append(s.records, *r)
+	datab, err := json.Marshal(s.records)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, datab, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *JSONStore) List(kind string, limit int) ([]Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	o := []Record{}
+	for _, r := range s.records {
+		if kind == "" || r.Kind == kind {
+			o = append(o, r)
+		}
+	}
+	sort.SliceStable(o, func(i, j int) bool { return o[i].Time.After(o[j].Time) })
+	if limit > 0 && len(o) > limit {
+		o = o[:limit]
+	}
+	return o, nil
+}
+
+func (s *JSONStore) Get(id string) (Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, r := range s.records {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return Record{}, fmt.Errorf("record %s not found", id)
+}
+
+func (s *JSONStore) Close() error {
+	return nil
+}
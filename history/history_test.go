@@ -0,0 +1,115 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sunshine69/automation-go/scanner"
+)
+
+func TestJSONStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	store, err := OpenJSONStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := scanner.ProjectOutputFmt{"a.txt": {"passwordXk9d": {File: "a.txt", Line_no: []int{0}, Matches: []string{"password", "Xk9d"}}}}
+	for i := 0; i < 2; i++ {
+		if err := store.Add(NewScanRecord(".", scanner.DefaultConfig(), findings)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if findings["a.txt"]["passwordXk9d"].Matches[1] != "Xk9d" {
+		t.Error("NewScanRecord must not modify the findings")
+	}
+
+	store, err = OpenJSONStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := store.List(KindScan, 1)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expect 1 record, got %v %v", records, err)
+	}
+	r, err := store.Get(records[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.FindingCount != 1 || r.Findings["a.txt"]["passwordXk9d"].Matches[1] != "*****" {
+		t.Errorf("expect 1 masked finding, got %+v", r)
+	}
+}
+
+func TestSQLiteStoreClassify(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	first := scanner.ProjectOutputFmt{"a.txt": {"passwordXk9d": {File: "a.txt", Line_no: []int{0}, Matches: []string{"password", "Xk9d"}}}}
+	second := scanner.ProjectOutputFmt{
+		"a.txt": first["a.txt"],
+		"b.txt": {"tokenZq8w": {File: "b.txt", Line_no: []int{3}, Matches: []string{"token", "Zq8w"}}},
+	}
+	for _, findings := range []scanner.ProjectOutputFmt{first, second} {
+		r := NewScanRecord(".", scanner.DefaultConfig(), findings)
+		if err := Classify(store, r); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := store.List(KindScan, 0)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("expect 2 records, got %v %v", records, err)
+	}
+	if records[0].NewCount != 1 || records[0].RecurringCount != 1 || records[1].NewCount != 1 {
+		t.Errorf("unexpected classification %+v", records)
+	}
+	r, err := store.Get(records[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.New) != 1 || r.New[0] != (FindingKey{"b.txt", "tokenZq8w"}) || r.Findings["b.txt"]["tokenZq8w"].Line_no[0] != 3 {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
+
+func TestSummaries(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	finding := func(id, file string) scanner.OutputFmt {
+		return scanner.OutputFmt{File: file, Line_no: []int{0}, Matches: []string{"password", "Xk9d"}, RuleID: "generic", Severity: scanner.SeverityHigh, ID: id}
+	}
+	now := time.Now()
+	scans := []scanner.ProjectOutputFmt{
+		{"a.txt": {"1": finding("CRED-0001-0000-0000", "a.txt")}, "b.txt": {"2": finding("CRED-0002-0000-0000", "b.txt")}},
+		{"a.txt": {"1": finding("CRED-0001-0000-0000", "a.txt")}},
+		{"a.txt": {"1": finding("CRED-0001-0000-0000", "a.txt"), "3": finding("CRED-0003-0000-0000", "a.txt")}},
+	}
+	for idx, findings := range scans {
+		s := NewSummary(".", scanner.DefaultConfig(), findings)
+		s.Time = now.Add(time.Duration(idx-2) * 72 * time.Hour) // 6 and 3 days ago, now
+		if _, err := WriteSummary(dir, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summaries, err := LoadSummaries(dir)
+	if err != nil || len(summaries) != 3 {
+		t.Fatalf("expect 3 summaries, got %v %v", summaries, err)
+	}
+	if s := summaries[0]; s.FindingCount != 2 || s.BySeverity[scanner.SeverityHigh] != 2 || s.Findings[0].ID != "CRED-0001-0000-0000" {
+		t.Errorf("unexpected first summary %+v", s)
+	}
+	report := NewTrendReport(summaries, now.Add(-4*24*time.Hour))
+	if len(report.Days) != 3 || report.Days[0].New != 2 || report.Days[1].Fixed != 1 || report.Days[2].New != 1 || report.Days[2].Findings != 2 {
+		t.Errorf("unexpected days %+v", report.Days)
+	}
+	if len(report.New) != 1 || report.New[0].ID != "CRED-0003-0000-0000" {
+		t.Errorf("expect the new finding of the last scan, got %+v", report.New)
+	}
+	if len(report.Fixed) != 1 || report.Fixed[0].ID != "CRED-0002-0000-0000" {
+		t.Errorf("expect the finding fixed 3 days ago, got %+v", report.Fixed)
+	}
+}
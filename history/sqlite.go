@@ -0,0 +1,158 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sunshine69/automation-go/scanner"
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS records (
+	id TEXT PRIMARY KEY,
+	kind TEXT NOT NULL,
+	time INTEGER NOT NULL,
+	root TEXT NOT NULL,
+	config_hash TEXT NOT NULL,
+	finding_count INTEGER NOT NULL,
+	new_count INTEGER NOT NULL DEFAULT 0,
+	recurring_count INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS records_kind_time ON records(kind, time);
+CREATE TABLE IF NOT EXISTS findings (
+	record_id TEXT NOT NULL REFERENCES records(id) ON DELETE CASCADE,
+	file TEXT NOT NULL,
+	signature TEXT NOT NULL,
+	pattern TEXT NOT NULL,
+	line_no TEXT NOT NULL,
+	matches TEXT NOT NULL,
+	is_new INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS findings_record ON findings(record_id);
+`
+
+// SQLiteStore keep the records in a sqlite database (pure go driver, no cgo needed)
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore open or create the database file and its schema
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can not create history schema in %s - %w", path, err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Add(r *Record) error {
+	if r.ID == "" {
+		r.ID = newID()
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO records(id, kind, time, root, config_hash, finding_count, new_count, recurring_count) VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, r.Kind, r.Time.UnixNano(), r.Root, r.ConfigHash, r.FindingCount, r.NewCount, r.RecurringCount); err != nil {
+		return err
+	}
+	isNew := map[FindingKey]bool{}
+	for _, k := range r.New {
+		isNew[k] = true
+	}
+	for file, matches := range r.Findings {
+		for sig, o := range matches {
+			lineNo, _ := json.Marshal(o.Line_no)
+			matchesb, _ := json.Marshal(o.Matches)
+			if _, err := tx.Exec(`INSERT INTO findings(record_id, file, signature, pattern, line_no, matches, is_new) VALUES(?, ?, ?, ?, ?, ?, ?)`,
+				r.ID, file, sig, o.Pattern, string(lineNo), string(matchesb), isNew[FindingKey{file, sig}]); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// List return the records without their findings, use Get for those
+func (s *SQLiteStore) List(kind string, limit int) ([]Record, error) {
+	query := `SELECT id, kind, time, root, config_hash, finding_count, new_count, recurring_count FROM records`
+	args := []any{}
+	if kind != "" {
+		query += ` WHERE kind = ?`
+		args = append(args, kind)
+	}
+	query += ` ORDER BY time DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	o := []Record{}
+	for rows.Next() {
+		var r Record
+		var ts int64
+		if err := rows.Scan(&r.ID, &r.Kind, &ts, &r.Root, &r.ConfigHash, &r.FindingCount, &r.NewCount, &r.RecurringCount); err != nil {
+			return nil, err
+		}
+		r.Time = time.Unix(0, ts)
+		o = append(o, r)
+	}
+	return o, rows.Err()
+}
+
+func (s *SQLiteStore) Get(id string) (Record, error) {
+	var r Record
+	var ts int64
+	err := s.db.QueryRow(`SELECT id, kind, time, root, config_hash, finding_count, new_count, recurring_count FROM records WHERE id = ?`, id).
+		Scan(&r.ID, &r.Kind, &ts, &r.Root, &r.ConfigHash, &r.FindingCount, &r.NewCount, &r.RecurringCount)
+	if err == sql.ErrNoRows {
+		return r, fmt.Errorf("record %s not found", id)
+	}
+	if err != nil {
+		return r, err
+	}
+	r.Time = time.Unix(0, ts)
+	r.Findings = scanner.ProjectOutputFmt{}
+	rows, err := s.db.Query(`SELECT file, signature, pattern, line_no, matches, is_new FROM findings WHERE record_id = ?`, id)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var file, sig, lineNo, matches string
+		var isNew bool
+		o := scanner.OutputFmt{}
+		if err := rows.Scan(&file, &sig, &o.Pattern, &lineNo, &matches, &isNew); err != nil {
+			return r, err
+		}
+		o.File = file
+		json.Unmarshal([]byte(lineNo), &o.Line_no)
+		json.Unmarshal([]byte(matches), &o.Matches)
+		if _, ok := r.Findings[file]; !ok {
+			r.Findings[file] = map[string]scanner.OutputFmt{}
+		}
+		r.Findings[file][sig] = o
+		if isNew {
+			r.New = append(r.New, FindingKey{file, sig})
+		}
+	}
+	return r, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,220 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sunshine69/automation-go/scanner"
+)
+
+// Summary is the summary of a scan in a history directory, one json file per scan so the scans of many jobs can be
+// added to the same directory, eg. an artifact or a git repository, without a database. See WriteSummary.
+type Summary struct {
+	Time         time.Time
+	Root         string // the scanned path
+	ConfigHash   string
+	FindingCount int
+	BySeverity   map[string]int
+	Findings     []SummaryFinding // sorted by id
+}
+
+// SummaryFinding is a finding of a Summary, without its values
+type SummaryFinding struct {
+	ID       string // see scanner.FindingID
+	File     string
+	RuleID   string
+	Severity string
+}
+
+// key identify the finding across the summaries, its id or for a finding without fingerprint its file and rule
+func (f SummaryFinding) key() string {
+	if f.ID != "" {
+		return f.ID
+	}
+	return f.File + "\x00" + f.RuleID
+}
+
+// NewSummary create the summary of a scan, each finding id once
+func NewSummary(root string, cfg scanner.Config, findings scanner.ProjectOutputFmt) Summary {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	s := Summary{Time: time.Now(), Root: root, ConfigHash: cfg.Hash(), BySeverity: map[string]int{}, Findings: []SummaryFinding{}}
+	seen := map[string]bool{}
+	for _, o := range findings.List() {
+		f := SummaryFinding{ID: o.ID, File: o.File, RuleID: o.RuleID, Severity: o.Severity}
+		if f.RuleID == "" {
+			f.RuleID = scanner.PatternRuleID(o.Pattern)
+		}
+		if f.Severity == "" {
+			f.Severity = scanner.SeverityMedium
+		}
+		if seen[f.key()] {
+			continue
+		}
+		seen[f.key()] = true
+		s.Findings = append(s.Findings, f)
+		s.BySeverity[f.Severity]++
+	}
+	s.FindingCount = len(s.Findings)
+	sort.Slice(s.Findings, func(i, j int) bool { return s.Findings[i].key() < s.Findings[j].key() })
+	return s
+}
+
+// WriteSummary add the summary to the directory, created if needed, as a new file named by the time of the scan.
+// It returns the path of the file.
+func WriteSummary(dir string, s Summary) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	datab, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, ".summary-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(datab); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	// the random part of the temporary name keeps the files of two scans of the same second apart
+	fpath := filepath.Join(dir, s.Time.UTC().Format("20060102T150405")+"-"+strings.TrimPrefix(filepath.Base(f.Name()), ".summary-")+".json")
+	return fpath, os.Rename(f.Name(), fpath)
+}
+
+// LoadSummaries read the summaries of the directory, oldest first
+func LoadSummaries(dir string) ([]Summary, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	summaries := []Summary{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		datab, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		s := Summary{}
+		if err := json.Unmarshal(datab, &s); err != nil {
+			return nil, fmt.Errorf("invalid summary %s - %w", e.Name(), err)
+		}
+		summaries = append(summaries, s)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].Time.Before(summaries[j].Time) })
+	return summaries, nil
+}
+
+// TrendDay is a day of a TrendReport: the findings of the last scan of each path that day, and the findings new
+// and fixed in the scans of the day
+type TrendDay struct {
+	Date     string
+	Scans    int
+	Findings int
+	New      int
+	Fixed    int
+}
+
+// TrendFinding is a finding new or fixed in a TrendReport, Time is when
+type TrendFinding struct {
+	SummaryFinding
+	Root string
+	Time time.Time
+}
+
+// TrendReport is the evolution of the findings of the summaries: per day, and the findings new or fixed since
+type TrendReport struct {
+	Since time.Time
+	Days  []TrendDay
+	New   []TrendFinding // first found since Since and still in the last scan of their path
+	Fixed []TrendFinding // found before, and not in the scans of their path since they were fixed, after Since
+}
+
+// NewTrendReport compare each summary with the previous one of the same path. A finding is new the first time a
+// path has it and fixed when a scan of the path no longer has it.
+func NewTrendReport(summaries []Summary, since time.Time) TrendReport {
+	report := TrendReport{Since: since, Days: []TrendDay{}, New: []TrendFinding{}, Fixed: []TrendFinding{}}
+	type state struct {
+		current map[string]SummaryFinding
+		found   map[string]time.Time // when each finding was first found
+		fixed   map[string]TrendFinding
+	}
+	roots := map[string]*state{}
+	days := map[string]*TrendDay{}
+	lastFindings := map[string]map[string]int{} // the findings of the last scan of the day, by day and path
+	for _, s := range summaries {
+		st, ok := roots[s.Root]
+		if !ok {
+			st = &state{current: map[string]SummaryFinding{}, found: map[string]time.Time{}, fixed: map[string]TrendFinding{}}
+			roots[s.Root] = st
+		}
+		date := s.Time.Format("2006-01-02")
+		day, ok := days[date]
+		if !ok {
+			day = &TrendDay{Date: date}
+			days[date] = day
+			lastFindings[date] = map[string]int{}
+		}
+		day.Scans++
+		lastFindings[date][s.Root] = s.FindingCount
+		current := map[string]SummaryFinding{}
+		for _, f := range s.Findings {
+			current[f.key()] = f
+			if _, ok := st.current[f.key()]; !ok {
+				if _, ok := st.found[f.key()]; !ok {
+					st.found[f.key()] = s.Time
+				}
+				delete(st.fixed, f.key())
+				day.New++
+			}
+		}
+		for k, f := range st.current {
+			if _, ok := current[k]; !ok {
+				st.fixed[k] = TrendFinding{SummaryFinding: f, Root: s.Root, Time: s.Time}
+				day.Fixed++
+			}
+		}
+		st.current = current
+	}
+	for date, day := range days {
+		for _, n := range lastFindings[date] {
+			day.Findings += n
+		}
+		report.Days = append(report.Days, *day)
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Date < report.Days[j].Date })
+	for root, st := range roots {
+		for k, f := range st.current {
+			if !st.found[k].Before(since) {
+				report.New = append(report.New, TrendFinding{SummaryFinding: f, Root: root, Time: st.found[k]})
+			}
+		}
+		for _, f := range st.fixed {
+			if !f.Time.Before(since) {
+				report.Fixed = append(report.Fixed, f)
+			}
+		}
+	}
+	for _, list := range [][]TrendFinding{report.New, report.Fixed} {
+		sort.Slice(list, func(i, j int) bool {
+			if !list[i].Time.Equal(list[j].Time) {
+				return list[i].Time.Before(list[j].Time)
+			}
+			return list[i].key() < list[j].key()
+		})
+	}
+	return report
+}
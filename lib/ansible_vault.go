@@ -0,0 +1,314 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+	"gopkg.in/yaml.v3"
+)
+
+// VaultHeader starts the ansible vault encrypted files and the inline !vault values, eg. $ANSIBLE_VAULT;1.1;AES256
+const VaultHeader = "$ANSIBLE_VAULT;"
+
+// ErrNoVaultSecret is returned decrypting a vault without any vault secret
+var ErrNoVaultSecret = errors.New("no vault secret")
+
+// ErrVaultNoMatch is returned when none of the vault secrets decrypts a vault
+var ErrVaultNoMatch = errors.New("no vault secret decrypts it")
+
+// VaultSecret is a vault password with its vault id, the label of ansible --vault-id label@file. The id is
+// 'default' when not given.
+type VaultSecret struct {
+	ID       string
+	Password []byte
+}
+
+// IsVault tell if the text is ansible vault encrypted
+func IsVault(text string) bool {
+	return strings.HasPrefix(strings.TrimLeft(text, " \t\r\n"), VaultHeader)
+}
+
+// LoadVaultSecrets read the vault passwords like the ansible --vault-id option: each entry is 'label@file' or a
+// file. The password is the file content without the ending new lines; an executable file is run and its output is
+// the password.
+func LoadVaultSecrets(ids []string) ([]VaultSecret, error) {
+	secrets := []VaultSecret{}
+	for _, id := range ids {
+		label, fpath, found := strings.Cut(id, "@")
+		if !found {
+			label, fpath = "default", id
+		}
+		if fpath == "prompt" {
+			return nil, fmt.Errorf("vault id %s - prompting for the vault password is not supported", id)
+		}
+		st, err := os.Stat(fpath)
+		if err != nil {
+			return nil, fmt.Errorf("can not read the vault password file %s - %w", fpath, err)
+		}
+		var datab []byte
+		if st.Mode().IsRegular() && st.Mode().Perm()&0o111 != 0 {
+			if datab, err = exec.Command(fpath, "--vault-id", label).Output(); err != nil {
+				return nil, fmt.Errorf("vault password script %s failed - %w", fpath, err)
+			}
+		} else if datab, err = os.ReadFile(fpath); err != nil {
+			return nil, fmt.Errorf("can not read the vault password file %s - %w", fpath, err)
+		}
+		if datab = bytes.TrimRight(datab, "\r\n"); len(datab) == 0 {
+			return nil, fmt.Errorf("empty vault password file %s", fpath)
+		}
+		secrets = append(secrets, VaultSecret{ID: label, Password: datab})
+	}
+	return secrets, nil
+}
+
+var (
+	vaultSecretsLock sync.Mutex
+	vaultSecrets     []VaultSecret
+	vaultSecretsSet  bool
+)
+
+// SetVaultSecrets set the secrets used to decrypt the vaults read by IncludeVars, IncludeVarsWithOpt and
+// ParseExtraVars
+func SetVaultSecrets(secrets ...VaultSecret) {
+	vaultSecretsLock.Lock()
+	defer vaultSecretsLock.Unlock()
+	vaultSecrets, vaultSecretsSet = secrets, true
+}
+
+// DefaultVaultSecrets return the secrets of SetVaultSecrets, else the ones of the ansible env vars
+// ANSIBLE_VAULT_IDENTITY_LIST (comma separated label@file) and ANSIBLE_VAULT_PASSWORD_FILE
+func DefaultVaultSecrets() ([]VaultSecret, error) {
+	vaultSecretsLock.Lock()
+	defer vaultSecretsLock.Unlock()
+	if vaultSecretsSet {
+		return vaultSecrets, nil
+	}
+	ids := []string{}
+	for _, id := range strings.Split(os.Getenv("ANSIBLE_VAULT_IDENTITY_LIST"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if f := os.Getenv("ANSIBLE_VAULT_PASSWORD_FILE"); f != "" {
+		ids = append(ids, f)
+	}
+	return LoadVaultSecrets(ids)
+}
+
+// vaultKeys derive the AES key, the HMAC key and the counter IV of a vault from the password and salt
+func vaultKeys(password, salt []byte) (aesKey, hmacKey, iv []byte) {
+	key := pbkdf2.Key(password, salt, 10000, 80, sha256.New)
+	return key[:32], key[32:64], key[64:80]
+}
+
+// VaultEncrypt encrypt the text like ansible-vault encrypt, with AES256. The vault is format 1.2 with the secret ID
+// in the header, or 1.1 if the ID is empty or 'default'.
+func VaultEncrypt(plain string, secret VaultSecret) (string, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	aesKey, hmacKey, iv := vaultKeys(secret.Password, salt)
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append([]byte(plain), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCTR(block, iv).XORKeyStream(ciphertext, padded)
+	h := hmac.New(sha256.New, hmacKey)
+	h.Write(ciphertext)
+	body := hex.EncodeToString([]byte(hex.EncodeToString(salt) + "\n" + hex.EncodeToString(h.Sum(nil)) + "\n" + hex.EncodeToString(ciphertext)))
+
+	var o strings.Builder
+	if secret.ID == "" || secret.ID == "default" {
+		o.WriteString(VaultHeader + "1.1;AES256\n")
+	} else {
+		o.WriteString(VaultHeader + "1.2;AES256;" + secret.ID + "\n")
+	}
+	for len(body) > 80 {
+		o.WriteString(body[:80] + "\n")
+		body = body[80:]
+	}
+	o.WriteString(body + "\n")
+	return o.String(), nil
+}
+
+// VaultDecrypt return the plain text of an ansible vault, 1.1 or 1.2 with AES256. The secrets with the vault ID of
+// the header are tried first, then the others.
+func VaultDecrypt(vaultText string, secrets ...VaultSecret) (string, error) {
+	if len(secrets) == 0 {
+		return "", ErrNoVaultSecret
+	}
+	header, body, _ := strings.Cut(strings.TrimLeft(vaultText, " \t\r\n"), "\n")
+	fields := strings.Split(strings.TrimSpace(header), ";")
+	if len(fields) < 3 || fields[0] != strings.TrimSuffix(VaultHeader, ";") || strings.ToUpper(strings.TrimSpace(fields[2])) != "AES256" {
+		return "", fmt.Errorf("unsupported vault header '%s'", header)
+	}
+	envelope, err := hex.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return "", fmt.Errorf("invalid vault - %w", err)
+	}
+	parts := strings.Split(string(envelope), "\n")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("invalid vault, expect salt, hmac and cipher text")
+	}
+	salt, err1 := hex.DecodeString(parts[0])
+	mac, err2 := hex.DecodeString(parts[1])
+	ciphertext, err3 := hex.DecodeString(parts[2])
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return "", fmt.Errorf("invalid vault - %w", err)
+	}
+	ordered := secrets
+	if len(fields) > 3 {
+		ordered = []VaultSecret{}
+		for _, s := range secrets {
+			if s.ID == fields[3] {
+				ordered = append(ordered, s)
+			}
+		}
+		for _, s := range secrets {
+			if s.ID != fields[3] {
+				ordered = append(ordered, s)
+			}
+		}
+	}
+	for _, secret := range ordered {
+		aesKey, hmacKey, iv := vaultKeys(secret.Password, salt)
+		h := hmac.New(sha256.New, hmacKey)
+		h.Write(ciphertext)
+		if !hmac.Equal(h.Sum(nil), mac) {
+			continue
+		}
+		block, err := aes.NewCipher(aesKey)
+		if err != nil {
+			return "", err
+		}
+		plain := make([]byte, len(ciphertext))
+		cipher.NewCTR(block, iv).XORKeyStream(plain, ciphertext)
+		if n := len(plain); n > 0 && int(plain[n-1]) <= aes.BlockSize && int(plain[n-1]) <= n {
+			plain = plain[:n-int(plain[n-1])] // pkcs7 padding
+		}
+		return string(plain), nil
+	}
+	return "", ErrVaultNoMatch
+}
+
+// VaultEncryptString return a yaml '!vault' value of the text for the key name, like ansible-vault encrypt_string
+// --name. It can be pasted in a vars file, eg group_vars/all.yml.
+func VaultEncryptString(name, plain string, secret VaultSecret) (string, error) {
+	vaultText, err := VaultEncrypt(plain, secret)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(vaultText, "\n"), "\n")
+	return name + ": !vault |\n          " + strings.Join(lines, "\n          ") + "\n", nil
+}
+
+// VaultEncryptFile encrypt the file in place like ansible-vault encrypt. A file already encrypted is an error.
+func VaultEncryptFile(fpath string, secret VaultSecret) error {
+	st, err := os.Stat(fpath)
+	if err != nil {
+		return err
+	}
+	datab, err := os.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	if IsVault(string(datab)) {
+		return fmt.Errorf("%s is already encrypted", fpath)
+	}
+	vaultText, err := VaultEncrypt(string(datab), secret)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fpath, []byte(vaultText), st.Mode().Perm())
+}
+
+// VaultDecryptFile decrypt the file in place like ansible-vault decrypt
+func VaultDecryptFile(fpath string, secrets ...VaultSecret) error {
+	st, err := os.Stat(fpath)
+	if err != nil {
+		return err
+	}
+	datab, err := os.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	plain, err := VaultDecrypt(string(datab), secrets...)
+	if err != nil {
+		return fmt.Errorf("%s - %w", fpath, err)
+	}
+	return os.WriteFile(fpath, []byte(plain), st.Mode().Perm())
+}
+
+// unmarshalVars decode a vars file decrypting the vaults: the whole file, or the '!vault' values. The secrets
+// default to DefaultVaultSecrets. The decrypted values of MinSecretLength or more are registered as secrets so they are
+// masked in the logs.
+func unmarshalVars(datab []byte, secrets []VaultSecret) (map[string]any, error) {
+	getSecrets := func() ([]VaultSecret, error) {
+		if len(secrets) > 0 {
+			return secrets, nil
+		}
+		return DefaultVaultSecrets()
+	}
+	if IsVault(string(datab)) {
+		s, err := getSecrets()
+		if err != nil {
+			return nil, err
+		}
+		plain, err := VaultDecrypt(string(datab), s...)
+		if err != nil {
+			return nil, err
+		}
+		datab = []byte(plain)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(datab, &doc); err != nil {
+		return nil, err
+	}
+	var decryptNode func(n *yaml.Node) error
+	decryptNode = func(n *yaml.Node) error {
+		if n.Kind == yaml.ScalarNode && n.Tag == "!vault" {
+			s, err := getSecrets()
+			if err != nil {
+				return err
+			}
+			plain, err := VaultDecrypt(n.Value, s...)
+			if err != nil {
+				return fmt.Errorf("line %d - %w", n.Line, err)
+			}
+			if len(plain) >= MinSecretLength {
+				RegisterSecret(plain)
+			}
+			n.Tag, n.Value, n.Style = "!!str", plain, 0
+		}
+		for _, c := range n.Content {
+			if err := decryptNode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := decryptNode(&doc); err != nil {
+		return nil, err
+	}
+	m := map[string]any{}
+	if len(doc.Content) == 0 {
+		return m, nil
+	}
+	return m, doc.Decode(&m)
+}
@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnsibleVault(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "dev-pass.txt"), []byte("dev-s3cret\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "prod-pass.sh"), []byte("#!/bin/sh\necho prod-s3cret\n"), 0o700)
+	secrets, err := LoadVaultSecrets([]string{filepath.Join(dir, "dev-pass.txt"), "prod@" + filepath.Join(dir, "prod-pass.sh")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []VaultSecret{{ID: "default", Password: []byte("dev-s3cret")}, {ID: "prod", Password: []byte("prod-s3cret")}}
+	if !reflect.DeepEqual(secrets, expected) {
+		t.Errorf("LoadVaultSecrets = %v; expected %v", secrets, expected)
+	}
+	dev, prod := secrets[0], secrets[1]
+
+	vaultText, err := VaultEncrypt("line1\nline2\n", prod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(vaultText, "$ANSIBLE_VAULT;1.2;AES256;prod\n") || !IsVault(vaultText) {
+		t.Errorf("unexpected vault header: %s", vaultText)
+	}
+	if plain, err := VaultDecrypt(vaultText, dev, prod); err != nil || plain != "line1\nline2\n" {
+		t.Errorf("VaultDecrypt = %q, %v", plain, err)
+	}
+	if _, err := VaultDecrypt(vaultText, dev); !errors.Is(err, ErrVaultNoMatch) {
+		t.Errorf("expect no match with the wrong password, got %v", err)
+	}
+	if _, err := VaultDecrypt(vaultText); !errors.Is(err, ErrNoVaultSecret) {
+		t.Errorf("expect an error without secret, got %v", err)
+	}
+
+	fpath := filepath.Join(dir, "secrets.yml")
+	os.WriteFile(fpath, []byte("db_password: Zx9cVb7nMq2wE\n"), 0o640)
+	if err := VaultEncryptFile(fpath, dev); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(fpath); !strings.HasPrefix(string(data), "$ANSIBLE_VAULT;1.1;AES256\n") {
+		t.Errorf("expect the file encrypted, got %s", data)
+	}
+	if err := VaultEncryptFile(fpath, dev); err == nil {
+		t.Error("expect error encrypting a vault again")
+	}
+	inline, err := VaultEncryptString("api_token", "Qw8eRt5yUi3oP", prod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	short, err := VaultEncryptString("api_tls", "yes", prod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "all.yml"), []byte("api_user: deploy\n"+inline+short+"api_port: 443\n"), 0o640)
+
+	vars, err := IncludeVarsWithOpt(IncludeVarsOpt{Dir: dir, VaultSecrets: secrets})
+	expectedVars := map[string]any{"db_password": "Zx9cVb7nMq2wE", "api_user": "deploy", "api_token": "Qw8eRt5yUi3oP", "api_tls": "yes", "api_port": 443}
+	if err != nil || !reflect.DeepEqual(vars, expectedVars) {
+		t.Errorf("IncludeVarsWithOpt = %v, %v; expected %v", vars, err, expectedVars)
+	}
+	if masked := MaskCredential("token Qw8eRt5yUi3oP"); masked != "token *****" {
+		t.Errorf("the decrypted value must be masked, got '%s'", masked)
+	}
+	if masked := MaskCredential("tls: yes"); masked != "tls: yes" {
+		t.Errorf("the short decrypted value must not be masked, got '%s'", masked)
+	}
+	if _, err := IncludeVarsWithOpt(IncludeVarsOpt{File: filepath.Join(dir, "all.yml"), VaultSecrets: []VaultSecret{dev}}); err == nil {
+		t.Error("expect error when no secret decrypts a value")
+	}
+
+	t.Cleanup(func() { vaultSecretsSet, vaultSecrets = false, nil })
+	SetVaultSecrets(prod)
+	if vars := IncludeVars(filepath.Join(dir, "all.yml")); vars["api_token"] != "Qw8eRt5yUi3oP" {
+		t.Errorf("expect IncludeVars to decrypt with the default secrets, got %v", vars)
+	}
+	if err := VaultDecryptFile(fpath, dev); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(fpath); string(data) != "db_password: Zx9cVb7nMq2wE\n" {
+		t.Errorf("expect the file decrypted, got %s", data)
+	}
+}
+
+// TestAnsibleVaultInterop check the vaults of ansible-vault decrypt here and the other way round
+func TestAnsibleVaultInterop(t *testing.T) {
+	if _, err := exec.LookPath("ansible-vault"); err != nil {
+		t.Skip("ansible-vault not found")
+	}
+	dir := t.TempDir()
+	passFile := filepath.Join(dir, "pass.txt")
+	os.WriteFile(passFile, []byte("interop-s3cret\n"), 0o600)
+	secrets, err := LoadVaultSecrets([]string{passFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("ansible-vault", "encrypt_string", "--vault-password-file", passFile, "--name", "api_token", "Qw8eRt5yUi3oP").Output()
+	if err != nil {
+		t.Fatalf("ansible-vault encrypt_string: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "all.yml"), out, 0o640)
+	if vars, err := IncludeVarsWithOpt(IncludeVarsOpt{File: filepath.Join(dir, "all.yml"), VaultSecrets: secrets}); err != nil || vars["api_token"] != "Qw8eRt5yUi3oP" {
+		t.Errorf("expect the value of ansible-vault decrypted, got %v, %v", vars, err)
+	}
+
+	fpath := filepath.Join(dir, "secrets.yml")
+	os.WriteFile(fpath, []byte("db_password: Zx9cVb7nMq2wE\n"), 0o640)
+	if err := VaultEncryptFile(fpath, secrets[0]); err != nil {
+		t.Fatal(err)
+	}
+	out, err = exec.Command("ansible-vault", "view", "--vault-password-file", passFile, fpath).Output()
+	if err != nil || string(out) != "db_password: Zx9cVb7nMq2wE\n" {
+		t.Errorf("expect ansible-vault to decrypt the file, got %q, %v", out, err)
+	}
+}
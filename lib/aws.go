@@ -0,0 +1,227 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// AwsCredentials to sign the aws api requests
+type AwsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AwsConfig is the region and the credentials used to call aws. Endpoint overrides the service endpoint, eg to use
+// localstack.
+type AwsConfig struct {
+	Region      string
+	Credentials AwsCredentials
+	Endpoint    string
+	client      *http.Client
+}
+
+// LoadAwsConfig resolve the region and the credentials like the aws cli: the AWS_* env first, then the profile
+// (AWS_PROFILE or 'default') of ~/.aws/credentials and ~/.aws/config. Empty profile and region use the env.
+func LoadAwsConfig(profile, region string) (*AwsConfig, error) {
+	cfg := &AwsConfig{
+		Region:   firstNonEmpty(region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")),
+		Endpoint: os.Getenv("AWS_ENDPOINT_URL"),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+	if profile == "" && os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		cfg.Credentials = AwsCredentials{os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}
+	} else {
+		profile = firstNonEmpty(profile, os.Getenv("AWS_PROFILE"), "default")
+		home, _ := os.UserHomeDir()
+		credFile := firstNonEmpty(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), filepath.Join(home, ".aws", "credentials"))
+		creds, err := ini.Load(credFile)
+		if err != nil {
+			return nil, fmt.Errorf("no aws credentials in the env and can not read %s - %w", credFile, err)
+		}
+		sec, err := creds.GetSection(profile)
+		if err != nil {
+			return nil, fmt.Errorf("aws profile %s not found in %s", profile, credFile)
+		}
+		cfg.Credentials = AwsCredentials{sec.Key("aws_access_key_id").String(), sec.Key("aws_secret_access_key").String(), sec.Key("aws_session_token").String()}
+		if cfg.Region == "" {
+			configFile := firstNonEmpty(os.Getenv("AWS_CONFIG_FILE"), filepath.Join(home, ".aws", "config"))
+			if conf, err := ini.Load(configFile); err == nil {
+				secName := "profile " + profile
+				if profile == "default" {
+					secName = "default"
+				}
+				cfg.Region = conf.Section(secName).Key("region").String()
+			}
+		}
+	}
+	if cfg.Credentials.AccessKeyID == "" || cfg.Credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("aws credentials not found")
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("aws region not set, use the region option or AWS_REGION")
+	}
+	RegisterSecret(cfg.Credentials.SecretAccessKey)
+	RegisterSecret(cfg.Credentials.SessionToken)
+	return cfg, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// awsURIEncode encode like aws wants: everything but unreserved characters, space as %20
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// SignAwsRequestV4 sign the request with aws signature version 4. body is the request payload (nil for none).
+// Every header already set on the request is signed together with host and x-amz-date.
+func SignAwsRequestV4(req *http.Request, body []byte, creds AwsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	payloadHash := sha256Hex(body)
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + strings.Join(strings.Fields(headers[k]), " ") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	queryParts := []string{}
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			queryParts = append(queryParts, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	canonPath := awsURIEncode(path, false)
+	if service != "s3" { // all services but s3 encode the path twice
+		canonPath = awsURIEncode(canonPath, false)
+	}
+
+	canonRequest := strings.Join([]string{req.Method, canonPath, strings.Join(queryParts, "&"), canonHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonRequest))
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// endpointURL return the service endpoint, https://<service>.<region>.amazonaws.com unless overridden
+func (c *AwsConfig) endpointURL(service string) string {
+	if c.Endpoint != "" {
+		return strings.TrimSuffix(c.Endpoint, "/")
+	}
+	return fmt.Sprintf("https://%s.%s.amazonaws.com", service, c.Region)
+}
+
+// CallJsonApi call an aws api using the json 1.1 protocol (secretsmanager, ssm...), eg
+// CallJsonApi("ssm", "AmazonSSM.GetParameter", map[string]any{"Name": "/app/db"})
+func (c *AwsConfig) CallJsonApi(service, target string, input any) (map[string]any, error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", c.endpointURL(service)+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	SignAwsRequestV4(req, body, c.Credentials, c.Region, service, time.Now())
+	client := c.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{}
+	if len(respb) > 0 {
+		if err := json.Unmarshal(respb, &out); err != nil {
+			return nil, fmt.Errorf("%s: invalid response %s", target, strings.TrimSpace(string(respb)))
+		}
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s: %s %v %v", target, resp.Status, out["__type"], firstNonNil(out["message"], out["Message"]))
+	}
+	return out, nil
+}
+
+func firstNonNil(values ...any) any {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+	return ""
+}
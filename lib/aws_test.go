@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// get-vanilla of the aws signature v4 test suite
+func TestSignAwsRequestV4(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+	SignAwsRequestV4(req, nil, AwsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}, "us-east-1", "service", now)
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Errorf("unexpected Authorization\n got: %s\nwant: %s", got, expected)
+	}
+}
+
+func TestLookupAwsSsm(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDTEST/") || r.Header.Get("X-Amz-Target") != "AmazonSSM.GetParameter" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var input map[string]any
+		json.NewDecoder(r.Body).Decode(&input)
+		json.NewEncoder(w).Encode(map[string]any{"Parameter": map[string]any{"Name": input["Name"], "Type": "SecureString", "Value": "Rt5pWq8zKm"}})
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secretkey")
+	t.Setenv("AWS_REGION", "ap-southeast-2")
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+
+	out := TemplateString("{{ lookup('aws_ssm', '/app/db/password') }}", map[string]any{})
+	if out != "Rt5pWq8zKm" {
+		t.Errorf("unexpected output '%s'", out)
+	}
+	if MaskCredential("Rt5pWq8zKm") != "*****" {
+		t.Error("SecureString value must be masked")
+	}
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserDataMaxSize is the user-data size limit of EC2 (16KB), most other clouds accept at least that
+const UserDataMaxSize = 16384
+
+// userDataHeaders are the first lines cloud-init recognises, see https://cloudinit.readthedocs.io/en/latest/explanation/format.html
+var userDataHeaders = []string{"#cloud-config", "#!", "#include", "#cloud-boothook", "#part-handler", "#cloud-config-archive", "## template: jinja", "Content-Type: multipart/"}
+
+// ValidateUserData check the rendered user-data is something cloud-init will run: it must start with one of the
+// known headers and a #cloud-config must be a yaml mapping. maxSize is the size limit in bytes, 0 for no limit.
+func ValidateUserData(data []byte, maxSize int) error {
+	if maxSize > 0 && len(data) > maxSize {
+		return fmt.Errorf("user-data is %d bytes, over the limit of %d", len(data), maxSize)
+	}
+	firstLine, _, _ := strings.Cut(string(data), "\n")
+	firstLine = strings.TrimRight(firstLine, "\r ")
+	known := false
+	for _, h := range userDataHeaders {
+		if strings.HasPrefix(firstLine, h) {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("user-data starts with '%s', expect a cloud-init header like #cloud-config or #!/bin/sh", firstLine)
+	}
+	if firstLine != "#cloud-config" {
+		return nil
+	}
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid #cloud-config yaml - %w", err)
+	}
+	if _, ok := doc.(map[string]any); !ok && doc != nil {
+		return fmt.Errorf("#cloud-config must be a yaml mapping, got %T", doc)
+	}
+	return nil
+}
+
+// GzipUserData compress the user-data, cloud-init detects and decompresses it. Use it to fit a bigger config in
+// the size limit.
+func GzipUserData(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderUserData(t *testing.T) {
+	tmpl := "#cloud-config\nhostname: {{ hostname }}\npackages:\n{% for p in packages %}  - {{ p }}\n{% endfor %}"
+	out, err := TemplateStringWithOpt(tmpl, map[string]any{"hostname": "web1", "packages": []string{"nginx", "git"}}, TemplateOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "hostname: web1") || !strings.Contains(out, "  - git") {
+		t.Errorf("unexpected user-data: %s", out)
+	}
+	if err := ValidateUserData([]byte(out), UserDataMaxSize); err != nil {
+		t.Error(err)
+	}
+	if _, err := TemplateStringWithOpt("{{ a | no_such_filter }}", map[string]any{"a": 1}, TemplateOpt{}); err == nil {
+		t.Error("expected error for an invalid template")
+	}
+}
+
+func TestValidateUserData(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		max  int
+		ok   bool
+	}{
+		{"#!/bin/sh\necho hi\n", 0, true},
+		{"Content-Type: multipart/mixed; boundary=\"x\"\n", 0, true},
+		{"#cloud-config\nusers: [\n", 0, false},
+		{"#cloud-config\n- a\n- b\n", 0, false},
+		{"hostname: web1\n", 0, false},
+		{"#cloud-config\nhostname: web1\n", 10, false},
+	} {
+		if err := ValidateUserData([]byte(tc.data), tc.max); (err == nil) != tc.ok {
+			t.Errorf("ValidateUserData(%q, %d) = %v", tc.data, tc.max, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+)
+
+// ExprError is returned when an expression can not be parsed or evaluated. It keeps the failing expression so
+// callers can show which of the when/until/assert conditions broke.
+type ExprError struct {
+	Expr string
+	Err  error
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("error while evaluating expression '%s': %s", e.Expr, e.Err)
+}
+
+func (e *ExprError) Unwrap() error {
+	return e.Err
+}
+
+// Expressions are raw jinja2 as in ansible conditionals; surrounding {{ }} are tolerated and removed
+func cleanExpr(expr string) string {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "{{") && strings.HasSuffix(expr, "}}") {
+		expr = strings.TrimSpace(expr[2 : len(expr)-2])
+	}
+	return expr
+}
+
+var exprOperators = []string{"==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "~"}
+
+var exprKeywords = sliceToSet([]string{"and", "or", "not", "in", "is", "if", "else"})
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// parenthesizeFilters wrap every operand having a filter in parenthesis, eg. 'users | length == 2' becomes
+// '(users | length) == 2'. Filters bind tighter than any operator in jinja2 but the gonja parser fails on a comparison
+// right after a filter.
+func parenthesizeFilters(expr string) string {
+	var out, segment strings.Builder
+	depth, hasFilter := 0, false
+	var quote byte
+	flush := func() {
+		seg := segment.String()
+		trimmed := strings.TrimSpace(seg)
+		if hasFilter && trimmed != "" {
+			lead := seg[:strings.Index(seg, trimmed)]
+			out.WriteString(lead + "(" + trimmed + ")" + seg[len(lead)+len(trimmed):])
+		} else {
+			out.WriteString(seg)
+		}
+		segment.Reset()
+		hasFilter = false
+	}
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		if quote != 0 {
+			segment.WriteByte(c)
+			if c == '\\' && i+1 < len(expr) {
+				i++
+				segment.WriteByte(expr[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '|':
+			if depth == 0 {
+				hasFilter = true
+			}
+		}
+		if depth == 0 && quote == 0 {
+			if op := matchExprOperator(expr, i); op != "" {
+				flush()
+				out.WriteString(op)
+				i += len(op) - 1
+				continue
+			}
+		}
+		segment.WriteByte(c)
+	}
+	flush()
+	return out.String()
+}
+
+// matchExprOperator return the operator or keyword starting at position i, or empty string
+func matchExprOperator(expr string, i int) string {
+	for _, op := range exprOperators {
+		if strings.HasPrefix(expr[i:], op) {
+			return op
+		}
+	}
+	if i > 0 && isIdentChar(expr[i-1]) {
+		return ""
+	}
+	j := i
+	for j < len(expr) && isIdentChar(expr[j]) {
+		j++
+	}
+	if _, ok := exprKeywords[expr[i:j]]; ok {
+		return expr[i:j]
+	}
+	return ""
+}
+
+func renderExpr(expr, tmplSrc string, vars map[string]any) (string, error) {
+	tmpl, err := TemplateFromStringWithConfig(tmplSrc, &CustomConfig)
+	if err != nil {
+		return "", &ExprError{Expr: expr, Err: err}
+	}
+	out, err := tmpl.ExecuteToString(exec.NewContext(vars))
+	if err != nil {
+		return "", &ExprError{Expr: expr, Err: err}
+	}
+	return out, nil
+}
+
+// EvalBool evaluate a jinja2 expression as a boolean using the jinja2 truth rules, eg.
+// "result.rc != 0 and 'ERROR' in result.stderr", "item is defined", "users | length > 2".
+// This is what when, until, changed_when, failed_when and assert use.
+func EvalBool(expr string, vars map[string]any) (bool, error) {
+	expr = cleanExpr(expr)
+	if expr == "" {
+		return false, &ExprError{Expr: expr, Err: fmt.Errorf("empty expression")}
+	}
+	out, err := renderExpr(expr, "{% if "+parenthesizeFilters(expr)+" %}True{% else %}False{% endif %}", vars)
+	return out == "True", err
+}
+
+// EvalConditions return true if all expressions are true, the same as a list of when conditions. It stops at the
+// first false or failing expression.
+func EvalConditions(conditions []string, vars map[string]any) (bool, error) {
+	for _, cond := range conditions {
+		ok, err := EvalBool(cond, vars)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// EvalExpr evaluate a jinja2 expression and return its value as go types (string, int, float64, bool, nil,
+// []any and map[string]any), eg "users | map(attribute='name') | list" or "config['ports'][0]".
+func EvalExpr(expr string, vars map[string]any) (any, error) {
+	expr = cleanExpr(expr)
+	out, err := renderExpr(expr, "{{ ("+parenthesizeFilters(expr)+") | to_json }}", vars)
+	if err != nil {
+		return nil, err
+	}
+	var val any
+	if err := json.Unmarshal([]byte(out), &val); err != nil {
+		return nil, &ExprError{Expr: expr, Err: fmt.Errorf("can not decode value '%s' - %w", out, err)}
+	}
+	return normalizeJsonNumbers(val), nil
+}
+
+// json decode all numbers as float64; convert back the whole numbers to int
+func normalizeJsonNumbers(val any) any {
+	switch v := val.(type) {
+	case float64:
+		if v == float64(int(v)) {
+			return int(v)
+		}
+	case []any:
+		for i := range v {
+			v[i] = normalizeJsonNumbers(v[i])
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = normalizeJsonNumbers(v[k])
+		}
+	}
+	return val
+}
+
+// Assert evaluate all 'that' expressions like the ansible assert module. The error names the first failing
+// expression; if failMsg is not empty it is used as the error message instead.
+func Assert(that []string, vars map[string]any, failMsg string) error {
+	for _, cond := range that {
+		ok, err := EvalBool(cond, vars)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if failMsg != "" {
+				return fmt.Errorf("%s", failMsg)
+			}
+			return fmt.Errorf("assertion failed: %s", cleanExpr(cond))
+		}
+	}
+	return nil
+}
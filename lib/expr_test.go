@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvalExpressions(t *testing.T) {
+	vars := map[string]any{
+		"result": map[string]any{"rc": 1, "stderr": "ERROR: boom"},
+		"users":  []any{map[string]any{"name": "alice"}, map[string]any{"name": "bob"}},
+		"config": map[string]any{"ports": []any{80, 443}},
+	}
+	boolCases := map[string]bool{
+		"result.rc != 0 and 'ERROR' in result.stderr": true,
+		"{{ result.rc == 0 }}":                        false,
+		"undefined_var is defined":                    false,
+		"users | length > 1":                          true,
+		"users | length == 2 and 2 == users | length": true,
+		"not (config.ports[1] == 443)":                false,
+	}
+	for expr, expected := range boolCases {
+		got, err := EvalBool(expr, vars)
+		if err != nil || got != expected {
+			t.Errorf("EvalBool(%s) = %v, %v; expected %v", expr, got, err, expected)
+		}
+	}
+	if ok, err := EvalConditions([]string{"result.rc == 1", "users | length == 3"}, vars); ok || err != nil {
+		t.Errorf("EvalConditions should be false: %v %v", ok, err)
+	}
+
+	val, err := EvalExpr("users | map(attribute='name') | list", vars)
+	if err != nil || !reflect.DeepEqual(val, []any{"alice", "bob"}) {
+		t.Errorf("EvalExpr list = %v, %v", val, err)
+	}
+	if val, err = EvalExpr("config['ports'][0] + 1", vars); err != nil || val != 81 {
+		t.Errorf("EvalExpr int = %v, %v", val, err)
+	}
+
+	_, err = EvalBool("result.rc ==", vars)
+	var exprErr *ExprError
+	if !errors.As(err, &exprErr) || exprErr.Expr != "result.rc ==" {
+		t.Errorf("expected an ExprError with the expression, got %v", err)
+	}
+	if err := Assert([]string{"result.rc == 1", "users | length == 5"}, vars, ""); err == nil || !strings.Contains(err.Error(), "users | length == 5") {
+		t.Errorf("Assert should name the failing expression, got %v", err)
+	}
+}
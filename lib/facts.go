@@ -0,0 +1,294 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+)
+
+// osFamilies map the os family to the distributions in it, same as the OS_FAMILY_MAP of ansible
+var osFamilies = map[string][]string{
+	"RedHat": {"RedHat", "Fedora", "CentOS", "Scientific", "SLC", "Ascendos", "CloudLinux", "PSBM", "OracleLinux", "OVS",
+		"OEL", "Amazon", "Virtuozzo", "XenServer", "Alibaba", "EulerOS", "openEuler", "AlmaLinux", "Rocky", "TencentOS",
+		"EuroLinux", "Kylin Linux Advanced Server"},
+	"Debian": {"Debian", "Ubuntu", "Raspbian", "Neon", "KDE neon", "Linux Mint", "SteamOS", "Devuan", "Kali",
+		"Cumulus Linux", "Pop!_OS", "Parrot", "Pardus GNU/Linux", "Uos", "Deepin", "OSMC"},
+	"Suse": {"SuSE", "SLES", "SLED", "openSUSE", "openSUSE Tumbleweed", "SLES_SAP", "SUSE_LINUX", "openSUSE Leap",
+		"ALP-Dolomite", "SL-Micro"},
+	"Archlinux":  {"Archlinux", "Antergos", "Manjaro"},
+	"Gentoo":     {"Gentoo", "Funtoo"},
+	"Alpine":     {"Alpine"},
+	"Darwin":     {"MacOSX"},
+	"FreeBSD":    {"FreeBSD", "HardenedBSD"},
+	"Mandrake":   {"Mandrake", "Mandriva", "Mageia"},
+	"Solaris":    {"Solaris", "Nexenta", "OmniOS", "OpenIndiana", "SmartOS"},
+	"Slackware":  {"Slackware"},
+	"Altlinux":   {"Altlinux"},
+	"ClearLinux": {"Clear Linux OS", "Clear Linux Mix"},
+	"Flatcar":    {"Flatcar"},
+	"Windows":    {"Microsoft Windows"},
+}
+
+// osReleaseIDs map the ID of /etc/os-release to the ansible_distribution name
+var osReleaseIDs = map[string]string{
+	"rhel": "RedHat", "fedora": "Fedora", "centos": "CentOS", "ol": "OracleLinux", "amzn": "Amazon", "almalinux": "AlmaLinux",
+	"rocky": "Rocky", "debian": "Debian", "ubuntu": "Ubuntu", "raspbian": "Raspbian", "linuxmint": "Linux Mint",
+	"devuan": "Devuan", "kali": "Kali", "pop": "Pop!_OS", "sles": "SLES", "sled": "SLED", "opensuse-leap": "openSUSE Leap",
+	"opensuse-tumbleweed": "openSUSE Tumbleweed", "arch": "Archlinux", "manjaro": "Manjaro", "gentoo": "Gentoo",
+	"alpine": "Alpine", "mageia": "Mageia", "slackware": "Slackware", "altlinux": "Altlinux", "clear-linux-os": "Clear Linux OS",
+	"flatcar": "Flatcar",
+}
+
+// OsFamily return the ansible_os_family of a distribution, eg Ubuntu => Debian. It is the distribution itself if
+// not known, like ansible does.
+func OsFamily(distribution string) string {
+	for family, dists := range osFamilies {
+		for _, d := range dists {
+			if strings.EqualFold(d, distribution) {
+				return family
+			}
+		}
+	}
+	return distribution
+}
+
+// PkgMgr return the ansible_pkg_mgr for the distribution, eg apt, dnf, yum, zypper, apk. majorVersion is the
+// ansible_distribution_major_version, it picks yum or dnf for the RedHat family.
+func PkgMgr(distribution, majorVersion string) string {
+	major, _ := strconv.Atoi(majorVersion)
+	switch distribution {
+	case "Fedora", "openEuler", "Kylin Linux Advanced Server":
+		return "dnf"
+	case "Amazon":
+		if major >= 2022 {
+			return "dnf"
+		}
+		return "yum"
+	case "OpenIndiana":
+		return "pkg5"
+	}
+	switch OsFamily(distribution) {
+	case "RedHat":
+		if major >= 8 {
+			return "dnf"
+		}
+		return "yum"
+	case "Debian":
+		return "apt"
+	case "Suse":
+		return "zypper"
+	case "Archlinux":
+		return "pacman"
+	case "Gentoo":
+		return "portage"
+	case "Alpine":
+		return "apk"
+	case "Darwin":
+		return "homebrew"
+	case "FreeBSD":
+		return "pkgng"
+	case "Mandrake":
+		return "urpmi"
+	case "Solaris":
+		return "pkg5"
+	case "Slackware":
+		return "slackpkg"
+	case "Altlinux":
+		return "apt_rpm"
+	case "ClearLinux":
+		return "swupd"
+	case "Windows":
+		return "win_package"
+	}
+	return "unknown"
+}
+
+// ServiceMgr guess the ansible_service_mgr from the distribution when the init process could not be inspected:
+// systemd except for the old releases and the distributions not using it.
+func ServiceMgr(distribution, majorVersion string) string {
+	major, _ := strconv.Atoi(majorVersion)
+	switch OsFamily(distribution) {
+	case "Darwin":
+		return "launchd"
+	case "FreeBSD":
+		return "bsdinit"
+	case "Alpine", "Gentoo":
+		return "openrc"
+	case "Solaris":
+		return "smf"
+	case "Slackware":
+		return "sysvinit"
+	case "Windows":
+		return "win_service"
+	case "RedHat":
+		if distribution != "Fedora" && distribution != "Amazon" && major > 0 && major < 7 {
+			if major == 6 {
+				return "upstart"
+			}
+			return "sysvinit"
+		}
+	case "Debian":
+		if distribution == "Ubuntu" && major > 0 && major < 15 {
+			return "upstart"
+		}
+		if distribution == "Debian" && major > 0 && major < 8 {
+			return "sysvinit"
+		}
+	}
+	return "systemd"
+}
+
+// factValue get a fact by its name with or without the ansible_ prefix, as in hostvars or in ansible_facts
+func factValue(facts map[string]any, name string) string {
+	for _, k := range []string{"ansible_" + name, name} {
+		if v, ok := facts[k]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+// DeriveFacts add os_family, pkg_mgr and service_mgr computed from distribution and distribution_major_version when
+// they are missing. The keys use the prefix of the facts: ansible_os_family in hostvars, os_family in an
+// ansible_facts map. The facts map is updated and returned.
+func DeriveFacts(facts map[string]any) map[string]any {
+	dist, major := factValue(facts, "distribution"), factValue(facts, "distribution_major_version")
+	if dist == "" {
+		return facts
+	}
+	prefix := ""
+	if _, ok := facts["ansible_distribution"]; ok {
+		prefix = "ansible_"
+	}
+	for name, derive := range map[string]func() string{
+		"os_family":   func() string { return OsFamily(dist) },
+		"pkg_mgr":     func() string { return PkgMgr(dist, major) },
+		"service_mgr": func() string { return ServiceMgr(dist, major) },
+	} {
+		if factValue(facts, name) == "" {
+			facts[prefix+name] = derive()
+		}
+	}
+	return facts
+}
+
+// deriveVarsFacts run DeriveFacts on the vars and on their ansible_facts map, so the templates get the derived
+// facts of the facts given as vars, eg. with --extra-vars @facts.json
+func deriveVarsFacts(vars map[string]any) map[string]any {
+	DeriveFacts(vars)
+	if facts, ok := vars["ansible_facts"].(map[string]any); ok {
+		DeriveFacts(facts)
+	}
+	return vars
+}
+
+// LocalFacts gather the distribution facts of this machine from /etc/os-release (linux) or runtime.GOOS, plus the
+// derived ones. The service manager is the name of the process 1 when readable.
+func LocalFacts() (map[string]any, error) {
+	facts := map[string]any{
+		"ansible_system":       strings.ToUpper(runtime.GOOS[:1]) + runtime.GOOS[1:],
+		"ansible_architecture": runtime.GOARCH,
+	}
+	switch runtime.GOOS {
+	case "linux":
+		osRelease, err := readOsRelease("/etc/os-release")
+		if err != nil {
+			if osRelease, err = readOsRelease("/usr/lib/os-release"); err != nil {
+				return nil, err
+			}
+		}
+		dist, ok := osReleaseIDs[osRelease["ID"]]
+		if !ok {
+			dist = firstNonEmpty(osRelease["NAME"], osRelease["ID"])
+		}
+		version := osRelease["VERSION_ID"]
+		facts["ansible_distribution"] = dist
+		facts["ansible_distribution_version"] = version
+		facts["ansible_distribution_major_version"], _, _ = strings.Cut(version, ".")
+		facts["ansible_distribution_release"] = osRelease["VERSION_CODENAME"]
+		if comm, err := os.ReadFile("/proc/1/comm"); err == nil {
+			switch name := strings.TrimSpace(string(comm)); name {
+			case "systemd", "openrc-init", "runit", "s6-svscan":
+				facts["ansible_service_mgr"] = strings.TrimSuffix(strings.TrimSuffix(name, "-init"), "-svscan")
+			case "init":
+				if _, err := os.Stat("/sbin/openrc"); err == nil {
+					facts["ansible_service_mgr"] = "openrc"
+				}
+			}
+		}
+	case "darwin":
+		facts["ansible_distribution"] = "MacOSX"
+	case "freebsd":
+		facts["ansible_distribution"] = "FreeBSD"
+	case "windows":
+		facts["ansible_distribution"] = "Microsoft Windows"
+		facts["ansible_system"] = "Win32NT"
+	default:
+		facts["ansible_distribution"] = facts["ansible_system"]
+	}
+	return DeriveFacts(facts), nil
+}
+
+// readOsRelease parse the KEY=value lines of an os-release file
+func readOsRelease(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	o := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.HasPrefix(k, "#") {
+			continue
+		}
+		o[k] = strings.Trim(v, `"'`)
+	}
+	return o, scanner.Err()
+}
+
+// osFamilyOfValue return the os family from a template value: a facts dict (hostvars[host] or ansible_facts), a
+// family name or a distribution name
+func osFamilyOfValue(in *exec.Value) string {
+	switch v := in.ToGoSimpleType(false).(type) {
+	case map[string]any:
+		if family := factValue(v, "os_family"); family != "" {
+			return family
+		}
+		return OsFamily(factValue(v, "distribution"))
+	case string:
+		for family := range osFamilies {
+			if strings.EqualFold(family, v) {
+				return family
+			}
+		}
+		return OsFamily(v)
+	}
+	return ""
+}
+
+// osFamilyTest build the '<family>_family' jinja2 test, eg {% if ansible_facts is debian_family %} or
+// {{ ansible_distribution is redhat_family }}
+func osFamilyTest(family string) exec.TestFunction {
+	return func(ctx *exec.Context, in *exec.Value, params *exec.VarArgs) (bool, error) {
+		if in.IsError() {
+			return false, fmt.Errorf("%s", in.Error())
+		}
+		return osFamilyOfValue(in) == family, nil
+	}
+}
+
+func registerOsFamilyTests(e *exec.Environment) {
+	for family := range osFamilies {
+		name := strings.ToLower(family) + "_family"
+		if !e.Tests.Exists(name) {
+			e.Tests.Register(name, osFamilyTest(family))
+		}
+	}
+}
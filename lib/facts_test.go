@@ -0,0 +1,44 @@
+package lib
+
+import "testing"
+
+func TestDeriveFacts(t *testing.T) {
+	for _, tc := range []struct {
+		dist, major, family, pkgMgr, serviceMgr string
+	}{
+		{"Ubuntu", "22", "Debian", "apt", "systemd"},
+		{"Ubuntu", "14", "Debian", "apt", "upstart"},
+		{"CentOS", "7", "RedHat", "yum", "systemd"},
+		{"Rocky", "9", "RedHat", "dnf", "systemd"},
+		{"Fedora", "40", "RedHat", "dnf", "systemd"},
+		{"Alpine", "3", "Alpine", "apk", "openrc"},
+		{"openSUSE Leap", "15", "Suse", "zypper", "systemd"},
+	} {
+		facts := DeriveFacts(map[string]any{"ansible_distribution": tc.dist, "ansible_distribution_major_version": tc.major})
+		if facts["ansible_os_family"] != tc.family || facts["ansible_pkg_mgr"] != tc.pkgMgr || facts["ansible_service_mgr"] != tc.serviceMgr {
+			t.Errorf("%s %s: unexpected facts %v", tc.dist, tc.major, facts)
+		}
+	}
+	facts := DeriveFacts(map[string]any{"distribution": "Debian", "pkg_mgr": "aptitude"})
+	if facts["pkg_mgr"] != "aptitude" || facts["os_family"] != "Debian" || facts["ansible_os_family"] != nil {
+		t.Errorf("gathered facts must not be overridden and keep their prefix: %v", facts)
+	}
+	vars, err := ParseExtraVars([]string{`{"ansible_facts": {"distribution": "Rocky", "distribution_major_version": "9"}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := TemplateString("{{ ansible_facts.pkg_mgr }} {{ ansible_facts.service_mgr }}", vars); out != "dnf systemd" {
+		t.Errorf("expect the facts of the extra vars derived, got '%s'", out)
+	}
+}
+
+func TestOsFamilyTests(t *testing.T) {
+	data := map[string]any{
+		"ansible_facts":        map[string]any{"distribution": "Ubuntu", "os_family": "Debian"},
+		"ansible_distribution": "Rocky",
+	}
+	out := TemplateString(`{{ ansible_facts is debian_family }} {{ ansible_distribution is redhat_family }} {{ 'Alpine' is debian_family }}`, data)
+	if out != "True True False" {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
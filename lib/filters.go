@@ -0,0 +1,427 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	json "github.com/json-iterator/go"
+	gexec "github.com/nikolalohinski/gonja/v2/exec"
+	"gopkg.in/yaml.v3"
+)
+
+// Omit is the value of the omit variable of the templates, eg. '{{ mode | default(omit) }}' like ansible. A module
+// argument rendered to it is removed, see RemoveOmitted.
+const Omit = "__omit_place_holder__"
+
+// ansibleFilters are the ansible core filters gonja does not have or has with another behaviour, eg. its unique fails
+// on a list of dicts. default and d are replaced too, see registerAnsibleFilters.
+var ansibleFilters = map[string]FilterFunc{
+	"to_nice_json":  filterToNiceJson,
+	"to_nice_yaml":  filterToNiceYaml,
+	"from_json":     filterFromJson,
+	"from_yaml":     filterFromYaml,
+	"combine":       filterCombine,
+	"dict2items":    filterDict2Items,
+	"items2dict":    filterItems2Dict,
+	"flatten":       filterFlatten,
+	"ternary":       filterTernary,
+	"unique":        filterUnique,
+	"regex_replace": filterRegexReplace,
+	"regex_search":  filterRegexSearch,
+}
+
+var ansibleFiltersOnce sync.Once
+
+// registerAnsibleFilters add the ansibleFilters and the omit variable to the environment
+func registerAnsibleFilters(e *gexec.Environment) {
+	ansibleFiltersOnce.Do(func() {
+		for name, fn := range ansibleFilters {
+			if e.Filters.Exists(name) {
+				e.Filters.Replace(name, goFilter(name, fn))
+			} else {
+				e.Filters.Register(name, goFilter(name, fn))
+			}
+		}
+		e.Filters.Replace("default", filterDefault)
+		e.Filters.Replace("d", filterDefault)
+		e.Context.Set("omit", Omit)
+	})
+}
+
+// goFilter adapt a FilterFunc to gonja, the dicts are passed as map[string]any
+func goFilter(name string, fn FilterFunc) gexec.FilterFunction {
+	return func(e *gexec.Evaluator, in *gexec.Value, params *gexec.VarArgs) *gexec.Value {
+		if in.IsError() {
+			return in
+		}
+		args, kwargs := varArgsToGo(params)
+		out, err := fn(in.ToGoSimpleType(false), args, kwargs)
+		if err != nil {
+			return gexec.AsValue(fmt.Errorf("filter '%s' - %w", name, err))
+		}
+		return gexec.AsValue(out)
+	}
+}
+
+// filterArg return the argument idx, or the keyword argument name, else def
+func filterArg(args []any, kwargs map[string]any, idx int, name string, def any) any {
+	if v, ok := kwargs[name]; ok {
+		return v
+	}
+	if idx < len(args) {
+		return args[idx]
+	}
+	return def
+}
+
+// truthy tell if a value is true for jinja2: not none, false, zero or empty
+func truthy(v any) bool {
+	if v == nil {
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() != 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() != 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() != 0
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() > 0
+	}
+	return true
+}
+
+// filterDefault is the jinja2 default filter, 'd' too: the value, or the default if it is undefined or with
+// boolean=true if it is false. gonja only takes a false boolean value as false.
+func filterDefault(e *gexec.Evaluator, in *gexec.Value, params *gexec.VarArgs) *gexec.Value {
+	p := params.Expect(1, []*gexec.KwArg{{Name: "boolean", Default: false}})
+	if p.IsError() {
+		return gexec.AsValue(fmt.Errorf("wrong signature for 'default' - %w", p))
+	}
+	if in.IsError() || in.IsNil() || (p.GetKeywordArgument("boolean", false).IsTrue() && !in.IsTrue()) {
+		return p.First()
+	}
+	return in
+}
+
+func filterToNiceJson(in any, args []any, kwargs map[string]any) (any, error) {
+	indent, ok := filterArg(args, kwargs, 0, "indent", 4).(int)
+	if !ok {
+		return nil, fmt.Errorf("indent must be an integer")
+	}
+	b, err := json.ConfigCompatibleWithStandardLibrary.MarshalIndent(in, "", strings.Repeat(" ", indent))
+	return string(b), err
+}
+
+func filterToNiceYaml(in any, args []any, kwargs map[string]any) (any, error) {
+	indent, ok := filterArg(args, kwargs, 0, "indent", 4).(int)
+	if !ok {
+		return nil, fmt.Errorf("indent must be an integer")
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(in); err != nil {
+		return nil, err
+	}
+	return buf.String(), enc.Close()
+}
+
+func filterFromJson(in any, args []any, kwargs map[string]any) (any, error) {
+	var o any
+	err := json.ConfigCompatibleWithStandardLibrary.Unmarshal([]byte(fmt.Sprint(in)), &o)
+	return o, err
+}
+
+func filterFromYaml(in any, args []any, kwargs map[string]any) (any, error) {
+	var o any
+	err := yaml.Unmarshal([]byte(fmt.Sprint(in)), &o)
+	return o, err
+}
+
+// filterCombine merge the dicts of the arguments over the input, the later win: combine(b, c, recursive=True,
+// list_merge='append'). list_merge of the recursive merges is replace (default), keep, append or prepend.
+func filterCombine(in any, args []any, kwargs map[string]any) (any, error) {
+	recursive := truthy(kwargs["recursive"])
+	listMerge, _ := filterArg(nil, kwargs, 0, "list_merge", "replace").(string)
+	switch listMerge {
+	case "replace", "keep", "append", "prepend":
+	default:
+		return nil, fmt.Errorf("list_merge must be replace, keep, append or prepend, got '%s'", listMerge)
+	}
+	var merge func(old, new any) any
+	merge = func(old, new any) any {
+		oldMap, ok1 := old.(map[string]any)
+		newMap, ok2 := new.(map[string]any)
+		if ok1 && ok2 {
+			o := make(map[string]any, len(oldMap)+len(newMap))
+			for k, v := range oldMap {
+				o[k] = v
+			}
+			for k, v := range newMap {
+				if cur, ok := o[k]; ok && recursive {
+					o[k] = merge(cur, v)
+				} else {
+					o[k] = v
+				}
+			}
+			return o
+		}
+		oldList, ok1 := old.([]any)
+		newList, ok2 := new.([]any)
+		if ok1 && ok2 {
+			switch listMerge {
+			case "keep":
+				return old
+			case "append":
+				return append(append([]any{}, oldList...), newList...)
+			case "prepend":
+				return append(append([]any{}, newList...), oldList...)
+			}
+		}
+		return new
+	}
+	dicts := append([]any{in}, args...)
+	if len(dicts) == 1 {
+		if list, ok := in.([]any); ok { // [a, b] | combine
+			dicts = list
+		}
+	}
+	o := map[string]any{}
+	for idx, d := range dicts {
+		m, ok := d.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expect dicts, got %T at %d", d, idx)
+		}
+		for k, v := range m {
+			if cur, ok := o[k]; ok && recursive {
+				o[k] = merge(cur, v)
+			} else {
+				o[k] = v
+			}
+		}
+	}
+	return o, nil
+}
+
+// filterDict2Items turn a dict into a list of {key: k, value: v} sorted by key; key_name and value_name rename them
+func filterDict2Items(in any, args []any, kwargs map[string]any) (any, error) {
+	m, ok := in.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expect a dict, got %T", in)
+	}
+	keyName := fmt.Sprint(filterArg(nil, kwargs, 0, "key_name", "key"))
+	valueName := fmt.Sprint(filterArg(nil, kwargs, 0, "value_name", "value"))
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	o := make([]any, 0, len(m))
+	for _, k := range keys {
+		o = append(o, map[string]any{keyName: k, valueName: m[k]})
+	}
+	return o, nil
+}
+
+// filterItems2Dict turn a list of {key: k, value: v} into a dict, the reverse of dict2items
+func filterItems2Dict(in any, args []any, kwargs map[string]any) (any, error) {
+	list, ok := in.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expect a list, got %T", in)
+	}
+	keyName := fmt.Sprint(filterArg(nil, kwargs, 0, "key_name", "key"))
+	valueName := fmt.Sprint(filterArg(nil, kwargs, 0, "value_name", "value"))
+	o := make(map[string]any, len(list))
+	for idx, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expect a list of dicts, got %T at %d", item, idx)
+		}
+		k, ok := m[keyName]
+		if !ok {
+			return nil, fmt.Errorf("item %d has no '%s'", idx, keyName)
+		}
+		o[fmt.Sprint(k)] = m[valueName]
+	}
+	return o, nil
+}
+
+// filterFlatten flatten the nested lists, to levels deep if given; the none values are dropped unless skip_nulls=False
+func filterFlatten(in any, args []any, kwargs map[string]any) (any, error) {
+	list, ok := in.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expect a list, got %T", in)
+	}
+	levels := -1
+	if v := filterArg(args, kwargs, 0, "levels", nil); v != nil {
+		if levels, ok = v.(int); !ok {
+			return nil, fmt.Errorf("levels must be an integer")
+		}
+	}
+	skipNulls := true
+	if v, ok := kwargs["skip_nulls"]; ok {
+		skipNulls = truthy(v)
+	}
+	var flatten func(list []any, depth int) []any
+	flatten = func(list []any, depth int) []any {
+		o := []any{}
+		for _, item := range list {
+			if sub, ok := item.([]any); ok && depth != 0 {
+				o = append(o, flatten(sub, depth-1)...)
+			} else if item != nil || !skipNulls {
+				o = append(o, item)
+			}
+		}
+		return o
+	}
+	return flatten(list, levels), nil
+}
+
+// filterTernary return the first argument if the input is true, else the second; the third one if given when the
+// input is none
+func filterTernary(in any, args []any, kwargs map[string]any) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("expect the true and false values")
+	}
+	if in == nil && len(args) > 2 {
+		return args[2], nil
+	}
+	if truthy(in) {
+		return args[0], nil
+	}
+	return args[1], nil
+}
+
+// filterUnique drop the duplicates of a list, keeping the first; unlike the gonja one the items may be dicts and
+// lists. The strings are compared ignoring the case unless case_sensitive=True, attribute compares the items by it.
+func filterUnique(in any, args []any, kwargs map[string]any) (any, error) {
+	list, ok := in.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expect a list, got %T", in)
+	}
+	caseSensitive := truthy(filterArg(nil, kwargs, 0, "case_sensitive", false))
+	attribute, _ := filterArg(nil, kwargs, 0, "attribute", "").(string)
+	seen := map[string]bool{}
+	o := []any{}
+	for idx, item := range list {
+		v := item
+		if attribute != "" {
+			m, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("item %d has no attribute '%s'", idx, attribute)
+			}
+			v = m[attribute]
+		}
+		if s, ok := v.(string); ok && !caseSensitive {
+			v = strings.ToLower(s)
+		}
+		b, err := json.ConfigCompatibleWithStandardLibrary.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if key := fmt.Sprintf("%T:%s", v, b); !seen[key] {
+			seen[key] = true
+			o = append(o, item)
+		}
+	}
+	return o, nil
+}
+
+// filterRegexReplace is the ansible regex_replace(pattern, replacement="", ignorecase=False, multiline=False,
+// count=0); the python \1 groups of the replacement are go $1 ones
+func filterRegexReplace(in any, args []any, kwargs map[string]any) (any, error) {
+	ptn, err := filterRegexp(args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	replacement := convertPerlCapPattern(fmt.Sprint(filterArg(args, kwargs, 1, "replacement", "")))
+	count, _ := filterArg(nil, kwargs, 0, "count", 0).(int)
+	if count <= 0 {
+		return ptn.ReplaceAllString(fmt.Sprint(in), replacement), nil
+	}
+	input, o, last := fmt.Sprint(in), "", 0
+	for _, loc := range ptn.FindAllStringSubmatchIndex(input, count) {
+		o += input[last:loc[0]] + string(ptn.ExpandString(nil, replacement, input, loc))
+		last = loc[1]
+	}
+	return o + input[last:], nil
+}
+
+// filterRegexSearch is the ansible regex_search(pattern, *groups, ignorecase=False, multiline=False): the match, or
+// the list of the groups given as '\\1' or '\\g<name>'; an empty string if it does not match. Unlike ansible, and
+// as this filter always did here, a pattern with groups and no group given returns the list of all its groups.
+func filterRegexSearch(in any, args []any, kwargs map[string]any) (any, error) {
+	ptn, err := filterRegexp(args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	input := fmt.Sprint(in)
+	match := ptn.FindStringSubmatch(input)
+	if match == nil {
+		return "", nil
+	}
+	if len(args) < 2 {
+		if len(match) > 1 {
+			return match[1:], nil
+		}
+		return match[0], nil
+	}
+	o := []any{}
+	for _, g := range args[1:] {
+		group := fmt.Sprint(g)
+		idx := -1
+		switch {
+		case strings.HasPrefix(group, `\g<`) && strings.HasSuffix(group, ">"):
+			idx = ptn.SubexpIndex(group[3 : len(group)-1])
+		case strings.HasPrefix(group, `\`):
+			fmt.Sscanf(group[1:], "%d", &idx)
+		}
+		if idx < 0 || idx >= len(match) {
+			return nil, fmt.Errorf("unknown group '%s'", group)
+		}
+		o = append(o, match[idx])
+	}
+	return o, nil
+}
+
+// filterRegexp compile the pattern of the regex filters, the first argument, with their ignorecase and multiline
+// flags
+func filterRegexp(args []any, kwargs map[string]any) (*regexp.Regexp, error) {
+	pattern, ok := filterArg(args, kwargs, 0, "pattern", nil).(string)
+	if !ok {
+		return nil, fmt.Errorf("expect a pattern")
+	}
+	flags := ""
+	if truthy(kwargs["ignorecase"]) {
+		flags += "i"
+	}
+	if truthy(kwargs["multiline"]) {
+		flags += "m"
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// RemoveOmitted return the map without the keys rendered to Omit, eg. the arguments of a module
+func RemoveOmitted(args map[string]any) map[string]any {
+	o := make(map[string]any, len(args))
+	for k, v := range args {
+		if s, ok := v.(string); ok && s == Omit {
+			continue
+		}
+		o[k] = v
+	}
+	return o
+}
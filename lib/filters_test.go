@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnsibleFilters(t *testing.T) {
+	vars := map[string]any{
+		"base":    map[string]any{"a": 1, "nested": map[string]any{"x": 1, "l": []any{1}}},
+		"over":    map[string]any{"b": 2, "nested": map[string]any{"y": 2, "l": []any{2}}},
+		"users":   []any{map[string]any{"name": "alice"}, map[string]any{"name": "bob"}, map[string]any{"name": "alice"}},
+		"nested":  []any{1, []any{2, []any{3, nil}}},
+		"json":    `{"a": [1, 2], "b": "x"}`,
+		"version": "release-1.2.3",
+		"empty":   "",
+		"text":    "Hello",
+	}
+	exprCases := map[string]any{
+		"base | combine(over)": map[string]any{"a": 1, "b": 2, "nested": map[string]any{"y": 2, "l": []any{2}}},
+		"base | combine(over, recursive=True, list_merge='append')": map[string]any{"a": 1, "b": 2,
+			"nested": map[string]any{"x": 1, "y": 2, "l": []any{1, 2}}},
+		"{'k1': 1, 'k2': 2} | dict2items":                                   []any{map[string]any{"key": "k1", "value": 1}, map[string]any{"key": "k2", "value": 2}},
+		"{'k1': 1} | dict2items(key_name='n', value_name='v')":              []any{map[string]any{"n": "k1", "v": 1}},
+		"[{'key': 'a', 'value': 1}, {'key': 'b', 'value': 2}] | items2dict": map[string]any{"a": 1, "b": 2},
+		"nested | flatten":                                         []any{1, 2, 3},
+		"nested | flatten(levels=1)":                               []any{1, 2, []any{3, nil}},
+		"users | unique | list":                                    []any{map[string]any{"name": "alice"}, map[string]any{"name": "bob"}},
+		"['a', 'A', 'b'] | unique(case_sensitive=True)":            []any{"a", "A", "b"},
+		"json | from_json":                                         map[string]any{"a": []any{1, 2}, "b": "x"},
+		"'a: 1' | from_yaml":                                       map[string]any{"a": 1},
+		"version | regex_search('(\\\\d+)\\\\.(\\\\d+)', '\\\\2')": []any{"2"},
+		"version | regex_search('(\\\\d+)\\\\.(\\\\d+)')":          []any{"1", "2"},
+		"version | regex_search('x(\\\\d+)')":                      "",
+	}
+	for expr, expected := range exprCases {
+		got, err := EvalExpr(expr, vars)
+		if err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("EvalExpr(%s) = %#v, %v; expected %#v", expr, got, err, expected)
+		}
+	}
+	strCases := map[string]string{
+		"{{ (1 == 1) | ternary('yes', 'no') }}":                        "yes",
+		"{{ empty | ternary('yes', 'no') }}":                           "no",
+		"{{ text | default('d', true) }}":                              "Hello",
+		"{{ empty | default('d', true) }}":                             "d",
+		"{{ undefined_var | default(omit) }}":                          Omit,
+		"{{ text | b64encode | b64decode }}":                           "Hello",
+		"{{ version | regex_replace('(\\\\d+)', 'v\\\\1', count=1) }}": "release-v1.2.3",
+		"{{ text | regex_replace('hello', 'bye', ignorecase=True) }}":  "bye",
+		"{{ version | regex_search('\\\\d+\\\\.\\\\d+') }}":            "1.2",
+		"{{ {'a': 1} | to_nice_json }}":                                "{\n    \"a\": 1\n}",
+		"{{ {'a': [1]} | to_nice_yaml(indent=2) }}":                    "a:\n  - 1\n",
+	}
+	for tpl, expected := range strCases {
+		if got := TemplateString(tpl, vars); got != expected {
+			t.Errorf("TemplateString(%s) = %q; expected %q", tpl, got, expected)
+		}
+	}
+	args := RemoveOmitted(map[string]any{"path": "/tmp/x", "mode": Omit})
+	if !reflect.DeepEqual(args, map[string]any{"path": "/tmp/x"}) {
+		t.Errorf("RemoveOmitted = %v", args)
+	}
+}
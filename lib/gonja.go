@@ -49,56 +49,6 @@ func convertPerlCapPattern(input string) string {
 	return result
 }
 
-var filterFuncRegexReplace exec.FilterFunction = func(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
-	if in.IsError() {
-		return in
-	}
-	// 2 args is mandate, option arg count default val is nil. Backslash \ need to escape like \\ so template compile works
-	// p := params.Expect(2, []*exec.KwArg{{Name: "count", Default: nil}})
-	// if p.IsError() {
-	// 	return exec.AsValue(errors.Wrap(p, "Wrong signature for 'regex_replace'"))
-	// }
-	pattern := params.Args[0].String()
-	new := params.Args[1].String()
-	new = convertPerlCapPattern(new)
-	// count := params.KwArgs["count"]
-
-	ptn := regexp.MustCompile(pattern)
-
-	// if count.IsNil() {
-	// 	return exec.AsValue(ptn.ReplaceAllString(in.String(), new))
-	// }
-
-	// counter := 0
-	// output := ptn.ReplaceAllStringFunc(in.String(), func(value string) string {
-	// 	if counter == count.Integer() {
-	// 		return value
-	// 	}
-	// 	counter++
-	// 	return ptn.ReplaceAllString(value, new)
-	// })
-	output := ptn.ReplaceAllString(in.String(), new)
-	return exec.AsValue(output)
-}
-
-var filterFuncRegexSearch exec.FilterFunction = func(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
-	if in.IsError() {
-		return in
-	}
-	// Simulate the ansible regex_search filter. Map to golang FindString and FindStringSubMatch
-	ptn := regexp.MustCompile(params.Args[0].String())
-	input := in.String()
-
-	out := ptn.FindStringSubmatch(input)
-	if len(out) == 1 { // return a match
-		return exec.AsValue(out[0])
-	} // If there is capture then return a list of captures (submatch). Ignoring all args
-	if len(out) > 1 {
-		return exec.AsValue(out[1:])
-	}
-	return exec.AsValue("")
-}
-
 var filterFuncToYaml exec.FilterFunction = func(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
 	if in.IsError() {
 		return in
@@ -115,10 +65,9 @@ var filterFuncToYaml exec.FilterFunction = func(e *exec.Evaluator, in *exec.Valu
 
 	if !indent.IsNil() {
 		encoder.SetIndent(indent.Integer())
-		fmt.Println("WARN indent not supported")
 	}
-	if err := encoder.Encode(in.ToGoSimpleType(true)); err != nil {
-		panic(fmt.Sprintf("Error encoding YAML: %s\n", err))
+	if err := encoder.Encode(in.ToGoSimpleType(false)); err != nil {
+		return exec.AsValue(errors.Wrap(err, "Unable to encode to yaml"))
 	}
 
 	return exec.AsValue(buf.String())
@@ -192,18 +141,15 @@ var filterFuncB64Decode exec.FilterFunction = func(e *exec.Evaluator, in *exec.V
 		return exec.AsValue(errors.Wrap(p, "Wrong signature for 'to_yaml'"))
 	}
 	// wrap is unsupported in golang, try to implement it later on
-	o := u.Must(b64.StdEncoding.DecodeString(in.String()))
+	o, err := b64.StdEncoding.DecodeString(in.String())
+	if err != nil {
+		return exec.AsValue(errors.Wrap(err, "Unable to decode base64"))
+	}
 	return exec.AsValue(string(o))
 }
 
 func CustomEnvironment() *exec.Environment {
 	e := gonja.DefaultEnvironment
-	if !e.Filters.Exists("regex_replace") {
-		e.Filters.Register("regex_replace", filterFuncRegexReplace)
-	}
-	if !e.Filters.Exists("regex_search") {
-		e.Filters.Register("regex_search", filterFuncRegexSearch)
-	}
 	if !e.Filters.Exists("to_yaml") {
 		e.Filters.Register("to_yaml", filterFuncToYaml)
 	}
@@ -216,6 +162,12 @@ func CustomEnvironment() *exec.Environment {
 	if !e.Filters.Exists("b64decode") {
 		e.Filters.Register("b64decode", filterFuncB64Decode)
 	}
+	if !e.Context.Has("lookup") {
+		e.Context.Set("lookup", lookupFunction)
+		e.Context.Set("query", queryFunction)
+	}
+	registerOsFamilyTests(e)
+	registerAnsibleFilters(e)
 	return e
 }
 
@@ -319,10 +271,30 @@ func TemplateFile(src, dest string, data map[string]interface{}, fileMode os.Fil
 	}
 	if contentb, err := os.ReadFile(src); err == nil {
 		content := strings.ReplaceAll(string(contentb), "\r\n", "\n")
-		u.CheckErr(os.WriteFile(dest, []byte(TemplateString(content, data)), fileMode), "TemplateFile")
+		out := u.Must(TemplateStringWithOpt(content, data, TemplateOpt{Name: src}))
+		u.CheckErr(os.WriteFile(dest, []byte(out), fileMode), "TemplateFile")
 	}
 }
 
+// TemplateFileWithOpt is TemplateFile returning the errors instead of exiting, see TemplateOpt for the strict mode
+func TemplateFileWithOpt(src, dest string, data map[string]interface{}, fileMode os.FileMode, opt TemplateOpt) error {
+	if fileMode == 0 {
+		fileMode = 0o777
+	}
+	if opt.Name == "" {
+		opt.Name = src
+	}
+	contentb, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	out, err := TemplateStringWithOpt(strings.ReplaceAll(string(contentb), "\r\n", "\n"), data, opt)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dest, []byte(out), fileMode)
+}
+
 // One day if the upstream lib fixed we can restore this func
 func TemplateFileOld(src, dest string, data map[string]interface{}, fileMode os.FileMode) {
 	if fileMode == 0 {
@@ -340,14 +312,30 @@ func TemplateFileOld(src, dest string, data map[string]interface{}, fileMode os.
 	u.CheckErr(tmpl.Execute(destFile, execContext), "[ERROR] Can not template "+src+" => "+dest)
 }
 
+// TemplateString render the template text, an undefined variable is rendered as an empty string. It panics on a
+// template error, use TemplateStringWithOpt to get the error.
 func TemplateString(srcString string, data map[string]interface{}) string {
-	_, newSrc, CustomConfig := inspectTemplateString(srcString)
+	return u.Must(TemplateStringWithOpt(srcString, data, TemplateOpt{}))
+}
+
+// TemplateStringWithOpt render the template text and return the errors as *TemplateError, telling the line and the
+// expression failing. With opt.Strict an undefined variable is an error.
+func TemplateStringWithOpt(srcString string, data map[string]interface{}, opt TemplateOpt) (string, error) {
+	_, newSrc, cfg := inspectTemplateString(srcString)
+	lineOffset := 1
 	if newSrc == "" {
-		newSrc = srcString
+		newSrc, lineOffset = srcString, 0
 	}
-	tmpl := u.Must(TemplateFromStringWithConfig(newSrc, CustomConfig))
-	execContext := exec.NewContext(data)
-	return u.Must(tmpl.ExecuteToString(execContext))
+	cfg.StrictUndefined = opt.Strict
+	tmpl, err := TemplateFromStringWithConfig(newSrc, cfg)
+	if err != nil {
+		return "", newTemplateError(opt, newSrc, lineOffset, cfg, err)
+	}
+	out, err := tmpl.ExecuteToString(exec.NewContext(data))
+	if err != nil {
+		return "", newTemplateError(opt, newSrc, lineOffset, cfg, err)
+	}
+	return out, nil
 }
 
 // TemplateDirTree read all templates files in the src directory and template to the target directory keeping the directory
@@ -360,12 +348,12 @@ func TemplateDirTree(srcDirpath, targetRoot string, tmplData map[string]interfac
 	os.Chdir(srcDirpath)
 	filepath.Walk(".", func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
-			fmt.Printf("prevent panic by handling failure accessing a path %q: %v\n", srcDirpath, err)
+			Logger().Error("prevent panic by handling failure accessing a path", "path", srcDirpath, "error", err)
 			return err
 		}
 		if !info.IsDir() {
 			srcFile, destFile := filepath.Join(srcDirpath, path), filepath.Join(targetRoot, path)
-			fmt.Printf("Going to template file %s => %s\n", srcFile, destFile)
+			Logger().Info("template file", "src", srcFile, "dest", destFile)
 			destDir := filepath.Dir(destFile)
 			u.CheckErr(os.MkdirAll(destDir, 0o777), "TemplateDirTree")
 			TemplateFile(srcFile, destFile, tmplData, 0644)
@@ -0,0 +1,276 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Inventory is an ansible inventory: groups of hosts and the host vars. It marshals to the json format of the
+// dynamic inventory scripts with ToDynamicJson.
+type Inventory struct {
+	Groups   map[string][]string
+	HostVars map[string]map[string]any
+}
+
+// NewInventory return an empty inventory
+func NewInventory() *Inventory {
+	return &Inventory{Groups: map[string][]string{}, HostVars: map[string]map[string]any{}}
+}
+
+// AddHost add a host to the groups, merging its vars with the ones it already has
+func (inv *Inventory) AddHost(name string, vars map[string]any, groups ...string) {
+	if _, ok := inv.HostVars[name]; !ok {
+		inv.HostVars[name] = map[string]any{}
+	}
+	for k, v := range vars {
+		inv.HostVars[name][k] = v
+	}
+	for _, g := range groups {
+		if !slices.Contains(inv.Groups[g], name) {
+			inv.Groups[g] = append(inv.Groups[g], name)
+		}
+	}
+}
+
+// ToDynamicJson return the inventory in the format ansible expects from 'inventory.sh --list'
+func (inv *Inventory) ToDynamicJson() map[string]any {
+	o := map[string]any{"_meta": map[string]any{"hostvars": inv.HostVars}}
+	children := []string{}
+	for g, hosts := range inv.Groups {
+		sorted := append([]string{}, hosts...)
+		sort.Strings(sorted)
+		o[g] = map[string]any{"hosts": sorted}
+		children = append(children, g)
+	}
+	sort.Strings(children)
+	ungrouped := []string{}
+	for h := range inv.HostVars {
+		found := false
+		for _, hosts := range inv.Groups {
+			if slices.Contains(hosts, h) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ungrouped = append(ungrouped, h)
+		}
+	}
+	sort.Strings(ungrouped)
+	if len(ungrouped) > 0 {
+		o["ungrouped"] = map[string]any{"hosts": ungrouped}
+		children = append(children, "ungrouped")
+	}
+	o["all"] = map[string]any{"children": children}
+	return o
+}
+
+// tfComputeResource tells where to find the host data in the attributes of a terraform compute resource.
+// Each field is a list of attribute paths tried in order; a path uses '.' and list indexes, eg
+// 'network_interface.0.access_config.0.nat_ip'.
+type tfComputeResource struct {
+	Name      []string
+	PublicIP  []string
+	PrivateIP []string
+	Tags      []string
+}
+
+var tfComputeResources = map[string]tfComputeResource{
+	"aws_instance":                          {Name: []string{"tags.Name", "id"}, PublicIP: []string{"public_ip"}, PrivateIP: []string{"private_ip"}, Tags: []string{"tags"}},
+	"google_compute_instance":               {Name: []string{"name"}, PublicIP: []string{"network_interface.0.access_config.0.nat_ip"}, PrivateIP: []string{"network_interface.0.network_ip"}, Tags: []string{"labels"}},
+	"azurerm_linux_virtual_machine":         {Name: []string{"name"}, PublicIP: []string{"public_ip_address"}, PrivateIP: []string{"private_ip_address"}, Tags: []string{"tags"}},
+	"azurerm_windows_virtual_machine":       {Name: []string{"name"}, PublicIP: []string{"public_ip_address"}, PrivateIP: []string{"private_ip_address"}, Tags: []string{"tags"}},
+	"digitalocean_droplet":                  {Name: []string{"name"}, PublicIP: []string{"ipv4_address"}, PrivateIP: []string{"ipv4_address_private"}, Tags: []string{"tags"}},
+	"hcloud_server":                         {Name: []string{"name"}, PublicIP: []string{"ipv4_address"}, Tags: []string{"labels"}},
+	"openstack_compute_instance_v2":         {Name: []string{"name"}, PublicIP: []string{"access_ip_v4"}, PrivateIP: []string{"network.0.fixed_ip_v4"}, Tags: []string{"metadata"}},
+	"vsphere_virtual_machine":               {Name: []string{"name"}, PrivateIP: []string{"default_ip_address"}},
+	"libvirt_domain":                        {Name: []string{"name"}, PrivateIP: []string{"network_interface.0.addresses.0"}},
+	"linode_instance":                       {Name: []string{"label"}, PublicIP: []string{"ip_address"}, PrivateIP: []string{"private_ip_address"}, Tags: []string{"tags"}},
+	"oci_core_instance":                     {Name: []string{"display_name"}, PublicIP: []string{"public_ip"}, PrivateIP: []string{"private_ip"}, Tags: []string{"freeform_tags"}},
+	"google_compute_instance_from_template": {Name: []string{"name"}, PublicIP: []string{"network_interface.0.access_config.0.nat_ip"}, PrivateIP: []string{"network_interface.0.network_ip"}, Tags: []string{"labels"}},
+}
+
+// attrPath get a value from the attributes with a path like 'network_interface.0.network_ip'
+func attrPath(attrs map[string]any, path string) any {
+	var cur any = attrs
+	for _, part := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]any:
+			cur = v[part]
+		case []any:
+			var idx int
+			if _, err := fmt.Sscanf(part, "%d", &idx); err != nil || idx >= len(v) {
+				return nil
+			}
+			cur = v[idx]
+		default:
+			return nil
+		}
+	}
+	return cur
+}
+
+func firstAttr(attrs map[string]any, paths []string) string {
+	for _, p := range paths {
+		if v := attrPath(attrs, p); v != nil && fmt.Sprint(v) != "" {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+var groupNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// safeGroupName replace the characters ansible does not accept in group names with '_'
+func safeGroupName(name string) string {
+	return groupNameInvalidChars.ReplaceAllString(name, "_")
+}
+
+// TerraformInventory build an inventory from the compute resources of a terraform state (format version 4).
+// Each host gets ansible_host (the public ip if any else the private ip), public_ip, private_ip, tags, tf_type and
+// tf_address as vars. It is in the group of its resource type, the 'tag_<key>_<value>' groups and the groups listed
+// in its 'ansible_groups' (or 'ansible_group') tag, comma separated. The host name is its name attribute, eg the
+// Name tag; the instances sharing a name, eg made with count or for_each, are named by their tf_address instead.
+func TerraformInventory(state []byte) (*Inventory, error) {
+	var tfstate struct {
+		Version   int `json:"version"`
+		Resources []struct {
+			Module    string `json:"module"`
+			Mode      string `json:"mode"`
+			Type      string `json:"type"`
+			Name      string `json:"name"`
+			Instances []struct {
+				IndexKey   any            `json:"index_key"`
+				Attributes map[string]any `json:"attributes"`
+			} `json:"instances"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(state, &tfstate); err != nil {
+		return nil, fmt.Errorf("invalid terraform state - %w", err)
+	}
+	if tfstate.Version != 4 {
+		return nil, fmt.Errorf("unsupported terraform state version %d, expect 4", tfstate.Version)
+	}
+	type tfHost struct {
+		name, address string
+		vars          map[string]any
+		groups        []string
+	}
+	hosts, nameCount := []tfHost{}, map[string]int{}
+	for _, res := range tfstate.Resources {
+		spec, ok := tfComputeResources[res.Type]
+		if !ok || res.Mode != "managed" {
+			continue
+		}
+		for _, inst := range res.Instances {
+			address := res.Type + "." + res.Name
+			if res.Module != "" {
+				address = res.Module + "." + address
+			}
+			switch k := inst.IndexKey.(type) {
+			case string:
+				address += fmt.Sprintf("[%q]", k)
+			case float64:
+				address += fmt.Sprintf("[%d]", int(k))
+			}
+			name := firstAttr(inst.Attributes, spec.Name)
+			if name == "" {
+				name = address
+			}
+			vars := map[string]any{
+				"tf_type":    res.Type,
+				"tf_address": address,
+				"public_ip":  firstAttr(inst.Attributes, spec.PublicIP),
+				"private_ip": firstAttr(inst.Attributes, spec.PrivateIP),
+			}
+			vars["ansible_host"] = firstNonEmpty(vars["public_ip"].(string), vars["private_ip"].(string))
+			groups := []string{res.Type}
+			tags := map[string]any{}
+			for _, p := range spec.Tags {
+				switch t := attrPath(inst.Attributes, p).(type) {
+				case map[string]any:
+					tags = t
+				case []any: // tags as a list, eg digitalocean
+					for _, item := range t {
+						tags[fmt.Sprint(item)] = ""
+					}
+				}
+			}
+			vars["tags"] = tags
+			for k, v := range tags {
+				val := fmt.Sprint(v)
+				switch k {
+				case "ansible_groups", "ansible_group":
+					for _, g := range strings.Split(val, ",") {
+						if g = strings.TrimSpace(g); g != "" {
+							groups = append(groups, safeGroupName(g))
+						}
+					}
+				default:
+					if val == "" {
+						groups = append(groups, safeGroupName("tag_"+k))
+					} else {
+						groups = append(groups, safeGroupName("tag_"+k+"_"+val))
+					}
+				}
+			}
+			if res.Module != "" {
+				groups = append(groups, safeGroupName(res.Module))
+			}
+			hosts = append(hosts, tfHost{name: name, address: address, vars: vars, groups: groups})
+			nameCount[name]++
+		}
+	}
+	inv := NewInventory()
+	for _, h := range hosts {
+		if nameCount[h.name] > 1 {
+			h.name = h.address
+		}
+		inv.AddHost(h.name, h.vars, h.groups...)
+	}
+	return inv, nil
+}
+
+// LoadTerraformState read a terraform state from a local file, an http(s) url (eg the http backend) or, if source
+// is a directory, with 'terraform state pull' which works with any remote backend configured there.
+func LoadTerraformState(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: 60 * time.Second}
+		req, err := http.NewRequest("GET", source, nil)
+		if err != nil {
+			return nil, err
+		}
+		if user := os.Getenv("TF_HTTP_USERNAME"); user != "" {
+			req.SetBasicAuth(user, os.Getenv("TF_HTTP_PASSWORD"))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("GET %s: %s", source, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return os.ReadFile(source)
+	}
+	cmd := exec.Command("terraform", "state", "pull")
+	cmd.Dir = source
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestTerraformInventory(t *testing.T) {
+	state, err := LoadTerraformState("testdata/terraform/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := TerraformInventory(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.HostVars) != 5 {
+		t.Fatalf("expect 5 hosts, got %v", inv.HostVars)
+	}
+	web1 := inv.HostVars["web-1"]
+	if web1["ansible_host"] != "54.1.2.3" || web1["private_ip"] != "10.0.1.10" || web1["tf_address"] != "aws_instance.web[0]" {
+		t.Errorf("unexpected web-1 vars %v", web1)
+	}
+	if inv.HostVars["web-2"]["ansible_host"] != "10.0.1.11" {
+		t.Errorf("expect the private ip as ansible_host for web-2, got %v", inv.HostVars["web-2"])
+	}
+	if inv.HostVars["pg-main"]["tf_address"] != "module.db.google_compute_instance.pg" {
+		t.Errorf("unexpected pg-main vars %v", inv.HostVars["pg-main"])
+	}
+	// the count instances share their Name tag, they are named by address
+	if inv.HostVars["aws_instance.worker[0]"]["ansible_host"] != "10.0.2.10" || inv.HostVars["aws_instance.worker[1]"]["ansible_host"] != "10.0.2.11" {
+		t.Errorf("expect the workers named by their address, got %v", inv.HostVars)
+	}
+	expectedGroups := map[string]int{"aws_instance": 4, "tag_Name_worker": 2, "tag_env_prod": 2, "webservers": 1, "nginx": 1, "tag_role_db": 1, "module_db": 1}
+	for g, n := range expectedGroups {
+		if len(inv.Groups[g]) != n {
+			t.Errorf("expect %d host(s) in group %s, got %v", n, g, inv.Groups[g])
+		}
+	}
+	if _, ok := inv.ToDynamicJson()["_meta"]; !ok {
+		t.Error("expect _meta in the dynamic inventory json")
+	}
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+var logger atomic.Pointer[slog.Logger]
+
+// Logger return the logger used by the lib. It is slog.Default() unless SetLogger has been called.
+func Logger() *slog.Logger {
+	if l := logger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// SetLogger inject the logger the lib will use. Pass nil to go back to slog.Default().
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// NewLogger create a logger writing to w. level is one of debug, info, warn, error and format is text or json.
+func NewLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level '%s' - %w", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format '%s', expect text or json", format)
+	}
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"fmt"
+)
+
+func init() {
+	RegisterLookup("aws_secret", lookupAwsSecret)
+	RegisterLookup("aws_ssm", lookupAwsSsm)
+}
+
+func awsConfigFromKwargs(kwargs map[string]any) (*AwsConfig, error) {
+	kw := func(name string) string {
+		if v, ok := kwargs[name]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	cfg, err := LoadAwsConfig(kw("profile"), kw("region"))
+	if err != nil {
+		return nil, err
+	}
+	if endpoint := kw("endpoint"); endpoint != "" {
+		cfg.Endpoint = endpoint
+	}
+	return cfg, nil
+}
+
+// lookupAwsSecret is lookup('aws_secret', 'prod/app/db', region='us-east-1', profile='prod') returning the
+// SecretString of each secret (name or arn). The option version_stage (default AWSCURRENT) selects the version.
+func lookupAwsSecret(terms []any, kwargs map[string]any) ([]any, error) {
+	cfg, err := awsConfigFromKwargs(kwargs)
+	if err != nil {
+		return nil, err
+	}
+	o := []any{}
+	for _, term := range terms {
+		input := map[string]any{"SecretId": fmt.Sprint(term)}
+		if stage, ok := kwargs["version_stage"]; ok {
+			input["VersionStage"] = fmt.Sprint(stage)
+		}
+		resp, err := cfg.CallJsonApi("secretsmanager", "secretsmanager.GetSecretValue", input)
+		if err != nil {
+			return nil, err
+		}
+		secret, ok := resp["SecretString"].(string)
+		if !ok {
+			return nil, fmt.Errorf("secret %v has no SecretString, binary secrets are not supported", term)
+		}
+		RegisterSecret(secret)
+		o = append(o, secret)
+	}
+	return o, nil
+}
+
+// lookupAwsSsm is lookup('aws_ssm', '/app/db/password', region='us-east-1') returning the parameter values.
+// SecureString parameters are decrypted unless decrypt=False.
+func lookupAwsSsm(terms []any, kwargs map[string]any) ([]any, error) {
+	cfg, err := awsConfigFromKwargs(kwargs)
+	if err != nil {
+		return nil, err
+	}
+	decrypt := true
+	if d, ok := kwargs["decrypt"].(bool); ok {
+		decrypt = d
+	}
+	o := []any{}
+	for _, term := range terms {
+		resp, err := cfg.CallJsonApi("ssm", "AmazonSSM.GetParameter", map[string]any{"Name": fmt.Sprint(term), "WithDecryption": decrypt})
+		if err != nil {
+			return nil, err
+		}
+		param, _ := resp["Parameter"].(map[string]any)
+		value, ok := param["Value"].(string)
+		if !ok {
+			return nil, fmt.Errorf("no value for ssm parameter %v", term)
+		}
+		if param["Type"] == "SecureString" && decrypt {
+			RegisterSecret(value)
+		}
+		o = append(o, value)
+	}
+	return o, nil
+}
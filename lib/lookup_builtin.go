@@ -0,0 +1,331 @@
+package lib
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func init() {
+	RegisterLookup("env", lookupEnv)
+	RegisterLookup("file", lookupFile)
+	RegisterLookup("pipe", lookupPipe)
+	RegisterLookup("url", lookupUrl)
+	RegisterLookup("password", lookupPassword)
+	RegisterLookup("ini", lookupIni)
+}
+
+// lookupBool read a boolean option, given as a template boolean or as text in a 'key=value' term
+func lookupBool(v any, def bool) bool {
+	switch b := v.(type) {
+	case nil:
+		return def
+	case bool:
+		return b
+	case string:
+		if p, err := strconv.ParseBool(strings.ToLower(b)); err == nil {
+			return p
+		}
+		switch strings.ToLower(b) {
+		case "yes", "on":
+			return true
+		case "no", "off":
+			return false
+		}
+		return def
+	}
+	return truthy(v)
+}
+
+// lookupTermArgs split an ansible term 'name key=value ...' into the name and its options. The kwargs of the lookup
+// call override the options of the term.
+func lookupTermArgs(term any, kwargs map[string]any) (string, map[string]any, error) {
+	name, rest, _ := strings.Cut(strings.TrimSpace(fmt.Sprint(term)), " ")
+	opts, err := parseKeyValueArgs(rest)
+	if err != nil {
+		return "", nil, err
+	}
+	for k, v := range kwargs {
+		opts[k] = v
+	}
+	return name, opts, nil
+}
+
+// lookupFindFile return the path of a file read by a lookup. Like ansible a relative path not found in the current
+// directory is searched in the files directory.
+func lookupFindFile(path string) (string, error) {
+	if _, err := os.Stat(path); err == nil || filepath.IsAbs(path) {
+		return path, err
+	}
+	alt := filepath.Join("files", path)
+	if _, err := os.Stat(alt); err == nil {
+		return alt, nil
+	}
+	return "", fmt.Errorf("could not find file '%s'", path)
+}
+
+// lookupEnv is lookup('env', 'HOME', ...), the value of the env vars. An unset var gives the default kwarg, an
+// empty string if not set.
+func lookupEnv(terms []any, kwargs map[string]any) ([]any, error) {
+	o := []any{}
+	for _, term := range terms {
+		if v, ok := os.LookupEnv(fmt.Sprint(term)); ok {
+			o = append(o, v)
+		} else if def, ok := kwargs["default"]; ok {
+			o = append(o, def)
+		} else {
+			o = append(o, "")
+		}
+	}
+	return o, nil
+}
+
+// lookupFile is lookup('file', 'path', ...), the content of the files. The trailing spaces are removed unless
+// rstrip=False, the leading ones with lstrip=True.
+func lookupFile(terms []any, kwargs map[string]any) ([]any, error) {
+	o := []any{}
+	for _, term := range terms {
+		path, err := lookupFindFile(fmt.Sprint(term))
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		content := string(data)
+		if lookupBool(kwargs["rstrip"], true) {
+			content = strings.TrimRight(content, " \t\r\n")
+		}
+		if lookupBool(kwargs["lstrip"], false) {
+			content = strings.TrimLeft(content, " \t\r\n")
+		}
+		o = append(o, content)
+	}
+	return o, nil
+}
+
+// lookupPipe is lookup('pipe', 'command', ...), the output of the commands run by the shell without the trailing
+// newlines. A command failing is an error.
+func lookupPipe(terms []any, kwargs map[string]any) ([]any, error) {
+	o := []any{}
+	for _, term := range terms {
+		cmd := exec.Command("sh", "-c", fmt.Sprint(term))
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("command '%s' failed - %w", term, err)
+		}
+		o = append(o, strings.TrimRight(string(out), "\r\n"))
+	}
+	return o, nil
+}
+
+// lookupUrl is lookup('url', 'https://...', ...), the content of the urls split into lines unless
+// split_lines=False. The kwargs validate_certs, headers, username, password and timeout (seconds, default 10) set
+// the request.
+func lookupUrl(terms []any, kwargs map[string]any) ([]any, error) {
+	timeout := 10 * time.Second
+	if v, ok := kwargs["timeout"]; ok {
+		secs, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout '%v'", v)
+		}
+		timeout = time.Duration(secs * float64(time.Second))
+	}
+	client := &http.Client{Timeout: timeout}
+	if !lookupBool(kwargs["validate_certs"], true) {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	o := []any{}
+	for _, term := range terms {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprint(term), nil)
+		if err != nil {
+			return nil, err
+		}
+		if headers, ok := kwargs["headers"].(map[string]any); ok {
+			for k, v := range headers {
+				req.Header.Set(k, fmt.Sprint(v))
+			}
+		}
+		if user, ok := kwargs["username"]; ok {
+			req.SetBasicAuth(fmt.Sprint(user), fmt.Sprint(kwargs["password"]))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("GET %s - %s", term, resp.Status)
+		}
+		if !lookupBool(kwargs["split_lines"], true) {
+			o = append(o, string(body))
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(body), "\n"), "\n") {
+			o = append(o, strings.TrimRight(line, "\r"))
+		}
+	}
+	return o, nil
+}
+
+var passwordCharSets = map[string]string{
+	"ascii_letters":   "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"ascii_lowercase": "abcdefghijklmnopqrstuvwxyz",
+	"ascii_uppercase": "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"digits":          "0123456789",
+	"hexdigits":       "0123456789abcdefABCDEF",
+	"octdigits":       "01234567",
+	"punctuation":     "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+}
+
+// passwordChars build the characters of a generated password from the ansible chars option, a comma separated
+// list of set names (ascii_letters, digits ...) or literal characters; ',,' is a literal comma.
+func passwordChars(spec string) string {
+	if spec == "" {
+		return passwordCharSets["ascii_letters"] + passwordCharSets["digits"] + ".,:-_"
+	}
+	var chars strings.Builder
+	for _, part := range strings.Split(strings.ReplaceAll(spec, ",,", "\x00"), ",") {
+		part = strings.ReplaceAll(part, "\x00", ",")
+		if set, ok := passwordCharSets[part]; ok {
+			chars.WriteString(set)
+		} else {
+			chars.WriteString(part)
+		}
+	}
+	return chars.String()
+}
+
+// GeneratePassword return a random password of length characters taken from chars, see the password lookup
+func GeneratePassword(length int, chars string) (string, error) {
+	if length < 1 || chars == "" {
+		return "", fmt.Errorf("cannot generate a password of length %d from '%s'", length, chars)
+	}
+	runes := []rune(chars)
+	max := big.NewInt(int64(len(runes)))
+	o := make([]rune, length)
+	for i := range o {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		o[i] = runes[n.Int64()]
+	}
+	return string(o), nil
+}
+
+// lookupPassword is lookup('password', 'path length=20 chars=ascii_letters,digits'). It returns the password
+// stored in the file, or generates one and stores it in the file (mode 0600) so the next runs get the same one.
+// '/dev/null' generates a new password each time. The passwords are registered as secrets.
+func lookupPassword(terms []any, kwargs map[string]any) ([]any, error) {
+	o := []any{}
+	for _, term := range terms {
+		path, opts, err := lookupTermArgs(term, kwargs)
+		if err != nil {
+			return nil, err
+		}
+		length := 20
+		if v, ok := opts["length"]; ok {
+			if length, err = strconv.Atoi(fmt.Sprint(v)); err != nil {
+				return nil, fmt.Errorf("invalid length '%v'", v)
+			}
+		}
+		var password string
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil && path != os.DevNull:
+			// ansible stores 'password salt=xxx' when it encrypts, the salt is not part of the password
+			password, _, _ = strings.Cut(strings.TrimRight(string(data), "\r\n"), " salt=")
+		case err == nil || os.IsNotExist(err):
+			chars := ""
+			if v, ok := opts["chars"]; ok {
+				chars = fmt.Sprint(v)
+			}
+			if password, err = GeneratePassword(length, passwordChars(chars)); err != nil {
+				return nil, err
+			}
+			if path != os.DevNull {
+				if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+					return nil, err
+				}
+				if err := os.WriteFile(path, []byte(password+"\n"), 0o600); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, err
+		}
+		RegisterSecret(password)
+		o = append(o, password)
+	}
+	return o, nil
+}
+
+// lookupIni is lookup('ini', 'key section=global file=ansible.ini default= re=False type=ini'), the value of the
+// key in the section of the file. type=properties reads a java properties file, without sections. With re=True the
+// key is a regex and the values of all the keys matching it are returned.
+func lookupIni(terms []any, kwargs map[string]any) ([]any, error) {
+	o := []any{}
+	for _, term := range terms {
+		key, opts, err := lookupTermArgs(term, kwargs)
+		if err != nil {
+			return nil, err
+		}
+		opt := func(name, def string) string {
+			if v, ok := opts[name]; ok && v != nil {
+				return fmt.Sprint(v)
+			}
+			return def
+		}
+		path, err := lookupFindFile(opt("file", "ansible.ini"))
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := ini.LoadSources(ini.LoadOptions{AllowBooleanKeys: true}, path)
+		if err != nil {
+			return nil, err
+		}
+		var section *ini.Section
+		if opt("type", "ini") == "properties" {
+			section = cfg.Section(ini.DefaultSection)
+		} else if section, err = cfg.GetSection(opt("section", "global")); err != nil {
+			return nil, fmt.Errorf("%s - %w", path, err)
+		}
+		if !lookupBool(opts["re"], false) {
+			if section.HasKey(key) {
+				o = append(o, section.Key(key).String())
+			} else {
+				o = append(o, opt("default", ""))
+			}
+			continue
+		}
+		ptn, err := regexp.Compile("^(?:" + key + ")")
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range section.Keys() {
+			if ptn.MatchString(k.Name()) {
+				o = append(o, k.Value())
+			}
+		}
+	}
+	return o, nil
+}
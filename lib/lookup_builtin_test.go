@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupBuiltin(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "motd"), []byte("  welcome\n\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "app.ini"), []byte("[db]\nuser = admin\nport = 5432\n[web]\nport = 80\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "app.properties"), []byte("name=demo\n"), 0o644)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "line1\nline2 %s\n", r.Header.Get("X-Env"))
+	}))
+	defer srv.Close()
+	t.Setenv("LOOKUP_TEST_VAR", "value1")
+	vars := map[string]any{"dir": dir, "url": srv.URL}
+
+	cases := map[string]string{
+		"{{ lookup('env', 'LOOKUP_TEST_VAR') }}":                                      "value1",
+		"{{ lookup('env', 'LOOKUP_TEST_UNSET', default='none') }}":                    "none",
+		"{{ lookup('file', dir + '/motd') }}":                                         "  welcome",
+		"{{ lookup('file', dir + '/motd', lstrip=True) }}":                            "welcome",
+		"{{ lookup('pipe', 'echo hello') }}":                                          "hello",
+		"{{ lookup('url', url, headers={'X-Env': 'prod'}) }}":                         "line1,line2 prod",
+		"{{ query('url', url, split_lines=False) | length }}":                         "1",
+		"{{ lookup('ini', 'user section=db file=' + dir + '/app.ini') }}":             "admin",
+		"{{ lookup('ini', 'port', section='web', file=dir + '/app.ini') }}":           "80",
+		"{{ lookup('ini', 'pass section=db default=x file=' + dir + '/app.ini') }}":   "x",
+		"{{ lookup('ini', 'name type=properties file=' + dir + '/app.properties') }}": "demo",
+	}
+	for tpl, expected := range cases {
+		if got := TemplateString(tpl, vars); got != expected {
+			t.Errorf("TemplateString(%s) = %q; expected %q", tpl, got, expected)
+		}
+	}
+
+	pwdFile := filepath.Join(dir, "credentials", "db")
+	first := TemplateString("{{ lookup('password', dir + '/credentials/db length=12 chars=digits') }}", vars)
+	if len(first) != 12 || first != TemplateString("{{ lookup('password', dir + '/credentials/db') }}", vars) {
+		t.Errorf("expect the stored password returned again, got '%s'", first)
+	}
+	if st, err := os.Stat(pwdFile); err != nil || st.Mode().Perm() != 0o600 {
+		t.Errorf("expect the password stored with mode 0600, got %v %v", st, err)
+	}
+	if masked := MaskCredential("password " + first); masked != "password *****" {
+		t.Errorf("generated password must be masked, got '%s'", masked)
+	}
+	if out, err := lookupPassword([]any{"/dev/null length=8"}, map[string]any{}); err != nil || len(out[0].(string)) != 8 {
+		t.Errorf("expect a password not stored, got %v %v", out, err)
+	}
+	if _, err := lookupPipe([]any{"exit 3"}, map[string]any{}); err == nil {
+		t.Error("expect error for a failing command")
+	}
+}
@@ -0,0 +1,205 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterLookup("hashi_vault", lookupHashiVault)
+}
+
+// VaultClient is a minimal HashiCorp Vault client, enough to log in and read secrets
+type VaultClient struct {
+	Addr      string
+	Token     string
+	Namespace string
+	client    *http.Client
+}
+
+// VaultAuthOpt select how to log in. Empty fields are taken from the env:
+// VAULT_ADDR, VAULT_NAMESPACE, VAULT_AUTH_METHOD (token, approle or kubernetes, default token), VAULT_TOKEN,
+// VAULT_ROLE_ID, VAULT_SECRET_ID, VAULT_K8S_ROLE, VAULT_K8S_TOKEN_PATH and VAULT_AUTH_MOUNT.
+type VaultAuthOpt struct {
+	Addr       string
+	Namespace  string
+	AuthMethod string
+	Token      string
+	RoleID     string // approle
+	SecretID   string // approle
+	K8sRole    string // kubernetes
+	K8sJWTPath string // kubernetes service account token, default /var/run/secrets/kubernetes.io/serviceaccount/token
+	Mount      string // auth mount point, default the auth method name
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// NewVaultClient log in to vault with the auth method and return a client holding the token
+func NewVaultClient(opt VaultAuthOpt) (*VaultClient, error) {
+	c := &VaultClient{
+		Addr:      strings.TrimSuffix(firstNonEmpty(opt.Addr, os.Getenv("VAULT_ADDR"), "http://127.0.0.1:8200"), "/"),
+		Namespace: firstNonEmpty(opt.Namespace, os.Getenv("VAULT_NAMESPACE")),
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	method := firstNonEmpty(opt.AuthMethod, os.Getenv("VAULT_AUTH_METHOD"), "token")
+	mount := firstNonEmpty(opt.Mount, os.Getenv("VAULT_AUTH_MOUNT"), method)
+	var body map[string]any
+	switch method {
+	case "token":
+		c.Token = firstNonEmpty(opt.Token, os.Getenv("VAULT_TOKEN"))
+		if c.Token == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				if tokenb, err := os.ReadFile(home + "/.vault-token"); err == nil {
+					c.Token = strings.TrimSpace(string(tokenb))
+				}
+			}
+		}
+		if c.Token == "" {
+			return nil, fmt.Errorf("vault token auth: no token, set VAULT_TOKEN")
+		}
+		RegisterSecret(c.Token)
+		return c, nil
+	case "approle":
+		body = map[string]any{
+			"role_id":   firstNonEmpty(opt.RoleID, os.Getenv("VAULT_ROLE_ID")),
+			"secret_id": firstNonEmpty(opt.SecretID, os.Getenv("VAULT_SECRET_ID")),
+		}
+	case "kubernetes":
+		jwtPath := firstNonEmpty(opt.K8sJWTPath, os.Getenv("VAULT_K8S_TOKEN_PATH"), "/var/run/secrets/kubernetes.io/serviceaccount/token")
+		jwt, err := os.ReadFile(jwtPath)
+		if err != nil {
+			return nil, fmt.Errorf("vault kubernetes auth: %w", err)
+		}
+		body = map[string]any{"role": firstNonEmpty(opt.K8sRole, os.Getenv("VAULT_K8S_ROLE")), "jwt": strings.TrimSpace(string(jwt))}
+	default:
+		return nil, fmt.Errorf("unsupported vault auth method '%s', expect token, approle or kubernetes", method)
+	}
+	resp, err := c.Request("POST", "auth/"+mount+"/login", body)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s login: %w", method, err)
+	}
+	auth, _ := resp["auth"].(map[string]any)
+	c.Token, _ = auth["client_token"].(string)
+	if c.Token == "" {
+		return nil, fmt.Errorf("vault %s login: no client_token in response", method)
+	}
+	RegisterSecret(c.Token)
+	return c, nil
+}
+
+// Request call the vault api at /v1/<path> and decode the json response
+func (c *VaultClient) Request(method, path string, body map[string]any) (map[string]any, error) {
+	var reader io.Reader
+	if body != nil {
+		datab, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(datab)
+	}
+	req, err := http.NewRequest(method, c.Addr+"/v1/"+strings.TrimPrefix(path, "/"), reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("X-Vault-Token", c.Token)
+	}
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s %s", method, path, resp.Status, strings.TrimSpace(string(respb)))
+	}
+	out := map[string]any{}
+	if len(respb) > 0 {
+		if err := json.Unmarshal(respb, &out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// ReadSecret read a secret and return its data. For kv version 2 paths (secret/data/...) the inner data is returned.
+func (c *VaultClient) ReadSecret(path string) (map[string]any, error) {
+	resp, err := c.Request("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := resp["data"].(map[string]any)
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, hasMeta := data["metadata"]; hasMeta {
+			data = inner
+		}
+	}
+	if data == nil {
+		return nil, fmt.Errorf("no data at vault path %s", path)
+	}
+	return data, nil
+}
+
+// lookupHashiVault is lookup('hashi_vault', 'secret/data/app:key'). Without ':key' the whole secret is returned
+// as a dict. The kwargs url, namespace, auth_method, token, role_id, secret_id, role, jwt_path and mount_point
+// override the VAULT_* env. The values read are registered as secrets so they are masked in the logs.
+func lookupHashiVault(terms []any, kwargs map[string]any) ([]any, error) {
+	kw := func(name string) string {
+		if v, ok := kwargs[name]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	client, err := NewVaultClient(VaultAuthOpt{
+		Addr: kw("url"), Namespace: kw("namespace"), AuthMethod: kw("auth_method"), Token: kw("token"),
+		RoleID: kw("role_id"), SecretID: kw("secret_id"), K8sRole: kw("role"), K8sJWTPath: kw("jwt_path"), Mount: kw("mount_point"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	o := []any{}
+	for _, term := range terms {
+		path, key, hasKey := strings.Cut(fmt.Sprint(term), ":")
+		data, err := client.ReadSecret(path)
+		if err != nil {
+			return nil, err
+		}
+		if !hasKey {
+			for _, v := range data {
+				if s, ok := v.(string); ok {
+					RegisterSecret(s)
+				}
+			}
+			o = append(o, data)
+			continue
+		}
+		v, ok := data[key]
+		if !ok {
+			return nil, fmt.Errorf("key '%s' not found in vault secret %s", key, path)
+		}
+		if s, ok := v.(string); ok {
+			RegisterSecret(s)
+		}
+		o = append(o, v)
+	}
+	return o, nil
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLookupHashiVault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": "s.approletoken"}})
+		case "/v1/secret/data/app":
+			if r.Header.Get("X-Vault-Token") != "s.approletoken" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": map[string]any{"db_password": "Vq7xLm2Pz9"}, "metadata": map[string]any{"version": 1}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_AUTH_METHOD", "approle")
+	t.Setenv("VAULT_ROLE_ID", "role")
+	t.Setenv("VAULT_SECRET_ID", "secret")
+
+	out := TemplateString("pass={{ lookup('hashi_vault', 'secret/data/app:db_password') }}", map[string]any{})
+	if out != "pass=Vq7xLm2Pz9" {
+		t.Errorf("unexpected output '%s'", out)
+	}
+	if masked := MaskCredential("connecting with Vq7xLm2Pz9"); masked != "connecting with *****" {
+		t.Errorf("vault value must be masked, got '%s'", masked)
+	}
+	if _, err := lookupHashiVault([]any{"secret/data/app:missing"}, map[string]any{}); err == nil {
+		t.Error("expect error for a missing key")
+	}
+}
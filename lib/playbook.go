@@ -0,0 +1,636 @@
+package lib
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"gopkg.in/yaml.v3"
+)
+
+// PlaybookError is one problem found while checking a playbook. File, Line and Column point to the yaml node
+// that caused it so the output can be used by editors and CI annotations.
+type PlaybookError struct {
+	File    string
+	Line    int
+	Column  int
+	Msg     string
+	Keyword string // the removed keyword the error is about, one of DeprecatedKeywords
+}
+
+func (e PlaybookError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+var playKeywords = sliceToSet([]string{
+	"any_errors_fatal", "become", "become_exe", "become_flags", "become_method", "become_user", "check_mode",
+	"collections", "connection", "debugger", "diff", "environment", "fact_path", "force_handlers", "gather_facts",
+	"gather_subset", "gather_timeout", "handlers", "hosts", "ignore_errors", "ignore_unreachable",
+	"max_fail_percentage", "module_defaults", "name", "no_log", "order", "port", "post_tasks", "pre_tasks",
+	"remote_user", "roles", "run_once", "serial", "strategy", "tags", "tasks", "throttle", "timeout", "vars",
+	"vars_files", "vars_prompt",
+})
+
+var taskKeywords = sliceToSet([]string{
+	"action", "any_errors_fatal", "args", "async", "become", "become_exe", "become_flags", "become_method",
+	"become_user", "changed_when", "check_mode", "collections", "connection", "debugger", "delay", "delegate_facts",
+	"delegate_to", "diff", "environment", "failed_when", "ignore_errors", "ignore_unreachable", "local_action",
+	"loop", "loop_control", "module_defaults", "name", "no_log", "notify", "poll", "port", "register",
+	"remote_user", "retries", "run_once", "tags", "throttle", "timeout", "until", "vars", "when", "listen",
+	"with_items", "with_dict", "with_fileglob", "with_first_found", "with_together", "with_subelements",
+	"with_sequence", "with_nested", "with_indexed_items", "with_list", "with_lines", "with_random_choice",
+	"with_file", "with_template", "with_inventory_hostnames", "with_flattened",
+})
+
+var blockKeywords = sliceToSet([]string{"block", "rescue", "always"})
+
+// moduleSpec describe the arguments a module accepts. FreeForm modules accept a plain string as argument
+type moduleSpec struct {
+	Required []string
+	Allowed  []string
+	FreeForm bool
+}
+
+// Argument schemas of the most used builtin modules. Modules not listed here are only checked for existence.
+var moduleSpecs = map[string]moduleSpec{
+	"command":             {FreeForm: true, Allowed: []string{"cmd", "argv", "chdir", "creates", "removes", "stdin", "stdin_add_newline", "strip_empty_ends", "expand_argument_vars"}},
+	"shell":               {FreeForm: true, Allowed: []string{"cmd", "chdir", "creates", "removes", "executable", "stdin", "stdin_add_newline", "strip_empty_ends"}},
+	"raw":                 {FreeForm: true, Allowed: []string{"executable"}},
+	"script":              {FreeForm: true, Allowed: []string{"cmd", "chdir", "creates", "removes", "executable", "decrypt"}},
+	"debug":               {Allowed: []string{"msg", "var", "verbosity"}},
+	"fail":                {Allowed: []string{"msg"}},
+	"assert":              {Required: []string{"that"}, Allowed: []string{"fail_msg", "msg", "quiet", "success_msg"}},
+	"set_fact":            {FreeForm: true},
+	"include_vars":        {FreeForm: true, Allowed: []string{"file", "dir", "name", "depth", "files_matching", "ignore_files", "extensions", "ignore_unknown_extensions", "hash_behaviour"}},
+	"include_tasks":       {FreeForm: true, Allowed: []string{"file", "apply"}},
+	"import_tasks":        {FreeForm: true, Allowed: []string{"file"}},
+	"include_role":        {Required: []string{"name"}, Allowed: []string{"tasks_from", "vars_from", "defaults_from", "handlers_from", "apply", "public", "allow_duplicates", "rolespec_validate"}},
+	"import_role":         {Required: []string{"name"}, Allowed: []string{"tasks_from", "vars_from", "defaults_from", "handlers_from", "public", "allow_duplicates", "rolespec_validate"}},
+	"meta":                {FreeForm: true},
+	"file":                {Required: []string{"path"}, Allowed: []string{"state", "owner", "group", "mode", "src", "recurse", "force", "follow", "modification_time", "modification_time_format", "access_time", "access_time_format", "attributes", "seuser", "serole", "setype", "selevel", "unsafe_writes"}},
+	"copy":                {Required: []string{"dest"}, Allowed: []string{"src", "content", "owner", "group", "mode", "backup", "force", "remote_src", "validate", "directory_mode", "follow", "local_follow", "decrypt", "checksum", "attributes", "seuser", "serole", "setype", "selevel", "unsafe_writes"}},
+	"template":            {Required: []string{"src", "dest"}, Allowed: []string{"owner", "group", "mode", "backup", "force", "validate", "newline_sequence", "block_start_string", "block_end_string", "variable_start_string", "variable_end_string", "comment_start_string", "comment_end_string", "trim_blocks", "lstrip_blocks", "output_encoding", "follow", "attributes", "seuser", "serole", "setype", "selevel", "unsafe_writes"}},
+	"lineinfile":          {Required: []string{"path"}, Allowed: []string{"line", "regexp", "search_string", "state", "insertafter", "insertbefore", "create", "backup", "backrefs", "firstmatch", "owner", "group", "mode", "validate", "attributes", "seuser", "serole", "setype", "selevel", "unsafe_writes"}},
+	"blockinfile":         {Required: []string{"path"}, Allowed: []string{"block", "marker", "marker_begin", "marker_end", "state", "insertafter", "insertbefore", "create", "backup", "append_newline", "prepend_newline", "owner", "group", "mode", "validate", "attributes", "seuser", "serole", "setype", "selevel", "unsafe_writes"}},
+	"replace":             {Required: []string{"path", "regexp"}, Allowed: []string{"replace", "after", "before", "backup", "encoding", "owner", "group", "mode", "validate", "attributes", "seuser", "serole", "setype", "selevel", "unsafe_writes"}},
+	"stat":                {Required: []string{"path"}, Allowed: []string{"follow", "get_checksum", "checksum_algorithm", "get_mime", "get_attributes"}},
+	"service":             {Required: []string{"name"}, Allowed: []string{"state", "enabled", "arguments", "pattern", "runlevel", "sleep", "use"}},
+	"systemd":             {Allowed: []string{"name", "state", "enabled", "daemon_reload", "daemon_reexec", "force", "masked", "no_block", "scope"}},
+	"package":             {Required: []string{"name"}, Allowed: []string{"state", "use"}},
+	"apt":                 {Allowed: []string{"name", "pkg", "state", "update_cache", "cache_valid_time", "upgrade", "deb", "autoremove", "autoclean", "purge", "force", "install_recommends", "default_release", "dpkg_options", "only_upgrade", "allow_unauthenticated", "allow_downgrade", "allow_change_held_packages", "lock_timeout", "fail_on_autoremove", "force_apt_get", "policy_rc_d", "update_cache_retries", "update_cache_retry_max_delay", "clean"}},
+	"dnf":                 {Allowed: []string{"name", "pkg", "state", "list", "enablerepo", "disablerepo", "conf_file", "disable_gpg_check", "installroot", "releasever", "autoremove", "exclude", "skip_broken", "update_cache", "update_only", "security", "bugfix", "enable_plugin", "disable_plugin", "disable_excludes", "validate_certs", "allow_downgrade", "install_repoquery", "download_only", "download_dir", "lock_timeout", "install_weak_deps", "cacheonly", "nobest", "best", "sslverify", "use_backend"}},
+	"yum":                 {Allowed: []string{"name", "pkg", "state", "list", "enablerepo", "disablerepo", "conf_file", "disable_gpg_check", "installroot", "releasever", "autoremove", "exclude", "skip_broken", "update_cache", "update_only", "security", "bugfix", "validate_certs", "allow_downgrade", "download_only", "download_dir", "lock_timeout", "install_weak_deps", "cacheonly", "use_backend"}},
+	"user":                {Required: []string{"name"}, Allowed: []string{"state", "uid", "group", "groups", "append", "shell", "home", "create_home", "move_home", "system", "password", "update_password", "comment", "expires", "remove", "force", "generate_ssh_key", "ssh_key_bits", "ssh_key_file", "ssh_key_type", "ssh_key_comment", "ssh_key_passphrase", "non_unique", "password_lock", "local", "skeleton", "umask", "seuser", "profile", "authorization", "role", "login_class", "hidden", "password_expire_max", "password_expire_min", "password_expire_warn"}},
+	"group":               {Required: []string{"name"}, Allowed: []string{"state", "gid", "system", "local", "non_unique", "force", "gid_min", "gid_max"}},
+	"get_url":             {Required: []string{"url", "dest"}, Allowed: []string{"checksum", "force", "headers", "mode", "owner", "group", "timeout", "url_username", "url_password", "validate_certs", "backup", "tmp_dest", "use_proxy", "force_basic_auth", "client_cert", "client_key", "http_agent", "attributes", "decompress", "unredirected_headers", "use_gssapi", "use_netrc", "ciphers", "seuser", "serole", "setype", "selevel", "unsafe_writes"}},
+	"uri":                 {Required: []string{"url"}, Allowed: []string{"method", "body", "body_format", "headers", "status_code", "return_content", "timeout", "dest", "creates", "removes", "follow_redirects", "force_basic_auth", "url_username", "url_password", "validate_certs", "client_cert", "client_key", "src", "remote_src", "force", "use_proxy", "unix_socket", "http_agent", "use_gssapi", "use_netrc", "ca_path", "ciphers", "decompress", "unredirected_headers", "mode", "owner", "group", "attributes", "seuser", "serole", "setype", "selevel", "unsafe_writes"}},
+	"unarchive":           {Required: []string{"src", "dest"}, Allowed: []string{"remote_src", "creates", "owner", "group", "mode", "extra_opts", "exclude", "include", "keep_newer", "list_files", "io_buffer_size", "decrypt", "validate_certs", "copy", "attributes", "seuser", "serole", "setype", "selevel", "unsafe_writes"}},
+	"git":                 {Required: []string{"repo", "dest"}, Allowed: []string{"version", "force", "depth", "clone", "update", "accept_hostkey", "accept_newhostkey", "key_file", "ssh_opts", "bare", "recursive", "track_submodules", "remote", "refspec", "reference", "umask", "verify_commit", "gpg_whitelist", "gpg_allowlist", "archive", "archive_prefix", "separate_git_dir", "single_branch", "executable"}},
+	"wait_for":            {Allowed: []string{"host", "port", "path", "state", "timeout", "delay", "connect_timeout", "sleep", "search_regex", "exclude_hosts", "active_connection_states", "msg"}},
+	"pause":               {Allowed: []string{"minutes", "seconds", "prompt", "echo"}},
+	"setup":               {Allowed: []string{"gather_subset", "gather_timeout", "filter", "fact_path"}},
+	"ping":                {Allowed: []string{"data"}},
+	"fetch":               {Required: []string{"src", "dest"}, Allowed: []string{"flat", "fail_on_missing", "validate_checksum"}},
+	"find":                {Required: []string{"paths"}, Allowed: []string{"patterns", "excludes", "contains", "read_whole_file", "file_type", "age", "age_stamp", "size", "recurse", "hidden", "follow", "get_checksum", "checksum_algorithm", "use_regex", "depth", "encoding", "limit", "mode", "exact_mode"}},
+	"cron":                {Allowed: []string{"name", "minute", "hour", "day", "month", "weekday", "job", "state", "user", "special_time", "disabled", "backup", "cron_file", "env", "insertafter", "insertbefore"}},
+	"add_host":            {Required: []string{"name"}, Allowed: []string{"groups"}},
+	"group_by":            {Required: []string{"key"}, Allowed: []string{"parents"}},
+	"wait_for_connection": {Allowed: []string{"connect_timeout", "delay", "sleep", "timeout"}},
+}
+
+// All module names shipped in ansible.builtin. A non fully qualified module name outside this list can not be resolved.
+var builtinModules = sliceToSet([]string{
+	"add_host", "apt", "apt_key", "apt_repository", "assemble", "assert", "async_status", "blockinfile", "command",
+	"copy", "cron", "deb822_repository", "debconf", "debug", "dnf", "dnf5", "dpkg_selections", "expect", "fail",
+	"fetch", "file", "find", "gather_facts", "get_url", "getent", "git", "group", "group_by", "hostname",
+	"import_playbook", "import_role", "import_tasks", "include_role", "include_tasks", "include_vars", "iptables",
+	"known_hosts", "lineinfile", "meta", "mount_facts", "package", "package_facts", "pause", "ping", "pip", "raw",
+	"reboot", "replace", "rpm_key", "script", "service", "service_facts", "set_fact", "set_stats", "setup", "shell",
+	"slurp", "stat", "subversion", "systemd", "systemd_service", "sysvinit", "tempfile", "template", "unarchive",
+	"uri", "user", "validate_argument_spec", "wait_for", "wait_for_connection", "yum", "yum_repository",
+})
+
+// sliceToSet convert a list of strings into a set for fast lookup
+func sliceToSet(items []string) map[string]struct{} {
+	o := make(map[string]struct{}, len(items))
+	for _, i := range items {
+		o[i] = struct{}{}
+	}
+	return o
+}
+
+// DeprecatedKeywords are the keywords removed from ansible and what to use instead. The syntax checker reports them
+// as invalid; lint reports them as deprecated syntax.
+var DeprecatedKeywords = map[string]string{
+	"sudo":      "use 'become' instead of 'sudo'",
+	"sudo_user": "use 'become_user' instead of 'sudo_user'",
+	"su":        "use 'become' with 'become_method: su' instead of 'su'",
+	"su_user":   "use 'become_user' instead of 'su_user'",
+	"include":   "use 'include_tasks' or 'import_tasks' instead of 'include'",
+}
+
+// playbookChecker hold the state of one syntax check run. Files already checked are tracked to avoid include loops
+type playbookChecker struct {
+	errors    []PlaybookError
+	visited   map[string]struct{}
+	extraVars map[string]any
+	// Vars of the current play, used to render templated file paths
+	vars map[string]any
+	// Optional hooks called for every task and every vars mapping met during the walk, see PlaybookWalk
+	taskHook func(file string, task *yaml.Node)
+	varsHook func(file string, vars *yaml.Node)
+}
+
+// renderPath render a templated path using the current play vars. ok is false if it can not be resolved statically
+func (c *playbookChecker) renderPath(p string) (rendered string, ok bool) {
+	if !isTemplated(p) {
+		return p, true
+	}
+	if c.vars == nil {
+		return "", false
+	}
+	tmpl, err := TemplateFromStringWithConfig(p, &CustomConfig)
+	if err != nil {
+		return "", false
+	}
+	out, err := tmpl.ExecuteToString(exec.NewContext(c.vars))
+	if err != nil || out == "" || isTemplated(out) {
+		return "", false
+	}
+	return out, true
+}
+
+// loadPlayVars resolve the vars known before running a play: playbook group_vars/all, play vars and extra vars
+func (c *playbookChecker) loadPlayVars(file string, play *yaml.Node) {
+	r := NewVarResolver()
+	if f := findYamlFile(filepath.Join(filepath.Dir(file), "group_vars", "all")); f != "" {
+		groupVars := map[string]any{}
+		if datab, err := os.ReadFile(f); err == nil && yaml.Unmarshal(datab, &groupVars) == nil {
+			r.Add(VarsPlaybookGroupVarsAll, groupVars)
+		}
+	}
+	if v := mappingValue(play, "vars"); v != nil {
+		playVars := map[string]any{}
+		if v.Decode(&playVars) == nil {
+			r.Add(VarsPlay, playVars)
+		}
+	}
+	r.Add(VarsExtra, c.extraVars)
+	c.vars, _ = r.ResolveTemplated()
+	if c.vars != nil {
+		deriveVarsFacts(c.vars)
+	}
+}
+
+func (c *playbookChecker) visitVars(file string, vars *yaml.Node) {
+	if c.varsHook != nil && vars != nil && vars.Kind == yaml.MappingNode {
+		c.varsHook(file, vars)
+	}
+}
+
+// visitVarsFile load a vars file and pass its content to the vars hook
+func (c *playbookChecker) visitVarsFile(varsFile, from string, fromNode *yaml.Node) {
+	if c.varsHook == nil {
+		return
+	}
+	if _, ok := c.visited[varsFile]; ok {
+		return
+	}
+	c.visited[varsFile] = struct{}{}
+	c.visitVars(varsFile, c.loadYamlNode(varsFile, from, fromNode))
+}
+
+func (c *playbookChecker) addErr(file string, node *yaml.Node, format string, args ...any) {
+	e := PlaybookError{File: file, Msg: fmt.Sprintf(format, args...)}
+	if node != nil {
+		e.Line, e.Column = node.Line, node.Column
+	}
+	c.errors = append(c.errors, e)
+}
+
+// addKeywordErr add an error about the removed keyword, see DeprecatedKeywords
+func (c *playbookChecker) addKeywordErr(file string, node *yaml.Node, keyword, format string, args ...any) {
+	c.addErr(file, node, format, args...)
+	c.errors[len(c.errors)-1].Keyword = keyword
+}
+
+// loadYamlNode parse a yaml file and return the document content node
+func (c *playbookChecker) loadYamlNode(file string, from string, fromNode *yaml.Node) *yaml.Node {
+	datab, err := os.ReadFile(file)
+	if err != nil {
+		c.addErr(from, fromNode, "can not read file %s - %s", file, err)
+		return nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(datab, &doc); err != nil {
+		c.addErr(file, nil, "yaml syntax error - %s", err)
+		return nil
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// PlaybookSyntaxCheck parses the playbook, resolves roles, imported playbooks and included task files and validates
+// task keywords and module arguments. No host is contacted. All errors found are returned rather than stopping at
+// the first one. An empty list means the playbook passed the check.
+// extraVars (may be nil) are used with the play vars to resolve templated file paths; paths that can not be
+// resolved statically are skipped.
+func PlaybookSyntaxCheck(playbookPath string, extraVars map[string]any) []PlaybookError {
+	c := &playbookChecker{visited: map[string]struct{}{}, extraVars: extraVars}
+	c.checkPlaybook(playbookPath, playbookPath, nil)
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		if c.errors[i].File != c.errors[j].File {
+			return c.errors[i].File < c.errors[j].File
+		}
+		return c.errors[i].Line < c.errors[j].Line
+	})
+	return c.errors
+}
+
+// PlaybookWalkOpt are the hooks of PlaybookWalk, both optional
+type PlaybookWalkOpt struct {
+	ExtraVars map[string]any
+	// TaskHook is called for every task, VarsHook for every vars mapping: the play vars, the vars files and the
+	// group_vars and host_vars next to the playbook
+	TaskHook func(file string, task *yaml.Node)
+	VarsHook func(file string, vars *yaml.Node)
+}
+
+// PlaybookWalk walks the playbook the same way PlaybookSyntaxCheck does, calling the hooks on the way, and returns
+// the syntax errors unsorted. It lets a linter check the tasks and vars without its own walker.
+func PlaybookWalk(playbookPath string, opt PlaybookWalkOpt) []PlaybookError {
+	c := &playbookChecker{visited: map[string]struct{}{}, extraVars: opt.ExtraVars, taskHook: opt.TaskHook, varsHook: opt.VarsHook}
+	c.checkPlaybook(playbookPath, playbookPath, nil)
+	if c.varsHook != nil {
+		playbookDir := filepath.Dir(playbookPath)
+		for _, d := range []string{"group_vars", "host_vars"} {
+			filepath.WalkDir(filepath.Join(playbookDir, d), func(p string, de fs.DirEntry, err error) error {
+				if err != nil || de.IsDir() {
+					return nil
+				}
+				if ext := filepath.Ext(p); ext == ".yml" || ext == ".yaml" || ext == "" {
+					c.visitVarsFile(p, playbookPath, nil)
+				}
+				return nil
+			})
+		}
+	}
+	return c.errors
+}
+
+func (c *playbookChecker) checkPlaybook(file, from string, fromNode *yaml.Node) {
+	if _, ok := c.visited[file]; ok {
+		return
+	}
+	c.visited[file] = struct{}{}
+	root := c.loadYamlNode(file, from, fromNode)
+	if root == nil {
+		return
+	}
+	if root.Kind != yaml.SequenceNode {
+		c.addErr(file, root, "a playbook must be a list of plays")
+		return
+	}
+	for _, play := range root.Content {
+		c.checkPlay(file, play)
+	}
+}
+
+func (c *playbookChecker) checkPlay(file string, play *yaml.Node) {
+	if play.Kind != yaml.MappingNode {
+		c.addErr(file, play, "a play must be a mapping")
+		return
+	}
+	baseDir := filepath.Dir(file)
+	c.loadPlayVars(file, play)
+	if v := mappingValue(play, "import_playbook"); v != nil {
+		if p, ok := c.renderPath(v.Value); ok {
+			c.checkPlaybook(resolveRelPath(baseDir, p), file, v)
+		}
+		return
+	}
+	if mappingValue(play, "hosts") == nil {
+		c.addErr(file, play, "play has no 'hosts' keyword")
+	}
+	for i := 0; i+1 < len(play.Content); i += 2 {
+		k, v := play.Content[i], play.Content[i+1]
+		if _, ok := playKeywords[k.Value]; !ok {
+			c.addErr(file, k, "'%s' is not a valid attribute for a Play", k.Value)
+			continue
+		}
+		switch k.Value {
+		case "tasks", "pre_tasks", "post_tasks", "handlers":
+			c.checkTaskList(file, baseDir, v)
+		case "roles":
+			c.checkRoles(file, baseDir, v)
+		case "vars_files":
+			c.checkVarsFiles(file, baseDir, v)
+		case "vars":
+			c.visitVars(file, v)
+		}
+	}
+}
+
+func (c *playbookChecker) checkVarsFiles(file, baseDir string, node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		c.addErr(file, node, "vars_files must be a list")
+		return
+	}
+	for _, item := range node.Content {
+		if item.Kind != yaml.ScalarNode { // a list of candidates is resolved at run time
+			continue
+		}
+		rendered, ok := c.renderPath(item.Value)
+		if !ok {
+			continue
+		}
+		p := resolveRelPath(baseDir, rendered)
+		if !fileExists(p) {
+			c.addErr(file, item, "vars file %s not found", item.Value)
+			continue
+		}
+		c.visitVarsFile(p, file, item)
+	}
+}
+
+func (c *playbookChecker) checkRoles(file, baseDir string, node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		c.addErr(file, node, "roles must be a list")
+		return
+	}
+	for _, item := range node.Content {
+		roleName, roleNode := "", item
+		switch item.Kind {
+		case yaml.ScalarNode:
+			roleName = item.Value
+		case yaml.MappingNode:
+			if v := mappingValue(item, "role"); v != nil {
+				roleName, roleNode = v.Value, v
+			} else if v := mappingValue(item, "name"); v != nil {
+				roleName, roleNode = v.Value, v
+			}
+		}
+		if roleName == "" {
+			c.addErr(file, item, "role entry has no role name")
+			continue
+		}
+		c.checkRole(file, baseDir, roleName, "main", roleNode)
+	}
+}
+
+// findRoleDir search the role in the roles directory next to the playbook then in the ANSIBLE_ROLES_PATH
+func findRoleDir(baseDir, roleName string) string {
+	candidates := []string{filepath.Join(baseDir, "roles", roleName), filepath.Join(baseDir, roleName)}
+	for _, p := range filepath.SplitList(os.Getenv("ANSIBLE_ROLES_PATH")) {
+		candidates = append(candidates, filepath.Join(p, roleName))
+	}
+	for _, d := range candidates {
+		if st, err := os.Stat(d); err == nil && st.IsDir() {
+			return d
+		}
+	}
+	return ""
+}
+
+func (c *playbookChecker) checkRole(file, baseDir, roleName, tasksFrom string, roleNode *yaml.Node) {
+	roleName, ok := c.renderPath(roleName)
+	if !ok || strings.Count(roleName, ".") >= 2 { // collection roles are not resolvable locally
+		return
+	}
+	roleDir := findRoleDir(baseDir, roleName)
+	if roleDir == "" {
+		c.addErr(file, roleNode, "the role '%s' was not found", roleName)
+		return
+	}
+	for _, sub := range []string{filepath.Join("tasks", tasksFrom), filepath.Join("handlers", "main")} {
+		if f := findYamlFile(filepath.Join(roleDir, sub)); f != "" {
+			c.checkTaskFile(f, file, roleNode)
+		}
+	}
+	for _, sub := range []string{"vars", "defaults"} {
+		if f := findYamlFile(filepath.Join(roleDir, sub, "main")); f != "" {
+			c.visitVarsFile(f, file, roleNode)
+		}
+	}
+}
+
+func (c *playbookChecker) checkTaskFile(taskFile, from string, fromNode *yaml.Node) {
+	if _, ok := c.visited[taskFile]; ok {
+		return
+	}
+	c.visited[taskFile] = struct{}{}
+	root := c.loadYamlNode(taskFile, from, fromNode)
+	if root == nil {
+		return
+	}
+	c.checkTaskList(taskFile, filepath.Dir(taskFile), root)
+}
+
+func (c *playbookChecker) checkTaskList(file, baseDir string, node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		c.addErr(file, node, "a task list must be a list")
+		return
+	}
+	for _, task := range node.Content {
+		c.checkTask(file, baseDir, task)
+	}
+}
+
+func (c *playbookChecker) checkTask(file, baseDir string, task *yaml.Node) {
+	if task.Kind != yaml.MappingNode {
+		c.addErr(file, task, "a task must be a mapping")
+		return
+	}
+	if c.taskHook != nil {
+		c.taskHook(file, task)
+	}
+	c.visitVars(file, mappingValue(task, "vars"))
+	modules := []*yaml.Node{}
+	isBlock := false
+	for i := 0; i+1 < len(task.Content); i += 2 {
+		k, v := task.Content[i], task.Content[i+1]
+		if _, ok := blockKeywords[k.Value]; ok {
+			isBlock = true
+			c.checkTaskList(file, baseDir, v)
+			continue
+		}
+		if _, ok := taskKeywords[k.Value]; ok || strings.HasPrefix(k.Value, "with_") {
+			continue
+		}
+		if _, ok := DeprecatedKeywords[k.Value]; ok && k.Value != "include" {
+			c.addKeywordErr(file, k, k.Value, "'%s' is not a valid attribute for a Task", k.Value)
+			continue
+		}
+		modules = append(modules, k)
+	}
+	if isBlock {
+		for _, m := range modules {
+			c.addErr(file, m, "'%s' is not a valid attribute for a Block", m.Value)
+		}
+		return
+	}
+	if len(modules) == 0 {
+		if mappingValue(task, "action") == nil && mappingValue(task, "local_action") == nil {
+			c.addErr(file, task, "no module/action detected in task")
+		}
+		return
+	}
+	if len(modules) > 1 {
+		names := []string{}
+		for _, m := range modules {
+			names = append(names, m.Value)
+		}
+		c.addErr(file, modules[1], "conflicting action statements: %s", strings.Join(names, ", "))
+		return
+	}
+	c.checkModule(file, baseDir, modules[0], mappingValue(task, modules[0].Value), mappingValue(task, "args"))
+}
+
+// checkModule validate the module name and its arguments, and follow task file and role inclusion
+func (c *playbookChecker) checkModule(file, baseDir string, keyNode, argNode, extraArgs *yaml.Node) {
+	name := keyNode.Value
+	shortName := strings.TrimPrefix(strings.TrimPrefix(name, "ansible.builtin."), "ansible.legacy.")
+	if _, ok := builtinModules[shortName]; !ok {
+		if _, ok := DeprecatedKeywords[name]; ok {
+			c.addKeywordErr(file, keyNode, name, "couldn't resolve module/action '%s'", name)
+		} else if !strings.Contains(name, ".") {
+			c.addErr(file, keyNode, "couldn't resolve module/action '%s'", name)
+		}
+		return
+	}
+	args := map[string]*yaml.Node{}
+	freeForm := ""
+	switch argNode.Kind {
+	case yaml.ScalarNode:
+		freeForm = argNode.Value
+		// 'module: key=value ...' are the arguments of the modules not taking a free form
+		if spec, ok := moduleSpecs[shortName]; ok && !spec.FreeForm && freeForm != "" {
+			if kv, err := parseKeyValueArgs(freeForm); err == nil {
+				for k, v := range kv {
+					args[k] = &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v), Line: argNode.Line, Column: argNode.Column}
+				}
+				freeForm = ""
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(argNode.Content); i += 2 {
+			args[argNode.Content[i].Value] = argNode.Content[i+1]
+		}
+	}
+	if extraArgs != nil && extraArgs.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(extraArgs.Content); i += 2 {
+			args[extraArgs.Content[i].Value] = extraArgs.Content[i+1]
+		}
+	}
+
+	switch shortName {
+	case "include_tasks", "import_tasks":
+		taskFile := freeForm
+		if v, ok := args["file"]; ok {
+			taskFile = v.Value
+		}
+		if rendered, ok := c.renderPath(taskFile); taskFile != "" && ok {
+			p := resolveRelPath(baseDir, rendered)
+			if !fileExists(p) {
+				c.addErr(file, keyNode, "task file %s not found", rendered)
+			} else {
+				c.checkTaskFile(p, file, keyNode)
+			}
+		}
+	case "include_role", "import_role":
+		if v, ok := args["name"]; ok {
+			tasksFrom := "main"
+			if tf, ok := args["tasks_from"]; ok {
+				tasksFrom = strings.TrimSuffix(strings.TrimSuffix(tf.Value, ".yml"), ".yaml")
+			}
+			c.checkRole(file, c.playbookDirOf(baseDir), v.Value, tasksFrom, v)
+		}
+	}
+
+	spec, ok := moduleSpecs[shortName]
+	if !ok {
+		return
+	}
+	if freeForm != "" {
+		if !spec.FreeForm {
+			c.addErr(file, argNode, "module '%s' does not take free form arguments", name)
+		}
+		return
+	}
+	if argNode.Kind != yaml.MappingNode && argNode.Kind != yaml.ScalarNode {
+		c.addErr(file, argNode, "arguments of module '%s' must be a mapping", name)
+		return
+	}
+	if spec.Allowed != nil || spec.Required != nil {
+		allowed := sliceToSet(append(append([]string{}, spec.Allowed...), spec.Required...))
+		for argName, argValNode := range args {
+			if _, ok := allowed[argName]; !ok {
+				c.addErr(file, argValNode, "unsupported parameter '%s' for module '%s'", argName, name)
+			}
+		}
+	}
+	for _, r := range spec.Required {
+		if _, ok := args[r]; !ok {
+			if spec.FreeForm && len(args) == 0 {
+				continue
+			}
+			c.addErr(file, keyNode, "missing required argument '%s' for module '%s'", r, name)
+		}
+	}
+}
+
+// playbookDirOf return the directory a role lookup should start from. Task files inside a role live in
+// <playbook_dir>/roles/<role>/tasks so we walk back up to the playbook dir.
+func (c *playbookChecker) playbookDirOf(dir string) string {
+	if filepath.Base(dir) == "tasks" || filepath.Base(dir) == "handlers" {
+		if rolesDir := filepath.Dir(filepath.Dir(dir)); filepath.Base(rolesDir) == "roles" {
+			return filepath.Dir(rolesDir)
+		}
+	}
+	return dir
+}
+
+// mappingValue return the value node for key in a yaml mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func isTemplated(s string) bool {
+	return strings.Contains(s, "{{") || strings.Contains(s, "{%")
+}
+
+func resolveRelPath(baseDir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(baseDir, p)
+}
+
+func fileExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+// findYamlFile return the path with .yml, .yaml or no extension whichever exists first
+func findYamlFile(pathNoExt string) string {
+	for _, ext := range []string{".yml", ".yaml", ""} {
+		if st, err := os.Stat(pathNoExt + ext); err == nil && !st.IsDir() {
+			return pathNoExt + ext
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlaybookSyntaxCheck(t *testing.T) {
+	errs := PlaybookSyntaxCheck("testdata/playbook/site.yml", nil)
+	expected := []string{
+		"'unknown_play_key' is not a valid attribute for a Play",
+		"the role 'missing_role' was not found",
+		"unsupported parameter 'dets' for module 'copy'",
+		"missing required argument 'dest' for module 'copy'",
+		"couldn't resolve module/action 'tempalte'",
+		"task file extra.yml not found",
+		"conflicting action statements: package, shell",
+	}
+	all := []string{}
+	for _, e := range errs {
+		all = append(all, e.Error())
+	}
+	joined := strings.Join(all, "\n")
+	for _, exp := range expected {
+		if !strings.Contains(joined, exp) {
+			t.Errorf("expected error '%s' not found in:\n%s", exp, joined)
+		}
+	}
+	if len(errs) != len(expected) {
+		t.Errorf("expected %d errors, got %d:\n%s", len(expected), len(errs), joined)
+	}
+}
+
+func TestPlaybookKeyValueArgs(t *testing.T) {
+	playbook := filepath.Join(t.TempDir(), "site.yml")
+	os.WriteFile(playbook, []byte(`- hosts: all
+  tasks:
+    - file: path=/tmp/x state=touch
+    - package: name=nginx state=present
+    - ansible.builtin.copy: src=a dest=/b
+    - debug: msg=hi
+    - copy: src=a
+    - file: not key value
+    - file: path=/tmp/x colour=blue
+`), 0o644)
+	all := []string{}
+	for _, e := range PlaybookSyntaxCheck(playbook, nil) {
+		all = append(all, fmt.Sprintf("%d: %s", e.Line, e.Msg))
+	}
+	expected := []string{
+		"7: missing required argument 'dest' for module 'copy'",
+		"8: module 'file' does not take free form arguments",
+		"9: unsupported parameter 'colour' for module 'file'",
+	}
+	if !reflect.DeepEqual(all, expected) {
+		t.Errorf("PlaybookSyntaxCheck = %q; expected %q", all, expected)
+	}
+}
@@ -0,0 +1,323 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+
+	gexec "github.com/nikolalohinski/gonja/v2/exec"
+)
+
+// Plugin kinds, the same as ansible. Plugins of a kind live in the '<kind>_plugins' sub directory of a plugin path,
+// eg. 'plugins/filter_plugins/to_upper.so'.
+const (
+	PluginFilter    = "filter"
+	PluginLookup    = "lookup"
+	PluginCallback  = "callback"
+	PluginInventory = "inventory"
+)
+
+var PluginKinds = []string{PluginFilter, PluginLookup, PluginCallback, PluginInventory}
+
+// Plugin status
+const (
+	PluginLoaded  = "loaded"
+	PluginFailed  = "failed"
+	PluginSkipped = "skipped"
+)
+
+// Plugin is a plugin found in a plugin path. Type is 'go' for compiled go plugins (.so) and 'exec' for executables
+// speaking the exec protocol. Reason tells why the plugin failed to load or was skipped.
+type Plugin struct {
+	Name   string
+	Kind   string
+	Type   string
+	Path   string
+	Status string
+	Reason string
+}
+
+// FilterFunc is a filter implemented outside of gonja. in is the value before the pipe, args and kwargs the filter
+// parameters, all as plain go types.
+type FilterFunc func(in any, args []any, kwargs map[string]any) (any, error)
+
+// LookupFunc is a lookup plugin, eg. lookup('env', 'HOME'). It returns a list like ansible lookups.
+type LookupFunc func(terms []any, kwargs map[string]any) ([]any, error)
+
+var (
+	lookupLock    sync.Mutex
+	lookupPlugins = map[string]LookupFunc{}
+)
+
+// PluginPaths return the plugin search paths. ANSIBLE_GO_PLUGIN_PATH (a ':' separated list) replaces the default
+// ./plugins and $HOME/.ansible-go/plugins
+func PluginPaths() []string {
+	if p := os.Getenv("ANSIBLE_GO_PLUGIN_PATH"); p != "" {
+		return filepath.SplitList(p)
+	}
+	o := []string{"plugins"}
+	if home, err := os.UserHomeDir(); err == nil {
+		o = append(o, filepath.Join(home, ".ansible-go", "plugins"))
+	}
+	return o
+}
+
+// RegisterFilter add a filter to the template environment. It is an error to replace an existing filter.
+func RegisterFilter(name string, fn FilterFunc) error {
+	return CustomEnvironment().Filters.Register(name, goFilter(name, fn))
+}
+
+// RegisterLookup add a lookup usable in templates as lookup('name', ...) and query('name', ...)
+func RegisterLookup(name string, fn LookupFunc) error {
+	lookupLock.Lock()
+	defer lookupLock.Unlock()
+	if _, ok := lookupPlugins[name]; ok {
+		return fmt.Errorf("lookup with name '%s' is already registered", name)
+	}
+	lookupPlugins[name] = fn
+	return nil
+}
+
+func getLookup(name string) (LookupFunc, bool) {
+	lookupLock.Lock()
+	defer lookupLock.Unlock()
+	fn, ok := lookupPlugins[name]
+	return fn, ok
+}
+
+func varArgsToGo(params *gexec.VarArgs) ([]any, map[string]any) {
+	args, kwargs := []any{}, map[string]any{}
+	for _, a := range params.Args {
+		args = append(args, a.ToGoSimpleType(false))
+	}
+	for k, v := range params.KwArgs {
+		kwargs[k] = v.ToGoSimpleType(false)
+	}
+	return args, kwargs
+}
+
+// runLookup is the lookup() and query() template function. lookup join the results with ',' like ansible unless
+// wantlist=True is given; query always return a list.
+func runLookup(params *gexec.VarArgs, wantList bool) *gexec.Value {
+	if len(params.Args) < 1 {
+		return gexec.AsValue(fmt.Errorf("lookup needs the plugin name as first argument"))
+	}
+	name := params.Args[0].String()
+	fn, ok := getLookup(name)
+	if !ok {
+		return gexec.AsValue(fmt.Errorf("lookup plugin '%s' not found", name))
+	}
+	args, kwargs := varArgsToGo(params)
+	if wl, ok := kwargs["wantlist"].(bool); ok {
+		wantList = wantList || wl
+		delete(kwargs, "wantlist")
+	}
+	out, err := fn(args[1:], kwargs)
+	if err != nil {
+		return gexec.AsValue(fmt.Errorf("lookup '%s' - %w", name, err))
+	}
+	if wantList {
+		return gexec.AsValue(out)
+	}
+	if len(out) == 1 {
+		return gexec.AsValue(out[0])
+	}
+	strs := make([]string, len(out))
+	for i, v := range out {
+		strs[i] = fmt.Sprint(v)
+	}
+	return gexec.AsValue(strings.Join(strs, ","))
+}
+
+func lookupFunction(_ *gexec.Evaluator, params *gexec.VarArgs) *gexec.Value {
+	return runLookup(params, false)
+}
+
+func queryFunction(_ *gexec.Evaluator, params *gexec.VarArgs) *gexec.Value {
+	return runLookup(params, true)
+}
+
+// DiscoverPlugins list the plugins found in the '<kind>_plugins' directories of the paths without loading them.
+// Missing directories are ignored.
+func DiscoverPlugins(paths []string) []Plugin {
+	o := []Plugin{}
+	for _, dir := range paths {
+		for _, kind := range PluginKinds {
+			kindDir := filepath.Join(dir, kind+"_plugins")
+			entries, err := os.ReadDir(kindDir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+					continue
+				}
+				p := Plugin{Kind: kind, Path: filepath.Join(kindDir, entry.Name())}
+				ext := filepath.Ext(entry.Name())
+				p.Name = strings.TrimSuffix(entry.Name(), ext)
+				info, err := entry.Info()
+				switch {
+				case err != nil:
+					p.Status, p.Reason = PluginFailed, err.Error()
+				case ext == ".so":
+					p.Type = "go"
+				case ext == ".py":
+					p.Status, p.Reason = PluginSkipped, "python plugins are not supported, use a go plugin or an executable"
+				case info.Mode()&0o111 != 0:
+					p.Type = "exec"
+				default:
+					p.Status, p.Reason = PluginSkipped, "not a go plugin (.so) nor an executable"
+				}
+				o = append(o, p)
+			}
+		}
+	}
+	sort.SliceStable(o, func(i, j int) bool {
+		if o[i].Kind != o[j].Kind {
+			return o[i].Kind < o[j].Kind
+		}
+		return o[i].Name < o[j].Name
+	})
+	return o
+}
+
+// LoadPlugins discover and load the plugins of the paths. Filter plugins are added to the template environment and
+// lookup plugins to lookup()/query(). The first plugin found for a name wins, the next ones are skipped. Callback and
+// inventory plugins are only checked as nothing runs playbooks here.
+//
+// A go filter plugin exports 'Filters map[string]func(any, []any, map[string]any) (any, error)', a go lookup plugin
+// exports 'Lookup func([]any, map[string]any) ([]any, error)'. An exec plugin reads a JSON request on stdin
+// ({"kind", "name", "input", "args", "kwargs"} for filters, {"kind", "name", "terms", "kwargs"} for lookups) and
+// writes {"result": ..., "failed": bool, "msg": "..."} on stdout; its file name is the filter/lookup name.
+func LoadPlugins(paths []string) []Plugin {
+	plugins := DiscoverPlugins(paths)
+	seen := map[string]string{}
+	for i := range plugins {
+		p := &plugins[i]
+		if p.Status != "" {
+			continue
+		}
+		key := p.Kind + "/" + p.Name
+		if prev, ok := seen[key]; ok {
+			p.Status, p.Reason = PluginSkipped, "shadowed by "+prev
+			continue
+		}
+		var err error
+		switch p.Type {
+		case "go":
+			err = loadGoPlugin(p)
+		case "exec":
+			err = loadExecPlugin(p)
+		}
+		if err != nil {
+			p.Status, p.Reason = PluginFailed, err.Error()
+			continue
+		}
+		seen[key] = p.Path
+		if p.Status == "" {
+			p.Status = PluginLoaded
+		}
+	}
+	return plugins
+}
+
+func loadGoPlugin(p *Plugin) error {
+	plug, err := plugin.Open(p.Path)
+	if err != nil {
+		return err
+	}
+	switch p.Kind {
+	case PluginFilter:
+		sym, err := plug.Lookup("Filters")
+		if err != nil {
+			return err
+		}
+		filters, ok := sym.(*map[string]func(any, []any, map[string]any) (any, error))
+		if !ok {
+			return fmt.Errorf("symbol Filters has type %T, expect map[string]func(any, []any, map[string]any) (any, error)", sym)
+		}
+		names := []string{}
+		for name, fn := range *filters {
+			if err := RegisterFilter(name, fn); err != nil {
+				return err
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		p.Reason = "filters: " + strings.Join(names, ", ")
+	case PluginLookup:
+		sym, err := plug.Lookup("Lookup")
+		if err != nil {
+			return err
+		}
+		fn, ok := sym.(func([]any, map[string]any) ([]any, error))
+		if !ok {
+			return fmt.Errorf("symbol Lookup has type %T, expect func([]any, map[string]any) ([]any, error)", sym)
+		}
+		return RegisterLookup(p.Name, fn)
+	default:
+		symName := strings.ToUpper(p.Kind[:1]) + p.Kind[1:]
+		if _, err := plug.Lookup(symName); err != nil {
+			return err
+		}
+		p.Status, p.Reason = PluginSkipped, p.Kind+" plugins are not used, ansible-go does not run playbooks"
+	}
+	return nil
+}
+
+func loadExecPlugin(p *Plugin) error {
+	path, name := p.Path, p.Name
+	switch p.Kind {
+	case PluginFilter:
+		return RegisterFilter(name, func(in any, args []any, kwargs map[string]any) (any, error) {
+			return callExecPlugin(path, map[string]any{"kind": PluginFilter, "name": name, "input": in, "args": args, "kwargs": kwargs})
+		})
+	case PluginLookup:
+		return RegisterLookup(name, func(terms []any, kwargs map[string]any) ([]any, error) {
+			out, err := callExecPlugin(path, map[string]any{"kind": PluginLookup, "name": name, "terms": terms, "kwargs": kwargs})
+			if err != nil {
+				return nil, err
+			}
+			if l, ok := out.([]any); ok {
+				return l, nil
+			}
+			return []any{out}, nil
+		})
+	default:
+		p.Status, p.Reason = PluginSkipped, p.Kind+" plugins are not used, ansible-go does not run playbooks"
+	}
+	return nil
+}
+
+// callExecPlugin run the plugin executable with the JSON request on stdin and decode its result
+func callExecPlugin(path string, request map[string]any) (any, error) {
+	reqb, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(reqb), &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s - %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	var resp struct {
+		Result any    `json:"result"`
+		Failed bool   `json:"failed"`
+		Msg    string `json:"msg"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("%s - invalid response '%s': %w", path, strings.TrimSpace(stdout.String()), err)
+	}
+	if resp.Failed {
+		return nil, fmt.Errorf("%s", resp.Msg)
+	}
+	return normalizeJsonNumbers(resp.Result), nil
+}
@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPlugins(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"filter_plugins/test_shout": "#!/bin/sh\ncat >/dev/null\necho '{\"result\": \"HELLO\"}'\n",
+		"lookup_plugins/test_pair":  "#!/bin/sh\ncat >/dev/null\necho '{\"result\": [\"a\", \"b\"]}'\n",
+		"lookup_plugins/legacy.py":  "# python plugin\n",
+		"callback_plugins/notify":   "#!/bin/sh\n",
+	}
+	for name, content := range files {
+		fpath := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fpath), 0o755)
+		if err := os.WriteFile(fpath, []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status := map[string]string{}
+	for _, p := range LoadPlugins([]string{dir}) {
+		status[p.Kind+"/"+p.Name] = p.Status
+	}
+	expected := map[string]string{
+		"filter/test_shout": PluginLoaded,
+		"lookup/test_pair":  PluginLoaded,
+		"lookup/legacy":     PluginSkipped,
+		"callback/notify":   PluginSkipped,
+	}
+	for k, v := range expected {
+		if status[k] != v {
+			t.Errorf("plugin %s: expect status %s, got '%s'", k, v, status[k])
+		}
+	}
+
+	out := TemplateString("{{ 'x' | test_shout }} {{ lookup('test_pair') }} {{ query('test_pair') | length }}", map[string]any{})
+	if out != "HELLO a,b 2" {
+		t.Errorf("unexpected template output '%s'", out)
+	}
+}
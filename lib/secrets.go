@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Secret values registered with RegisterSecret, eg. the lookup passwords and the decrypted vault values.
+// MaskCredential replaces them with ***** so they never show up in logs or outputs.
+var (
+	registeredSecrets   = map[string]struct{}{}
+	registeredSecretsMu sync.RWMutex
+)
+
+// MinSecretLength is the length under which a decrypted vault value is not registered as a secret, like the scanner
+// drops the short values; masking values like "yes" or "80" would mangle the logs
+var MinSecretLength = 6
+
+// RegisterSecret add a value to the list of secrets masked by MaskCredential. Empty values are ignored.
+func RegisterSecret(value string) {
+	if value == "" {
+		return
+	}
+	registeredSecretsMu.Lock()
+	defer registeredSecretsMu.Unlock()
+	registeredSecrets[value] = struct{}{}
+}
+
+// maskRegisteredSecrets replace the registered secrets, the longest first so a secret containing another one is
+// masked whole
+func maskRegisteredSecrets(input string) string {
+	registeredSecretsMu.RLock()
+	secrets := make([]string, 0, len(registeredSecrets))
+	for s := range registeredSecrets {
+		secrets = append(secrets, s)
+	}
+	registeredSecretsMu.RUnlock()
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, s := range secrets {
+		input = strings.ReplaceAll(input, s, "*****")
+	}
+	return input
+}
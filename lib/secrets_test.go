@@ -0,0 +1,18 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestRegisterSecret(t *testing.T) {
+	RegisterSecret("fromEnv456")
+	if out := MaskCredential("ssh login with fromEnv456 ok"); out != "ssh login with ***** ok" {
+		t.Errorf("secret not masked: %s", out)
+	}
+	RegisterSecret("fromEnv456-suffix")
+	for i := 0; i < 10; i++ {
+		if out := MaskCredential("token fromEnv456-suffix"); out != "token *****" {
+			t.Fatalf("a secret containing another must be masked whole, got %s", out)
+		}
+	}
+}
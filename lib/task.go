@@ -0,0 +1,97 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// TaskResult is the outcome of a task with the same fields ansible registers for command like modules
+type TaskResult struct {
+	Changed     bool     `json:"changed"`
+	Failed      bool     `json:"failed"`
+	Rc          int      `json:"rc"`
+	Cmd         string   `json:"cmd"`
+	Stdout      string   `json:"stdout"`
+	Stderr      string   `json:"stderr"`
+	StdoutLines []string `json:"stdout_lines"`
+	StderrLines []string `json:"stderr_lines"`
+	Msg         string   `json:"msg"`
+}
+
+// ToMap convert the result to a map so it can be used in templates and conditions, eg 'result.rc != 0'
+func (r TaskResult) ToMap() map[string]any {
+	return map[string]any{
+		"changed":      r.Changed,
+		"failed":       r.Failed,
+		"rc":           r.Rc,
+		"cmd":          r.Cmd,
+		"stdout":       r.Stdout,
+		"stderr":       r.Stderr,
+		"stdout_lines": r.StdoutLines,
+		"stderr_lines": r.StderrLines,
+		"msg":          r.Msg,
+	}
+}
+
+// RunCommandTask run a command with 'bash -c' and return the result like the ansible shell module: changed is
+// always true and failed is true if the return code is not 0. Use ApplyChangedFailedWhen to refine them.
+func RunCommandTask(cmd string) TaskResult {
+	var stdout, stderr bytes.Buffer
+	command := exec.Command("bash", "-c", cmd)
+	command.Stdout, command.Stderr = &stdout, &stderr
+	err := command.Run()
+	r := TaskResult{Changed: true, Cmd: cmd, Stdout: strings.TrimSuffix(stdout.String(), "\n"), Stderr: strings.TrimSuffix(stderr.String(), "\n")}
+	r.StdoutLines, r.StderrLines = splitResultLines(r.Stdout), splitResultLines(r.Stderr)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			r.Rc = exitErr.ExitCode()
+			r.Msg = "non-zero return code"
+		} else {
+			r.Rc = -1
+			r.Msg = err.Error()
+		}
+		r.Failed = true
+	}
+	return r
+}
+
+func splitResultLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
+
+// ApplyChangedFailedWhen evaluate changed_when and failed_when conditions against the result, the same way ansible
+// does. The result is available in the conditions under registerName (eg 'result') together with vars. A list of
+// conditions is AND-ed. An empty list leaves the field untouched. If a condition can not be evaluated the task is
+// marked failed with the error in Msg and the error is returned.
+func (r *TaskResult) ApplyChangedFailedWhen(changedWhen, failedWhen []string, registerName string, vars map[string]any) error {
+	ctx := make(map[string]any, len(vars)+1)
+	for k, v := range vars {
+		ctx[k] = v
+	}
+	if registerName != "" {
+		ctx[registerName] = r.ToMap()
+	}
+	if len(changedWhen) > 0 {
+		changed, err := EvalConditions(changedWhen, ctx)
+		if err != nil {
+			r.Failed, r.Msg = true, err.Error()
+			return err
+		}
+		r.Changed = changed
+	}
+	if len(failedWhen) > 0 {
+		failed, err := EvalConditions(failedWhen, ctx)
+		if err != nil {
+			r.Failed, r.Msg = true, err.Error()
+			return err
+		}
+		r.Failed = failed
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package lib
+
+import "testing"
+
+func TestApplyChangedFailedWhen(t *testing.T) {
+	r := RunCommandTask("echo already installed; echo warn >&2; exit 3")
+	if !r.Failed || r.Rc != 3 || r.Stdout != "already installed" || len(r.StderrLines) != 1 {
+		t.Fatalf("unexpected command result: %+v", r)
+	}
+	err := r.ApplyChangedFailedWhen(
+		[]string{"'already' not in result.stdout"},
+		[]string{"result.rc != 0", "result.rc != expected_rc"},
+		"result", map[string]any{"expected_rc": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Changed || r.Failed {
+		t.Errorf("expected not changed and not failed: %+v", r)
+	}
+
+	r = RunCommandTask("true")
+	if err := r.ApplyChangedFailedWhen([]string{"false"}, nil, "result", nil); err != nil || r.Changed || r.Failed {
+		t.Errorf("changed_when false not applied: %+v %v", r, err)
+	}
+	if err := r.ApplyChangedFailedWhen(nil, []string{"result.rc >"}, "result", nil); err == nil || !r.Failed {
+		t.Errorf("expected an evaluation error to fail the task: %+v", r)
+	}
+}
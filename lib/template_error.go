@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2/config"
+)
+
+// TemplateOpt set how TemplateStringWithOpt and TemplateFileWithOpt render a template
+type TemplateOpt struct {
+	// Strict make an undefined variable an error instead of an empty string, like the jinja2 StrictUndefined. The
+	// default filter and the 'is defined' test still accept undefined variables.
+	Strict bool
+	// Name of the template in the errors, the src file for TemplateFileWithOpt
+	Name string
+	// DefaultHint add to the undefined variable errors a hint to use the default filter
+	DefaultHint bool
+}
+
+// TemplateError is a template failing to parse or render. It tells where: the template name, the line and column
+// (0 when gonja does not say) and the expression, eg '{{ db_user }}'. Undefined is the undefined variable in
+// strict mode, empty for the other errors.
+type TemplateError struct {
+	Name      string
+	Line      int
+	Column    int
+	Expr      string
+	Undefined string
+	Hint      string
+	Err       error
+}
+
+func (e *TemplateError) Error() string {
+	loc := e.Name
+	if e.Line > 0 {
+		loc += fmt.Sprintf(":%d", e.Line)
+		if e.Column > 0 {
+			loc += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+	msg := loc + ": " + e.reason()
+	if e.Expr != "" {
+		msg += " in '" + e.Expr + "'"
+	}
+	if e.Hint != "" {
+		msg += " - " + e.Hint
+	}
+	return msg
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	tmplUndefinedNamePtn = regexp.MustCompile(`Unable to evaluate name "([^"]+)"`)
+	tmplUndefinedAttrPtn = regexp.MustCompile(`(?i)unable to evaluate ([\w.\[\]'"]+): (?:attribute|item) '?[^' ]+'? not found`)
+	tmplExecLinePtn      = regexp.MustCompile(`at line (\d+)`)
+	tmplParseErrPtn      = regexp.MustCompile(`(?s)^failed to parse template '.*': (.*) \(Line: (\d+) Col: (\d+), near .*\)$`)
+)
+
+// reason is the gonja error without its wrapping, which repeats the template and the nodes
+func (e *TemplateError) reason() string {
+	if e.Undefined != "" {
+		return fmt.Sprintf("'%s' is undefined", e.Undefined)
+	}
+	msg := e.Err.Error()
+	if m := tmplParseErrPtn.FindStringSubmatch(msg); m != nil {
+		return strings.TrimSuffix(m[1], ".")
+	}
+	if idx := strings.LastIndex(msg, ": "); idx >= 0 {
+		return msg[idx+2:]
+	}
+	return msg
+}
+
+// newTemplateError locate the gonja error err in the template src. lineOffset is the number of lines removed at the
+// top of the template before parsing it, eg. the '#jinja2:' config line.
+func newTemplateError(opt TemplateOpt, src string, lineOffset int, cfg *config.Config, err error) *TemplateError {
+	e := &TemplateError{Name: opt.Name, Err: err}
+	if e.Name == "" {
+		e.Name = "template"
+	}
+	msg := err.Error()
+	if m := tmplUndefinedNamePtn.FindStringSubmatch(msg); m != nil {
+		e.Undefined = m[1]
+	} else if m := tmplUndefinedAttrPtn.FindStringSubmatch(msg); m != nil {
+		e.Undefined = m[1]
+	}
+	if m := tmplParseErrPtn.FindStringSubmatch(msg); m != nil {
+		e.Line, _ = strconv.Atoi(m[2])
+		e.Column, _ = strconv.Atoi(m[3])
+	} else if m := tmplExecLinePtn.FindStringSubmatch(msg); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+	}
+	if e.Line > 0 {
+		e.locateExpr(src, cfg)
+		e.Line += lineOffset
+	}
+	if e.Undefined != "" && opt.DefaultHint {
+		e.Hint = fmt.Sprintf("use '%s | default(...)' if it may be undefined", e.Undefined)
+	}
+	return e
+}
+
+// locateExpr find the tag of the template at e.Line, the one using the undefined variable if there are several,
+// and set the expression and column
+func (e *TemplateError) locateExpr(src string, cfg *config.Config) {
+	q := regexp.QuoteMeta
+	tagPtn := regexp.MustCompile(`(?s)` + q(cfg.VariableStartString) + `.*?` + q(cfg.VariableEndString) + `|` +
+		q(cfg.BlockStartString) + `.*?` + q(cfg.BlockEndString))
+	var namePtn *regexp.Regexp
+	if e.Undefined != "" {
+		namePtn = regexp.MustCompile(`(?:^|[^\w.])(` + q(e.Undefined) + `)\b`)
+	}
+	parseCol, found := e.Column, false
+	for _, loc := range tagPtn.FindAllStringIndex(src, -1) {
+		startLine := strings.Count(src[:loc[0]], "\n") + 1
+		endLine := startLine + strings.Count(src[loc[0]:loc[1]], "\n")
+		if startLine > e.Line {
+			break
+		}
+		if endLine < e.Line {
+			continue
+		}
+		// a parse error column points into the tag failing
+		lineStart := strings.LastIndex(src[:loc[0]], "\n") + 1
+		if parseCol > 0 && startLine == e.Line && (loc[0]-lineStart+1 > parseCol || loc[1]-lineStart < parseCol-1) {
+			continue
+		}
+		tag := src[loc[0]:loc[1]]
+		if namePtn != nil {
+			if m := namePtn.FindStringSubmatchIndex(tag); m != nil {
+				pos := loc[0] + m[2]
+				e.Expr = tag
+				e.Line = strings.Count(src[:pos], "\n") + 1
+				e.Column = pos - strings.LastIndex(src[:pos], "\n")
+				return
+			}
+		}
+		if !found {
+			e.Expr, found = tag, true
+			if e.Column == 0 {
+				e.Column = loc[0] - lineStart + 1
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateStrict(t *testing.T) {
+	vars := map[string]any{"app": map[string]any{"name": "web"}}
+	src := "name: {{ app.name }}\nuser: {{ 'x' }} {{ db_user | upper }}\n"
+	if out, err := TemplateStringWithOpt(src, vars, TemplateOpt{}); err != nil || out != "name: web\nuser: x \n" {
+		t.Errorf("permissive mode must render undefined as empty, got %q %v", out, err)
+	}
+	_, err := TemplateStringWithOpt(src, vars, TemplateOpt{Strict: true, Name: "app.j2", DefaultHint: true})
+	var te *TemplateError
+	if !errors.As(err, &te) {
+		t.Fatalf("expect a TemplateError, got %v", err)
+	}
+	if te.Line != 2 || te.Column != 20 || te.Undefined != "db_user" || te.Expr != "{{ db_user | upper }}" {
+		t.Errorf("unexpected error location %+v", te)
+	}
+	expected := "app.j2:2:20: 'db_user' is undefined in '{{ db_user | upper }}' - use 'db_user | default(...)' if it may be undefined"
+	if te.Error() != expected {
+		t.Errorf("unexpected error message %q", te.Error())
+	}
+
+	cases := map[string]string{
+		"{{ missing | default('d') }} {{ missing is defined }} {{ app.missing is defined }}": "d False False",
+		"{% if app.name is defined %}{{ app.name }}{% endif %}":                              "web",
+	}
+	for tpl, expected := range cases {
+		if out, err := TemplateStringWithOpt(tpl, vars, TemplateOpt{Strict: true}); err != nil || out != expected {
+			t.Errorf("TemplateStringWithOpt(%s) = %q, %v; expected %q", tpl, out, err, expected)
+		}
+	}
+	errCases := map[string]string{
+		"{{ app.port }}": "template:1:4: 'app.port' is undefined in '{{ app.port }}'",
+		"line1\n{% for i in items %}{% endfor %}":                               "template:2:13: 'items' is undefined in '{% for i in items %}'",
+		"#jinja2:variable_start_string:'[[',variable_end_string:']]'\n[[ zz ]]": "template:2:4: 'zz' is undefined in '[[ zz ]]'",
+		"a\n  {{ x + }}": "template:2:10: expected either a number, string, keyword or identifier in '{{ x + }}'",
+	}
+	for tpl, expected := range errCases {
+		if _, err := TemplateStringWithOpt(tpl, vars, TemplateOpt{Strict: true}); err == nil || err.Error() != expected {
+			t.Errorf("TemplateStringWithOpt(%q) error = %v; expected %q", tpl, err, expected)
+		}
+	}
+
+	dir := t.TempDir()
+	srcFile, dest := filepath.Join(dir, "conf.j2"), filepath.Join(dir, "conf")
+	os.WriteFile(srcFile, []byte("port={{ port }}\n"), 0o644)
+	if err := TemplateFileWithOpt(srcFile, dest, vars, 0o644, TemplateOpt{Strict: true}); err == nil || !strings.HasPrefix(err.Error(), srcFile+":1:9: 'port' is undefined") {
+		t.Errorf("expect the template file name in the error, got %v", err)
+	}
+	if err := TemplateFileWithOpt(srcFile, dest, map[string]any{"port": 80}, 0o644, TemplateOpt{Strict: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "port=80\n" {
+		t.Errorf("unexpected output '%s'", data)
+	}
+}
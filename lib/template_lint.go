@@ -0,0 +1,291 @@
+package lib
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// TemplateLintResult is the report of TemplateLint for one template
+type TemplateLintResult struct {
+	File string
+	// Variables used by the template, with the attribute path when it is static, eg 'app.port'. The variables the
+	// template defines (set, for, macro, import ...) and the environment globals like lookup are not listed.
+	Variables []string
+	Filters   []string
+	// Missing are the variables not in the vars. The ones tested with 'is defined' or given a default are not missing.
+	Missing []string
+	// Errors are the syntax error, else an error per use of a missing variable or of an unknown filter
+	Errors []*TemplateError
+}
+
+// templateLintRef is a variable used by a template
+type templateLintRef struct {
+	path, root string
+	line, col  int
+}
+
+// templateLintKeywords are the names of the jinja2 syntax, not variables
+var templateLintKeywords = sliceToSet([]string{"if", "else", "elif", "true", "false", "none", "True", "False", "None",
+	"recursive", "as", "import", "context", "with", "without", "ignore", "missing", "loop", "self", "caller",
+	"varargs", "kwargs"})
+
+// TemplateLint check a template file without rendering it: it reports the syntax error, lists the variables and
+// filters it uses and the variables missing from vars. Run it over a roles directory with TemplateLintDir as a
+// check before deploying.
+func TemplateLint(file string, vars map[string]any) (*TemplateLintResult, error) {
+	contentb, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return TemplateLintString(file, strings.ReplaceAll(string(contentb), "\r\n", "\n"), vars), nil
+}
+
+// TemplateLintString is TemplateLint for a template text, name is used in the errors
+func TemplateLintString(name, src string, vars map[string]any) *TemplateLintResult {
+	res := &TemplateLintResult{File: name, Variables: []string{}, Filters: []string{}, Missing: []string{}, Errors: []*TemplateError{}}
+	_, newSrc, cfg := inspectTemplateString(src)
+	lineOffset := 1
+	if newSrc == "" {
+		newSrc, lineOffset = src, 0
+	}
+	if _, err := TemplateFromStringWithConfig(newSrc, cfg); err != nil {
+		res.Errors = append(res.Errors, newTemplateError(TemplateOpt{Name: name}, newSrc, lineOffset, cfg, err))
+		return res
+	}
+	refs, filters, locals, guarded := scanTemplateTokens(newSrc, cfg)
+	env := CustomEnvironment()
+
+	seenFilter := map[string]bool{}
+	for _, f := range filters {
+		if !seenFilter[f.path] {
+			seenFilter[f.path] = true
+			res.Filters = append(res.Filters, f.path)
+		}
+		if !env.Filters.Exists(f.path) {
+			res.Errors = append(res.Errors, &TemplateError{Name: name, Line: f.line + lineOffset, Column: f.col,
+				Err: fmt.Errorf("filter '%s' not found", f.path)})
+		}
+	}
+	seenVar, seenMissing := map[string]bool{}, map[string]bool{}
+	for _, ref := range refs {
+		if _, ok := locals[ref.root]; ok || env.Context.Has(ref.root) {
+			continue
+		}
+		if !seenVar[ref.path] {
+			seenVar[ref.path] = true
+			res.Variables = append(res.Variables, ref.path)
+		}
+		if templateLintGuarded(ref.path, guarded) {
+			continue
+		}
+		if _, ok := vars[ref.root]; ok && (ref.path == ref.root || validateAKeyWithDotInAmap(ref.path, vars)) {
+			continue
+		}
+		if !seenMissing[ref.path] {
+			seenMissing[ref.path] = true
+			res.Missing = append(res.Missing, ref.path)
+		}
+		res.Errors = append(res.Errors, &TemplateError{Name: name, Line: ref.line + lineOffset, Column: ref.col,
+			Undefined: ref.path, Err: fmt.Errorf("'%s' is undefined", ref.path)})
+	}
+	sort.Strings(res.Variables)
+	sort.Strings(res.Filters)
+	sort.Strings(res.Missing)
+	return res
+}
+
+// templateLintGuarded tell if the variable path, or one of its parents, is tested or has a default
+func templateLintGuarded(path string, guarded map[string]struct{}) bool {
+	for p := path; p != ""; {
+		if _, ok := guarded[p]; ok {
+			return true
+		}
+		idx := strings.LastIndex(p, ".")
+		if idx < 0 {
+			break
+		}
+		p = p[:idx]
+	}
+	return false
+}
+
+// scanTemplateTokens go through the tokens of the template tags and return the variables used, the filters used,
+// the names the template defines and the variables tested with 'is defined' or given a default
+func scanTemplateTokens(src string, cfg *config.Config) (refs, filters []templateLintRef, locals, guarded map[string]struct{}) {
+	locals, guarded = map[string]struct{}{}, map[string]struct{}{}
+	toks := []*tokens.Token{}
+	stream := tokens.Lex(src, cfg)
+	for !stream.End() {
+		toks = append(toks, stream.Next())
+	}
+	tok := func(i int) *tokens.Token {
+		if i < 0 || i >= len(toks) {
+			return &tokens.Token{Type: tokens.EOF}
+		}
+		return toks[i]
+	}
+	statement, depth := "", 0
+	lastRef, lastRefEnd := -1, -1
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch t.Type {
+		case tokens.BlockBegin:
+			statement, depth = "", 0
+			if tok(i+1).Type == tokens.Name {
+				statement = tok(i + 1).Val
+				i++
+			}
+			continue
+		case tokens.BlockEnd, tokens.VariableEnd:
+			statement = ""
+			continue
+		case tokens.LeftParenthesis, tokens.LeftBracket, tokens.LeftBrace:
+			depth++
+			continue
+		case tokens.RightParenthesis, tokens.RightBracket, tokens.RightBrace:
+			depth--
+			continue
+		case tokens.Name:
+		default:
+			continue
+		}
+		prev, next := tok(i-1), tok(i+1)
+		switch {
+		case prev.Type == tokens.Pipe:
+			filters = append(filters, templateLintRef{path: t.Val, line: t.Line, col: t.Col})
+			if (t.Val == "default" || t.Val == "d") && lastRef >= 0 && lastRefEnd == i-2 {
+				guarded[refs[lastRef].path] = struct{}{}
+			}
+			continue
+		case prev.Type == tokens.Is || (prev.Type == tokens.Not && tok(i-2).Type == tokens.Is):
+			isAt := i - 1
+			if prev.Type == tokens.Not {
+				isAt--
+			}
+			if (t.Val == "defined" || t.Val == "undefined" || t.Val == "none") && lastRef >= 0 && lastRefEnd == isAt-1 {
+				guarded[refs[lastRef].path] = struct{}{}
+			}
+			continue
+		case statement == "filter" && depth == 0:
+			filters = append(filters, templateLintRef{path: t.Val, line: t.Line, col: t.Col})
+			continue
+		case statement == "block" || statement == "endblock":
+			continue
+		case statement == "macro" && (prev.Type == tokens.Name && prev.Val == "macro" ||
+			depth == 1 && (prev.Type == tokens.LeftParenthesis || prev.Type == tokens.Comma)):
+			// the macro name and its parameters, the defaults are values
+			locals[t.Val] = struct{}{}
+			continue
+		case next.Type == tokens.Assign && (depth > 0 || statement == "with"):
+			// a keyword argument of a call, or a 'with' variable
+			if statement == "with" && depth == 0 {
+				locals[t.Val] = struct{}{}
+			}
+			continue
+		case statement == "set" && depth == 0 && !templateLintAfterAssign(toks, i):
+			locals[t.Val] = struct{}{}
+			continue
+		case statement == "for" && depth == 0 && !templateLintAfterIn(toks, i):
+			locals[t.Val] = struct{}{}
+			continue
+		case statement == "import" || statement == "from":
+			if prev.Type == tokens.Name && prev.Val == "as" || statement == "from" && templateLintAfterImport(toks, i) {
+				locals[t.Val] = struct{}{}
+			}
+			continue
+		}
+		if _, ok := templateLintKeywords[t.Val]; ok || prev.Type == tokens.Dot {
+			continue
+		}
+		ref, end := templateLintPath(toks, i)
+		refs = append(refs, ref)
+		lastRef, lastRefEnd = len(refs)-1, end
+		i = end
+	}
+	return refs, filters, locals, guarded
+}
+
+// templateLintPath read the variable starting at the token i with its static attributes, eg 'app.db["port"]' is
+// app.db.port. It returns the variable and the index of its last token.
+func templateLintPath(toks []*tokens.Token, i int) (templateLintRef, int) {
+	t := toks[i]
+	ref := templateLintRef{path: t.Val, root: t.Val, line: t.Line, col: t.Col}
+	end := i
+	for {
+		switch {
+		case end+2 < len(toks) && toks[end+1].Type == tokens.Dot && toks[end+2].Type == tokens.Name:
+			// a method call like dict.items() is not an attribute
+			if end+3 < len(toks) && toks[end+3].Type == tokens.LeftParenthesis {
+				return ref, end
+			}
+			ref.path += "." + toks[end+2].Val
+			end += 2
+		case end+3 < len(toks) && toks[end+1].Type == tokens.LeftBracket && toks[end+2].Type == tokens.String &&
+			toks[end+3].Type == tokens.RightBracket:
+			ref.path += "." + toks[end+2].Val
+			end += 3
+		default:
+			return ref, end
+		}
+	}
+}
+
+// templateLintAfterAssign tell if the token i of a set statement is in the value, after '='
+func templateLintAfterAssign(toks []*tokens.Token, i int) bool {
+	for j := i - 1; j >= 0 && toks[j].Type != tokens.BlockBegin; j-- {
+		if toks[j].Type == tokens.Assign {
+			return true
+		}
+	}
+	return false
+}
+
+// templateLintAfterIn tell if the token i of a for statement is in the iterated value, after 'in'
+func templateLintAfterIn(toks []*tokens.Token, i int) bool {
+	for j := i - 1; j >= 0 && toks[j].Type != tokens.BlockBegin; j-- {
+		if toks[j].Type == tokens.In {
+			return true
+		}
+	}
+	return false
+}
+
+// templateLintAfterImport tell if the token i of a from statement is a name imported, after 'import'
+func templateLintAfterImport(toks []*tokens.Token, i int) bool {
+	for j := i - 1; j >= 0 && toks[j].Type != tokens.BlockBegin; j-- {
+		if toks[j].Type == tokens.Name && toks[j].Val == "import" {
+			return true
+		}
+	}
+	return false
+}
+
+// TemplateLintDir run TemplateLint on the templates of a directory tree, eg. a roles directory: the files in a
+// templates directory and the files ending with .j2, .jinja or .jinja2. The results are in the walk order.
+func TemplateLintDir(dir string, vars map[string]any) ([]*TemplateLintResult, error) {
+	o := []*TemplateLintResult{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		ext := filepath.Ext(path)
+		inTemplates := strings.Contains(filepath.ToSlash(path), "/templates/") || strings.HasPrefix(filepath.ToSlash(path), "templates/")
+		if !inTemplates && ext != ".j2" && ext != ".jinja" && ext != ".jinja2" {
+			return nil
+		}
+		res, err := TemplateLint(path, vars)
+		if err != nil {
+			return err
+		}
+		o = append(o, res)
+		return nil
+	})
+	return o, err
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTemplateLint(t *testing.T) {
+	src := `{% set prefix = app.name | upper %}
+{% for user in users if user.active %}
+{{ prefix }}-{{ user.name }}-{{ loop.index }}: {{ db["host"] }}:{{ db.port | default(5432) }}
+{% endfor %}
+{% macro row(key, value='-') %}{{ key }}={{ value | no_such_filter }}{% endmacro %}
+{% if proxy is defined %}proxy={{ proxy.url }}{% endif %}
+{{ row('env', lookup('env', 'HOME')) }} {{ region }} {{ app.port }}
+`
+	vars := map[string]any{"app": map[string]any{"name": "web"}, "users": []any{}, "db": map[string]any{"host": "h"}}
+	res := TemplateLintString("app.j2", src, vars)
+	if expected := []string{"app.name", "app.port", "db.host", "db.port", "proxy", "proxy.url", "region", "users"}; !reflect.DeepEqual(res.Variables, expected) {
+		t.Errorf("Variables = %v; expected %v", res.Variables, expected)
+	}
+	if expected := []string{"default", "no_such_filter", "upper"}; !reflect.DeepEqual(res.Filters, expected) {
+		t.Errorf("Filters = %v; expected %v", res.Filters, expected)
+	}
+	if expected := []string{"app.port", "region"}; !reflect.DeepEqual(res.Missing, expected) {
+		t.Errorf("Missing = %v; expected %v", res.Missing, expected)
+	}
+	errs := []string{}
+	for _, e := range res.Errors {
+		errs = append(errs, e.Error())
+	}
+	expected := []string{"app.j2:5:53: filter 'no_such_filter' not found", "app.j2:7:44: 'region' is undefined", "app.j2:7:57: 'app.port' is undefined"}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("Errors = %q; expected %q", errs, expected)
+	}
+
+	res = TemplateLintString("bad.j2", "ok\n{% if x %}{{ x }}", vars)
+	if len(res.Errors) != 1 || res.Errors[0].Line != 2 || len(res.Variables) != 0 {
+		t.Errorf("expect the syntax error only, got %+v", res)
+	}
+
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "roles", "web", "templates"), 0o755)
+	os.WriteFile(filepath.Join(dir, "roles", "web", "templates", "nginx.conf"), []byte("listen {{ port }};\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "motd.j2"), []byte("hello {{ app.name }}\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("{{ not_a_template }}\n"), 0o644)
+	results, err := TemplateLintDir(dir, vars)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("expect 2 templates linted, got %v %v", results, err)
+	}
+	for _, r := range results {
+		if filepath.Base(r.File) == "nginx.conf" && !reflect.DeepEqual(r.Missing, []string{"port"}) {
+			t.Errorf("expect port missing in nginx.conf, got %v", r.Missing)
+		}
+		if filepath.Base(r.File) == "motd.j2" && len(r.Errors) != 0 {
+			t.Errorf("expect motd.j2 ok, got %v", r.Errors)
+		}
+	}
+}
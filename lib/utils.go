@@ -17,17 +17,15 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// Validate a yaml file and load it into a map
+// Validate a yaml file and load it into a map. The vaults are decrypted with DefaultVaultSecrets.
 func IncludeVars(filename string) map[string]interface{} {
-	m := make(map[string]interface{})
-	ValidateYamlFile(filename, &m)
-	return m
+	return u.Must(IncludeVarsWithOpt(IncludeVarsOpt{File: filename}))
 }
 
 func IniGetVal(inifilepath, section, option string) string {
 	cfg, err := ini.Load(inifilepath)
 	if err != nil {
-		fmt.Println("Error loading INI file:", err)
+		Logger().Error("can not load INI file", "file", inifilepath, "error", err)
 		return ""
 	}
 	// Get an option value from a section
@@ -139,14 +137,14 @@ func HelmChartValidation(chartPath string, valuesFile []string) bool {
 // MaskCredential RegexPattern
 var MaskCredentialPattern *regexp.Regexp = regexp.MustCompile(`(?i)(password|token|pass|passkey|secret|secret_key|access_key|PAT)([:=]{1,1})[\s]*[^\s]+`)
 
-// Mask all credentials pattern
+// Mask all credentials pattern and the secrets registered with RegisterSecret
 func MaskCredential(inputstr string) string {
-	return MaskCredentialPattern.ReplaceAllString(inputstr, "$1$2 *****")
+	return maskRegisteredSecrets(MaskCredentialPattern.ReplaceAllString(inputstr, "$1$2 *****"))
 }
 
-// Mask all credentials pattern
+// Mask all credentials pattern and the secrets registered with RegisterSecret
 func MaskCredentialByte(inputbytes []byte) string {
-	return string(MaskCredentialPattern.ReplaceAll(inputbytes, []byte("$1$2 *****")))
+	return maskRegisteredSecrets(string(MaskCredentialPattern.ReplaceAll(inputbytes, []byte("$1$2 *****"))))
 }
 
 // Validate yaml files. Optionally return the unmarshalled object if you pass yamlobj not nil
@@ -158,7 +156,7 @@ func ValidateYamlFile(yaml_file string, yamlobj *map[string]interface{}) map[str
 	}
 	err := yaml.Unmarshal(data, &yamlobj)
 	if err1 := u.CheckErrNonFatal(err, "ValidateYamlFile Unmarshal"); err1 != nil {
-		fmt.Printf("Yaml content has error:\n%s\n", MaskCredentialByte(data))
+		Logger().Error("yaml content has error", "file", yaml_file, "content", MaskCredentialByte(data))
 		panic(err1.Error())
 	}
 	return *yamlobj
@@ -224,7 +222,7 @@ func IsLikelyPasswordOrToken[W string | map[string]struct{}](value, check_mode s
 	if entropy_threshold == 0 {
 		entropy_threshold = 2.5
 	}
-	if entropy := calculateEntropy(value); entropy <= entropy_threshold {
+	if entropy := CalculateEntropy(value); entropy <= entropy_threshold {
 		return false
 	}
 	hasWord := false
@@ -277,7 +275,8 @@ func IsLikelyPasswordOrToken[W string | map[string]struct{}](value, check_mode s
 	}
 }
 
-func calculateEntropy(s string) float64 {
+// CalculateEntropy return the Shannon entropy of the string in bits per character
+func CalculateEntropy(s string) float64 {
 	// Count the frequency of each character
 	frequency := make(map[rune]int)
 	for _, char := range s {
@@ -2,6 +2,7 @@ package lib
 
 import (
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"testing"
@@ -101,5 +102,5 @@ func TestPasswordDetect(t *testing.T) {
 }
 
 func TestIniHandling(t *testing.T) {
-	IniSetVal("test.ini", "global", "tfs_token", "aaaaaa")
+	IniSetVal(filepath.Join(t.TempDir(), "test.ini"), "global", "tfs_token", "aaaaaa")
 }
@@ -0,0 +1,411 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"gopkg.in/yaml.v3"
+)
+
+// VarLevel is a variable precedence level. The values follow the ansible documented precedence order, the
+// higher value wins. See https://docs.ansible.com/ansible/latest/playbook_guide/playbooks_variables.html#variable-precedence-where-should-i-put-a-variable
+type VarLevel int
+
+const (
+	VarsCommandLine VarLevel = iota + 1 // command line values (eg -u my_user, these are not variables)
+	VarsRoleDefaults
+	VarsInventoryGroup // inventory file or script group vars
+	VarsInventoryGroupVarsAll
+	VarsPlaybookGroupVarsAll
+	VarsInventoryGroupVars
+	VarsPlaybookGroupVars
+	VarsInventoryHost // inventory file or script host vars
+	VarsInventoryHostVars
+	VarsPlaybookHostVars
+	VarsHostFacts // host facts and cached set_facts
+	VarsPlay
+	VarsPlayVarsPrompt
+	VarsPlayVarsFiles
+	VarsRole
+	VarsBlock
+	VarsTask
+	VarsIncludeVars
+	VarsSetFacts // set_facts and registered vars
+	VarsRoleParams
+	VarsIncludeParams
+	VarsExtra // extra vars always win
+)
+
+var varLevelNames = map[VarLevel]string{
+	VarsCommandLine: "command_line", VarsRoleDefaults: "role_defaults", VarsInventoryGroup: "inventory_group",
+	VarsInventoryGroupVarsAll: "inventory_group_vars_all", VarsPlaybookGroupVarsAll: "playbook_group_vars_all",
+	VarsInventoryGroupVars: "inventory_group_vars", VarsPlaybookGroupVars: "playbook_group_vars",
+	VarsInventoryHost: "inventory_host", VarsInventoryHostVars: "inventory_host_vars",
+	VarsPlaybookHostVars: "playbook_host_vars", VarsHostFacts: "host_facts", VarsPlay: "play_vars",
+	VarsPlayVarsPrompt: "play_vars_prompt", VarsPlayVarsFiles: "play_vars_files", VarsRole: "role_vars",
+	VarsBlock: "block_vars", VarsTask: "task_vars", VarsIncludeVars: "include_vars", VarsSetFacts: "set_facts",
+	VarsRoleParams: "role_params", VarsIncludeParams: "include_params", VarsExtra: "extra_vars",
+}
+
+func (l VarLevel) String() string {
+	if n, ok := varLevelNames[l]; ok {
+		return n
+	}
+	return fmt.Sprintf("level_%d", int(l))
+}
+
+// VarResolver collect variables from all sources and resolve them in ansible precedence order. Within one level the
+// later added map wins. If MergeHash is true dicts are merged recursively (ansible hash_behaviour=merge) instead of
+// replaced.
+type VarResolver struct {
+	MergeHash bool
+	layers    map[VarLevel][]map[string]any
+}
+
+func NewVarResolver() *VarResolver {
+	return &VarResolver{layers: map[VarLevel][]map[string]any{}}
+}
+
+// Add vars at the given level. The map is not copied, later changes to it are visible in Resolve.
+func (r *VarResolver) Add(level VarLevel, vars map[string]any) *VarResolver {
+	if vars != nil {
+		r.layers[level] = append(r.layers[level], vars)
+	}
+	return r
+}
+
+// Clear remove all vars of a level, eg. task vars before moving to the next task
+func (r *VarResolver) Clear(level VarLevel) *VarResolver {
+	delete(r.layers, level)
+	return r
+}
+
+func (r *VarResolver) levels() []VarLevel {
+	levels := make([]VarLevel, 0, len(r.layers))
+	for l := range r.layers {
+		levels = append(levels, l)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+	return levels
+}
+
+// Resolve return the effective variables
+func (r *VarResolver) Resolve() map[string]any {
+	o := map[string]any{}
+	for _, l := range r.levels() {
+		for _, m := range r.layers[l] {
+			for k, v := range m {
+				if r.MergeHash {
+					o[k] = mergeValue(o[k], v)
+				} else {
+					o[k] = v
+				}
+			}
+		}
+	}
+	return o
+}
+
+// Lookup return the effective value of a variable and the level it came from
+func (r *VarResolver) Lookup(name string) (val any, level VarLevel, found bool) {
+	levels := r.levels()
+	for i := len(levels) - 1; i >= 0; i-- {
+		maps := r.layers[levels[i]]
+		for j := len(maps) - 1; j >= 0; j-- {
+			if v, ok := maps[j][name]; ok {
+				if r.MergeHash {
+					if _, isMap := v.(map[string]any); isMap {
+						return r.Resolve()[name], levels[i], true
+					}
+				}
+				return v, levels[i], true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// ResolveTemplated resolve the vars then render string values that reference other vars, the same as ansible lazy
+// evaluation. It iterates until nothing changes (max 10 rounds) so chained references work.
+func (r *VarResolver) ResolveTemplated() (map[string]any, error) {
+	vars := r.Resolve()
+	for round := 0; round < 10; round++ {
+		changed := false
+		for k, v := range vars {
+			s, ok := v.(string)
+			if !ok || !isTemplated(s) {
+				continue
+			}
+			tmpl, err := TemplateFromStringWithConfig(s, &CustomConfig)
+			if err != nil {
+				return vars, fmt.Errorf("var '%s': %w", k, err)
+			}
+			out, err := tmpl.ExecuteToString(exec.NewContext(vars))
+			if err != nil {
+				return vars, fmt.Errorf("var '%s': %w", k, err)
+			}
+			if out != s {
+				vars[k] = out
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return vars, nil
+}
+
+func mergeValue(old, new any) any {
+	oldMap, ok1 := old.(map[string]any)
+	newMap, ok2 := new.(map[string]any)
+	if !ok1 || !ok2 {
+		return new
+	}
+	o := make(map[string]any, len(oldMap)+len(newMap))
+	for k, v := range oldMap {
+		o[k] = v
+	}
+	for k, v := range newMap {
+		o[k] = mergeValue(o[k], v)
+	}
+	return o
+}
+
+// ParseExtraVars parse the values of --extra-vars flags the same way ansible does. Each value is one of
+//   - @file: a yaml or json file, it may be vault encrypted or have !vault values, see DefaultVaultSecrets
+//   - a json or yaml flow mapping, eg '{"a": 1}'
+//   - space separated key=value pairs, values are strings and may be quoted, eg 'a=1 b="x y"'
+//
+// Later values override earlier ones. The distribution facts given get their derived facts, see DeriveFacts.
+func ParseExtraVars(values []string) (map[string]any, error) {
+	o := map[string]any{}
+	for _, val := range values {
+		val = strings.TrimSpace(val)
+		switch {
+		case val == "":
+			continue
+		case strings.HasPrefix(val, "@"):
+			datab, err := os.ReadFile(val[1:])
+			if err != nil {
+				return nil, err
+			}
+			m, err := unmarshalVars(datab, nil)
+			if err != nil {
+				return nil, fmt.Errorf("extra vars file %s: %w", val[1:], err)
+			}
+			for k, v := range m {
+				o[k] = v
+			}
+		case strings.HasPrefix(val, "{"):
+			m := map[string]any{}
+			if err := json.Unmarshal([]byte(val), &m); err != nil {
+				if err1 := yaml.Unmarshal([]byte(val), &m); err1 != nil {
+					return nil, fmt.Errorf("extra vars '%s' is not valid json - %w", val, err)
+				}
+			}
+			for k, v := range m {
+				o[k] = v
+			}
+		default:
+			kv, err := parseKeyValueArgs(val)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range kv {
+				o[k] = v
+			}
+		}
+	}
+	return deriveVarsFacts(o), nil
+}
+
+// parseKeyValueArgs split 'a=1 b="x y" c='z' into a map. Quotes around values are removed.
+func parseKeyValueArgs(s string) (map[string]any, error) {
+	o := map[string]any{}
+	tokens := []string{}
+	var cur strings.Builder
+	var quote rune
+	for _, ch := range s {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(ch)
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ' ' || ch == '\t':
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(ch)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in '%s'", s)
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	for _, t := range tokens {
+		k, v, found := strings.Cut(t, "=")
+		if !found || k == "" {
+			return nil, fmt.Errorf("invalid extra vars '%s', expect key=value", t)
+		}
+		o[k] = v
+	}
+	return o, nil
+}
+
+// IncludeVarsOpt mirror the options of the ansible include_vars module. Exactly one of File, Dir or FirstFound should
+// be set.
+type IncludeVarsOpt struct {
+	// Load a single file
+	File string
+	// Load all files in the directory sorted alphabetically, descending into sub directories up to Depth (0 = no
+	// limit). FilesMatching is a regex the file names must match, IgnoreFiles a list of names to skip and Extensions
+	// the allowed extensions (default yaml, yml, json).
+	Dir           string
+	Depth         int
+	FilesMatching string
+	IgnoreFiles   []string
+	Extensions    []string
+	// Load the first existing file of the list, like with_first_found. Candidates may be templated, eg
+	// '{{ ansible_distribution }}.yml', and are rendered with Vars. Relative candidates are searched in Paths then in
+	// the current directory.
+	FirstFound []string
+	Paths      []string
+	Vars       map[string]any
+	// Nest all loaded vars under this name instead of loading them at the top level
+	Name string
+	// Secrets to decrypt the vault encrypted files and '!vault' values, default DefaultVaultSecrets
+	VaultSecrets []VaultSecret
+}
+
+// IncludeVarsWithOpt load vars files the same way the ansible include_vars module does and return the vars map.
+// Files loaded later override earlier ones.
+func IncludeVarsWithOpt(opt IncludeVarsOpt) (map[string]any, error) {
+	files := []string{}
+	switch {
+	case opt.File != "":
+		files = append(files, opt.File)
+	case opt.Dir != "":
+		var err error
+		if files, err = listVarsFiles(opt); err != nil {
+			return nil, err
+		}
+	case len(opt.FirstFound) > 0:
+		f, err := FirstFound(opt.FirstFound, opt.Paths, opt.Vars)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	default:
+		return nil, fmt.Errorf("one of file, dir or first_found is required")
+	}
+
+	o := map[string]any{}
+	for _, f := range files {
+		datab, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		m, err := unmarshalVars(datab, opt.VaultSecrets)
+		if err != nil {
+			return nil, fmt.Errorf("include_vars %s: %w", f, err)
+		}
+		for k, v := range m {
+			o[k] = v
+		}
+	}
+	if opt.Name != "" {
+		return map[string]any{opt.Name: o}, nil
+	}
+	return o, nil
+}
+
+func listVarsFiles(opt IncludeVarsOpt) ([]string, error) {
+	exts := opt.Extensions
+	if len(exts) == 0 {
+		exts = []string{"yaml", "yml", "json"}
+	}
+	var matchPtn *regexp.Regexp
+	if opt.FilesMatching != "" {
+		var err error
+		if matchPtn, err = regexp.Compile(opt.FilesMatching); err != nil {
+			return nil, err
+		}
+	}
+	ignore := sliceToSet(opt.IgnoreFiles)
+	allowedExt := sliceToSet(exts)
+	files := []string{}
+	err := filepath.WalkDir(opt.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			rel, err := filepath.Rel(opt.Dir, p)
+			if err != nil || rel == "." {
+				return err
+			}
+			if opt.Depth > 0 && strings.Count(rel, string(filepath.Separator))+1 >= opt.Depth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := allowedExt[strings.TrimPrefix(filepath.Ext(p), ".")]; !ok {
+			return nil
+		}
+		if _, ok := ignore[d.Name()]; ok {
+			return nil
+		}
+		if matchPtn != nil && !matchPtn.MatchString(d.Name()) {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// FirstFound return the first existing file of the candidates, the same as the ansible first_found lookup.
+// Templated candidates are rendered with vars; relative ones are searched in paths and then the current directory.
+func FirstFound(candidates, paths []string, vars map[string]any) (string, error) {
+	for _, c := range candidates {
+		if isTemplated(c) {
+			tmpl, err := TemplateFromStringWithConfig(c, &CustomConfig)
+			if err != nil {
+				return "", err
+			}
+			if c, err = tmpl.ExecuteToString(exec.NewContext(vars)); err != nil {
+				return "", err
+			}
+		}
+		tryPaths := []string{c}
+		if !filepath.IsAbs(c) {
+			tryPaths = []string{}
+			for _, p := range paths {
+				tryPaths = append(tryPaths, filepath.Join(p, c))
+			}
+			tryPaths = append(tryPaths, c)
+		}
+		for _, p := range tryPaths {
+			if st, err := os.Stat(p); err == nil && !st.IsDir() {
+				return p, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no file was found when using first_found, candidates: %s", strings.Join(candidates, ", "))
+}
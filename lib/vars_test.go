@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVarResolverPrecedence(t *testing.T) {
+	r := NewVarResolver()
+	r.Add(VarsExtra, map[string]any{"a": "extra"})
+	r.Add(VarsRoleDefaults, map[string]any{"a": "default", "b": "default", "c": "default"})
+	r.Add(VarsPlay, map[string]any{"a": "play", "b": "play"})
+	r.Add(VarsTask, map[string]any{"b": "task", "msg": "{{ a }}-{{ b }}-{{ c }}"})
+
+	vars := r.Resolve()
+	if vars["a"] != "extra" || vars["b"] != "task" || vars["c"] != "default" {
+		t.Errorf("wrong precedence: %v", vars)
+	}
+	if _, level, _ := r.Lookup("b"); level != VarsTask {
+		t.Errorf("expected b from task_vars, got %s", level)
+	}
+	rendered, err := r.ResolveTemplated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered["msg"] != "extra-task-default" {
+		t.Errorf("wrong templated value: %v", rendered["msg"])
+	}
+
+	r = NewVarResolver()
+	r.MergeHash = true
+	r.Add(VarsRoleDefaults, map[string]any{"d": map[string]any{"x": 1, "y": 1}})
+	r.Add(VarsPlay, map[string]any{"d": map[string]any{"y": 2}})
+	d := r.Resolve()["d"].(map[string]any)
+	if d["x"] != 1 || d["y"] != 2 {
+		t.Errorf("hash merge failed: %v", d)
+	}
+}
+
+func TestParseExtraVars(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "vars.yml")
+	os.WriteFile(f, []byte("from_file: true\nb: file\n"), 0o644)
+	vars, err := ParseExtraVars([]string{`a=1 b="x y" c='z'`, `{"d": {"e": 2}}`, "@" + f})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["a"] != "1" || vars["b"] != "file" || vars["c"] != "z" || vars["from_file"] != true {
+		t.Errorf("unexpected vars: %v", vars)
+	}
+	if vars["d"].(map[string]any)["e"] != float64(2) {
+		t.Errorf("unexpected json vars: %v", vars["d"])
+	}
+	if _, err := ParseExtraVars([]string{"novalue"}); err == nil {
+		t.Error("expected error for a token without =")
+	}
+}
+
+func TestIncludeVarsWithOpt(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "vars", "sub"), 0o755)
+	os.WriteFile(filepath.Join(dir, "vars", "a.yml"), []byte("a: 1\nshared: a\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "vars", "b.json"), []byte(`{"b": 2, "shared": "b"}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "vars", "c.txt"), []byte("c: 3\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "vars", "sub", "d.yml"), []byte("d: 4\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "vars", "Debian.yml"), []byte("pkg: apt\n"), 0o644)
+
+	vars, err := IncludeVarsWithOpt(IncludeVarsOpt{Dir: filepath.Join(dir, "vars"), Depth: 1, IgnoreFiles: []string{"Debian.yml"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["a"] != 1 || vars["b"] != 2 || vars["shared"] != "b" || vars["c"] != nil || vars["d"] != nil {
+		t.Errorf("unexpected dir vars: %v", vars)
+	}
+	// the depth counts from the dir whatever its spelling
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(filepath.Join(dir, "vars"))
+	for depth, expected := range map[int]any{1: nil, 2: 4} {
+		vars, err = IncludeVarsWithOpt(IncludeVarsOpt{Dir: ".", Depth: depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vars["a"] != 1 || vars["d"] != expected {
+			t.Errorf("unexpected vars of . with depth %d: %v", depth, vars)
+		}
+	}
+	os.Chdir(wd)
+
+	vars, err = IncludeVarsWithOpt(IncludeVarsOpt{
+		FirstFound: []string{"{{ ansible_distribution }}.yml", "{{ ansible_os_family }}.yml", "default.yml"},
+		Paths:      []string{filepath.Join(dir, "vars")},
+		Vars:       map[string]any{"ansible_distribution": "Ubuntu", "ansible_os_family": "Debian"},
+		Name:       "os_vars",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["os_vars"].(map[string]any)["pkg"] != "apt" {
+		t.Errorf("unexpected first_found vars: %v", vars)
+	}
+	if _, err := IncludeVarsWithOpt(IncludeVarsOpt{FirstFound: []string{"nope.yml"}}); err == nil {
+		t.Error("expected error when no candidate exists")
+	}
+}
@@ -0,0 +1,245 @@
+// Package lint checks the ansible playbooks for the common mistakes, on top of the syntax check of lib. The literal
+// secrets in the vars are found with the cred-detect scanner.
+package lint
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	ag "github.com/sunshine69/automation-go/lib"
+	"github.com/sunshine69/automation-go/scanner"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity levels of the findings
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Finding is one rule violation found by Playbook
+type Finding struct {
+	ag.PlaybookError
+	Rule     string
+	Severity string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d:%d: [%s] %s: %s", f.File, f.Line, f.Column, f.Severity, f.Rule, f.Msg)
+}
+
+// Rules is the list of built in lint rules and their default severity
+var Rules = map[string]string{
+	"syntax-check":                 SeverityError,
+	"command-instead-of-module":    SeverityWarning,
+	"no-changed-when":              SeverityWarning,
+	"literal-secret-in-vars":       SeverityError,
+	"deprecated-syntax":            SeverityWarning,
+	"no-jinja-in-when":             SeverityWarning,
+	"deprecated-bare-vars-in-loop": SeverityWarning,
+}
+
+// Commands that have a dedicated module which should be used instead. Map command name => module name
+var commandToModule = map[string]string{
+	"apt": "apt", "apt-get": "apt", "yum": "yum", "dnf": "dnf", "zypper": "community.general.zypper",
+	"systemctl": "systemd", "service": "service", "git": "git", "curl": "get_url or uri", "wget": "get_url",
+	"chown": "file", "chmod": "file", "mkdir": "file", "ln": "file", "rm": "file", "touch": "file",
+	"unzip": "unarchive", "tar": "unarchive", "sed": "lineinfile or replace", "mount": "ansible.posix.mount",
+	"useradd": "user", "usermod": "user", "groupadd": "group", "crontab": "cron", "pip": "pip",
+	"rsync": "ansible.posix.synchronize", "hostname": "hostname",
+}
+
+var bareVarPtn = regexp.MustCompile(`^[A-Za-z_][\w.]*$`)
+
+// Options configure Playbook. Severity overrides the default severity per rule; a value of "off" disables the rule.
+// ExtraVars are used to resolve templated paths as in PlaybookSyntaxCheck. CheckMode and WordsFile configure the
+// scanner finding the literal secrets, see scanner.Config.
+type Options struct {
+	ExtraVars map[string]any
+	Severity  map[string]string
+	CheckMode string
+	WordsFile string
+}
+
+// Playbook walks the playbook the same way ag.PlaybookSyntaxCheck does and reports rule violations with file and
+// line. Syntax errors are reported under the rule 'syntax-check'. group_vars and host_vars next to the playbook are
+// checked for literal secrets too.
+func Playbook(playbookPath string, opt Options) ([]Finding, error) {
+	if opt.CheckMode == "" {
+		opt.CheckMode = "letter+digit"
+	}
+	secrets, err := newSecretFinder(opt)
+	if err != nil {
+		return nil, err
+	}
+	findings := []Finding{}
+	add := func(rule, file string, node *yaml.Node, format string, args ...any) {
+		sev := Rules[rule]
+		if s, ok := opt.Severity[rule]; ok {
+			sev = s
+		}
+		if sev == "off" {
+			return
+		}
+		f := Finding{PlaybookError: ag.PlaybookError{File: file, Msg: fmt.Sprintf(format, args...)}, Rule: rule, Severity: sev}
+		if node != nil {
+			f.Line, f.Column = node.Line, node.Column
+		}
+		findings = append(findings, f)
+	}
+
+	errs := ag.PlaybookWalk(playbookPath, ag.PlaybookWalkOpt{
+		ExtraVars: opt.ExtraVars,
+		TaskHook:  func(file string, task *yaml.Node) { lintTask(file, task, add) },
+		VarsHook:  func(file string, vars *yaml.Node) { lintVars(file, "", vars, secrets, add) },
+	})
+	for _, e := range errs {
+		if e.Keyword != "" {
+			add("deprecated-syntax", e.File, &yaml.Node{Line: e.Line, Column: e.Column}, "%s", ag.DeprecatedKeywords[e.Keyword])
+			continue
+		}
+		add("syntax-check", e.File, &yaml.Node{Line: e.Line, Column: e.Column}, "%s", e.Msg)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+func lintTask(file string, task *yaml.Node, add func(rule, file string, node *yaml.Node, format string, args ...any)) {
+	for i := 0; i+1 < len(task.Content); i += 2 {
+		k, v := task.Content[i], task.Content[i+1]
+		switch k.Value {
+		case "when", "changed_when", "failed_when", "until":
+			conds := []*yaml.Node{v}
+			if v.Kind == yaml.SequenceNode {
+				conds = v.Content
+			}
+			for _, cond := range conds {
+				if cond.Kind == yaml.ScalarNode && strings.Contains(cond.Value, "{{") {
+					add("no-jinja-in-when", file, cond, "'%s' is a raw expression, remove the jinja2 delimiters", k.Value)
+				}
+			}
+		}
+		if strings.HasPrefix(k.Value, "with_") && v.Kind == yaml.ScalarNode && bareVarPtn.MatchString(v.Value) {
+			add("deprecated-bare-vars-in-loop", file, v, "bare variable '%s' in %s, use '{{ %s }}'", v.Value, k.Value, v.Value)
+		}
+		shortName := strings.TrimPrefix(strings.TrimPrefix(k.Value, "ansible.builtin."), "ansible.legacy.")
+		if shortName != "command" && shortName != "shell" {
+			continue
+		}
+		taskArgs := mappingValue(task, "args")
+		if mappingValue(task, "changed_when") == nil && !hasArg(v, "creates") && !hasArg(v, "removes") && !hasArg(taskArgs, "creates") && !hasArg(taskArgs, "removes") {
+			add("no-changed-when", file, k, "commands should not change things if nothing needs doing, add 'changed_when' or 'creates/removes'")
+		}
+		cmdLine := v.Value
+		if v.Kind == yaml.MappingNode {
+			if c := mappingValue(v, "cmd"); c != nil {
+				cmdLine = c.Value
+			}
+		}
+		if fields := strings.Fields(cmdLine); len(fields) > 0 {
+			exe := filepath.Base(fields[0])
+			if exe == "sudo" && len(fields) > 1 {
+				exe = filepath.Base(fields[1])
+			}
+			if mod, ok := commandToModule[exe]; ok {
+				add("command-instead-of-module", file, k, "%s used in place of the %s module", exe, mod)
+			}
+		}
+	}
+}
+
+func hasArg(argNode *yaml.Node, name string) bool {
+	if argNode == nil {
+		return false
+	}
+	if argNode.Kind == yaml.MappingNode {
+		return mappingValue(argNode, name) != nil
+	}
+	for _, f := range strings.Fields(argNode.Value) {
+		if strings.HasPrefix(f, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// mappingValue return the value node for key in a yaml mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// secretFinder run the cred-detect scanner, its detectors, patterns and structured scan, on the vars values
+type secretFinder struct {
+	s *scanner.Scanner
+}
+
+func newSecretFinder(opt Options) (*secretFinder, error) {
+	cfg := scanner.DefaultConfig()
+	cfg.CheckMode, cfg.WordsFile = opt.CheckMode, opt.WordsFile
+	cfg.Structured, cfg.NoIgnoreFiles, cfg.NoLocalConfig, cfg.NoCodeOwners = true, true, true, true
+	s, err := scanner.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &secretFinder{s: s}, nil
+}
+
+// find return the rule id of the secret found in the value of the key, empty if none. The value is scanned as the
+// one line yaml 'key: value' so the keyword patterns see the key.
+func (f *secretFinder) find(key, value string) string {
+	datab, err := yaml.Marshal(map[string]string{key: value})
+	if err != nil {
+		return ""
+	}
+	for _, findings := range scanner.Collect(f.s.ScanReader(context.Background(), "vars.yml", strings.NewReader(string(datab)))) {
+		for _, o := range findings {
+			return o.RuleID
+		}
+	}
+	return ""
+}
+
+// lintVars recursively look for literal secrets in a vars mapping or list; key is the key of the list. Templated
+// values and vault encrypted values are fine.
+func lintVars(file, key string, vars *yaml.Node, secrets *secretFinder, add func(rule, file string, node *yaml.Node, format string, args ...any)) {
+	check := func(k string, v *yaml.Node) {
+		switch v.Kind {
+		case yaml.MappingNode, yaml.SequenceNode:
+			lintVars(file, k, v, secrets, add)
+		case yaml.ScalarNode:
+			if v.Tag == "!vault" || strings.Contains(v.Value, "{{") || strings.Contains(v.Value, "{%") || ag.IsVault(v.Value) {
+				return
+			}
+			if rule := secrets.find(k, v.Value); rule != "" {
+				add("literal-secret-in-vars", file, v, "var '%s' looks like a literal secret (%s), use ansible vault or a lookup", k, rule)
+			}
+		}
+	}
+	if vars.Kind == yaml.SequenceNode {
+		for _, v := range vars.Content {
+			check(key, v)
+		}
+		return
+	}
+	for i := 0; i+1 < len(vars.Content); i += 2 {
+		check(vars.Content[i].Value, vars.Content[i+1])
+	}
+}
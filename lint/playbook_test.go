@@ -0,0 +1,42 @@
+package lint
+
+import (
+	"testing"
+)
+
+func TestPlaybook(t *testing.T) {
+	findings, err := Playbook("testdata/site.yml", Options{Severity: map[string]string{"no-jinja-in-when": "error"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	secretLines := []int{}
+	for _, f := range findings {
+		got[f.Rule]++
+		if f.Rule == "no-jinja-in-when" && f.Severity != SeverityError {
+			t.Errorf("severity override not applied: %s", f.String())
+		}
+		if f.Rule == "literal-secret-in-vars" && f.File == "testdata/site.yml" {
+			secretLines = append(secretLines, f.Line)
+		}
+	}
+	expected := map[string]int{
+		"literal-secret-in-vars":    4,
+		"command-instead-of-module": 2,
+		"no-changed-when":           1,
+		"no-jinja-in-when":          1,
+		"deprecated-syntax":         2,
+	}
+	for rule, count := range expected {
+		if got[rule] != count {
+			t.Errorf("rule %s: expected %d findings, got %d", rule, count, got[rule])
+		}
+	}
+	if got["syntax-check"] != 0 {
+		t.Errorf("unexpected syntax-check findings: %v", findings)
+	}
+	// the detector finds the aws key whatever its name, the secrets in a list are checked
+	if len(secretLines) != 3 || secretLines[1] != 23 || secretLines[2] != 26 {
+		t.Errorf("expect the secrets of lines 3, 23 and 26 in site.yml, got %v", secretLines)
+	}
+}
@@ -0,0 +1,137 @@
+// Package creddetect embed the credential detection of cred-detect in a go program, without running the binary. It
+// is a small api over the scanner package: a Scanner configured once, and the findings of each scan read one at a
+// time from Results.
+//
+//	s, err := creddetect.New(creddetect.DefaultConfig())
+//	res := s.ScanPath(ctx, ".")
+//	defer res.Close()
+//	for res.Next() {
+//		f := res.Finding()
+//		fmt.Println(f.File, f.Line_no, f.RuleID)
+//	}
+//	if err := res.Err(); err != nil { ... }
+//
+// The scanner package has the rest: the git history, bucket and image scans, the profiles, the reports.
+package creddetect
+
+import (
+	"context"
+	"io"
+
+	"github.com/sunshine69/automation-go/scanner"
+)
+
+// Config of the scans, see scanner.Config
+type Config = scanner.Config
+
+// Finding is a credential found, see scanner.OutputFmt. Its values are redacted as Config.Redact says.
+type Finding = scanner.OutputFmt
+
+// Stats of a scan, see scanner.Stats
+type Stats = scanner.Stats
+
+// DefaultConfig return the config cred-detect uses when no option is given
+func DefaultConfig() Config {
+	return scanner.DefaultConfig()
+}
+
+// Scanner detect the credentials in the files and streams. It runs one scan at a time: read the Results of a scan,
+// or close them, before starting the next.
+type Scanner struct {
+	s *scanner.Scanner
+}
+
+// New create a scanner with the config
+func New(cfg Config) (*Scanner, error) {
+	s, err := scanner.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Scanner{s: s}, nil
+}
+
+// Configure change the config of the next scans
+func (c *Scanner) Configure(cfg Config) error {
+	return c.s.Configure(cfg)
+}
+
+// Config return the current config
+func (c *Scanner) Config() Config {
+	return c.s.Config()
+}
+
+// Engine return the scanner the Scanner runs, for the scans and settings this package does not have
+func (c *Scanner) Engine() *scanner.Scanner {
+	return c.s
+}
+
+// ScanPath scan a file or a directory tree
+func (c *Scanner) ScanPath(ctx context.Context, root string) *Results {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Results{s: c.s, findings: c.s.Scan(ctx, root), cancel: cancel}
+}
+
+// ScanReader scan a stream as one file named name, eg. a file being uploaded or stdin
+func (c *Scanner) ScanReader(ctx context.Context, name string, r io.Reader) *Results {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Results{s: c.s, findings: c.s.ScanReader(ctx, name, r), cancel: cancel}
+}
+
+// Stats of the last scan, complete once its Results are read to the end
+func (c *Scanner) Stats() Stats {
+	return c.s.Stats()
+}
+
+// Results iterate over the findings of a scan as they are found, like a bufio.Scanner. A finding is sent again
+// when it grows, eg. a second line of a file matching the same pattern: Collect keeps the last one of each.
+type Results struct {
+	s        *scanner.Scanner
+	findings <-chan Finding
+	cancel   context.CancelFunc
+	current  Finding
+	done     bool
+}
+
+// Next wait for the next finding, it returns false at the end of the scan
+func (r *Results) Next() bool {
+	if r.done {
+		return false
+	}
+	f, ok := <-r.findings
+	if !ok {
+		r.done = true
+		r.cancel()
+		return false
+	}
+	r.current = f
+	return true
+}
+
+// Finding return the finding read by Next
+func (r *Results) Finding() Finding {
+	return r.current
+}
+
+// Err return the error that stopped the scan, eg. its context cancelled. Valid once Next returned false.
+func (r *Results) Err() error {
+	if !r.done {
+		return nil
+	}
+	return r.s.Err()
+}
+
+// Close stop the scan and wait for its end; the findings not read are dropped
+func (r *Results) Close() {
+	r.cancel()
+	for r.Next() {
+	}
+}
+
+// Collect read the remaining findings, the last one of each file and token, see scanner.Collect
+func (r *Results) Collect() ([]Finding, error) {
+	output := scanner.ProjectOutputFmt{}
+	for r.Next() {
+		output.Add(r.Finding())
+	}
+	return output.List(), r.Err()
+}
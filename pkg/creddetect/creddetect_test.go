@@ -0,0 +1,61 @@
+package creddetect
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	dir := t.TempDir()
+	for idx, name := range []string{"a.conf", "b.conf", "c.conf"} {
+		content := "password=Zx9cVb7nMq2wE\ntoken=Qw8eRt5yUi3oP\n"
+		if idx == 2 {
+			content = "nothing here\n"
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	res := s.ScanPath(ctx, dir)
+	files := map[string]bool{}
+	for res.Next() {
+		files[filepath.Base(res.Finding().File)] = true
+	}
+	if err := res.Err(); err != nil || len(files) != 2 || !files["a.conf"] || !files["b.conf"] {
+		t.Errorf("expect the findings of a.conf and b.conf, got %v %v", files, err)
+	}
+	if st := s.Stats(); st.FilesScanned != 3 {
+		t.Errorf("expect 3 files scanned, got %+v", st)
+	}
+
+	findings, err := s.ScanReader(ctx, "stdin", strings.NewReader("password=Zx9cVb7nMq2wE\n")).Collect()
+	if err != nil || len(findings) != 1 || findings[0].File != "stdin" || findings[0].Matches[1] == "Zx9cVb7nMq2wE" {
+		t.Errorf("expect one masked finding of the stream, got %+v %v", findings, err)
+	}
+
+	res = s.ScanPath(ctx, dir)
+	if !res.Next() {
+		t.Fatal("expect a finding")
+	}
+	res.Close()
+	if res.Next() || !errors.Is(res.Err(), context.Canceled) {
+		t.Errorf("expect the closed scan stopped, got %v", res.Err())
+	}
+
+	cfg.Patterns = []string{"("}
+	if err := s.Configure(cfg); err == nil {
+		t.Errorf("expect an invalid pattern refused")
+	}
+}
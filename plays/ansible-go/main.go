@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	ag "github.com/sunshine69/automation-go/lib"
+	"github.com/sunshine69/automation-go/lint"
+	u "github.com/sunshine69/golang-tools/utils"
+)
+
+var (
+	version   string // Will hold the version number
+	buildTime string // Will hold the build time
+)
+
+func printVersionBuildInfo() {
+	fmt.Printf("Version: %s\nBuild time: %s\n", version, buildTime)
+}
+
+func lintRuleNames() []string {
+	o := []string{}
+	for k := range lint.Rules {
+		o = append(o, k)
+	}
+	sort.Strings(o)
+	return o
+}
+
+// runLint print lint findings and return the exit code. Only error severity findings make the lint fail.
+func runLint(playbook string, opt lint.Options) int {
+	findings, err := lint.Playbook(playbook, opt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		return 2
+	}
+	errCount := 0
+	for _, f := range findings {
+		fmt.Println(f.String())
+		if f.Severity == lint.SeverityError {
+			errCount++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d finding(s), %d error(s)\n", len(findings), errCount)
+	if errCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runTemplateLint print the errors of the templates of a file or directory and return the exit code
+func runTemplateLint(path string, vars map[string]any) int {
+	var results []*ag.TemplateLintResult
+	if st, err := os.Stat(path); err == nil && st.IsDir() {
+		results, err = ag.TemplateLintDir(path, vars)
+		u.CheckErr(err, "TemplateLintDir")
+	} else {
+		res, err := ag.TemplateLint(path, vars)
+		u.CheckErr(err, "TemplateLint")
+		results = append(results, res)
+	}
+	errCount := 0
+	for _, res := range results {
+		for _, e := range res.Errors {
+			fmt.Println(e.Error())
+		}
+		errCount += len(res.Errors)
+	}
+	fmt.Fprintf(os.Stderr, "%d template(s), %d error(s)\n", len(results), errCount)
+	if errCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runPluginsList print the plugins found in the plugin paths and why any failed to load
+func runPluginsList(plugins []ag.Plugin, paths []string) int {
+	failed := 0
+	fmt.Printf("%-10s %-20s %-5s %-8s %s\n", "KIND", "NAME", "TYPE", "STATUS", "PATH")
+	for _, p := range plugins {
+		fmt.Printf("%-10s %-20s %-5s %-8s %s\n", p.Kind, p.Name, p.Type, p.Status, p.Path)
+		if p.Reason != "" {
+			fmt.Printf("%-10s %s\n", "", p.Reason)
+		}
+		if p.Status == ag.PluginFailed {
+			failed++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d plugin(s) found in %s, %d failed\n", len(plugins), strings.Join(paths, ":"), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func main() {
+	optFlag := pflag.NewFlagSet("opt", pflag.ExitOnError)
+	syntax_check := optFlag.Bool("syntax-check", false, "Parse the playbook, resolve includes/roles and validate tasks and module arguments without connecting to any host")
+	extra_vars := optFlag.StringArrayP("extra-vars", "e", []string{}, "Set additional variables as key=value, JSON/YAML mapping or @file. Can be repeated; later values win")
+	lint_severity := optFlag.StringToString("severity", map[string]string{}, "lint: override rule severity, eg. --severity no-changed-when=error,deprecated-syntax=off. Levels: error, warning, info, off")
+	password_check_mode := optFlag.String("check-mode", "letter+digit", "lint: password check mode used to detect literal secrets in vars. See cred-detect --check-mode")
+	words_file := optFlag.String("words-file", "", "lint: words file used by the check modes having 'word'")
+	vault_ids := optFlag.StringArray("vault-id", []string{}, "Ansible vault password file, or label@file, to decrypt the vault encrypted vars files and !vault values. Can be repeated. Default from ANSIBLE_VAULT_IDENTITY_LIST and ANSIBLE_VAULT_PASSWORD_FILE")
+	plugin_path := optFlag.StringArray("plugin-path", ag.PluginPaths(), "Directories to search for <kind>_plugins sub directories. Default from ANSIBLE_GO_PLUGIN_PATH")
+
+	optFlag.Usage = func() {
+		fmt.Printf(`Usage: %s [playbook.yml] [opt]
+		       %s lint [playbook.yml] [opt]
+		       %s template-lint <dir|template> [opt]
+		       %s plugins list [opt]
+		       %s facts
+		Tools to work with ansible playbooks. Running playbooks is not supported; use ansible-playbook for that.
+		'facts' prints the distribution facts of this machine with the derived ansible_os_family, ansible_pkg_mgr and
+		ansible_service_mgr. Templates can test the family with eg. {%% if ansible_facts is debian_family %%}.
+		'template-lint' checks the templates of a file or a roles directory without rendering them: the syntax, the
+		unknown filters and the variables not given with --extra-vars, unless tested with 'is defined' or a default.
+
+		Options can be set with environment variables ANSIBLE_GO_<OPTION> where '-' becomes '_', eg.
+		ANSIBLE_GO_CHECK_MODE=letter, ANSIBLE_GO_EXTRA_VARS='env=prod region=us' or
+		ANSIBLE_GO_SEVERITY='{"no-changed-when": "error"}'. Command line options take precedence.
+
+		lint rules: %s
+
+		Options below:
+
+		`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], strings.Join(lintRuleNames(), ", "))
+		optFlag.PrintDefaults()
+	}
+	optFlag.Parse(os.Args[1:])
+
+	// ANSIBLE_GO_<FLAG> env vars, eg ANSIBLE_GO_CHECK_MODE, are used for the flags not given on the command line
+	viper.BindPFlags(optFlag)
+	viper.SetEnvPrefix("ANSIBLE_GO")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	viper.AutomaticEnv()
+	*syntax_check = viper.GetBool("syntax-check")
+	*extra_vars = viper.GetStringSlice("extra-vars")
+	*lint_severity = viper.GetStringMapString("severity")
+	*password_check_mode = viper.GetString("check-mode")
+	*words_file = viper.GetString("words-file")
+	*vault_ids = viper.GetStringSlice("vault-id")
+
+	if optFlag.NArg() < 1 {
+		optFlag.Usage()
+		os.Exit(2)
+	}
+	playbook := optFlag.Arg(0)
+	if playbook == "version" {
+		printVersionBuildInfo()
+		os.Exit(0)
+	}
+
+	if playbook == "facts" {
+		facts, err := ag.LocalFacts()
+		u.CheckErr(err, "LocalFacts")
+		fmt.Println(u.JsonDump(facts, "  "))
+		os.Exit(0)
+	}
+
+	// the filter and lookup plugins are used by every command rendering or checking templates
+	plugins := ag.LoadPlugins(*plugin_path)
+	if playbook == "plugins" {
+		if optFlag.NArg() < 2 || optFlag.Arg(1) != "list" {
+			optFlag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(runPluginsList(plugins, *plugin_path))
+	}
+	for _, p := range plugins {
+		if p.Status == ag.PluginFailed {
+			fmt.Fprintf(os.Stderr, "[WARN] %s plugin %s not loaded - %s\n", p.Kind, p.Path, p.Reason)
+		}
+	}
+
+	if len(*vault_ids) > 0 {
+		secrets, err := ag.LoadVaultSecrets(*vault_ids)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+			os.Exit(2)
+		}
+		ag.SetVaultSecrets(secrets...)
+	}
+
+	extraVars, err := ag.ParseExtraVars(*extra_vars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		os.Exit(2)
+	}
+
+	if playbook == "lint" {
+		if optFlag.NArg() < 2 {
+			optFlag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(runLint(optFlag.Arg(1), lint.Options{ExtraVars: extraVars, Severity: *lint_severity, CheckMode: *password_check_mode, WordsFile: *words_file}))
+	}
+
+	if playbook == "template-lint" {
+		if optFlag.NArg() < 2 {
+			optFlag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(runTemplateLint(optFlag.Arg(1), extraVars))
+	}
+
+	if !*syntax_check {
+		fmt.Fprintln(os.Stderr, "[ERROR] running playbooks is not supported, use --syntax-check")
+		os.Exit(2)
+	}
+	errs := ag.PlaybookSyntaxCheck(playbook, extraVars)
+	for _, e := range errs {
+		fmt.Fprintln(os.Stderr, e.Error())
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "Syntax check failed with %d error(s)\n", len(errs))
+		os.Exit(1)
+	}
+	fmt.Printf("playbook: %s\n", playbook)
+}
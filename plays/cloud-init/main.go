@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	ag "github.com/sunshine69/automation-go/lib"
+	u "github.com/sunshine69/golang-tools/utils"
+)
+
+var (
+	version   string // Will hold the version number
+	buildTime string // Will hold the build time
+)
+
+func printVersionBuildInfo() {
+	fmt.Printf("Version: %s\nBuild time: %s\n", version, buildTime)
+}
+
+func main() {
+	optFlag := pflag.NewFlagSet("opt", pflag.ExitOnError)
+	vars_files := optFlag.StringArray("vars-file", []string{}, "yaml or json vars file, can be repeated. Later files override earlier ones")
+	extra_vars := optFlag.StringArrayP("extra-vars", "e", []string{}, "Extra vars like ansible: key=value, json or @file. Override the vars files")
+	output := optFlag.StringP("output", "o", "", "Write the user-data to this file instead of stdout")
+	max_size := optFlag.Int("max-size", ag.UserDataMaxSize, "Fail if the user-data (after --gzip) is bigger than this many bytes. 0 to disable")
+	gzip_output := optFlag.Bool("gzip", false, "Gzip the user-data, cloud-init decompresses it")
+	base64_output := optFlag.Bool("base64", false, "Base64 encode the output, eg for the cloud apis that want it encoded")
+	no_validate := optFlag.Bool("no-validate", false, "Do not validate the rendered user-data")
+	vault_ids := optFlag.StringArray("vault-id", []string{}, "Ansible vault password file, or label@file, to decrypt the vault encrypted vars files and !vault values. Can be repeated. Default from ANSIBLE_VAULT_IDENTITY_LIST and ANSIBLE_VAULT_PASSWORD_FILE")
+	strict := optFlag.Bool("strict", false, "Fail on an undefined variable instead of rendering it as an empty string")
+	show_version := optFlag.Bool("version", false, "Print version")
+
+	optFlag.Usage = func() {
+		fmt.Printf(`Usage: %s <template> [opt]
+		Render a cloud-init user-data from a jinja2 template and vars files, eg.
+
+		  %s user-data.j2 --vars-file group_vars/web.yml -e hostname=web1 --gzip --base64
+
+		The result is checked: it must start with a cloud-init header (#cloud-config, #!, multipart ...), a
+		#cloud-config must be a valid yaml mapping and the size must be under --max-size (the EC2 limit by default).
+
+		Options below:
+
+		`, os.Args[0], os.Args[0])
+		optFlag.PrintDefaults()
+	}
+	optFlag.Parse(os.Args[1:])
+
+	if *show_version {
+		printVersionBuildInfo()
+		os.Exit(0)
+	}
+	if optFlag.NArg() != 1 {
+		optFlag.Usage()
+		os.Exit(2)
+	}
+	template_file := optFlag.Arg(0)
+
+	if len(*vault_ids) > 0 {
+		secrets, err := ag.LoadVaultSecrets(*vault_ids)
+		u.CheckErr(err, "LoadVaultSecrets")
+		ag.SetVaultSecrets(secrets...)
+	}
+	var_sources := []string{}
+	for _, f := range *vars_files {
+		var_sources = append(var_sources, "@"+f)
+	}
+	vars, err := ag.ParseExtraVars(append(var_sources, *extra_vars...))
+	u.CheckErr(err, "ParseExtraVars")
+
+	tmplb, err := os.ReadFile(template_file)
+	u.CheckErr(err, "ReadFile")
+	rendered, err := ag.TemplateStringWithOpt(string(tmplb), vars, ag.TemplateOpt{Name: template_file, Strict: *strict, DefaultHint: true})
+	u.CheckErr(err, "TemplateStringWithOpt")
+
+	data := []byte(rendered)
+	if !*no_validate {
+		// validate before gzip, the size limit is checked below on the final data
+		u.CheckErr(ag.ValidateUserData(data, 0), "ValidateUserData")
+	}
+	if *gzip_output {
+		data, err = ag.GzipUserData(data)
+		u.CheckErr(err, "GzipUserData")
+	}
+	if *max_size > 0 && len(data) > *max_size {
+		fmt.Fprintf(os.Stderr, "[ERROR] user-data is %d bytes, over the limit of %d. Try --gzip\n", len(data), *max_size)
+		os.Exit(1)
+	}
+	if *base64_output {
+		data = []byte(base64.StdEncoding.EncodeToString(data) + "\n")
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	u.CheckErr(os.WriteFile(*output, data, 0o600), "WriteFile")
+}
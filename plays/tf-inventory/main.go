@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	ag "github.com/sunshine69/automation-go/lib"
+	u "github.com/sunshine69/golang-tools/utils"
+)
+
+var (
+	version   string // Will hold the version number
+	buildTime string // Will hold the build time
+)
+
+func printVersionBuildInfo() {
+	fmt.Printf("Version: %s\nBuild time: %s\n", version, buildTime)
+}
+
+func main() {
+	optFlag := pflag.NewFlagSet("opt", pflag.ExitOnError)
+	list := optFlag.Bool("list", false, "Print the whole inventory (ansible dynamic inventory protocol)")
+	host := optFlag.String("host", "", "Print the vars of one host (ansible dynamic inventory protocol)")
+	state := optFlag.String("state", u.Getenv("TF_STATE", "terraform.tfstate"), "terraform state: a tfstate file, an http(s) url or a terraform directory (uses 'terraform state pull', works with any backend). Default from TF_STATE")
+	show_version := optFlag.Bool("version", false, "Print version")
+
+	optFlag.Usage = func() {
+		fmt.Printf(`Usage: %s --list [--state terraform.tfstate]
+		Ansible dynamic inventory from a terraform state. Use it as an inventory script, eg.
+
+		  export TF_STATE=/path/to/terraform/project
+		  ansible-playbook -i tf-inventory site.yml
+
+		Hosts are the compute resources (aws_instance, google_compute_instance, azurerm_*_virtual_machine ...). Groups are
+		the resource type, the module, tag_<key>_<value> and the groups listed in the 'ansible_groups' tag.
+
+		Options below:
+
+		`, os.Args[0])
+		optFlag.PrintDefaults()
+	}
+	optFlag.Parse(os.Args[1:])
+
+	if *show_version {
+		printVersionBuildInfo()
+		os.Exit(0)
+	}
+	if !*list && *host == "" {
+		optFlag.Usage()
+		os.Exit(2)
+	}
+
+	stateb, err := ag.LoadTerraformState(*state)
+	u.CheckErr(err, "LoadTerraformState")
+	inv, err := ag.TerraformInventory(stateb)
+	u.CheckErr(err, "TerraformInventory")
+
+	var out any = inv.ToDynamicJson()
+	if *host != "" {
+		hostVars, ok := inv.HostVars[*host]
+		if !ok {
+			hostVars = map[string]any{}
+		}
+		out = hostVars
+	}
+	je := json.NewEncoder(os.Stdout)
+	je.SetIndent("", "  ")
+	u.CheckErr(je.Encode(out), "Encode")
+}
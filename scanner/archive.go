@@ -0,0 +1,188 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ArchiveSep separate the archive path and the path of the file in it in the findings, eg app.jar!config/app.yaml
+const ArchiveSep = "!"
+
+// maxArchiveDepth is the number of nested archives opened, eg a jar in a war is depth 2
+const maxArchiveDepth = 3
+
+// maxArchiveMemory bound the size of a nested zip archive, it has to be read in memory
+const maxArchiveMemory = 256 * 1024 * 1024
+
+// archiveKind return zip, tar, tgz or gz for the archive file names, else an empty string
+func archiveKind(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tgz"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".gz"):
+		return "gz"
+	case strings.HasSuffix(name, ".zip"), strings.HasSuffix(name, ".jar"), strings.HasSuffix(name, ".war"), strings.HasSuffix(name, ".ear"):
+		return "zip"
+	}
+	return ""
+}
+
+// OuterPath return the path of the file on disk of a finding, the archive for a file in an archive
+func OuterPath(file string) string {
+	outer, _, _ := strings.Cut(file, ArchiveSep)
+	return outer
+}
+
+// processArchive scan the files in the archive at fpath
+func (s *Scanner) processArchive(ctx context.Context, fpath string, rules *Scanner, output_chan chan<- OutputFmt) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		s.Logger().Warn("can not read file", "path", fpath, "error", err)
+		s.skip(SkipUnreadable)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		s.Logger().Warn("can not read file", "path", fpath, "error", err)
+		s.skip(SkipUnreadable)
+		return
+	}
+	s.scanArchive(ctx, rules, fpath, f, info.Size(), 1, output_chan)
+}
+
+// scanArchive scan the files of an archive; name is its path, in the outer archives if nested. It returns false if
+// the context is done.
+func (s *Scanner) scanArchive(ctx context.Context, rules *Scanner, name string, r io.Reader, size int64, depth int, output_chan chan<- OutputFmt) bool {
+	switch kind := archiveKind(name); kind {
+	case "zip":
+		ra, ok := r.(io.ReaderAt)
+		if !ok { // a nested archive, zip needs random access
+			datab, err := io.ReadAll(io.LimitReader(r, maxArchiveMemory+1))
+			if err != nil || len(datab) > maxArchiveMemory {
+				s.Logger().Warn("skip nested archive, can not read it or too large", "path", name, "error", err)
+				return true
+			}
+			ra, size = bytes.NewReader(datab), int64(len(datab))
+		}
+		zr, err := zip.NewReader(ra, size)
+		if err != nil {
+			s.Logger().Warn("can not read archive", "path", name, "error", err)
+			return true
+		}
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				s.Logger().Warn("can not read archive entry", "path", name+ArchiveSep+zf.Name, "error", err)
+				continue
+			}
+			ok := s.scanArchiveEntry(ctx, rules, name+ArchiveSep+zf.Name, zf.Name, rc, int64(zf.UncompressedSize64), depth, output_chan)
+			rc.Close()
+			if !ok {
+				return false
+			}
+		}
+	case "tar", "tgz":
+		if kind == "tgz" {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				s.Logger().Warn("can not read archive", "path", name, "error", err)
+				return true
+			}
+			defer gz.Close()
+			r = gz
+		}
+		return s.scanTar(ctx, rules, name, name+ArchiveSep, tar.NewReader(r), depth, output_chan)
+	case "gz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			s.Logger().Warn("can not read archive", "path", name, "error", err)
+			return true
+		}
+		defer gz.Close()
+		inner := strings.TrimSuffix(path.Base(strings.ReplaceAll(name, ArchiveSep, "/")), path.Ext(name))
+		return s.scanArchiveEntry(ctx, rules, name+ArchiveSep+inner, inner, gz, -1, depth, output_chan)
+	}
+	return true
+}
+
+// scanTar scan the regular files of a tar archive; the findings are named prefix + the path in the archive. It
+// returns false if the context is done.
+func (s *Scanner) scanTar(ctx context.Context, rules *Scanner, name, prefix string, tr *tar.Reader, depth int, output_chan chan<- OutputFmt) bool {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			s.Logger().Warn("can not read archive", "path", name, "error", err)
+			return true
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		inner := strings.TrimPrefix(hdr.Name, "./")
+		if !s.scanArchiveEntry(ctx, rules, prefix+inner, inner, tr, hdr.Size, depth, output_chan) {
+			return false
+		}
+	}
+}
+
+// scanArchiveEntry scan a file of an archive, or the files of a nested archive with ScanArchives. The file name and
+// path patterns apply to the path in the archive. It returns false if the context is done.
+func (s *Scanner) scanArchiveEntry(ctx context.Context, rules *Scanner, name, inner string, r io.Reader, size int64, depth int, output_chan chan<- OutputFmt) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	s.filesScanned.Add(1)
+	if s.cfg.ScanArchives && archiveKind(inner) != "" {
+		if depth >= maxArchiveDepth {
+			s.Logger().Info("skip nested archive, too deep", "path", name)
+			s.skip(SkipTooDeep)
+			return true
+		}
+		return s.scanArchive(ctx, rules, name, r, size, depth+1, output_chan)
+	}
+	if rules.isExcludedPath(inner) || (rules.pathExcludePtn != nil && rules.pathExcludePtn.MatchString(name)) {
+		s.skip(SkipExcluded)
+		return true
+	}
+	if s.cfg.MaxFileSize > 0 && size > s.cfg.MaxFileSize {
+		s.Logger().Info("skip file larger than max-file-size", "path", name, "size", size)
+		s.skip(SkipTooLarge)
+		return true
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(8000)
+	enc, _ := detectEncoding(head[:min(len(head), encodingHead)])
+	var data io.Reader = decodeReader(br, enc)
+	if !isUTF16(enc) && bytes.IndexByte(head, 0) >= 0 {
+		switch {
+		case s.cfg.ScanBinaries == BinaryStrings:
+			if !s.binaryFits(name, size) {
+				return true
+			}
+			data = newStringsReader(io.LimitReader(br, s.cfg.MaxBinarySize)) // the size of a gz entry is not known
+		case s.cfg.SkipBinary:
+			s.Logger().Info("skip binary", "path", name)
+			s.skip(SkipBinary)
+			return true
+		}
+	}
+	s.filesProcessed.Add(1)
+	return s.newFileMatcher(ctx, rules, name, output_chan).matchAll(data) || ctx.Err() == nil
+}
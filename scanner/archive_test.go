@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestScanArchives(t *testing.T) {
+	dir := t.TempDir()
+	jar := zipBytes(t, map[string]string{"config/app.properties": "password=Xk9dLq2ZmP7wR4\n", "logo.png": "\x00\x01binary"})
+	var tgz bytes.Buffer
+	gw := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gw)
+	for name, content := range map[string]string{"etc/app.conf": "token='Ab3dEf9hIj2kLm'\n", "lib/app.jar": string(jar)} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	writeFiles(t, dir, map[string]string{
+		"app.zip":    string(zipBytes(t, map[string]string{"app.conf": "secret='Qw8eRt5yUi3oP'\n"})),
+		"dist.tgz":   tgz.String(),
+		"plain.conf": "host=localhost\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := Collect(s.Scan(context.Background(), dir)); len(output) != 0 {
+		t.Errorf("expect the archives skipped by default, got %v", output)
+	}
+	cfg.ScanArchives = true
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	output := Collect(s.Scan(context.Background(), dir))
+	got := []string{}
+	for file := range output {
+		rel, _ := filepath.Rel(dir, file)
+		got = append(got, rel)
+	}
+	sort.Strings(got)
+	want := []string{"app.zip!app.conf", "dist.tgz!etc/app.conf", "dist.tgz!lib/app.jar!config/app.properties"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expect findings in %v, got %v", want, got)
+	}
+	if OuterPath(filepath.Join(dir, want[2])) != filepath.Join(dir, "dist.tgz") {
+		t.Errorf("unexpected outer path %s", OuterPath(want[2]))
+	}
+}
+
+func tarBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
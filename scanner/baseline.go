@@ -0,0 +1,267 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SaveProfile write the profile as indented json, the format of the scan output
+func SaveProfile(filename string, profile ProjectOutputFmt) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	je := json.NewEncoder(f)
+	je.SetEscapeHTML(false)
+	je.SetIndent("", "  ")
+	if err := je.Encode(profile); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// MatchFinding tell if the finding is selected by the selector: its Fingerprint, its file, or file:line with line the
+// 0 based line number as in Line_no.
+func MatchFinding(o OutputFmt, selector string) bool {
+	if selector == "" {
+		return false
+	}
+	if selector == o.Fingerprint || selector == o.File {
+		return true
+	}
+	file, lineStr, ok := cutLast(selector, ":")
+	if !ok || file != o.File {
+		return false
+	}
+	line, err := strconv.Atoi(lineStr)
+	return err == nil && (slices.Contains(o.Line_no, line) || (o.End_line > 0 && len(o.Line_no) > 0 && line >= o.Line_no[0] && line <= o.End_line))
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if idx := strings.LastIndex(s, sep); idx >= 0 {
+		return s[:idx], s[idx+len(sep):], true
+	}
+	return s, "", false
+}
+
+// matchAny tell if the finding is selected by one of the selectors; no selector selects all
+func matchAny(o OutputFmt, selectors []string) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	for _, sel := range selectors {
+		if MatchFinding(o, sel) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddToProfile add the findings selected by the selectors (see MatchFinding; none selects all) to the profile so they
+// are accepted and not reported again, with their FirstSeen now if not set. It returns the findings added.
+func AddToProfile(profile, findings ProjectOutputFmt, selectors []string) []OutputFmt {
+	added := []OutputFmt{}
+	for file, matches := range findings {
+		for sig, o := range matches {
+			if !matchAny(o, selectors) {
+				continue
+			}
+			if _, ok := profile[file][sig]; ok {
+				continue
+			}
+			if _, ok := profile[file]; !ok {
+				profile[file] = map[string]OutputFmt{}
+			}
+			if o.FirstSeen == "" {
+				o.FirstSeen = time.Now().UTC().Format(time.RFC3339)
+			}
+			profile[file][sig] = o
+			added = append(added, o)
+		}
+	}
+	sortFindings(added)
+	return added
+}
+
+// RemoveFromProfile remove the findings selected by the selectors from the profile and return them. Unlike
+// AddToProfile at least one selector is needed.
+func RemoveFromProfile(profile ProjectOutputFmt, selectors []string) []OutputFmt {
+	removed := []OutputFmt{}
+	if len(selectors) == 0 {
+		return removed
+	}
+	for file, matches := range profile {
+		for sig, o := range matches {
+			if matchAny(o, selectors) {
+				removed = append(removed, o)
+				delete(matches, sig)
+			}
+		}
+		if len(matches) == 0 {
+			delete(profile, file)
+		}
+	}
+	sortFindings(removed)
+	return removed
+}
+
+// MergeProfiles return the union of the profiles, eg from several branches. A finding in more than one profile is
+// taken from the first one.
+func MergeProfiles(profiles ...ProjectOutputFmt) ProjectOutputFmt {
+	o := ProjectOutputFmt{}
+	for _, profile := range profiles {
+		AddToProfile(o, profile, nil)
+	}
+	return o
+}
+
+// PruneProfile remove the findings of the files that no longer exist and return them. Relative file paths are
+// resolved from root, the directory the profile was generated from. For a file in an archive the archive is checked.
+func PruneProfile(profile ProjectOutputFmt, root string) []OutputFmt {
+	removed := []OutputFmt{}
+	for file, matches := range profile {
+		fpath := OuterPath(file)
+		if !filepath.IsAbs(fpath) {
+			fpath = filepath.Join(root, fpath)
+		}
+		if _, err := os.Stat(fpath); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		for _, o := range matches {
+			removed = append(removed, o)
+		}
+		delete(profile, file)
+	}
+	sortFindings(removed)
+	return removed
+}
+
+// profileKey return the key, the file and the signature, of the profile finding of a finding: the one of the file with
+// the signature, or with the fingerprint. An empty sig or fingerprint is not looked up.
+func (s *Scanner) profileKey(file, sig, fingerprint string) (string, bool) {
+	if _, ok := s.profile[file][sig]; ok && sig != "" {
+		return file + "\x00" + sig, true
+	}
+	key, ok := s.profileFps[fingerprint]
+	return key, ok && fingerprint != ""
+}
+
+// markSeen record the profile finding as matched by the scan, see TouchProfile
+func (s *Scanner) markSeen(key string) {
+	s.mu.Lock()
+	s.profileSeen[key] = true
+	s.mu.Unlock()
+}
+
+// inProfile tell if a finding of the file is accepted in the profile, see profileKey, and mark it seen
+func (m *fileMatcher) inProfile(sig, fingerprint string) bool {
+	key, ok := m.s.profileKey(m.fpath, sig, fingerprint)
+	if ok {
+		m.s.markSeen(key)
+		if !slices.Contains(m.baselined, key) {
+			m.baselined = append(m.baselined, key)
+		}
+	}
+	return ok
+}
+
+// TouchProfile set the LastSeen of the profile findings matched by the last scan to now, and the FirstSeen of the ones
+// without one. A LastSeen of the same day is kept so a profile in git does not change on every run. It returns the
+// profile and whether it changed.
+func (s *Scanner) TouchProfile(now time.Time) (ProjectOutputFmt, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stamp, changed := now.UTC().Format(time.RFC3339), false
+	for file, matches := range s.profile {
+		for sig, o := range matches {
+			touched := o
+			if touched.FirstSeen == "" {
+				touched.FirstSeen = stamp
+			}
+			if s.profileSeen[file+"\x00"+sig] && !strings.HasPrefix(touched.LastSeen, stamp[:len("2006-01-02")]) {
+				touched.LastSeen = stamp
+			}
+			if touched.FirstSeen != o.FirstSeen || touched.LastSeen != o.LastSeen {
+				matches[sig], changed = touched, true
+			}
+		}
+	}
+	return s.profile, changed
+}
+
+// StaleFindings return the profile findings not seen for age: their LastSeen, or FirstSeen if never seen, is before
+// now minus age. The findings without dates are not stale, their age is not known.
+func StaleFindings(profile ProjectOutputFmt, age time.Duration, now time.Time) []OutputFmt {
+	stale := []OutputFmt{}
+	for _, matches := range profile {
+		for _, o := range matches {
+			if isStale(o, now.Add(-age)) {
+				stale = append(stale, o)
+			}
+		}
+	}
+	sortFindings(stale)
+	return stale
+}
+
+// PruneStale remove the stale findings of the profile, see StaleFindings, and return them
+func PruneStale(profile ProjectOutputFmt, age time.Duration, now time.Time) []OutputFmt {
+	removed := []OutputFmt{}
+	for file, matches := range profile {
+		for sig, o := range matches {
+			if isStale(o, now.Add(-age)) {
+				removed = append(removed, o)
+				delete(matches, sig)
+			}
+		}
+		if len(matches) == 0 {
+			delete(profile, file)
+		}
+	}
+	sortFindings(removed)
+	return removed
+}
+
+// isStale tell if the profile finding was last seen before the cutoff
+func isStale(o OutputFmt, cutoff time.Time) bool {
+	seen, err := time.Parse(time.RFC3339, firstNonEmpty(o.LastSeen, o.FirstSeen))
+	return err == nil && seen.Before(cutoff)
+}
+
+// withoutAges return a copy of the profile without the FirstSeen and LastSeen, what the findings depend on
+func withoutAges(profile ProjectOutputFmt) ProjectOutputFmt {
+	o := ProjectOutputFmt{}
+	for file, matches := range profile {
+		o[file] = map[string]OutputFmt{}
+		for sig, f := range matches {
+			f.FirstSeen, f.LastSeen = "", ""
+			o[file][sig] = f
+		}
+	}
+	return o
+}
+
+// ParseAge parse an age like 90d, in days, or a go duration like 36h
+func ParseAge(age string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(age, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q, expect a number of days like 90d or a duration like 36h", age)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(age)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q, expect a number of days like 90d or a duration like 36h", age)
+	}
+	return d, nil
+}
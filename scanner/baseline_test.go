@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBaseline(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.conf": "x"})
+	a := OutputFmt{File: filepath.Join(dir, "a.conf"), Line_no: []int{1}, Matches: []string{"password", "*****"}, Fingerprint: "fp-a"}
+	b := OutputFmt{File: filepath.Join(dir, "b.conf"), Line_no: []int{2, 5}, Matches: []string{"token", "*****"}, Fingerprint: "fp-b"}
+	findings := ProjectOutputFmt{a.File: {"password*****": a}, b.File: {"token*****": b}}
+
+	profile := ProjectOutputFmt{}
+	if added := AddToProfile(profile, findings, []string{b.File + ":5"}); len(added) != 1 || added[0].File != b.File {
+		t.Errorf("expect b added by file:line, got %v", added)
+	}
+	if added := AddToProfile(profile, findings, nil); len(added) != 1 || added[0].File != a.File {
+		t.Errorf("expect only a added, got %v", added)
+	}
+	if removed := RemoveFromProfile(profile, []string{"fp-a"}); len(removed) != 1 || len(profile) != 1 {
+		t.Errorf("expect a removed by fingerprint, got %v, profile %v", removed, profile)
+	}
+	if removed := RemoveFromProfile(profile, nil); len(removed) != 0 {
+		t.Errorf("expect nothing removed without selector, got %v", removed)
+	}
+
+	merged := MergeProfiles(profile, ProjectOutputFmt{a.File: {"password*****": a}})
+	if len(merged) != 2 {
+		t.Errorf("expect both files merged, got %v", merged)
+	}
+	// b.conf does not exist
+	if removed := PruneProfile(merged, dir); len(removed) != 1 || removed[0].File != b.File || len(merged) != 1 {
+		t.Errorf("expect b pruned, got %v, profile %v", removed, merged)
+	}
+
+	fpath := filepath.Join(dir, "profile.json")
+	if err := SaveProfile(fpath, merged); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadProfile(fpath); err != nil || loaded[a.File]["password*****"].Fingerprint != "fp-a" {
+		t.Errorf("unexpected profile %v, %v", loaded, err)
+	}
+}
+
+func TestProfileAges(t *testing.T) {
+	dir, tmp := t.TempDir(), t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.conf": "password=\"Xk9dLq2ZmP7wR4\"\n"})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := ProjectOutputFmt{}
+	if added := AddToProfile(profile, Collect(s.Scan(context.Background(), dir)), nil); len(added) != 1 || added[0].FirstSeen == "" {
+		t.Fatalf("expect the finding added with its FirstSeen, got %v", added)
+	}
+	gone := filepath.Join(dir, "gone.conf")
+	profile[gone] = map[string]OutputFmt{"password*****": {File: gone, Line_no: []int{0}, Fingerprint: "f1", FirstSeen: "2020-01-02T00:00:00Z"}}
+	cfg.ProfilePath, cfg.CachePath = filepath.Join(tmp, "profile.json"), filepath.Join(tmp, "cache.json")
+	if err := SaveProfile(cfg.ProfilePath, profile); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for run := range 2 { // the second run replays the cache
+		if err := s.Configure(cfg); err != nil {
+			t.Fatal(err)
+		}
+		if output := Collect(s.Scan(context.Background(), dir)); len(output) != 0 {
+			t.Fatalf("expect no findings with the profile, got %v", output)
+		}
+		touched, changed := s.TouchProfile(now)
+		if changed != (run == 0) {
+			t.Errorf("run %d: expect the profile changed only by the first run, got %v", run, changed)
+		}
+		if run == 0 {
+			if err := SaveProfile(cfg.ProfilePath, touched); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stale := StaleFindings(touched, 90*24*time.Hour, now)
+		if len(stale) != 1 || stale[0].File != gone {
+			t.Errorf("run %d: expect only the finding of the removed file stale, got %v", run, stale)
+		}
+	}
+	if st := s.Stats(); st.FilesCached != 1 {
+		t.Errorf("expect the profile ages not to reset the cache, got %+v", st)
+	}
+	if removed := PruneStale(profile, 90*24*time.Hour, now); len(removed) != 1 || len(profile) != 1 {
+		t.Errorf("expect the stale finding pruned, got %v and %v", removed, profile)
+	}
+	if age, err := ParseAge("90d"); err != nil || age != 90*24*time.Hour {
+		t.Errorf("expect 90 days, got %v %v", age, err)
+	}
+	if _, err := ParseAge("soon"); err == nil {
+		t.Error("expect an error for an invalid age")
+	}
+}
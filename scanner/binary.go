@@ -0,0 +1,76 @@
+package scanner
+
+import (
+	"bufio"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BinaryStrings is the Config.ScanBinaries mode matching the printable strings of the binary files, like strings(1),
+// instead of skipping them
+const BinaryStrings = "strings"
+
+// DefaultMaxBinarySize is the Config.MaxBinarySize used when not set
+const DefaultMaxBinarySize = 64 * 1024 * 1024
+
+// minStringLength is the length of the shortest string extracted from a binary, shorter ones are noise
+const minStringLength = 6
+
+// maxStringLength cut the longer strings so a large text section does not fill the memory
+const maxStringLength = 64 * 1024
+
+// stringsReader read the printable ascii and utf-8 runs of a binary, one per line. The findings of a binary are at
+// the index of the string as the line.
+type stringsReader struct {
+	r   *bufio.Reader
+	run []byte // the printable run being read
+	out []byte // the lines not read yet
+	err error
+}
+
+func newStringsReader(r io.Reader) *stringsReader {
+	return &stringsReader{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+func (sr *stringsReader) Read(p []byte) (int, error) {
+	for len(sr.out) == 0 && sr.err == nil {
+		c, size, err := sr.r.ReadRune()
+		if err != nil {
+			sr.err = err
+			sr.flush()
+			break
+		}
+		if (c == '\t' || unicode.IsPrint(c)) && !(c == utf8.RuneError && size == 1) {
+			sr.run = utf8.AppendRune(sr.run, c)
+			if len(sr.run) < maxStringLength {
+				continue
+			}
+		}
+		sr.flush()
+	}
+	if len(sr.out) > 0 {
+		n := copy(p, sr.out)
+		sr.out = sr.out[n:]
+		return n, nil
+	}
+	return 0, sr.err
+}
+
+// flush end the run, a line if it is long enough
+func (sr *stringsReader) flush() {
+	if len(sr.run) >= minStringLength {
+		sr.out = append(append(sr.out[:0], sr.run...), '\n')
+	}
+	sr.run = sr.run[:0]
+}
+
+// binaryFits tell if a binary is not larger than MaxBinarySize to have its strings matched, else it is skipped
+func (s *Scanner) binaryFits(name string, size int64) bool {
+	if size <= s.cfg.MaxBinarySize {
+		return true
+	}
+	s.Logger().Info("skip binary larger than max-binary-size", "path", name, "size", size)
+	s.skip(SkipTooLarge)
+	return false
+}
@@ -0,0 +1,51 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScanBinaries(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app": "\x7fELF\x02\x01\x00\x00\x00\x03\x00>\x00tiny\x00GCC: (GNU) 12.2.0\x00password='Zx9cVb7nMq2wE'\x00\x90\xff\xfe",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := Collect(s.Scan(context.Background(), dir)); len(output) != 0 || s.Stats().FilesSkipped[SkipBinary] != 1 {
+		t.Errorf("expect the binary skipped by default, got %v %+v", output, s.Stats())
+	}
+
+	cfg.ScanBinaries = BinaryStrings
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	output := Collect(s.Scan(context.Background(), dir))
+	found := output[filepath.Join(dir, "app")]
+	if len(found) != 1 {
+		t.Fatalf("expect the password of the binary strings found, got %v", output)
+	}
+	for _, o := range found {
+		if !reflect.DeepEqual(o.Line_no, []int{1}) { // ELF and tiny are too short, the password follows the GCC string
+			t.Errorf("expect the index of the string as the line, got %v", o.Line_no)
+		}
+	}
+
+	cfg.MaxBinarySize = 10
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if output := Collect(s.Scan(context.Background(), dir)); len(output) != 0 || s.Stats().FilesSkipped[SkipTooLarge] != 1 {
+		t.Errorf("expect the binary over max binary size skipped, got %v %+v", output, s.Stats())
+	}
+	cfg.ScanBinaries = "hex"
+	if err := s.Configure(cfg); err == nil {
+		t.Error("expect an unknown scan binaries mode refused")
+	}
+}
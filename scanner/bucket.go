@@ -0,0 +1,410 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ag "github.com/sunshine69/automation-go/lib"
+)
+
+// DefaultMaxObjectSize bound the download of an object of a bucket when MaxFileSize is not set; the larger objects
+// are skipped
+const DefaultMaxObjectSize = 100 * 1024 * 1024
+
+// BucketObject is an object of a bucket, Key is its path in the bucket
+type BucketObject struct {
+	Key  string
+	Size int64
+}
+
+// Bucket is a storage backend of ScanBucket, the objects of a cloud bucket under a prefix
+type Bucket interface {
+	// List call fn for each object under the prefix, it stops at the first error of fn
+	List(ctx context.Context, fn func(BucketObject) error) error
+	// Open read an object
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// BucketBackends open the bucket of a url by its scheme: s3://bucket/prefix, gs://bucket/prefix and
+// az://container/prefix. Add one to scan another storage.
+var BucketBackends = map[string]func(u *url.URL) (Bucket, error){
+	"s3": openS3Bucket,
+	"gs": openGCSBucket,
+	"az": openAzureBucket,
+}
+
+// IsBucketURL tell if the argument is the url of a bucket of one of the BucketBackends. An existing path is always
+// local.
+func IsBucketURL(arg string) bool {
+	if _, err := os.Stat(arg); err == nil {
+		return false
+	}
+	scheme, _, ok := strings.Cut(arg, "://")
+	_, known := BucketBackends[scheme]
+	return ok && known
+}
+
+// OpenBucket open the bucket of the url with its backend
+func OpenBucket(bucketURL string) (Bucket, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, err
+	}
+	open, ok := BucketBackends[u.Scheme]
+	if !ok || u.Host == "" {
+		return nil, fmt.Errorf("invalid bucket url %q, expect s3://bucket/prefix, gs://bucket/prefix or az://container/prefix", bucketURL)
+	}
+	return open(u)
+}
+
+// ScanBucket scan the objects of a bucket like the files of a directory: the name and path patterns apply to their
+// keys and the findings are in <scheme>://<bucket>/<key>. Concurrency objects are streamed at a time, each read up to
+// MaxFileSize bytes, DefaultMaxObjectSize if not set; the larger ones are skipped. The channel is closed when the
+// scan is done or the context is cancelled; check Err() afterward.
+func (s *Scanner) ScanBucket(ctx context.Context, bucketURL string) <-chan OutputFmt {
+	output_chan := make(chan OutputFmt)
+	s.resetStats()
+	s.err = nil
+	s.suppressed = nil
+	s.cache, s.checkpoint = nil, nil
+	s.owners = nil
+	go func() {
+		defer close(output_chan)
+		defer s.finishStats()
+		bucket, err := OpenBucket(bucketURL)
+		if err != nil {
+			s.err = err
+			return
+		}
+		u, _ := url.Parse(bucketURL)
+		base := u.Scheme + "://" + u.Host + "/"
+		limit := s.cfg.MaxFileSize
+		if limit <= 0 {
+			limit = DefaultMaxObjectSize
+		}
+		jobs := make(chan BucketObject)
+		var wg sync.WaitGroup
+		for range s.cfg.Concurrency {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for obj := range jobs {
+					s.scanObject(ctx, bucket, base+obj.Key, obj, limit, output_chan)
+				}
+			}()
+		}
+		err = bucket.List(ctx, func(obj BucketObject) error {
+			if strings.HasSuffix(obj.Key, "/") { // a folder placeholder
+				return nil
+			}
+			select {
+			case jobs <- obj:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(jobs)
+		wg.Wait()
+		if err == nil {
+			err = ctx.Err()
+		}
+		s.err = err
+	}()
+	return output_chan
+}
+
+// scanObject download an object up to limit bytes and scan it as the file name
+func (s *Scanner) scanObject(ctx context.Context, bucket Bucket, name string, obj BucketObject, limit int64, output_chan chan<- OutputFmt) {
+	if obj.Size > limit {
+		s.filesScanned.Add(1)
+		s.Logger().Info("skip object larger than the max size", "path", name, "size", obj.Size)
+		s.skip(SkipTooLarge)
+		return
+	}
+	if s.isExcludedPath(obj.Key) {
+		s.filesScanned.Add(1)
+		s.skip(SkipExcluded)
+		return
+	}
+	r, err := bucket.Open(ctx, obj.Key)
+	if err != nil {
+		s.filesScanned.Add(1)
+		if ctx.Err() == nil {
+			s.Logger().Warn("can not read object", "path", name, "error", err)
+			s.skip(SkipUnreadable)
+		}
+		return
+	}
+	defer r.Close()
+	defer s.timings.file(name, obj.Size, s.timings.start())
+	s.scanArchiveEntry(ctx, s, name, obj.Key, io.LimitReader(r, limit), obj.Size, 0, output_chan)
+}
+
+// bucketClient is the http client of the backends, the downloads are bound by the context
+var bucketClient = &http.Client{}
+
+// bucketGet do the request and return the body of a 200 response
+func bucketGet(req *http.Request) (io.ReadCloser, error) {
+	resp, err := bucketClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s://%s%s: %s %s", req.URL.Scheme, req.URL.Host, req.URL.Path, resp.Status, strings.TrimSpace(string(msg))) // the query may have a token
+	}
+	return resp.Body, nil
+}
+
+// s3Bucket read a bucket with the S3 REST API, signed with the aws credentials of the env or the profile like the aws
+// cli. AWS_ENDPOINT_URL selects another S3 compatible endpoint (minio, localstack), with path style urls.
+type s3Bucket struct {
+	cfg            *ag.AwsConfig
+	bucket, prefix string
+}
+
+func openS3Bucket(u *url.URL) (Bucket, error) {
+	cfg, err := ag.LoadAwsConfig("", "")
+	if err != nil {
+		return nil, err
+	}
+	return &s3Bucket{cfg: cfg, bucket: u.Host, prefix: strings.TrimPrefix(u.Path, "/")}, nil
+}
+
+// request return the signed GET request of a key of the bucket, the bucket itself if key is empty
+func (b *s3Bucket) request(ctx context.Context, key string, query url.Values) (*http.Request, error) {
+	base := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", b.bucket, b.cfg.Region)
+	p := "/" + key
+	if b.cfg.Endpoint != "" {
+		base, p = strings.TrimSuffix(b.cfg.Endpoint, "/"), "/"+b.bucket
+		if key != "" {
+			p += "/" + key
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", base, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Path, req.URL.RawPath = p, s3Escape(p) // the path is sent encoded as it is signed
+	req.URL.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	ag.SignAwsRequestV4(req, nil, b.cfg.Credentials, b.cfg.Region, "s3", time.Now())
+	return req, nil
+}
+
+func (b *s3Bucket) List(ctx context.Context, fn func(BucketObject) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {b.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := b.request(ctx, "", query)
+		if err != nil {
+			return err
+		}
+		body, err := bucketGet(req)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Contents []struct {
+				Key  string
+				Size int64
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(body).Decode(&page)
+		body.Close()
+		if err != nil {
+			return err
+		}
+		for _, c := range page.Contents {
+			if err := fn(BucketObject{Key: c.Key, Size: c.Size}); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (b *s3Bucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := b.request(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return bucketGet(req)
+}
+
+// s3Escape encode a path like the aws signature: all but the unreserved characters and the slashes
+func s3Escape(p string) string {
+	var sb strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || strings.IndexByte("-_.~/", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// gcsBucket read a bucket with the Google Cloud Storage JSON API, with the token of GOOGLE_OAUTH_ACCESS_TOKEN (eg
+// gcloud auth print-access-token), anonymous for a public bucket. STORAGE_EMULATOR_HOST selects an emulator.
+type gcsBucket struct {
+	base, token    string
+	bucket, prefix string
+}
+
+func openGCSBucket(u *url.URL) (Bucket, error) {
+	base := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		base = strings.TrimSuffix(host, "/")
+		if !strings.Contains(base, "://") {
+			base = "http://" + base
+		}
+	}
+	return &gcsBucket{base: base, token: os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"), bucket: u.Host, prefix: strings.TrimPrefix(u.Path, "/")}, nil
+}
+
+func (b *gcsBucket) get(ctx context.Context, u string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	return bucketGet(req)
+}
+
+func (b *gcsBucket) List(ctx context.Context, fn func(BucketObject) error) error {
+	token := ""
+	for {
+		query := url.Values{"prefix": {b.prefix}, "fields": {"items(name,size),nextPageToken"}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		body, err := b.get(ctx, fmt.Sprintf("%s/storage/v1/b/%s/o?%s", b.base, url.PathEscape(b.bucket), query.Encode()))
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+				Size string `json:"size"` // an int64 as a string
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(body).Decode(&page)
+		body.Close()
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			size, _ := strconv.ParseInt(item.Size, 10, 64)
+			if err := fn(BucketObject{Key: item.Name, Size: size}); err != nil {
+				return err
+			}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+func (b *gcsBucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return b.get(ctx, fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", b.base, url.PathEscape(b.bucket), url.PathEscape(key)))
+}
+
+// azureBucket read a container of the storage account AZURE_STORAGE_ACCOUNT with the Azure Blob REST API, with the
+// SAS token of AZURE_STORAGE_SAS_TOKEN, anonymous for a public container. AZURE_STORAGE_BLOB_ENDPOINT selects another
+// endpoint, eg azurite.
+type azureBucket struct {
+	base, sas         string
+	container, prefix string
+}
+
+func openAzureBucket(u *url.URL) (Bucket, error) {
+	base := os.Getenv("AZURE_STORAGE_BLOB_ENDPOINT")
+	if base == "" {
+		account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+		if account == "" {
+			return nil, fmt.Errorf("az:// needs the storage account in AZURE_STORAGE_ACCOUNT")
+		}
+		base = "https://" + account + ".blob.core.windows.net"
+	}
+	return &azureBucket{base: strings.TrimSuffix(base, "/"), sas: strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+		container: u.Host, prefix: strings.TrimPrefix(u.Path, "/")}, nil
+}
+
+func (b *azureBucket) get(ctx context.Context, p string, query url.Values) (io.ReadCloser, error) {
+	q := query.Encode()
+	if b.sas != "" {
+		q = strings.TrimPrefix(q+"&"+b.sas, "&")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", b.base+p+"?"+q, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", "2021-08-06")
+	return bucketGet(req)
+}
+
+func (b *azureBucket) List(ctx context.Context, fn func(BucketObject) error) error {
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {b.prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		body, err := b.get(ctx, "/"+url.PathEscape(b.container), query)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Blobs []struct {
+				Name       string
+				Properties struct {
+					ContentLength int64 `xml:"Content-Length"`
+				}
+			} `xml:"Blobs>Blob"`
+			NextMarker string
+		}
+		err = xml.NewDecoder(body).Decode(&page)
+		body.Close()
+		if err != nil {
+			return err
+		}
+		for _, blob := range page.Blobs {
+			if err := fn(BucketObject{Key: blob.Name, Size: blob.Properties.ContentLength}); err != nil {
+				return err
+			}
+		}
+		if page.NextMarker == "" {
+			return nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+func (b *azureBucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return b.get(ctx, "/"+url.PathEscape(b.container)+"/"+(&url.URL{Path: key}).EscapedPath(), url.Values{})
+}
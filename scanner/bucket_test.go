@@ -0,0 +1,76 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScanBucket(t *testing.T) {
+	objects := map[string]string{
+		"app/config.env":    "DB_PASSWORD=Zx9cVb7nMq2wE\n",
+		"app/notes (1).txt": "token: Qw8eRt5yUi3oPlk\n",
+		"app/big.log":       strings.Repeat("a", 2048),
+		"app/":              "",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/bucket" && r.URL.Query().Get("list-type") == "2":
+			if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `<ListBucketResult>`)
+			for _, key := range []string{"app/", "app/big.log", "app/config.env", "app/notes (1).txt"} {
+				fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size></Contents>`, key, len(objects[key]))
+			}
+			fmt.Fprint(w, `<IsTruncated>false</IsTruncated></ListBucketResult>`)
+		case r.URL.Path == "/storage/v1/b/bucket/o":
+			fmt.Fprintf(w, `{"items": [{"name": "app/config.env", "size": "%d"}]}`, len(objects["app/config.env"]))
+		case strings.HasPrefix(r.URL.Path, "/storage/v1/b/bucket/o/") && r.URL.Query().Get("alt") == "media":
+			fmt.Fprint(w, objects[strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")])
+		case strings.HasPrefix(r.URL.Path, "/bucket/"):
+			if r.URL.RawPath != "" && !strings.Contains(r.URL.RawPath, "%20%281%29") {
+				w.WriteHeader(http.StatusBadRequest) // the key is not encoded as signed
+				return
+			}
+			fmt.Fprint(w, objects[strings.TrimPrefix(r.URL.Path, "/bucket/")])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	t.Setenv("STORAGE_EMULATOR_HOST", srv.URL)
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Structured = true
+	cfg.MaxFileSize = 1024
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsBucketURL("s3://bucket/app") || IsBucketURL("app/s3") {
+		t.Errorf("unexpected IsBucketURL")
+	}
+	output := Collect(s.ScanBucket(context.Background(), "s3://bucket/app"))
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := output["s3://bucket/app/config.env"]; !ok || len(output["s3://bucket/app/notes (1).txt"]) == 0 {
+		t.Errorf("expect the findings of the objects, got %v", output)
+	}
+	if st := s.Stats(); st.FilesScanned != 3 || st.FilesSkipped[SkipTooLarge] != 1 {
+		t.Errorf("expect the object larger than max-file-size skipped, got %+v", st)
+	}
+	output = Collect(s.ScanBucket(context.Background(), "gs://bucket/app"))
+	if _, ok := output["gs://bucket/app/config.env"]; !ok || s.Err() != nil {
+		t.Errorf("expect the findings of the gcs object, got %v %v", output, s.Err())
+	}
+}
@@ -0,0 +1,194 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheVersion is bumped when the matching changes so the old caches are not used
+const cacheVersion = 9
+
+// CacheEntry is the result of the last scan of a file
+type CacheEntry struct {
+	Size       int64
+	ModTime    time.Time
+	Hash       string // sha256 of the content
+	Findings   []OutputFmt
+	Suppressed []OutputFmt `json:",omitempty"`
+	Rules      string      `json:",omitempty"` // the hash of the config of its directory if it has a local config, see LocalConfig
+	Baselined  []string    `json:",omitempty"` // the profile findings matched, see profileKey
+	Vault      bool        `json:",omitempty"` // the file has inline !vault values, see Stats.VaultEncrypted
+}
+
+// Cache keep the findings of each file between runs so only the changed files are scanned again. It is only valid
+// for the config and profile it was made with, see cacheKey.
+type Cache struct {
+	Version int
+	Key     string
+	Files   map[string]CacheEntry
+
+	path string
+	seen map[string]bool // files of this scan, the others are dropped on Save
+	mu   sync.Mutex
+}
+
+// LoadCache load the cache file. A missing file, or a cache made for another key, gives an empty cache.
+func LoadCache(path, key string) (*Cache, error) {
+	c := &Cache{Version: cacheVersion, Key: key, Files: map[string]CacheEntry{}, path: path, seen: map[string]bool{}}
+	datab, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	old := Cache{}
+	if err := json.Unmarshal(datab, &old); err != nil {
+		return c, fmt.Errorf("invalid cache %s - %w", path, err)
+	}
+	if old.Version == cacheVersion && old.Key == key && old.Files != nil {
+		c.Files = old.Files
+	}
+	return c, nil
+}
+
+// Save write the entries of the files seen since the cache was loaded
+func (c *Cache) Save() error {
+	return c.write(true)
+}
+
+// write write the cache file; prune drops the entries of the files not seen, a checkpoint keeps them for the rest of
+// the scan
+func (c *Cache) write(prune bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for fpath := range c.Files {
+		if prune && !c.seen[fpath] {
+			delete(c.Files, fpath)
+		}
+	}
+	datab, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, datab, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Lookup return the entry of the file if its content did not change: same size and modification time, or same
+// content hash.
+func (c *Cache) Lookup(fpath string, info fs.FileInfo) (CacheEntry, bool) {
+	c.mu.Lock()
+	c.seen[fpath] = true
+	entry, ok := c.Files[fpath]
+	c.mu.Unlock()
+	if !ok || entry.Size != info.Size() {
+		return entry, false
+	}
+	if entry.ModTime.Equal(info.ModTime()) {
+		return entry, true
+	}
+	if hash, err := fileHash(fpath); err != nil || hash != entry.Hash {
+		return entry, false
+	}
+	entry.ModTime = info.ModTime()
+	c.Put(fpath, entry)
+	return entry, true
+}
+
+// Put set the entry of the file
+func (c *Cache) Put(fpath string, entry CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[fpath] = true
+	c.Files[fpath] = entry
+}
+
+func fileHash(fpath string) (string, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// cacheKey identify the config and the profile the findings depend on
+func (s *Scanner) cacheKey() string {
+	cfg := s.cfg
+	cfg.CachePath, cfg.CheckpointPath, cfg.Concurrency = "", "", 0
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", cfg.Hash())
+	if cfg.Redact == RedactHmac { // the hmac values depend on the key
+		fmt.Fprintf(h, "%x\x00", sha256.Sum256([]byte(cfg.RedactKey)))
+	}
+	json.NewEncoder(h).Encode(withoutAges(s.profile)) // touching the profile keeps the cache
+	return fmt.Sprintf("%x", h.Sum(nil))[:32]
+}
+
+// CheckpointInterval is how often a scan with Config.CheckpointPath saves its progress
+var CheckpointInterval = 30 * time.Second
+
+// loadCheckpoint load the checkpoint of an interrupted scan of the root. Its files done and unchanged are not matched
+// again, their findings are replayed like the cached ones.
+func (s *Scanner) loadCheckpoint(root string) *Cache {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = root
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s", s.cacheKey(), abs)
+	c, err := LoadCache(s.cfg.CheckpointPath, fmt.Sprintf("%x", h.Sum(nil))[:32])
+	if err != nil {
+		s.Logger().Warn("can not load checkpoint, scanning all files", "checkpoint", s.cfg.CheckpointPath, "error", err)
+	} else if len(c.Files) > 0 {
+		s.Logger().Info("resuming scan from checkpoint", "checkpoint", s.cfg.CheckpointPath, "files", len(c.Files))
+	}
+	return c
+}
+
+// saveCheckpoints save the checkpoint every CheckpointInterval until done is closed
+func (s *Scanner) saveCheckpoints(done <-chan struct{}) {
+	ticker := time.NewTicker(CheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.checkpoint.write(false); err != nil {
+				s.Logger().Warn("can not save checkpoint", "checkpoint", s.cfg.CheckpointPath, "error", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// endCheckpoint remove the checkpoint of a complete scan, else save it to resume the scan
+func (s *Scanner) endCheckpoint(complete bool) {
+	if complete {
+		if err := os.Remove(s.cfg.CheckpointPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			s.Logger().Warn("can not remove checkpoint", "checkpoint", s.cfg.CheckpointPath, "error", err)
+		}
+		return
+	}
+	if err := s.checkpoint.write(false); err != nil {
+		s.Logger().Warn("can not save checkpoint", "checkpoint", s.cfg.CheckpointPath, "error", err)
+		return
+	}
+	s.Logger().Info("checkpoint saved", "checkpoint", s.cfg.CheckpointPath, "files", len(s.checkpoint.Files))
+}
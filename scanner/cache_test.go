@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScanCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.conf":   "password='Xk9dLq2ZmP7wR4'\n# cred-detect:ignore\ntoken='Ab3dEf9hIj2kLm'\n",
+		"clean.conf": "host=localhost\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.CachePath = filepath.Join(dir, ".cred-detect-cache.json")
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := Collect(s.Scan(context.Background(), dir))
+	if st := s.Stats(); st.FilesProcessed != 2 || st.FilesCached != 0 {
+		t.Errorf("expect all files scanned the first time, got %+v", st)
+	}
+	second := Collect(s.Scan(context.Background(), dir))
+	if st := s.Stats(); st.FilesProcessed != 0 || st.FilesCached != 2 {
+		t.Errorf("expect all files from the cache, got %+v", st)
+	}
+	if !reflect.DeepEqual(first, second) || len(s.Suppressed()) != 1 {
+		t.Errorf("expect the same findings from the cache, got %v and %v, suppressed %v", first, second, s.Suppressed())
+	}
+
+	// a changed file is scanned again, the cache is reset when the config changes
+	writeFiles(t, dir, map[string]string{"clean.conf": "secret='Qw8eRt5yUi3oP'\n"})
+	if output := Collect(s.Scan(context.Background(), dir)); len(output) != 2 {
+		t.Errorf("expect the new secret found, got %v", output)
+	}
+	if st := s.Stats(); st.FilesProcessed != 1 || st.FilesCached != 1 {
+		t.Errorf("expect only the changed file scanned, got %+v", st)
+	}
+	cfg.EntropyThreshold = 2
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	Collect(s.Scan(context.Background(), dir))
+	if st := s.Stats(); st.FilesCached != 0 {
+		t.Errorf("expect the cache reset after a config change, got %+v", st)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.conf": "nothing here\n",
+		"b.conf": "password='Zx9cVb7nMq2wE'\n",
+		"c.conf": "secret='Qw8eRt5yUi3oP'\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Concurrency = 1
+	cfg.CheckpointPath = filepath.Join(dir, "scan.ckpt")
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// interrupted at the first finding, a.conf is done
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for range s.Scan(ctx, dir) {
+		cancel()
+	}
+	if !errors.Is(s.Err(), context.Canceled) {
+		t.Fatalf("expect the scan interrupted, got %v", s.Err())
+	}
+	if _, err := os.Stat(cfg.CheckpointPath); err != nil {
+		t.Fatalf("expect the checkpoint saved, got %v", err)
+	}
+
+	output := Collect(s.Scan(context.Background(), dir))
+	if st := s.Stats(); st.FilesCached == 0 || st.FilesCached+st.FilesProcessed != 3 {
+		t.Errorf("expect the files done replayed from the checkpoint, got %+v", st)
+	}
+	if len(output) != 2 {
+		t.Errorf("expect the findings of b.conf and c.conf, got %v", output)
+	}
+	if _, err := os.Stat(cfg.CheckpointPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expect the checkpoint removed after a complete scan, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CodeOwnersFiles are the places of the CODEOWNERS file in a repository, relative to its root, in the order GitHub
+// and GitLab look for it
+var CodeOwnersFiles = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// CodeOwners map the paths of a repository to their owners, the users and teams of a CODEOWNERS file
+type CodeOwners struct {
+	root  string
+	rules []ownerRule
+}
+
+// ownerRule is a line of a CODEOWNERS file
+type ownerRule struct {
+	ignoreRule
+	files  bool // the pattern ends with /*, it does not own the sub directories
+	owners []string
+}
+
+// ParseCodeOwners parse a CODEOWNERS file: lines of a gitignore pattern and the owners, eg.
+// "/deploy/ @org/ops alice@example.com". The last line matching a path gives its owners, a pattern matching a
+// directory owns all its files. The ! patterns and the GitLab section headers are skipped.
+func ParseCodeOwners(data string) *CodeOwners {
+	c := &CodeOwners{}
+	for _, line := range strings.Split(data, "\n") {
+		line, _, _ = strings.Cut(line, " #")
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "!") || strings.HasPrefix(strings.TrimPrefix(fields[0], "^"), "[") {
+			continue
+		}
+		for _, rule := range parseIgnore(fields[0]) {
+			c.rules = append(c.rules, ownerRule{ignoreRule: rule, files: strings.HasSuffix(fields[0], "/*"), owners: fields[1:]})
+		}
+	}
+	return c
+}
+
+// LoadCodeOwners read the CODEOWNERS file of the repository in root, see CodeOwnersFiles; nil if it has none
+func LoadCodeOwners(root string) (*CodeOwners, error) {
+	for _, name := range CodeOwnersFiles {
+		c, err := LoadCodeOwnersFile(filepath.Join(root, filepath.FromSlash(name)), root)
+		if !os.IsNotExist(err) {
+			return c, err
+		}
+	}
+	return nil, nil
+}
+
+// LoadCodeOwnersFile read a CODEOWNERS file, its patterns are relative to root
+func LoadCodeOwnersFile(fpath, root string) (*CodeOwners, error) {
+	datab, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	c := ParseCodeOwners(string(datab))
+	c.root = root
+	return c, nil
+}
+
+// Owners return the owners of the path relative to the root with slashes, nil if it has none
+func (c *CodeOwners) Owners(rel string) []string {
+	var owners []string
+	parts := strings.Split(rel, "/")
+	for _, rule := range c.rules {
+		for idx := len(parts); idx > 0; idx-- {
+			isDir := idx < len(parts)
+			if isDir && rule.files {
+				break
+			}
+			if (!rule.dirOnly || isDir) && rule.re.MatchString(strings.Join(parts[:idx], "/")) {
+				owners = rule.owners
+				break
+			}
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	return owners
+}
+
+// OwnersOf return the owners of a file of a finding, a path under root; the file of an archive is owned like the
+// archive
+func (c *CodeOwners) OwnersOf(file string) []string {
+	file, _, _ = strings.Cut(file, ArchiveSep)
+	rel, err := filepath.Rel(c.root, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	return c.Owners(filepath.ToSlash(rel))
+}
+
+// loadOwners return the CODEOWNERS of the scan of root: CodeOwnersPath, else the one of the root if it is a directory
+func (s *Scanner) loadOwners(root string) *CodeOwners {
+	if s.cfg.NoCodeOwners {
+		return nil
+	}
+	var c *CodeOwners
+	var err error
+	if s.cfg.CodeOwnersPath != "" {
+		c, err = LoadCodeOwnersFile(s.cfg.CodeOwnersPath, root)
+	} else if info, serr := os.Stat(root); serr == nil && info.IsDir() {
+		c, err = LoadCodeOwners(root)
+	}
+	if err != nil {
+		s.Logger().Warn("can not read the CODEOWNERS file, the findings have no owners", "error", err)
+		return nil
+	}
+	return c
+}
+
+// setOwners set the Owners of the finding from the CODEOWNERS of the scan
+func (s *Scanner) setOwners(o *OutputFmt) {
+	if s.owners != nil {
+		o.Owners = s.owners.OwnersOf(o.File)
+	}
+}
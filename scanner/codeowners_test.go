@@ -0,0 +1,52 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCodeOwners(t *testing.T) {
+	c := ParseCodeOwners(`# owners
+* @org/all
+*.pem @org/security
+/deploy/ @org/ops # infra
+docs/* docs@example.com
+/vendor/
+[Section]
+`)
+	for rel, want := range map[string][]string{
+		"main.go":               {"@org/all"},
+		"certs/key.pem":         {"@org/security"},
+		"deploy/prod/app.yaml":  {"@org/ops"},
+		"deploy/tls.pem":        {"@org/ops"},
+		"docs/setup.md":         {"docs@example.com"},
+		"docs/nested/setup.md":  {"@org/all"},
+		"vendor/lib/config.yml": nil,
+	} {
+		if got := c.Owners(rel); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expect the owners %v, got %v", rel, want, got)
+		}
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".github/CODEOWNERS": "/deploy/ @org/ops\n",
+		"deploy/app.conf":    "password=\"Xk9dLq2ZmP7wR4\"\n",
+		"app.conf":           "password=\"Xk9dLq2ZmP7wR4\"\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, o := range Collect(s.Scan(context.Background(), dir)).List() {
+		rel, _ := filepath.Rel(dir, o.File)
+		got[filepath.ToSlash(rel)] = o.Owners
+	}
+	if want := map[string][]string{"deploy/app.conf": {"@org/ops"}, "app.conf": nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expect the owners of the findings %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// blobPtn match the base64 (standard or url) and hex blobs decoded with DecodeDepth; 16 base64 characters are 12
+// bytes, a short password in a kubernetes Secret
+var blobPtn = regexp.MustCompile(`[A-Za-z0-9+/_-]{16,}={0,2}`)
+
+var hexBlobPtn = regexp.MustCompile(`^(?:[0-9a-fA-F]{2}){16,}$`)
+
+// decodeBlob return the text a hex or base64 blob decodes to, ok is false if it does not decode to text
+func decodeBlob(blob string) (string, bool) {
+	if hexBlobPtn.MatchString(blob) {
+		if datab, err := hex.DecodeString(blob); err == nil && isText(datab) {
+			return string(datab), true
+		}
+	}
+	enc := base64.StdEncoding
+	if strings.ContainsAny(blob, "-_") {
+		enc = base64.URLEncoding
+	}
+	if !strings.HasSuffix(blob, "=") {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	datab, err := enc.DecodeString(blob)
+	if err != nil || !isText(datab) {
+		return "", false
+	}
+	return string(datab), true
+}
+
+// isText tell if the decoded bytes are printable utf8 text of at least 8 bytes; the random bytes of a token or of a
+// hash are not
+func isText(datab []byte) bool {
+	if len(datab) < 8 || !utf8.Valid(datab) {
+		return false
+	}
+	for _, r := range string(datab) {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// matchDecoded decode the blobs of text, the line idx or a line decoded from it, and run the patterns and the
+// multi-line detectors over the text with each blob replaced by what it decodes to. So the key of a kubernetes
+// Secret stays next to its decoded value, and the private key of a kubeconfig client-key-data is found. The decoded
+// text is decoded again up to DecodeDepth. It returns false if the context is done.
+func (m *fileMatcher) matchDecoded(idx int, text, raw string, depth int) bool {
+	if depth >= m.s.cfg.DecodeDepth {
+		return true
+	}
+	for _, loc := range blobPtn.FindAllStringIndex(text, -1) {
+		decoded, ok := decodeBlob(text[loc[0]:loc[1]])
+		if !ok {
+			continue
+		}
+		if m.s.cfg.Debug {
+			m.s.Logger().Debug("decoded blob", "path", m.fpath, "line", idx, "depth", depth+1)
+		}
+		blocks := make([]*blockFinder, 0, len(m.rules.blockDetectors))
+		for _, d := range m.rules.blockDetectors {
+			blocks = append(blocks, &blockFinder{d: d})
+		}
+		prev := m.prev
+		for _, line := range strings.Split(text[:loc[0]]+decoded+text[loc[1]:], "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			if !m.matchPatterns(idx, line, raw, m.prev) || !m.matchDecoded(idx, line, raw, depth+1) {
+				return false
+			}
+			for _, f := range blocks {
+				if b := f.next(idx, line, prev); b != nil && !m.matchBlock(f.d, b) {
+					return false
+				}
+			}
+			prev = line
+		}
+		for _, f := range blocks {
+			if b := f.flush(); b != nil && !m.matchBlock(f.d, b) {
+				return false
+			}
+		}
+	}
+	return true
+}
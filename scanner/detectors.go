@@ -0,0 +1,195 @@
+package scanner
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Confidence levels of the findings
+const (
+	ConfidenceHigh   = "high"   // a well known token format, almost never a false positive
+	ConfidenceMedium = "medium" // a known format that also matches other data
+	ConfidenceLow    = "low"    // a generic pattern filtered by the password heuristic
+)
+
+// Severity levels of the findings, the impact if the secret is real
+const (
+	SeverityHigh   = "high"   // gives access to a cloud account, a code host or money
+	SeverityMedium = "medium" // gives access to something, eg a password of unknown use
+	SeverityLow    = "low"    // usually not a secret, eg a certificate
+)
+
+// Detector is a built-in pattern for a well known token format. The value is the capture group 1 if the pattern has
+// one, else the whole match. Unlike the generic patterns the matches are not filtered by the password heuristic.
+type Detector struct {
+	ID          string
+	Name        string
+	Pattern     string
+	Severity    string
+	Confidence  string
+	Keywords    []string // lower case; if set the pattern only runs on the lines having one of them
+	SecretGroup int      // the capture group of the value if not 1
+	Remediation string   // how to revoke or rotate the secret, copied to the findings
+	DocURL      string   // the documentation of the remediation
+}
+
+// GenericRemediation is the remediation of the findings of the generic patterns and the structured scan
+const GenericRemediation = "Remove the value from the file, rotate it, and load it at run time from a secret store or the environment"
+
+// BuiltinDetectors are the structured detectors enabled by default, see Config.Detectors
+var BuiltinDetectors = []Detector{
+	{ID: "aws-access-key-id", Name: "AWS access key id", Pattern: `\b((?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16})\b`, Severity: SeverityHigh, Confidence: ConfidenceHigh, Remediation: "Deactivate and delete the access key in the AWS IAM console (Users > Security credentials), then create a new one", DocURL: "https://docs.aws.amazon.com/IAM/latest/UserGuide/id_credentials_access-keys.html#rotating_access_keys_console"},
+	{ID: "aws-secret-access-key", Name: "AWS secret access key", Pattern: `(?i)aws_?secret_?access_?key['"]?\s*[:=]\s*['"]?([A-Za-z0-9/+]{40})\b`, Severity: SeverityHigh, Confidence: ConfidenceHigh, Remediation: "Deactivate and delete the access key in the AWS IAM console (Users > Security credentials), then create a new one", DocURL: "https://docs.aws.amazon.com/IAM/latest/UserGuide/id_credentials_access-keys.html#rotating_access_keys_console"},
+	{ID: "gcp-service-account-key", Name: "GCP service account key", Pattern: `"private_key_id"\s*:\s*"([a-f0-9]{40})"`, Severity: SeverityHigh, Confidence: ConfidenceHigh, Remediation: "Delete the key of the service account in the Google Cloud console (IAM > Service accounts > Keys) and create a new one", DocURL: "https://cloud.google.com/iam/docs/keys-create-delete#deleting"},
+	{ID: "azure-sas-token", Name: "Azure SAS token", Pattern: `sv=\d{4}-\d{2}-\d{2}[^\s'"]*?&sig=([A-Za-z0-9%/+]{40,}(?:%3D|=){0,2})`, Severity: SeverityHigh, Confidence: ConfidenceHigh, Remediation: "Rotate the storage account key the token is signed with, or revoke the stored access policy it uses", DocURL: "https://learn.microsoft.com/en-us/azure/storage/common/storage-account-keys-manage"},
+	{ID: "github-pat", Name: "GitHub token", Pattern: `\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`, Severity: SeverityHigh, Confidence: ConfidenceHigh, Remediation: "Revoke the token at https://github.com/settings/tokens (or the settings of the GitHub app) and create a new one", DocURL: "https://docs.github.com/en/authentication/keeping-your-account-and-data-secure/token-expiration-and-revocation"},
+	{ID: "gitlab-pat", Name: "GitLab token", Pattern: `\b(glpat-[0-9A-Za-z_-]{20})\b`, Severity: SeverityHigh, Confidence: ConfidenceHigh, Remediation: "Revoke the token in GitLab (User settings > Access tokens) and create a new one", DocURL: "https://docs.gitlab.com/ee/user/profile/personal_access_tokens.html#revoke-a-personal-access-token"},
+	{ID: "slack-token", Name: "Slack token", Pattern: `\b(xox[baprs]-[0-9A-Za-z-]{10,72})\b`, Severity: SeverityMedium, Confidence: ConfidenceHigh, Remediation: "Revoke the token with the auth.revoke api or reinstall the Slack app to issue a new one", DocURL: "https://api.slack.com/methods/auth.revoke"},
+	{ID: "slack-webhook", Name: "Slack webhook url", Pattern: `(https://hooks\.slack\.com/services/T[A-Z0-9]+/B[A-Z0-9]+/[A-Za-z0-9]{20,})`, Severity: SeverityMedium, Confidence: ConfidenceHigh, Remediation: "Remove the incoming webhook in the Slack app settings and create a new one", DocURL: "https://api.slack.com/messaging/webhooks"},
+	{ID: "stripe-key", Name: "Stripe secret key", Pattern: `\b((?:sk|rk)_(?:live|test)_[0-9A-Za-z]{24,99})\b`, Severity: SeverityHigh, Confidence: ConfidenceHigh, Remediation: "Roll the key in the Stripe dashboard (Developers > API keys)", DocURL: "https://docs.stripe.com/keys#rolling-keys"},
+	{ID: "jwt", Name: "JSON web token", Pattern: `\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`, Severity: SeverityMedium, Confidence: ConfidenceMedium, Remediation: "Revoke the token or rotate the key it is signed with, and check its expiry", DocURL: "https://datatracker.ietf.org/doc/html/rfc8725"},
+}
+
+// BlockDetector find a secret spanning many lines: from a line matching Start to the next line matching End, at
+// most MaxSpan lines apart. With Indented the block is instead the lines more indented than the start line (a yaml
+// literal block) and End is not used.
+type BlockDetector struct {
+	ID          string
+	Name        string
+	Start       string
+	End         string
+	Indented    bool
+	MaxSpan     int
+	Severity    string
+	Confidence  string
+	Remediation string // see Detector
+	DocURL      string
+}
+
+// BuiltinBlockDetectors are the multi-line detectors enabled by default, see Config.Detectors
+var BuiltinBlockDetectors = []BlockDetector{
+	{ID: "private-key", Name: "Private key", Start: `-----BEGIN[A-Z ]*PRIVATE KEY( BLOCK)?-----`, End: `-----END[A-Z ]*PRIVATE KEY( BLOCK)?-----`, MaxSpan: 200, Severity: SeverityHigh, Confidence: ConfidenceHigh, Remediation: "Revoke the certificates of the key, replace the key pair and remove the public key from the authorized keys"},
+	{ID: "certificate", Name: "Certificate", Start: `-----BEGIN CERTIFICATE-----`, End: `-----END CERTIFICATE-----`, MaxSpan: 200, Severity: SeverityLow, Confidence: ConfidenceLow, Remediation: "Check the certificate is public; if it is bundled with its private key replace both"},
+	{ID: "yaml-literal-secret", Name: "Secret in a yaml literal block", Start: `(?i)^\s*['"]?[\w.-]*(password|passwd|secret|token|private_key|api_key)[\w.-]*['"]?\s*:\s*[|>][-+0-9]*\s*$`, Indented: true, MaxSpan: 200, Severity: SeverityMedium, Confidence: ConfidenceMedium, Remediation: GenericRemediation},
+}
+
+// DetectorIDs return the ids of the built-in detectors, single and multi-line
+func DetectorIDs() []string {
+	o := make([]string, 0, len(BuiltinDetectors)+len(BuiltinBlockDetectors))
+	for _, d := range BuiltinDetectors {
+		o = append(o, d.ID)
+	}
+	for _, d := range BuiltinBlockDetectors {
+		o = append(o, d.ID)
+	}
+	return o
+}
+
+// detectorByID return the built-in detector with the id
+func detectorByID(id string) (Detector, bool) {
+	for _, d := range BuiltinDetectors {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Detector{}, false
+}
+
+func blockDetectorByID(id string) (BlockDetector, bool) {
+	for _, d := range BuiltinBlockDetectors {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return BlockDetector{}, false
+}
+
+// compiledBlockDetector is a BlockDetector with its patterns compiled
+type compiledBlockDetector struct {
+	BlockDetector
+	start, end *regexp.Regexp
+}
+
+// indentOf return the number of leading spaces and tabs
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// foundBlock is a block found by a blockFinder
+type foundBlock struct {
+	start, end int      // line indexes
+	lines      []string // the lines from start to end
+	prev       string   // the line above start, for the suppression comments
+}
+
+// blockFinder find the blocks of a detector in the lines of a file as they are read. Only the lines of the block
+// being read are kept.
+type blockFinder struct {
+	d          *compiledBlockDetector
+	open       bool
+	start, end int // end is the last line of an indented block so far
+	indent     int
+	lines      []string
+	prev       string
+}
+
+// next read the line idx; prev is the line above. It returns the block completed by the line, if any.
+func (f *blockFinder) next(idx int, line, prev string) *foundBlock {
+	var done *foundBlock
+	if f.open {
+		if f.d.Indented {
+			switch {
+			case idx-f.start > f.d.MaxSpan || (strings.TrimSpace(line) != "" && indentOf(line) <= f.indent):
+				done = f.close() // and line may start the next block
+			case strings.TrimSpace(line) == "":
+				f.lines = append(f.lines, line)
+				return nil
+			default:
+				f.end = idx
+				f.lines = append(f.lines, line)
+				return nil
+			}
+		} else {
+			f.lines = append(f.lines, line)
+			if f.d.end.MatchString(line) {
+				f.end = idx
+				return f.close()
+			}
+			if idx-f.start >= f.d.MaxSpan {
+				f.open, f.lines = false, nil
+			}
+			if !f.d.start.MatchString(line) { // else an unterminated block, restart at this line
+				return nil
+			}
+			f.open, f.lines = false, nil
+		}
+	}
+	loc := f.d.start.FindStringIndex(line)
+	if loc == nil {
+		return done
+	}
+	if !f.d.Indented && f.d.end.MatchString(line[loc[1]:]) { // block on one line, eg a json string
+		return &foundBlock{start: idx, end: idx, lines: []string{line}, prev: prev}
+	}
+	f.open, f.start, f.end, f.indent, f.lines, f.prev = true, idx, idx, indentOf(line), []string{line}, prev
+	return done
+}
+
+// close end the current block, it returns the block if it has content
+func (f *blockFinder) close() *foundBlock {
+	f.open = false
+	lines := f.lines
+	f.lines = nil
+	if f.end <= f.start && f.d.Indented {
+		return nil
+	}
+	return &foundBlock{start: f.start, end: f.end, lines: lines[:f.end-f.start+1], prev: f.prev}
+}
+
+// flush return the indented block still open at the end of the file, if any
+func (f *blockFinder) flush() *foundBlock {
+	if !f.open || !f.d.Indented {
+		return nil
+	}
+	return f.close()
+}
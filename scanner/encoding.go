@@ -0,0 +1,181 @@
+package scanner
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The encodings of the text files transcoded to utf-8 before matching, see detectEncoding
+const (
+	EncodingUTF8BOM = "utf-8-bom" // utf-8 starting with a byte order mark, dropped
+	EncodingUTF16LE = "utf-16le"  // eg. the files written by Windows tools and PowerShell
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "latin-1" // not valid utf-8, each byte is a character
+)
+
+// encodingHead is the number of bytes of the start of a file looked at to detect its encoding
+const encodingHead = 512
+
+// detectEncoding return the encoding of the text starting with head, and the length of its byte order mark; the
+// encoding is empty for utf-8, or ascii, which is matched as is. Without a byte order mark an utf-16 text is told by
+// the zero bytes of its ascii characters, on the odd bytes in little endian, and a latin-1 one by being invalid utf-8.
+func detectEncoding(head []byte) (string, int) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8BOM, 3
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE, 2
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE, 2
+	}
+	if len(head) >= 8 {
+		var even, odd int
+		for idx := 0; idx+1 < len(head); idx += 2 {
+			if head[idx] == 0 {
+				even++
+			}
+			if head[idx+1] == 0 {
+				odd++
+			}
+		}
+		pairs := len(head) / 2
+		switch {
+		case odd*10 >= pairs*4 && even*20 < pairs:
+			return EncodingUTF16LE, 0
+		case even*10 >= pairs*4 && odd*20 < pairs:
+			return EncodingUTF16BE, 0
+		}
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return "", 0 // binary
+	}
+	// the last character may be cut by the end of the head
+	valid := head
+	for cut := 0; cut < utf8.UTFMax-1 && len(valid) > 0 && !utf8.Valid(valid); cut++ {
+		valid = valid[:len(valid)-1]
+	}
+	if !utf8.Valid(valid) {
+		return EncodingLatin1, 0
+	}
+	return "", 0
+}
+
+// isUTF16 tell if the encoding is one of the utf-16 ones, their zero bytes do not make the file a binary
+func isUTF16(enc string) bool {
+	return enc == EncodingUTF16LE || enc == EncodingUTF16BE
+}
+
+// isBinaryFile tell if the file is a binary like utils.IsBinaryFileSimple does, by a zero or control byte in its first
+// bytes, but an utf-16 text is not one
+func isBinaryFile(fpath string) (bool, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, encodingHead)
+	n, err := io.ReadFull(f, head)
+	if n == 0 {
+		return false, err
+	}
+	head = head[:n]
+	if enc, _ := detectEncoding(head); isUTF16(enc) {
+		return false, nil
+	}
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// decodeReader return the text of r, in the encoding, as utf-8; a byte order mark at its start is dropped
+func decodeReader(r io.Reader, enc string) io.Reader {
+	if enc == "" {
+		return r
+	}
+	return &decoder{r: r, enc: enc, buf: make([]byte, 32*1024), start: true}
+}
+
+// decoder transcode a text to utf-8, see decodeReader
+type decoder struct {
+	r     io.Reader
+	enc   string
+	buf   []byte
+	in    []byte // the bytes read and not decoded yet: half an utf-16 unit, or the first of a surrogate pair
+	out   []byte // the utf-8 not read yet
+	start bool   // at the start of the text, where a byte order mark is dropped
+	err   error
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 && d.err == nil {
+		n, err := d.r.Read(d.buf)
+		d.in = append(d.in, d.buf[:n]...)
+		d.err = err
+		d.decode(err != nil)
+	}
+	if len(d.out) > 0 {
+		n := copy(p, d.out)
+		d.out = d.out[n:]
+		return n, nil
+	}
+	return 0, d.err
+}
+
+// decode move the complete characters of in to out, all of them at the end of the text
+func (d *decoder) decode(end bool) {
+	out := d.out[:0]
+	used := 0
+	switch d.enc {
+	case EncodingLatin1:
+		for _, b := range d.in {
+			out = utf8.AppendRune(out, rune(b))
+		}
+		used = len(d.in)
+	case EncodingUTF16LE, EncodingUTF16BE:
+		unit := func(idx int) rune {
+			if d.enc == EncodingUTF16LE {
+				return rune(d.in[idx]) | rune(d.in[idx+1])<<8
+			}
+			return rune(d.in[idx])<<8 | rune(d.in[idx+1])
+		}
+		for used+1 < len(d.in) {
+			c := unit(used)
+			if utf16.IsSurrogate(c) && c < 0xDC00 {
+				if used+3 >= len(d.in) {
+					if !end {
+						break
+					}
+					c, used = utf8.RuneError, used+2
+				} else if next := unit(used + 2); next >= 0xDC00 && next <= 0xDFFF {
+					c, used = utf16.DecodeRune(c, next), used+4
+				} else {
+					c, used = utf8.RuneError, used+2
+				}
+			} else {
+				if utf16.IsSurrogate(c) {
+					c = utf8.RuneError
+				}
+				used += 2
+			}
+			out = utf8.AppendRune(out, c)
+		}
+		if end {
+			used = len(d.in) // an odd last byte
+		}
+	default: // utf-8, only the byte order mark is dropped
+		out = append(out, d.in...)
+		used = len(d.in)
+	}
+	d.in = d.in[:copy(d.in, d.in[used:])]
+	if d.start && len(out) > 0 {
+		out = bytes.TrimPrefix(out, []byte("\uFEFF"))
+		d.start = false
+	}
+	d.out = out
+}
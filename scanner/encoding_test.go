@@ -0,0 +1,72 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+func TestEncodings(t *testing.T) {
+	utf16Of := func(text string, bigEndian bool) string {
+		b := []byte{}
+		for _, c := range utf16.Encode([]rune(text)) {
+			if bigEndian {
+				b = append(b, byte(c>>8), byte(c))
+			} else {
+				b = append(b, byte(c), byte(c>>8))
+			}
+		}
+		return string(b)
+	}
+	text := "# réglages 🔑\r\nname=app\r\npassword=\"Xk9dLq2ZmP7wR4\"\r\n"
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"le.ini":     "\xFF\xFE" + utf16Of(text, false),
+		"be.ini":     utf16Of(text, true),
+		"latin1.ini": "# r\xE9glages\nname=app\npassword=\"Xk9dLq2Z\xE9mP7wR4\"\n",
+		"bom.ini":    "\xEF\xBB\xBFpassword=\"Xk9dLq2ZmP7wR4\"\n",
+	})
+	for name, want := range map[string]string{"le.ini": EncodingUTF16LE, "be.ini": EncodingUTF16BE, "latin1.ini": EncodingLatin1, "bom.ini": EncodingUTF8BOM} {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		if enc, _ := detectEncoding(data); enc != want {
+			t.Errorf("%s: expect the encoding %s, got %q", name, want, enc)
+		}
+	}
+	if enc, _ := detectEncoding([]byte(text)); enc != "" {
+		t.Errorf("expect no encoding for utf-8, got %q", enc)
+	}
+	// a surrogate pair read a byte at a time
+	decoded, err := io.ReadAll(decodeReader(iotest.OneByteReader(strings.NewReader("\xFF\xFE"+utf16Of(text, false))), EncodingUTF16LE))
+	if err != nil || string(decoded) != text {
+		t.Errorf("unexpected decoded text %q %v", decoded, err)
+	}
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Redact = RedactNone
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := Collect(s.Scan(context.Background(), dir)).List()
+	got := map[string]string{}
+	for _, o := range findings {
+		line := 2
+		if strings.HasSuffix(o.File, "bom.ini") {
+			line = 0
+		}
+		if !reflect.DeepEqual(o.Line_no, []int{line}) {
+			t.Errorf("%s: unexpected lines %v", o.File, o.Line_no)
+		}
+		got[filepath.Base(o.File)] = o.Matches[1]
+	}
+	want := map[string]string{"le.ini": "Xk9dLq2ZmP7wR4", "be.ini": "Xk9dLq2ZmP7wR4", "latin1.ini": "Xk9dLq2ZémP7wR4", "bom.ini": "Xk9dLq2ZmP7wR4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expect the values of all encodings %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvSelf is the file name of the findings in the environment of the cred-detect process
+const EnvSelf = "env"
+
+// EnvOpt are the environments ScanEnv scans besides its own
+type EnvOpt struct {
+	Procs   bool     // the environment of the other processes readable, /proc/<pid>/environ; linux only
+	Compose []string // docker-compose files, the environment of their services
+}
+
+// envSource is an environment to scan as KEY=value lines
+type envSource struct {
+	name string
+	data []byte
+}
+
+// ScanEnv detect the credentials in the environment variables: of this process, with opt.Procs of the other
+// processes, eg on a CI runner, and of the services of the docker-compose files. Each variable is a KEY=value line
+// matched by the patterns, and its value is checked like a .env file of the structured scan whatever Structured is,
+// so DB_PASSWORD or GITHUB_TOKEN are found by their name. The findings of a process are in /proc/<pid>/environ, the
+// findings of a compose file at the lines of the file.
+func (s *Scanner) ScanEnv(ctx context.Context, opt EnvOpt) <-chan OutputFmt {
+	output_chan := make(chan OutputFmt)
+	s.resetStats()
+	s.err = nil
+	s.suppressed = nil
+	s.cache, s.checkpoint = nil, nil
+	s.owners = nil
+	go func() {
+		defer close(output_chan)
+		defer s.finishStats()
+		sources := []envSource{{name: EnvSelf, data: []byte(strings.Join(os.Environ(), "\n"))}}
+		s.filesScanned.Add(1)
+		if opt.Procs {
+			sources = append(sources, s.procEnvs()...)
+		}
+		for _, fpath := range opt.Compose {
+			s.filesScanned.Add(1)
+			data, err := os.ReadFile(fpath)
+			if err == nil {
+				data, err = composeEnv(data)
+			}
+			if err != nil {
+				s.Logger().Warn("can not read compose file", "path", fpath, "error", err)
+				s.skip(SkipUnreadable)
+				continue
+			}
+			sources = append(sources, envSource{name: fpath, data: data})
+		}
+		for _, src := range sources {
+			s.filesProcessed.Add(1)
+			s.bytesProcessed.Add(int64(len(src.data)))
+			m := s.newFileMatcher(ctx, s, src.name, output_chan)
+			if !m.matchReader(bufio.NewReader(bytes.NewReader(src.data))) || !m.matchStructured("env", src.data) {
+				s.err = ctx.Err()
+				return
+			}
+		}
+	}()
+	return output_chan
+}
+
+// procEnvs read the environment of the other processes, the ones of the other users are not readable without root
+func (s *Scanner) procEnvs() []envSource {
+	if runtime.GOOS != "linux" {
+		s.Logger().Warn("the environment of the processes is only read on linux")
+		return nil
+	}
+	dirs, err := os.ReadDir("/proc")
+	if err != nil {
+		s.Logger().Warn("can not list the processes", "error", err)
+		return nil
+	}
+	self := strconv.Itoa(os.Getpid())
+	sources := []envSource{}
+	for _, d := range dirs {
+		if _, err := strconv.Atoi(d.Name()); err != nil || d.Name() == self {
+			continue
+		}
+		fpath := filepath.Join("/proc", d.Name(), "environ")
+		s.filesScanned.Add(1)
+		data, err := os.ReadFile(fpath)
+		if err != nil {
+			s.Logger().Debug("can not read the environment of the process", "path", fpath, "error", err)
+			s.skip(SkipUnreadable)
+			continue
+		}
+		if len(data) > 0 {
+			sources = append(sources, envSource{name: fpath, data: bytes.ReplaceAll(bytes.TrimRight(data, "\x00"), []byte{0}, []byte{'\n'})})
+		}
+	}
+	return sources
+}
+
+// composeEnv return the environment of the services of a docker-compose file as KEY=value lines at the lines of the
+// file, the other lines are empty so the findings have the line of the file. Both the mapping and the list forms of
+// environment are read.
+func composeEnv(data []byte) ([]byte, error) {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	lines := make([]string, bytes.Count(data, []byte{'\n'})+1)
+	// set put the variable at its line, the lines of a multi-line value follow
+	set := func(line int, variable string) {
+		for idx, l := range strings.Split(variable, "\n") {
+			if line-1+idx < len(lines) {
+				lines[line-1+idx] = l
+			}
+		}
+	}
+	var services *yaml.Node
+	if len(doc.Content) > 0 {
+		services = mappingValue(doc.Content[0], "services")
+	}
+	if services == nil || services.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for idx := 1; idx < len(services.Content); idx += 2 {
+		env := mappingValue(services.Content[idx], "environment")
+		if env == nil {
+			continue
+		}
+		switch env.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(env.Content); i += 2 {
+				set(env.Content[i].Line, env.Content[i].Value+"="+env.Content[i+1].Value)
+			}
+		case yaml.SequenceNode:
+			for _, item := range env.Content {
+				set(item.Line, item.Value)
+			}
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
@@ -0,0 +1,46 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScanEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docker-compose.yml": "services:\n  db:\n    environment:\n      POSTGRES_PASSWORD: Zx9cVb7nMq2wE\n      POSTGRES_USER: app\n" +
+			"  api:\n    environment:\n      - LOG_LEVEL=debug\n      - DEPLOY_KEY=Qw8eRt5yUi3oPlk\n",
+	})
+	t.Setenv("CRED_DETECT_TEST_SECRET", "Hj4kL9mNb2vC7x")
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Redact = RedactNone
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compose := filepath.Join(dir, "docker-compose.yml")
+	output := Collect(s.ScanEnv(context.Background(), EnvOpt{Compose: []string{compose}}))
+	values := map[string][]int{}
+	for _, file := range []string{EnvSelf, compose} {
+		for _, o := range output[file] {
+			for idx := 1; idx < len(o.Matches); idx += 2 {
+				values[o.Matches[idx]] = append(values[o.Matches[idx]], o.Line_no...)
+			}
+		}
+	}
+	if _, ok := values["Hj4kL9mNb2vC7x"]; !ok {
+		t.Errorf("expect the secret of the environment found, got %v", values)
+	}
+	if lines := values["Zx9cVb7nMq2wE"]; len(lines) == 0 || lines[0] != 3 {
+		t.Errorf("expect the secret of the compose mapping at its line, got %v", values)
+	}
+	if lines := values["Qw8eRt5yUi3oPlk"]; len(lines) == 0 || !slices.Contains(lines, 8) {
+		t.Errorf("expect the secret of the compose list found by its name at its line, got %v", values)
+	}
+	if st := s.Stats(); st.FilesScanned != 2 {
+		t.Errorf("expect the environment and the compose file scanned, got %+v", st)
+	}
+}
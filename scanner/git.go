@@ -0,0 +1,391 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitFinding is a finding in a line added by a commit
+type GitFinding struct {
+	OutputFmt
+	Commit string
+	Author string
+	Date   string // author date, RFC 3339
+}
+
+// GitHistoryOpt bound the commits scanned by ScanGitHistory. Empty Range scans all refs.
+type GitHistoryOpt struct {
+	Since string // passed to git log --since, eg '2024-01-01' or '3 months ago'
+	Range string // commit range, eg 'main..feature' or 'v1.0..HEAD'
+}
+
+// commitHeaderPrefix start the lines of the commit headers in our git log format, it can not appear in a patch
+const commitHeaderPrefix = "\x1ecommit\x1f"
+
+// gitLogArgs are the arguments of the git log of the repository printing the patches parseGitLog reads
+func gitLogArgs(repo string) []string {
+	return []string{"-C", repo, "log", "-p", "--no-color", "--no-ext-diff", "--unified=0", "--no-renames", "--diff-filter=AM",
+		"--format=" + commitHeaderPrefix + "%H\x1f%an <%ae>\x1f%aI"}
+}
+
+// ScanGitHistory scan the lines added by each commit of the repository, so the secrets removed from HEAD but still
+// in the history are found. Line_no are 0 based line numbers in the file as of that commit. File name patterns and
+// the profile apply to the path in the repository. The git command must be in the PATH.
+func (s *Scanner) ScanGitHistory(ctx context.Context, repo string, opt GitHistoryOpt) ([]GitFinding, error) {
+	args := gitLogArgs(repo)
+	if opt.Since != "" {
+		args = append(args, "--since="+opt.Since)
+	}
+	if opt.Range != "" {
+		args = append(args, opt.Range)
+	} else {
+		args = append(args, "--all")
+	}
+	args = append(args, "--")
+	s.ignore = s.newCredIgnore(repo)
+	s.owners = s.loadOwners(repo)
+	return s.runGit(ctx, args)
+}
+
+// ScanStaged scan the lines added in the git index (git diff --cached), eg from a pre-commit hook. The output is
+// keyed by the path relative to the top of the repository, like a scan of the repository root.
+func (s *Scanner) ScanStaged(ctx context.Context, repo string) (ProjectOutputFmt, error) {
+	s.ignore = s.newCredIgnore(repo)
+	s.owners = s.loadOwners(repo)
+	findings, err := s.runGit(ctx, []string{"-C", repo, "diff", "--cached", "-p", "--no-color", "--no-ext-diff", "--unified=0", "--no-renames", "--diff-filter=AM"})
+	if err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+	output := ProjectOutputFmt{}
+	for _, f := range findings {
+		if _, ok := output[f.File]; !ok {
+			output[f.File] = map[string]OutputFmt{}
+		}
+		output[f.File][f.Matches[0]+f.Matches[1]] = f.OutputFmt
+	}
+	return output, err
+}
+
+// RefUpdate is a line of the input of a git pre-receive hook: the push moves Ref from the commit Old to New. Old is
+// zeros for a new ref and New for a deleted one.
+type RefUpdate struct {
+	Old, New, Ref string
+}
+
+// ParseRefUpdates read the "<old> <new> <ref>" lines a pre-receive hook gets on its stdin
+func ParseRefUpdates(r io.Reader) ([]RefUpdate, error) {
+	updates := []RefUpdate{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid ref update %q, expect <old> <new> <ref>", sc.Text())
+		}
+		updates = append(updates, RefUpdate{Old: fields[0], New: fields[1], Ref: fields[2]})
+	}
+	return updates, sc.Err()
+}
+
+// isZeroOID tell if a commit id of a RefUpdate is the null one, of sha1 or sha256
+func isZeroOID(oid string) bool {
+	return strings.Trim(oid, "0") == ""
+}
+
+// ScanPush scan the lines added by the commits of a push, from a pre-receive hook running in the repository repo:
+// the commits of the new and updated refs not reachable from the refs of the repository before the push, so a commit
+// already in a branch is not scanned again. The deleted refs have nothing to scan. The pushed objects are still in
+// the quarantine directory, git finds them with the environment of the hook.
+func (s *Scanner) ScanPush(ctx context.Context, repo string, updates []RefUpdate) ([]GitFinding, error) {
+	args := gitLogArgs(repo)
+	revs := 0
+	for _, up := range updates {
+		if !isZeroOID(up.New) {
+			args = append(args, up.New)
+			revs++
+		}
+	}
+	s.ignore = s.newCredIgnore(repo)
+	s.owners = nil
+	if revs == 0 {
+		s.resetStats()
+		s.finishStats()
+		return []GitFinding{}, nil
+	}
+	return s.runGit(ctx, append(args, "--not", "--all", "--"))
+}
+
+// runGit run a git log or diff command printing patches and match the added lines. When the context is done the
+// findings so far are returned with the context error.
+func (s *Scanner) runGit(ctx context.Context, args []string) ([]GitFinding, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	findings, parseErr := s.parseGitLog(stdout)
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return findings, ctx.Err()
+		}
+		return nil, fmt.Errorf("git %s: %w %s", args[2], err, strings.TrimSpace(stderr.String()))
+	}
+	return findings, parseErr
+}
+
+// parseGitLog read the output of git log -p or git diff with --unified=0 and match the added lines
+func (s *Scanner) parseGitLog(r io.Reader) ([]GitFinding, error) {
+	s.resetStats()
+	defer s.finishStats()
+	s.suppressed = nil
+	found := map[string]*GitFinding{} // commit + file + signature => finding
+	var commit, author, date, file string
+	skipFile, inHeader := true, false
+	lineNo := 0
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, commitHeaderPrefix):
+			parts := strings.SplitN(strings.TrimPrefix(line, commitHeaderPrefix), "\x1f", 3)
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("unexpected git log header %q", line)
+			}
+			commit, author, date = parts[0], parts[1], parts[2]
+			file, skipFile, inHeader = "", true, false
+		case strings.HasPrefix(line, "diff --git "):
+			file, skipFile, inHeader = "", true, true
+		case inHeader && strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			skipFile = file == "/dev/null" || s.isExcludedGitPath(file)
+			s.filesScanned.Add(1)
+			if !skipFile {
+				s.filesProcessed.Add(1)
+			} else if file != "/dev/null" {
+				s.skip(SkipExcluded)
+			}
+		case strings.HasPrefix(line, "@@ "):
+			inHeader = false
+			// @@ -a,b +c,d @@: the added lines start at line c (1 based)
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+			n, _ := strconv.Atoi(start)
+			lineNo = n - 1
+		case !inHeader && strings.HasPrefix(line, "+") && !skipFile && file != "":
+			s.bytesProcessed.Add(int64(len(line)))
+			s.matchGitLine(found, line[1:], commit, author, date, file, lineNo)
+			lineNo++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	o := make([]GitFinding, 0, len(found))
+	for _, f := range found {
+		o = append(o, *f)
+	}
+	sort.Slice(o, func(i, j int) bool {
+		if o[i].Date != o[j].Date {
+			return o[i].Date > o[j].Date
+		}
+		if o[i].File != o[j].File {
+			return o[i].File < o[j].File
+		}
+		return firstLine(o[i].OutputFmt) < firstLine(o[j].OutputFmt)
+	})
+	return o, nil
+}
+
+// isExcludedGitPath apply the file name and path patterns and the .credignore files to a path in the repository
+func (s *Scanner) isExcludedGitPath(fpath string) bool {
+	return s.isExcludedPath(fpath) || (s.ignore != nil && s.ignore.Ignored(fpath))
+}
+
+// isExcludedPath apply the file name and path patterns to a path in the repository
+func (s *Scanner) isExcludedPath(fpath string) bool {
+	if s.pathExcludePtn != nil && s.pathExcludePtn.MatchString(fpath) {
+		return true
+	}
+	for _, name := range strings.Split(fpath, "/") {
+		if s.isExcludedName(name) {
+			return true
+		}
+	}
+	return fpath == s.cfg.ProfilePath || !s.filenamePtn.MatchString(path.Base(fpath)) || !s.isIncluded(fpath)
+}
+
+// isIncluded tell if the slash separated path relative to the root matches one of IncludeGlobs, true without globs.
+// A glob with a / matches the whole path, eg var/log/*.log, else the file name, eg *.log*, see path.Match.
+func (s *Scanner) isIncluded(rel string) bool {
+	if len(s.cfg.IncludeGlobs) == 0 {
+		return true
+	}
+	for _, glob := range s.cfg.IncludeGlobs {
+		name := path.Base(rel)
+		if strings.Contains(glob, "/") {
+			name = rel
+		}
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scanner) matchGitLine(found map[string]*GitFinding, data, commit, author, date, file string, lineNo int) {
+	for _, ptnStr := range s.linePatterns(data) {
+		start := s.timings.start()
+		_, pairs := s.lineMatches(ptnStr, s.patterns[ptnStr], data, file, lineNo)
+		s.timings.rule(s, ptnStr, start, len(pairs) > 0)
+		if len(pairs) == 0 {
+			continue
+		}
+		ruleID, severity, confidence := s.ruleOf(ptnStr, file, pairs)
+		fingerprint := Fingerprint(file, ruleID, pairs[1], data)
+		if suppressPtn.MatchString(data) { // only the line itself, the line above is not in the patch
+			s.addSuppressed(OutputFmt{File: file, Line_no: []int{lineNo}, Pattern: ptnStr, Matches: pairs, RuleID: ruleID, Severity: severity, Confidence: confidence, Fingerprint: fingerprint})
+			continue
+		}
+		sig := pairs[0] + pairs[1]
+		if key, ok := s.profileKey(file, sig, fingerprint); ok {
+			s.markSeen(key)
+			s.Logger().Info("matches exist in profile, skipping", "path", file, "commit", commit, "signature", sig)
+			continue
+		}
+		key := commit + "\x00" + file + "\x00" + sig
+		f, ok := found[key]
+		if !ok {
+			f = &GitFinding{OutputFmt: OutputFmt{File: file, Pattern: ptnStr, Line_no: []int{}, Matches: []string{}, RuleID: ruleID, Severity: severity, Confidence: confidence, Fingerprint: fingerprint,
+				SecretID: s.secretID(pairs[1])},
+				Commit: commit, Author: author, Date: date}
+			f.Remediation, f.DocURL = s.remediationOf(ptnStr)
+			f.ID = FindingID(fingerprint)
+			if s.owners != nil { // the paths are relative to the top of the repository
+				f.Owners = s.owners.Owners(file)
+			}
+			found[key] = f
+		}
+		s.redact(pairs)
+		f.Line_no = append(f.Line_no, lineNo)
+		f.Matches = append(f.Matches, pairs...)
+	}
+}
+
+// LineBlame is the commit that last changed a line of a finding, from git blame
+type LineBlame struct {
+	Line   int // 0 based, like Line_no
+	Commit string
+	Author string
+	Date   string // author date, RFC 3339
+}
+
+// Blame set the Blame of the findings of the files tracked in a git working tree: the commit, author and date of
+// each of their lines, so the finding can go to whoever added the secret. The lines not committed yet, the files not
+// in git and the files in archives are left out. The git command must be in the PATH.
+func (s *Scanner) Blame(ctx context.Context, output ProjectOutputFmt) {
+	for file, findings := range output {
+		if info, err := os.Stat(file); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		lines := []int{}
+		for _, o := range findings {
+			lines = append(lines, o.Line_no...)
+		}
+		blames, err := blameLines(ctx, file, lines)
+		if err != nil {
+			s.Logger().Debug("can not blame", "path", file, "error", err)
+			continue
+		}
+		for sig, o := range findings {
+			o.Blame = nil
+			for _, line := range o.Line_no {
+				if b, ok := blames[line]; ok {
+					o.Blame = append(o.Blame, b)
+				}
+			}
+			findings[sig] = o
+		}
+	}
+}
+
+// blameLines run git blame on the lines of the file, lines are 0 based
+func blameLines(ctx context.Context, file string, lines []int) (map[int]LineBlame, error) {
+	sort.Ints(lines)
+	lines = slices.Compact(lines)
+	args := []string{"-C", filepath.Dir(file), "blame", "--line-porcelain"}
+	for _, line := range lines {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", line+1, line+1))
+	}
+	args = append(args, "--", filepath.Base(file))
+	cmd := exec.CommandContext(ctx, "git", args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git blame: %w %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseBlame(string(out)), nil
+}
+
+// parseBlame read the output of git blame --line-porcelain: for each line a header '<commit> <orig> <final> [n]',
+// the commit fields and the line content after a tab
+func parseBlame(out string) map[int]LineBlame {
+	blames := map[int]LineBlame{}
+	var b LineBlame
+	var name, mail, tz string
+	var when int64
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch {
+		case strings.HasPrefix(line, "\t"): // the content ends the entry
+			if strings.Trim(b.Commit, "0") == "" { // not committed yet
+				continue
+			}
+			offset := 0
+			if t, err := time.Parse("-0700", tz); err == nil {
+				_, offset = t.Zone()
+			}
+			b.Author = strings.TrimSpace(name + " " + mail)
+			b.Date = time.Unix(when, 0).In(time.FixedZone("", offset)).Format(time.RFC3339)
+			blames[b.Line] = b
+		case len(key) == 40 && strings.Trim(key, "0123456789abcdef") == "":
+			fields := strings.Fields(value)
+			b = LineBlame{Commit: key}
+			if len(fields) >= 2 {
+				final, _ := strconv.Atoi(fields[1])
+				b.Line = final - 1
+			}
+		case key == "author":
+			name = value
+		case key == "author-mail":
+			mail = value
+		case key == "author-time":
+			when, _ = strconv.ParseInt(value, 10, 64)
+		case key == "author-tz":
+			tz = value
+		}
+	}
+	return blames
+}
@@ -0,0 +1,157 @@
+package scanner
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRepo init a git repository in a temp dir and return it with a func to run git in it
+func gitRepo(t *testing.T) (string, func(args ...string)) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=tester", "-c", "user.email=tester@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, out)
+		}
+	}
+	git("init", "-q")
+	return dir, git
+}
+
+func TestScanGitHistory(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFiles(t, dir, map[string]string{"app.conf": "host=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\n"})
+	git("add", "-A")
+	git("commit", "-q", "-m", "add config")
+	writeFiles(t, dir, map[string]string{"app.conf": "host=localhost\n"})
+	git("commit", "-q", "-am", "remove the password")
+
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := s.ScanGitHistory(context.Background(), dir, GitHistoryOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("expect 1 finding in history, got %+v", findings)
+	}
+	f := findings[0]
+	if f.File != "app.conf" || f.Author != "tester <tester@example.com>" || len(f.Commit) != 40 || f.Line_no[0] != 1 || f.Matches[1] != "*****" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestScanStaged(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFiles(t, dir, map[string]string{"old.conf": "token=Ab3dEf9hIj2kLm\n"})
+	git("add", "-A")
+	git("commit", "-q", "-m", "existing finding")
+	writeFiles(t, dir, map[string]string{"app.conf": "password=\"Xk9dLq2ZmP7wR4\"\n", "unstaged.conf": "secret=Qw8eRt5yUi3oP\n"})
+	git("add", "app.conf")
+
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := s.ScanStaged(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output) != 1 || output["app.conf"]["password*****"].File != "app.conf" {
+		t.Errorf("expect only the staged app.conf finding, got %v", output)
+	}
+}
+
+func TestBlame(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFiles(t, dir, map[string]string{"conf/app.conf": "host=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\n"})
+	git("add", "-A")
+	git("commit", "-q", "--date", "2024-01-02T03:04:05+02:00", "-m", "add config")
+	writeFiles(t, dir, map[string]string{
+		"conf/app.conf":  "host=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\ntoken=Ab3dEf9hIj2kLm\n",
+		"untracked.conf": "secret=Qw8eRt5yUi3oP\n",
+	})
+
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := Collect(s.Scan(context.Background(), dir))
+	s.Blame(context.Background(), output)
+	var blames []LineBlame
+	for file, matches := range output {
+		for _, o := range matches {
+			if filepath.Base(file) == "untracked.conf" && o.Blame != nil {
+				t.Errorf("expect no blame for an untracked file, got %+v", o.Blame)
+			}
+			if filepath.Base(file) == "app.conf" {
+				blames = o.Blame
+			}
+		}
+	}
+	if len(blames) != 1 {
+		t.Fatalf("expect the committed line blamed, not the new one, got %+v", blames)
+	}
+	b := blames[0]
+	if b.Line != 1 || len(b.Commit) != 40 || b.Author != "tester <tester@example.com>" || b.Date != "2024-01-02T03:04:05+02:00" {
+		t.Errorf("unexpected blame %+v", b)
+	}
+}
+
+func TestScanPush(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFiles(t, dir, map[string]string{"old.conf": "token=Ab3dEf9hIj2kLm\n"})
+	git("add", "-A")
+	git("commit", "-q", "-m", "existing finding")
+	git("branch", "-M", "main")
+	bare := filepath.Join(t.TempDir(), "server.git")
+	git("clone", "-q", "--bare", dir, bare)
+	writeFiles(t, dir, map[string]string{"app.conf": "password=\"Xk9dLq2ZmP7wR4\"\n"})
+	git("add", "-A")
+	git("commit", "-q", "-m", "add a password")
+	git("push", "-q", bare, "main:refs/heads/feature") // the objects a push brings, the ref is not moved yet
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "main", "main~1").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits := strings.Fields(string(out))
+	git("--git-dir", bare, "update-ref", "-d", "refs/heads/feature")
+
+	updates, err := ParseRefUpdates(strings.NewReader(commits[1] + " " + commits[0] + " refs/heads/main\n" +
+		commits[0] + " " + strings.Repeat("0", 40) + " refs/heads/gone\n"))
+	if err != nil || len(updates) != 2 || updates[0].Ref != "refs/heads/main" {
+		t.Fatalf("unexpected updates %+v %v", updates, err)
+	}
+	if _, err := ParseRefUpdates(strings.NewReader("abc refs/heads/main\n")); err == nil {
+		t.Error("expect an error for a line without the new commit")
+	}
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := s.ScanPush(context.Background(), bare, updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].File != "app.conf" || findings[0].Commit != commits[0] {
+		t.Errorf("expect only the finding of the pushed commit, got %+v", findings)
+	}
+}
@@ -0,0 +1,177 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitLabSchemaVersion is the version of the GitLab security report schema of ToGitLab
+const GitLabSchemaVersion = "15.0.7"
+
+// GitLab secret detection report, the gl-secret-detection-report.json artifact read by the merge request security
+// widget and the vulnerability report. See
+// https://gitlab.com/gitlab-org/security-products/security-report-schemas/-/blob/master/dist/secret-detection-report-format.json
+type GitLabReport struct {
+	Version         string                `json:"version"`
+	Vulnerabilities []GitLabVulnerability `json:"vulnerabilities"`
+	Scan            GitLabScan            `json:"scan"`
+}
+
+type GitLabVulnerability struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Severity    string             `json:"severity"`
+	Solution    string             `json:"solution,omitempty"`
+	Location    GitLabLocation     `json:"location"`
+	Identifiers []GitLabIdentifier `json:"identifiers"`
+	Links       []GitLabLink       `json:"links,omitempty"`
+}
+
+type GitLabLocation struct {
+	File      string       `json:"file"`
+	StartLine int          `json:"start_line"`
+	EndLine   int          `json:"end_line,omitempty"`
+	Commit    GitLabCommit `json:"commit"`
+}
+
+type GitLabCommit struct {
+	SHA string `json:"sha"`
+}
+
+type GitLabIdentifier struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type GitLabLink struct {
+	URL string `json:"url"`
+}
+
+type GitLabScan struct {
+	Analyzer  GitLabTool `json:"analyzer"`
+	Scanner   GitLabTool `json:"scanner"`
+	Type      string     `json:"type"`
+	StartTime string     `json:"start_time"`
+	EndTime   string     `json:"end_time"`
+	Status    string     `json:"status"`
+}
+
+type GitLabTool struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	URL     string       `json:"url,omitempty"`
+	Vendor  GitLabVendor `json:"vendor"`
+	Version string       `json:"version"`
+}
+
+type GitLabVendor struct {
+	Name string `json:"name"`
+}
+
+// gitLabNoCommit is the commit of the findings of the working tree, like the GitLab secret detection analyzer
+const gitLabNoCommit = "0000000"
+
+// ToGitLab convert the scan output to a GitLab secret detection report. Each line of a finding is a vulnerability,
+// its id is stable across runs so GitLab tracks it between pipelines. The file paths are made relative to root, the
+// scan started at start and ended at end.
+func ToGitLab(output ProjectOutputFmt, root, toolVersion string, start, end time.Time) GitLabReport {
+	vulns := []GitLabVulnerability{}
+	files := make([]string, 0, len(output))
+	for file := range output {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		path := file
+		if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		path = filepath.ToSlash(path)
+		sigs := make([]string, 0, len(output[file]))
+		for sig := range output[file] {
+			sigs = append(sigs, sig)
+		}
+		sort.Strings(sigs)
+		for _, sig := range sigs {
+			o := output[file][sig]
+			ruleID := firstNonEmpty(o.RuleID, PatternRuleID(o.Pattern))
+			name := "Possible hardcoded credential"
+			if d, ok := detectorByID(ruleID); ok {
+				name = d.Name
+			}
+			names := []string{}
+			for idx := 0; idx < len(o.Matches); idx += 2 {
+				if !slices.Contains(names, o.Matches[idx]) {
+					names = append(names, o.Matches[idx])
+				}
+			}
+			links := []GitLabLink{}
+			if o.DocURL != "" {
+				links = append(links, GitLabLink{URL: o.DocURL})
+			}
+			for _, line := range o.Line_no {
+				loc := GitLabLocation{File: path, StartLine: line + 1, Commit: GitLabCommit{SHA: gitLabNoCommit}} // Line_no is 0 based
+				if o.End_line > line {
+					loc.EndLine = o.End_line + 1
+				}
+				vulns = append(vulns, GitLabVulnerability{
+					ID:          gitLabID(path, ruleID, o.Fingerprint, line),
+					Name:        name,
+					Description: fmt.Sprintf("Possible credential in '%s'", strings.Join(names, "', '")),
+					Severity:    gitLabSeverity(o),
+					Solution:    o.Remediation,
+					Location:    loc,
+					Identifiers: []GitLabIdentifier{{Type: "cred_detect_rule_id", Name: "cred-detect rule " + ruleID, Value: ruleID}},
+					Links:       links,
+				})
+			}
+		}
+	}
+	tool := GitLabTool{ID: "cred-detect", Name: "cred-detect", URL: "https://github.com/sunshine69/automation-go", Vendor: GitLabVendor{Name: "cred-detect"}, Version: toolVersion}
+	if tool.Version == "" {
+		tool.Version = "dev" // the version is required
+	}
+	return GitLabReport{
+		Version:         GitLabSchemaVersion,
+		Vulnerabilities: vulns,
+		Scan: GitLabScan{
+			Analyzer:  tool,
+			Scanner:   tool,
+			Type:      "secret_detection",
+			StartTime: start.UTC().Format("2006-01-02T15:04:05"),
+			EndTime:   end.UTC().Format("2006-01-02T15:04:05"),
+			Status:    "success",
+		},
+	}
+}
+
+// gitLabID return the uuid of a finding line, a hash of where it is and of its fingerprint
+func gitLabID(path, ruleID, fingerprint string, line int) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d", path, ruleID, fingerprint, line)))
+	h[6] = h[6]&0x0f | 0x50 // version 5 like, a name based uuid
+	h[8] = h[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// gitLabSeverity map the severity to the GitLab one; a verified live token is critical
+func gitLabSeverity(o OutputFmt) string {
+	switch {
+	case o.Verified == StatusVerified:
+		return "Critical"
+	case o.Severity == SeverityHigh:
+		return "High"
+	case o.Severity == SeverityMedium:
+		return "Medium"
+	case o.Severity == SeverityLow:
+		return "Low"
+	default:
+		return "Unknown"
+	}
+}
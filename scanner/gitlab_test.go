@@ -0,0 +1,30 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToGitLab(t *testing.T) {
+	output := ProjectOutputFmt{
+		"/src/app/a.txt": {"aws-access-key-id*****": {File: "/src/app/a.txt", Line_no: []int{0, 4}, RuleID: "aws-access-key-id", Severity: SeverityHigh, Fingerprint: "fp", Matches: []string{"aws-access-key-id", "*****"}}},
+	}
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	report := ToGitLab(output, "/src", "1.0", start, start.Add(time.Minute))
+	if report.Version != GitLabSchemaVersion || report.Scan.Type != "secret_detection" || report.Scan.EndTime != "2024-05-01T10:01:00" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	vulns := report.Vulnerabilities
+	if len(vulns) != 2 {
+		t.Fatalf("expect one vulnerability per line, got %+v", vulns)
+	}
+	if loc := vulns[1].Location; loc.File != "app/a.txt" || loc.StartLine != 5 || loc.Commit.SHA == "" {
+		t.Errorf("unexpected location %+v", loc)
+	}
+	if vulns[0].Severity != "High" || vulns[0].Identifiers[0].Value != "aws-access-key-id" || vulns[0].ID == vulns[1].ID {
+		t.Errorf("unexpected vulnerability %+v", vulns[0])
+	}
+	if again := ToGitLab(output, "/src", "1.0", start, start); again.Vulnerabilities[0].ID != vulns[0].ID {
+		t.Errorf("expect the ids stable across runs, got %s and %s", vulns[0].ID, again.Vulnerabilities[0].ID)
+	}
+}
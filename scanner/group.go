@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The ways the findings of a scan can be grouped in the output
+const (
+	GroupByFile   = "file"   // ProjectOutputFmt, the findings of each file
+	GroupBySecret = "secret" // a SecretGroup per secret, see GroupSecrets
+)
+
+// SecretLocation is a finding of the secret of a SecretGroup
+type SecretLocation struct {
+	File        string
+	Line_no     []int
+	End_line    int         `json:",omitempty"`
+	Fingerprint string      `json:",omitempty"`
+	Blame       []LineBlame `json:",omitempty"`
+}
+
+// SecretGroup is a secret and the places it is found, eg the same api key copied in several config files. The
+// levels are the highest of its findings.
+type SecretGroup struct {
+	SecretID    string
+	RuleID      string
+	Name        string // the token name of the first finding
+	Value       string // masked, or its hmac
+	Confidence  string `json:",omitempty"`
+	Severity    string `json:",omitempty"`
+	Verified    string `json:",omitempty"`
+	Remediation string `json:",omitempty"`
+	DocURL      string `json:",omitempty"`
+	Locations   []SecretLocation
+}
+
+// GroupSecrets group the findings having the same SecretID into one SecretGroup with the list of their locations.
+// A finding without SecretID, eg from a profile of an older version, is a group of its own. The groups found in the
+// most places come first.
+func GroupSecrets(findings []OutputFmt) []SecretGroup {
+	findings = append([]OutputFmt{}, findings...)
+	sortFindings(findings)
+	groups := []SecretGroup{}
+	byID := map[string]int{}
+	for _, o := range findings {
+		key := o.SecretID
+		if key == "" {
+			key = fmt.Sprintf("%s\x00%s\x00%v", o.File, o.Fingerprint, o.Line_no)
+		}
+		idx, ok := byID[key]
+		if !ok {
+			idx = len(groups)
+			byID[key] = idx
+			g := SecretGroup{SecretID: o.SecretID, RuleID: o.RuleID, Remediation: o.Remediation, DocURL: o.DocURL, Locations: []SecretLocation{}}
+			if len(o.Matches) >= 2 {
+				g.Name, g.Value = o.Matches[0], o.Matches[1]
+			}
+			groups = append(groups, g)
+		}
+		g := &groups[idx]
+		g.Confidence = maxLevel(g.Confidence, o.Confidence)
+		g.Severity = maxLevel(g.Severity, o.Severity)
+		g.Verified = mergeStatus(g.Verified, o.Verified)
+		g.Locations = append(g.Locations, SecretLocation{File: o.File, Line_no: o.Line_no, End_line: o.End_line, Fingerprint: o.Fingerprint, Blame: o.Blame})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Locations) > len(groups[j].Locations)
+	})
+	return groups
+}
@@ -0,0 +1,43 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGroupSecrets(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.conf":     "password='Zx9cVb7nMq2wE'\n",
+		"b/app.yaml": "db:\n  password: 'Zx9cVb7nMq2wE'\n",
+		"c.conf":     "secret='Qw8eRt5yUi3oP'\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := GroupSecrets(Collect(s.Scan(context.Background(), dir)).List())
+	if len(groups) != 2 || len(groups[0].Locations) != 2 || len(groups[1].Locations) != 1 {
+		t.Fatalf("expect the password of a.conf and app.yaml grouped, got %+v", groups)
+	}
+	if groups[0].Locations[0].File != filepath.Join(dir, "a.conf") || groups[0].Locations[1].File != filepath.Join(dir, "b/app.yaml") || groups[0].Value != "*****" {
+		t.Errorf("expect the locations sorted and the value masked, got %+v", groups[0])
+	}
+	if groups[0].SecretID == "" || strings.Contains(groups[0].SecretID, "Zx9cVb7nMq2wE") {
+		t.Errorf("expect a hash as the secret id, got %q", groups[0].SecretID)
+	}
+
+	// with hmac the id is the keyed hash, stable across the runs with the key
+	cfg.Redact, cfg.RedactKey = RedactHmac, "k1"
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	hmacGroups := GroupSecrets(Collect(s.Scan(context.Background(), dir)).List())
+	if len(hmacGroups) != 2 || hmacGroups[0].SecretID != strings.TrimPrefix(HmacValue("k1", "Zx9cVb7nMq2wE"), HmacPrefix) {
+		t.Errorf("expect the hmac of the value as the secret id, got %+v", hmacGroups)
+	}
+}
@@ -0,0 +1,225 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	sprig "github.com/go-task/slim-sprig"
+	"gopkg.in/yaml.v3"
+)
+
+// helmChart is a helm chart loaded to render its templates with its default values, see matchKubernetes. The sub
+// charts are not rendered, nor .Files and lookup.
+type helmChart struct {
+	dir    string
+	name   string
+	meta   map[string]any // Chart.yaml with the keys capitalized like helm, eg .Chart.AppVersion
+	values map[string]any // values.yaml
+	tmpl   *template.Template
+	err    error // why the chart can not be rendered
+}
+
+// helmCapabilities is .Capabilities; every api version is said to be available so the optional manifests render
+type helmCapabilities struct {
+	KubeVersion struct{ Version, Major, Minor, GitVersion string }
+	APIVersions helmAPIVersions
+}
+
+type helmAPIVersions struct{}
+
+func (helmAPIVersions) Has(string) bool { return true }
+
+// chartOf return the chart of a template, or nil if fpath is not a manifest in the templates directory of a chart.
+// The charts are loaded once per scan.
+func (s *Scanner) chartOf(fpath string) *helmChart {
+	ext := strings.ToLower(filepath.Ext(fpath))
+	if (ext != ".yaml" && ext != ".yml") || strings.HasPrefix(filepath.Base(fpath), "_") {
+		return nil
+	}
+	dir := filepath.Dir(fpath)
+	for ; filepath.Base(dir) != "templates"; dir = filepath.Dir(dir) {
+		if dir == filepath.Dir(dir) {
+			return nil
+		}
+	}
+	dir = filepath.Dir(dir)
+	if _, err := os.Stat(filepath.Join(dir, "Chart.yaml")); err != nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.charts == nil {
+		s.charts = map[string]*helmChart{}
+	}
+	chart, ok := s.charts[dir]
+	if !ok {
+		chart = loadChart(dir)
+		if chart.err != nil {
+			s.Logger().Warn("can not load helm chart, line scan only", "path", dir, "error", chart.err)
+		}
+		s.charts[dir] = chart
+	}
+	return chart
+}
+
+// loadChart read the Chart.yaml and values.yaml of a chart and parse its templates; on error the chart has err set
+func loadChart(dir string) *helmChart {
+	c := &helmChart{dir: dir, meta: map[string]any{}, values: map[string]any{}}
+	datab, err := os.ReadFile(filepath.Join(dir, "Chart.yaml"))
+	if err == nil {
+		meta := map[string]any{}
+		if err = yaml.Unmarshal(datab, &meta); err == nil {
+			for k, v := range meta {
+				c.meta[strings.ToUpper(k[:1])+k[1:]] = v
+			}
+		}
+	}
+	if err != nil {
+		c.err = fmt.Errorf("Chart.yaml - %w", err)
+		return c
+	}
+	c.name, _ = c.meta["Name"].(string)
+	if datab, err := os.ReadFile(filepath.Join(dir, "values.yaml")); err == nil {
+		if err := yaml.Unmarshal(datab, &c.values); err != nil {
+			c.err = fmt.Errorf("values.yaml - %w", err)
+			return c
+		}
+	}
+	c.tmpl = template.New(c.name).Option("missingkey=zero").Funcs(c.funcs())
+	c.err = filepath.WalkDir(filepath.Join(dir, "templates"), func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(fpath)) {
+		case ".yaml", ".yml", ".tpl", ".txt":
+		default:
+			return nil
+		}
+		datab, err := os.ReadFile(fpath)
+		if err != nil {
+			return err
+		}
+		_, err = c.tmpl.New(c.templateName(fpath)).Parse(string(datab))
+		return err
+	})
+	return c
+}
+
+// templateName return the name of a template file like helm does, eg mychart/templates/secret.yaml
+func (c *helmChart) templateName(fpath string) string {
+	rel, _ := filepath.Rel(c.dir, fpath)
+	return c.name + "/" + filepath.ToSlash(rel)
+}
+
+// funcs return the functions of the helm templates: sprig without the ones reading the environment or the network,
+// and the helm ones
+func (c *helmChart) funcs() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	for _, name := range []string{"env", "expandenv", "getHostByName"} {
+		delete(funcs, name)
+	}
+	toYaml := func(v any) string {
+		datab, err := yaml.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(string(datab), "\n")
+	}
+	funcs["toYaml"] = toYaml
+	funcs["fromYaml"] = func(text string) map[string]any {
+		o := map[string]any{}
+		yaml.Unmarshal([]byte(text), &o)
+		return o
+	}
+	funcs["include"] = func(name string, data any) (string, error) {
+		var buf bytes.Buffer
+		err := c.tmpl.ExecuteTemplate(&buf, name, data)
+		return buf.String(), err
+	}
+	funcs["tpl"] = func(text string, data any) (string, error) {
+		t, err := c.tmpl.Clone()
+		if err == nil {
+			t, err = t.New("tpl").Parse(text)
+		}
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		err = t.Execute(&buf, data)
+		return buf.String(), err
+	}
+	funcs["required"] = func(msg string, v any) (any, error) {
+		if v == nil || v == "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return v, nil
+	}
+	funcs["lookup"] = func(...any) map[string]any { return map[string]any{} }
+	return funcs
+}
+
+// render execute the template of the file with the default values of the chart
+func (c *helmChart) render(fpath string) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	name := c.templateName(fpath)
+	capabilities := helmCapabilities{}
+	capabilities.KubeVersion.Version, capabilities.KubeVersion.Major, capabilities.KubeVersion.Minor = "v1.30.0", "1", "30"
+	capabilities.KubeVersion.GitVersion = capabilities.KubeVersion.Version
+	data := map[string]any{
+		"Values":       c.values,
+		"Chart":        c.meta,
+		"Release":      map[string]any{"Name": "release-name", "Namespace": "default", "Service": "Helm", "IsInstall": true, "Revision": 1},
+		"Capabilities": capabilities,
+		"Template":     map[string]any{"Name": name, "BasePath": c.name + "/templates"},
+	}
+	var buf bytes.Buffer
+	if err := c.tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(buf.String(), "<no value>", ""), nil
+}
+
+// valuesKey return the path of the first key of values.yaml whose value is the value, or is its base64, eg db.password
+func (c *helmChart) valuesKey(value string) string {
+	if value == "" {
+		return ""
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(value))
+	var find func(v any, path string) string
+	find = func(v any, path string) string {
+		switch v := v.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if found := find(v[k], strings.TrimPrefix(path+"."+k, ".")); found != "" {
+					return found
+				}
+			}
+		case []any:
+			for idx, item := range v {
+				if found := find(item, fmt.Sprintf("%s[%d]", path, idx)); found != "" {
+					return found
+				}
+			}
+		case string:
+			if v == value || v == encoded {
+				return path
+			}
+		}
+		return ""
+	}
+	return find(c.values, "")
+}
@@ -0,0 +1,84 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// FindingEvent is the payload sent to the finding hooks. It never has the credential value.
+type FindingEvent struct {
+	File       string
+	Line_no    []int
+	Pattern    string
+	Name       string // the token name, eg 'password'
+	RuleID     string `json:",omitempty"`
+	Severity   string `json:",omitempty"`
+	Confidence string `json:",omitempty"`
+	Verified   string `json:",omitempty"` // with Config.Verify, the status of the token, see StatusVerified
+	Root       string // the scanned path
+	Time       time.Time
+}
+
+// NewFindingEvent create the hook payload of a finding
+func NewFindingEvent(root string, o OutputFmt) FindingEvent {
+	ev := FindingEvent{File: o.File, Line_no: o.Line_no, Pattern: o.Pattern, RuleID: o.RuleID, Severity: o.Severity, Confidence: o.Confidence, Verified: o.Verified, Root: root, Time: time.Now()}
+	if len(o.Matches) > 0 {
+		ev.Name = o.Matches[0]
+	}
+	return ev
+}
+
+// Hook is called once per new finding, eg to start a rotation workflow or open a ticket
+type Hook func(ev FindingEvent) error
+
+// ParseHook parse a hook spec:
+//   - exec:<cmd> run the command with 'bash -c', the event as JSON on stdin and CRED_DETECT_FILE,
+//     CRED_DETECT_LINE and CRED_DETECT_NAME in the env
+//   - webhook:<url> POST the event as JSON to the url; a status >= 300 is an error
+func ParseHook(spec string) (Hook, error) {
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" {
+		return nil, fmt.Errorf("invalid hook '%s', expect exec:<cmd> or webhook:<url>", spec)
+	}
+	switch kind {
+	case "exec":
+		return func(ev FindingEvent) error {
+			payload, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			cmd := exec.Command("bash", "-c", target)
+			cmd.Stdin = bytes.NewReader(payload)
+			cmd.Env = append(os.Environ(), "CRED_DETECT_FILE="+ev.File, "CRED_DETECT_LINE="+fmt.Sprint(firstLine(OutputFmt{Line_no: ev.Line_no})+1), "CRED_DETECT_NAME="+ev.Name)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("hook '%s' - %w: %s", target, err, strings.TrimSpace(string(out)))
+			}
+			return nil
+		}, nil
+	case "webhook":
+		client := &http.Client{Timeout: 30 * time.Second}
+		return func(ev FindingEvent) error {
+			payload, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Post(target, "application/json", bytes.NewReader(payload))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				return fmt.Errorf("webhook %s returned %s", target, resp.Status)
+			}
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown hook type '%s', expect exec or webhook", kind)
+	}
+}
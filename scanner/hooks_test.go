@@ -0,0 +1,30 @@
+package scanner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	var got FindingEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	hook, err := ParseHook("webhook:" + srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := OutputFmt{File: "a.txt", Line_no: []int{1}, Matches: []string{"password", "Xk9dLq2ZmP7wR4"}, Verified: StatusVerified}
+	if err := hook(NewFindingEvent(".", o)); err != nil {
+		t.Fatal(err)
+	}
+	if got.File != "a.txt" || got.Name != "password" || got.Verified != StatusVerified {
+		t.Errorf("unexpected payload %+v", got)
+	}
+	if _, err := ParseHook("mail:someone"); err == nil {
+		t.Error("expect error for an unknown hook type")
+	}
+}
@@ -0,0 +1,159 @@
+package scanner
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// IgnoreFileName is the file of gitignore patterns excluding paths from a scan. It is read in the root and in every
+// directory; its patterns are relative to its directory and the deeper files take precedence.
+const IgnoreFileName = ".credignore"
+
+// ignoreRule is a compiled line of a .credignore file
+type ignoreRule struct {
+	re      *regexp.Regexp // matches the path relative to the directory of the file
+	negate  bool           // !pattern re-includes the path
+	dirOnly bool           // pattern/ only matches directories
+}
+
+// parseIgnore compile the lines of a .credignore file with the gitignore syntax
+func parseIgnore(data string) []ignoreRule {
+	rules := []ignoreRule{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " \t")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		// a pattern with a slash, other than at the end, is relative to the directory; else it matches a name
+		// at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		prefix := "^"
+		if !anchored {
+			prefix = "^(?:.*/)?"
+		}
+		re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+		if err != nil {
+			continue // an invalid pattern is ignored, like git does
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// globToRegexp translate a gitignore glob: * and ? do not match a slash, ** matches any directories
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for idx := 0; idx < len(glob); idx++ {
+		c := glob[idx]
+		switch {
+		case strings.HasPrefix(glob[idx:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			idx += 2
+		case strings.HasPrefix(glob[idx:], "**") && idx+2 == len(glob):
+			sb.WriteString(".*")
+			idx++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '\\' && idx+1 < len(glob):
+			idx++
+			sb.WriteString(regexp.QuoteMeta(glob[idx : idx+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[idx+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[idx+1 : idx+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			idx += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// credIgnore apply the .credignore files under root, read when a path in their directory is first checked
+type credIgnore struct {
+	root  string
+	mu    sync.Mutex
+	rules map[string][]ignoreRule // by directory relative to root, "." for the root
+}
+
+// newCredIgnore return the .credignore files under root, nil with NoIgnoreFiles
+func (s *Scanner) newCredIgnore(root string) *credIgnore {
+	if s.cfg.NoIgnoreFiles {
+		return nil
+	}
+	return &credIgnore{root: root, rules: map[string][]ignoreRule{}}
+}
+
+func (c *credIgnore) load(dir string) []ignoreRule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rules, ok := c.rules[dir]
+	if !ok {
+		if datab, err := os.ReadFile(filepath.Join(c.root, filepath.FromSlash(dir), IgnoreFileName)); err == nil {
+			rules = parseIgnore(string(datab))
+		}
+		c.rules[dir] = rules
+	}
+	return rules
+}
+
+// match tell if the path, relative to root with slashes, is ignored by the rules of its directory and of the parent
+// directories. The parent directories themselves are not checked, see Ignored.
+func (c *credIgnore) match(rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	ignored := false
+	for idx := range parts {
+		dir := path.Join(parts[:idx]...)
+		if dir == "" {
+			dir = "."
+		}
+		sub := strings.Join(parts[idx:], "/")
+		for _, rule := range c.load(dir) {
+			if (!rule.dirOnly || isDir) && rule.re.MatchString(sub) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// Ignored tell if the file, relative to root with slashes, or one of its parent directories is ignored
+func (c *credIgnore) Ignored(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for idx := 1; idx < len(parts); idx++ {
+		if c.match(strings.Join(parts[:idx], "/"), true) {
+			return true
+		}
+	}
+	return c.match(rel, false)
+}
@@ -0,0 +1,60 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestCredIgnore(t *testing.T) {
+	dir := t.TempDir()
+	secret := "password='Xk9dLq2ZmP7wR4'\n"
+	writeFiles(t, dir, map[string]string{
+		IgnoreFileName:                 "# fixtures\ntestdata/\n*.log\n/top.conf\ndocs/**/*.md\n",
+		"top.conf":                     secret,
+		"sub/top.conf":                 secret, // /top.conf is anchored to the root
+		"app.log":                      secret,
+		"testdata/a.conf":              secret,
+		"docs/guide/setup.md":          secret,
+		"svc/" + IgnoreFileName:        "*.conf\n!keep.conf\n",
+		"svc/drop.conf":                secret,
+		"svc/keep.conf":                secret,
+		"svc/debug.log":                secret, // the root *.log applies in sub directories
+		"svc/nested/" + IgnoreFileName: "!*.log\n",
+		"svc/nested/trace.log":         secret, // the deeper file re-includes it
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanned := func() []string {
+		got := []string{}
+		for file := range Collect(s.Scan(context.Background(), dir)) {
+			rel, _ := filepath.Rel(dir, file)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		sort.Strings(got)
+		return got
+	}
+	want := []string{"sub/top.conf", "svc/keep.conf", "svc/nested/trace.log"}
+	if got := scanned(); !slices.Equal(got, want) {
+		t.Errorf("expect findings in %v, got %v", want, got)
+	}
+	ci := s.newCredIgnore(dir)
+	for rel, ignored := range map[string]bool{"testdata/x/y.conf": true, "svc/drop.conf": true, "svc/keep.conf": false, "docs/a/b/c.md": true, "docs/readme.md": true, "readme.md": false} {
+		if ci.Ignored(rel) != ignored {
+			t.Errorf("%s: expect ignored %v", rel, ignored)
+		}
+	}
+	cfg.NoIgnoreFiles = true
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := scanned(); len(got) != 9 {
+		t.Errorf("expect all files scanned with NoIgnoreFiles, got %v", got)
+	}
+}
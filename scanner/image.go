@@ -0,0 +1,278 @@
+package scanner
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ImageFinding is a finding in a file of a container image layer
+type ImageFinding struct {
+	OutputFmt
+	Layer      string // digest of the uncompressed layer (diff id), or its path in the tarball if unknown
+	LayerIndex int    // 0 is the base layer
+	CreatedBy  string // the build instruction of the layer, from the image history
+}
+
+// ImageOpt are the options to pull an image from a registry. Username and Password are optional, anonymous tokens
+// are used for public images.
+type ImageOpt struct {
+	Username string
+	Password string
+	Platform string // os/arch[/variant] picked from a multi-platform image, default linux and the arch of the host
+}
+
+// maxImageMetadata bound the size of the json files read from an image: manifests, index and config
+const maxImageMetadata = 4 * 1024 * 1024
+
+// imageManifest is an OCI image manifest, an OCI index or a docker manifest list; only the fields we use
+type imageManifest struct {
+	Config    imageDescriptor   `json:"config"`
+	Layers    []imageDescriptor `json:"layers"`
+	Manifests []imageDescriptor `json:"manifests"`
+}
+
+type imageDescriptor struct {
+	Digest   string `json:"digest"`
+	Platform *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	} `json:"platform"`
+}
+
+// imageConfig is the config of an image; the history has one entry per instruction, empty_layer when it did not add
+// a layer, eg ENV
+type imageConfig struct {
+	RootFS struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+	History []struct {
+		CreatedBy  string `json:"created_by"`
+		EmptyLayer bool   `json:"empty_layer"`
+	} `json:"history"`
+}
+
+// imageLayer is a layer to scan: path is the layer blob in the tarball or the registry digest
+type imageLayer struct {
+	index     int
+	path      string
+	digest    string
+	createdBy string
+}
+
+// imageLayers return the layers of the image with the digest and instruction of each from the config
+func imageLayers(paths []string, config imageConfig) []imageLayer {
+	createdBy := []string{}
+	for _, h := range config.History {
+		if !h.EmptyLayer {
+			createdBy = append(createdBy, h.CreatedBy)
+		}
+	}
+	layers := make([]imageLayer, 0, len(paths))
+	for idx, p := range paths {
+		l := imageLayer{index: idx, path: p, digest: p}
+		if len(config.RootFS.DiffIDs) == len(paths) {
+			l.digest = config.RootFS.DiffIDs[idx]
+		}
+		if idx < len(createdBy) {
+			l.createdBy = createdBy[idx]
+		}
+		layers = append(layers, l)
+	}
+	return layers
+}
+
+// ScanImage scan the files of each layer of a container image, so a secret added by a layer is found even if a
+// later layer deletes it. ref is a tarball from docker save (or an OCI image layout tarball), else an image
+// reference pulled from its registry, eg alpine:3.20 or ghcr.io/org/app@sha256:... . The file name patterns and the
+// profile apply to the path in the layer; ScanArchives also scans the archives in the layers.
+func (s *Scanner) ScanImage(ctx context.Context, ref string, opt ImageOpt) ([]ImageFinding, error) {
+	s.resetStats()
+	defer s.finishStats()
+	s.suppressed = nil
+	var findings []ImageFinding
+	var err error
+	if info, statErr := os.Stat(ref); statErr == nil && info.Mode().IsRegular() {
+		findings, err = s.scanImageTarball(ctx, ref, opt.Platform)
+	} else {
+		findings, err = s.scanRegistryImage(ctx, ref, opt)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].LayerIndex != findings[j].LayerIndex {
+			return findings[i].LayerIndex < findings[j].LayerIndex
+		}
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return firstLine(findings[i].OutputFmt) < firstLine(findings[j].OutputFmt)
+	})
+	return findings, err
+}
+
+// scanImageTarball scan the first image of a docker save tarball, or of an OCI image layout tarball. The tarball
+// is read twice: first the json files for the manifest and the config, then the layers in the order they are in.
+func (s *Scanner) scanImageTarball(ctx context.Context, fpath, platform string) ([]ImageFinding, error) {
+	docs := map[string][]byte{} // path in the tarball => json content
+	err := readTarball(fpath, func(name string, r io.Reader) error {
+		br := bufio.NewReader(r)
+		if head, _ := br.Peek(1); len(head) == 1 && (head[0] == '{' || head[0] == '[') {
+			datab, err := io.ReadAll(io.LimitReader(br, maxImageMetadata+1))
+			if err == nil && len(datab) <= maxImageMetadata {
+				docs[name] = datab
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	configPath, layerPaths, err := tarballManifest(docs, platform)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fpath, err)
+	}
+	config := imageConfig{}
+	if err := json.Unmarshal(docs[configPath], &config); err != nil {
+		return nil, fmt.Errorf("%s: invalid image config %s - %w", fpath, configPath, err)
+	}
+	byPath := map[string]imageLayer{}
+	for _, l := range imageLayers(layerPaths, config) {
+		byPath[l.path] = l
+	}
+	findings := []ImageFinding{}
+	err = readTarball(fpath, func(name string, r io.Reader) error {
+		l, ok := byPath[name]
+		if !ok {
+			return nil
+		}
+		delete(byPath, name) // a layer shared by two entries is scanned once
+		layerFindings, err := s.scanLayer(ctx, l, r)
+		findings = append(findings, layerFindings...)
+		return err
+	})
+	if err == nil && len(byPath) > 0 {
+		err = fmt.Errorf("%s: %d layer(s) of the manifest not in the tarball", fpath, len(byPath))
+	}
+	return findings, err
+}
+
+// readTarball call fn with each regular file of the tar (or tar.gz) file, until fn returns an error
+func readTarball(fpath string, fn func(name string, r io.Reader) error) error {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := decompressed(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fpath, err)
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", fpath, err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if err := fn(path.Clean(hdr.Name), tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// tarballManifest return the paths of the config and the layers of the first image from manifest.json of docker
+// save, or else of the image of the platform from index.json of an OCI image layout
+func tarballManifest(docs map[string][]byte, platform string) (string, []string, error) {
+	if datab, ok := docs["manifest.json"]; ok {
+		manifest := []struct {
+			Config string
+			Layers []string
+		}{}
+		if err := json.Unmarshal(datab, &manifest); err != nil {
+			return "", nil, fmt.Errorf("invalid manifest.json - %w", err)
+		}
+		if len(manifest) == 0 {
+			return "", nil, errors.New("no image in manifest.json")
+		}
+		layers := make([]string, 0, len(manifest[0].Layers))
+		for _, l := range manifest[0].Layers {
+			layers = append(layers, path.Clean(l))
+		}
+		return path.Clean(manifest[0].Config), layers, nil
+	}
+	datab, ok := docs["index.json"]
+	for depth := 0; ok && depth < 3; depth++ { // the index may point to a nested index
+		m := imageManifest{}
+		if err := json.Unmarshal(datab, &m); err != nil {
+			return "", nil, fmt.Errorf("invalid image manifest - %w", err)
+		}
+		if len(m.Manifests) > 0 {
+			d, err := pickManifest(m, platform)
+			if err != nil {
+				return "", nil, err
+			}
+			datab, ok = docs[blobPath(d.Digest)]
+			continue
+		}
+		layers := make([]string, 0, len(m.Layers))
+		for _, l := range m.Layers {
+			layers = append(layers, blobPath(l.Digest))
+		}
+		return blobPath(m.Config.Digest), layers, nil
+	}
+	return "", nil, errors.New("not a docker save or OCI image tarball, no manifest.json or index.json")
+}
+
+// blobPath return the path of a blob in an OCI image layout, eg blobs/sha256/<hex>
+func blobPath(digest string) string {
+	algo, hex, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algo, hex)
+}
+
+// decompressed return the reader of a layer or tarball, gunzipped if it is gzip compressed
+func decompressed(r *bufio.Reader) (io.Reader, error) {
+	head, _ := r.Peek(4)
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(r)
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return nil, errors.New("zstd compressed layers are not supported")
+	}
+	return r, nil
+}
+
+// scanLayer scan the files of a layer blob
+func (s *Scanner) scanLayer(ctx context.Context, l imageLayer, r io.Reader) ([]ImageFinding, error) {
+	s.Logger().Info("scan layer", "index", l.index, "layer", l.digest, "created_by", l.createdBy)
+	lr, err := decompressed(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("layer %s: %w", l.digest, err)
+	}
+	output_chan := make(chan OutputFmt)
+	go func() {
+		defer close(output_chan)
+		s.scanTar(ctx, s, l.digest, "", tar.NewReader(lr), 1, output_chan)
+	}()
+	output := Collect(output_chan)
+	findings := []ImageFinding{}
+	for _, matches := range output {
+		for _, o := range matches {
+			findings = append(findings, ImageFinding{OutputFmt: o, Layer: l.digest, LayerIndex: l.index, CreatedBy: l.createdBy})
+		}
+	}
+	return findings, ctx.Err()
+}
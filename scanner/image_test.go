@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanImage(t *testing.T) {
+	layer0 := tarBytes(t, map[string]string{"etc/app.conf": "token='Ab3dEf9hIj2kLm'\n", "etc/hosts": "127.0.0.1 localhost\n"})
+	var layer1 bytes.Buffer
+	gw := gzip.NewWriter(&layer1)
+	gw.Write(tarBytes(t, map[string]string{"app/settings.ini": "password=Xk9dLq2ZmP7wR4\n"}))
+	gw.Close()
+	// the last layer deletes the file, the secret is still in the image
+	layer2 := tarBytes(t, map[string]string{"app/.wh.settings.ini": ""})
+	config := `{"rootfs":{"type":"layers","diff_ids":["sha256:aa","sha256:bb","sha256:cc"]},"history":[
+		{"created_by":"ADD rootfs.tar /"},{"created_by":"ENV APP=1","empty_layer":true},
+		{"created_by":"COPY settings.ini /app/"},{"created_by":"RUN rm /app/settings.ini"}]}`
+	want := []ImageFinding{
+		{OutputFmt: OutputFmt{File: "etc/app.conf"}, Layer: "sha256:aa", LayerIndex: 0, CreatedBy: "ADD rootfs.tar /"},
+		{OutputFmt: OutputFmt{File: "app/settings.ini"}, Layer: "sha256:bb", LayerIndex: 1, CreatedBy: "COPY settings.ini /app/"},
+	}
+	check := func(findings []ImageFinding, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(findings) != len(want) {
+			t.Fatalf("expect %d findings, got %+v", len(want), findings)
+		}
+		for idx, f := range findings {
+			if f.File != want[idx].File || f.Layer != want[idx].Layer || f.LayerIndex != want[idx].LayerIndex || f.CreatedBy != want[idx].CreatedBy {
+				t.Errorf("expect %+v, got %+v", want[idx], f)
+			}
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// docker save tarball
+	save := filepath.Join(t.TempDir(), "image.tar")
+	writeFiles(t, filepath.Dir(save), map[string]string{"image.tar": string(tarBytes(t, map[string]string{
+		"manifest.json":   `[{"Config":"cfg.json","RepoTags":["app:v1"],"Layers":["l0/layer.tar","l1/layer.tar.gz","l2/layer.tar"]}]`,
+		"cfg.json":        config,
+		"l0/layer.tar":    string(layer0),
+		"l1/layer.tar.gz": layer1.String(),
+		"l2/layer.tar":    string(layer2),
+	}))})
+	check(s.ScanImage(context.Background(), save, ImageOpt{}))
+
+	// registry, with a bearer token and a multi-platform index
+	blobs := map[string]string{"sha256:c0": config, "sha256:l0": string(layer0), "sha256:l1": layer1.String(), "sha256:l2": string(layer2)}
+	manifests := map[string]string{
+		"v1": `{"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[
+			{"digest":"sha256:m1","platform":{"os":"linux","architecture":"s390x"}},
+			{"digest":"sha256:m2","platform":{"os":"linux","architecture":"arm64","variant":"v8"}}]}`,
+		"sha256:m1": `{"config":{"digest":"sha256:none"},"layers":[]}`,
+		"sha256:m2": `{"config":{"digest":"sha256:c0"},"layers":[{"digest":"sha256:l0"},{"digest":"sha256:l1"},{"digest":"sha256:l2"}]}`,
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token":"t0k"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0k" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test",scope="repository:team/app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		kind, ref, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/team/app/"), "/")
+		content, ok := map[string]map[string]string{"manifests": manifests, "blobs": blobs}[kind][ref]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer srv.Close()
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/team/app:v1"
+	check(s.ScanImage(context.Background(), ref, ImageOpt{Platform: "linux/arm64/v8"}))
+	if _, err := s.ScanImage(context.Background(), ref, ImageOpt{Platform: "windows/amd64"}); err == nil {
+		t.Error("expect an error for a platform not in the index")
+	}
+
+	for ref, want := range map[string][3]string{
+		"alpine":                    {"registry-1.docker.io", "library/alpine", "latest"},
+		"org/app:1.2":               {"registry-1.docker.io", "org/app", "1.2"},
+		"ghcr.io/org/app@sha256:ab": {"ghcr.io", "org/app", "sha256:ab"},
+		"localhost:5000/app:dev":    {"localhost:5000", "app", "dev"},
+	} {
+		registry, repo, reference, err := ParseImageRef(ref)
+		if err != nil || [3]string{registry, repo, reference} != want {
+			t.Errorf("%s: expect %v, got %s %s %s %v", ref, want, registry, repo, reference, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// The rule file formats LoadRules reads, see ruleFormat
+const (
+	RuleFormatPack        = "cred-detect"
+	RuleFormatGitleaks    = "gitleaks"
+	RuleFormatTrufflehog2 = "trufflehog-v2"
+	RuleFormatTrufflehog3 = "trufflehog"
+)
+
+// ruleFormat guess the format of a rule file: the toml of gitleaks, the json name => regex of trufflehog v2, the
+// yaml custom detectors of trufflehog v3 (a detectors key) or a RulePack
+func ruleFormat(fpath string, datab []byte) string {
+	switch strings.ToLower(filepath.Ext(fpath)) {
+	case ".toml":
+		return RuleFormatGitleaks
+	case ".json":
+		return RuleFormatTrufflehog2
+	}
+	top := map[string]any{}
+	if yaml.Unmarshal(datab, &top) == nil {
+		if _, ok := top["detectors"]; ok {
+			return RuleFormatTrufflehog3
+		}
+	}
+	return RuleFormatPack
+}
+
+var nonIDChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// importedID return the id of an imported rule: the name in lower case with dashes, prefixed by the tool so it does
+// not take the id of a built-in detector
+func importedID(tool, name string) string {
+	return tool + "-" + strings.Trim(nonIDChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// importGitleaks convert the rules of a gitleaks toml config. The rules matching only a path are skipped, and the
+// allowlists are not imported, use a .credignore file or the profile instead.
+func importGitleaks(datab []byte) ([]Rule, error) {
+	cfg := struct {
+		Rules []struct {
+			ID          string   `toml:"id"`
+			Description string   `toml:"description"`
+			Regex       string   `toml:"regex"`
+			SecretGroup int      `toml:"secretGroup"`
+			Entropy     float64  `toml:"entropy"`
+			Keywords    []string `toml:"keywords"`
+		} `toml:"rules"`
+	}{}
+	if err := toml.Unmarshal(datab, &cfg); err != nil {
+		return nil, err
+	}
+	rules := []Rule{}
+	for _, r := range cfg.Rules {
+		if r.Regex == "" {
+			continue
+		}
+		rules = append(rules, Rule{ID: importedID("gitleaks", r.ID), Description: r.Description, Regex: r.Regex,
+			SecretGroup: r.SecretGroup, Entropy: r.Entropy, Keywords: r.Keywords})
+	}
+	return rules, nil
+}
+
+// importTrufflehog2 convert the rules file of trufflehog v2 (--rules), a json object of name => regex
+func importTrufflehog2(datab []byte) ([]Rule, error) {
+	regexes := map[string]string{}
+	if err := json.Unmarshal(datab, &regexes); err != nil {
+		return nil, err
+	}
+	rules := []Rule{}
+	for name, regex := range regexes {
+		rules = append(rules, Rule{ID: importedID("trufflehog", name), Description: name, Regex: regex})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+// importTrufflehog3 convert the custom detectors of a trufflehog v3 config. A detector having many regexes gives a
+// rule per regex, named detector-regex; the verify webhooks are not imported.
+func importTrufflehog3(datab []byte) ([]Rule, error) {
+	cfg := struct {
+		Detectors []struct {
+			Name     string            `yaml:"name"`
+			Keywords []string          `yaml:"keywords"`
+			Regex    map[string]string `yaml:"regex"`
+			Entropy  float64           `yaml:"entropy"`
+		} `yaml:"detectors"`
+	}{}
+	if err := yaml.Unmarshal(datab, &cfg); err != nil {
+		return nil, err
+	}
+	rules := []Rule{}
+	for _, d := range cfg.Detectors {
+		names := make([]string, 0, len(d.Regex))
+		for name := range d.Regex {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			id, description := importedID("trufflehog", d.Name), d.Name
+			if len(names) > 1 {
+				id, description = importedID("trufflehog", d.Name+"-"+name), d.Name+" "+name
+			}
+			rules = append(rules, Rule{ID: id, Description: description, Regex: d.Regex[name], Keywords: d.Keywords, Entropy: d.Entropy})
+		}
+	}
+	return rules, nil
+}
+
+// importRules convert the rules of a gitleaks or trufflehog file
+func importRules(format string, datab []byte) ([]Rule, error) {
+	switch format {
+	case RuleFormatGitleaks:
+		return importGitleaks(datab)
+	case RuleFormatTrufflehog2:
+		return importTrufflehog2(datab)
+	case RuleFormatTrufflehog3:
+		return importTrufflehog3(datab)
+	}
+	return nil, fmt.Errorf("unknown rule format '%s'", format)
+}
@@ -0,0 +1,69 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRuleImport(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"gitleaks.toml": `title = "custom"
+
+[[rules]]
+id = "acme-api-key"
+description = "ACME api key"
+regex = '''(?i)acme[_-]?key\s*=\s*"?(\w{4})(\w{28})'''
+secretGroup = 2
+keywords = ["acme"]
+
+[[rules]]
+id = "secret-file"
+path = '''\.pem$'''
+`,
+		"rules.json": `{"Hog token": "HOG[0-9A-Z]{17}"}`,
+		"trufflehog.yaml": `detectors:
+  - name: HogDetector
+    keywords: [hog]
+    regex:
+      id: '\b(HOG[0-9A-Z]{17})\b'
+      secret: 'hogsecret=([A-Za-z0-9]{20})'
+`,
+		"src/app.conf": "acme_key = \"abcd0123456789abcdef0123456789ab\"\n",
+	})
+	ids := func(rules []Rule) []string {
+		o := []string{}
+		for _, r := range rules {
+			o = append(o, r.ID)
+		}
+		return o
+	}
+	for file, want := range map[string][]string{
+		"gitleaks.toml":   {"gitleaks-acme-api-key"},
+		"rules.json":      {"trufflehog-hog-token"},
+		"trufflehog.yaml": {"trufflehog-hogdetector-id", "trufflehog-hogdetector-secret"},
+	} {
+		rules, err := LoadRules(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(rules); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expect rules %v, got %v", file, want, got)
+		}
+	}
+	rules, _ := LoadRules(filepath.Join(dir, "gitleaks.toml"))
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Redact = RedactNone
+	cfg.Rules = rules
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := Collect(s.Scan(context.Background(), filepath.Join(dir, "src"))).List()
+	if len(list) != 1 || list[0].Matches[1] != "0123456789abcdef0123456789ab" {
+		t.Errorf("expect the secret group 2 as the value, got %v", list)
+	}
+}
@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// JUnit XML report, the subset Jenkins, GitLab and Azure DevOps read
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *JUnitMessage `xml:"failure,omitempty"`
+	Skipped   *JUnitMessage `xml:"skipped,omitempty"`
+}
+
+type JUnitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// ToJUnit convert the scan output to a JUnit report with one test case per file and rule. The findings matching
+// failOn are failures, the others are skipped so they are visible without failing the build. Without findings the
+// report has one passing test case. The file paths are made relative to root.
+func ToJUnit(output ProjectOutputFmt, root string, failOn FailOn) JUnitTestSuites {
+	type key struct{ file, rule string }
+	cases := map[key][]OutputFmt{}
+	for file, matches := range output {
+		for _, o := range matches {
+			k := key{file, firstNonEmpty(o.RuleID, PatternRuleID(o.Pattern))}
+			cases[k] = append(cases[k], o)
+		}
+	}
+	keys := make([]key, 0, len(cases))
+	for k := range cases {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].file != keys[j].file {
+			return keys[i].file < keys[j].file
+		}
+		return keys[i].rule < keys[j].rule
+	})
+	suite := JUnitTestSuite{Name: "cred-detect", TestCases: []JUnitTestCase{}}
+	for _, k := range keys {
+		findings := cases[k]
+		sortFindings(findings)
+		file := k.file
+		if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+		file = filepath.ToSlash(file)
+		failing, lines, names, severity := false, []string{}, []string{}, ""
+		for _, o := range findings {
+			failing = failing || failOn.Match(o)
+			severity = maxLevel(severity, o.Severity)
+			for idx := 0; idx < len(o.Matches); idx += 2 {
+				if !slices.Contains(names, o.Matches[idx]) {
+					names = append(names, o.Matches[idx])
+				}
+			}
+			for _, line := range o.Line_no {
+				lines = append(lines, fmt.Sprintf("%s:%d severity %s, confidence %s", file, line+1, o.Severity, o.Confidence))
+			}
+		}
+		msg := &JUnitMessage{
+			Message: fmt.Sprintf("%d possible credential(s) in '%s'", len(lines), strings.Join(names, "', '")),
+			Type:    severity,
+			Text:    strings.Join(lines, "\n"),
+		}
+		tc := JUnitTestCase{ClassName: file, Name: k.rule, File: file, Line: firstLine(findings[0]) + 1}
+		if failing {
+			tc.Failure = msg
+			suite.Failures++
+		} else {
+			tc.Skipped = msg
+			suite.Skipped++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	if len(suite.TestCases) == 0 {
+		suite.TestCases = append(suite.TestCases, JUnitTestCase{ClassName: "cred-detect", Name: "no credential found"})
+	}
+	suite.Tests = len(suite.TestCases)
+	return JUnitTestSuites{Name: "cred-detect", Tests: suite.Tests, Failures: suite.Failures, Skipped: suite.Skipped, Suites: []JUnitTestSuite{suite}}
+}
@@ -0,0 +1,32 @@
+package scanner
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestToJUnit(t *testing.T) {
+	output := ProjectOutputFmt{
+		"/src/a.conf": {
+			"password*****":          {File: "/src/a.conf", Line_no: []int{1, 4}, Matches: []string{"password", "*****", "token", "*****"}, RuleID: "cred-detect/0", Severity: SeverityMedium, Confidence: ConfidenceLow},
+			"aws-access-key-id*****": {File: "/src/a.conf", Line_no: []int{2}, Matches: []string{"aws-access-key-id", "*****"}, RuleID: "aws-access-key-id", Severity: SeverityHigh, Confidence: ConfidenceHigh},
+		},
+	}
+	report := ToJUnit(output, "/src", FailOn{Severity: SeverityHigh})
+	if report.Tests != 2 || report.Failures != 1 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts %+v", report)
+	}
+	tc := report.Suites[0].TestCases
+	if tc[0].Name != "aws-access-key-id" || tc[0].Failure == nil || tc[0].ClassName != "a.conf" || tc[0].Line != 3 {
+		t.Errorf("unexpected test case %+v", tc[0])
+	}
+	if tc[1].Skipped == nil || tc[1].Skipped.Message != "2 possible credential(s) in 'password', 'token'" {
+		t.Errorf("unexpected test case %+v", tc[1])
+	}
+	if _, err := xml.Marshal(report); err != nil {
+		t.Fatal(err)
+	}
+	if empty := ToJUnit(ProjectOutputFmt{}, "/src", FailOn{}); empty.Tests != 1 || empty.Failures != 0 {
+		t.Errorf("expect one passing test case, got %+v", empty)
+	}
+}
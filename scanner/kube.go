@@ -0,0 +1,203 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	ag "github.com/sunshine69/automation-go/lib"
+	"gopkg.in/yaml.v3"
+)
+
+// KubernetesRuleID is the rule of the values of the kubernetes Secrets, and of the ConfigMaps under a suspicious key,
+// found with Config.Kubernetes
+const KubernetesRuleID = "kubernetes-secret"
+
+// KubernetesRemediation is the remediation of the KubernetesRuleID findings
+const KubernetesRemediation = "Remove the Secret from the repository, rotate its values and create it at deploy time, eg with sealed-secrets, external-secrets or the CI"
+
+const kubernetesDocURL = "https://kubernetes.io/docs/concepts/security/secrets-good-practices/"
+
+// kubeValue is a value of a kubernetes manifest; path is the kind, name and key of the value, eg
+// Secret/db.data.password, line is 0 based
+type kubeValue struct {
+	line        int
+	path, value string
+	secret      bool // a value of a Secret, else of a ConfigMap
+}
+
+// kubeValues return the values of the Secrets, the data decoded from base64, and the values of the ConfigMaps under
+// a suspicious key, in the yaml documents of data. A List is walked too.
+func kubeValues(data []byte) ([]kubeValue, error) {
+	values := []kubeValue{}
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.DocumentNode {
+			for _, c := range n.Content {
+				walk(c)
+			}
+			return
+		}
+		kind, name := "", ""
+		if k := mappingValue(n, "kind"); k != nil {
+			kind = k.Value
+		}
+		if meta := mappingValue(n, "metadata"); meta != nil {
+			if v := mappingValue(meta, "name"); v != nil {
+				name = v.Value
+			}
+		}
+		switch kind {
+		case "List", "SecretList", "ConfigMapList":
+			if items := mappingValue(n, "items"); items != nil && items.Kind == yaml.SequenceNode {
+				for _, c := range items.Content {
+					walk(c)
+				}
+			}
+		case "Secret", "ConfigMap":
+			for _, field := range []string{"data", "stringData"} {
+				entries := mappingValue(n, field)
+				if entries == nil || entries.Kind != yaml.MappingNode || (kind == "ConfigMap" && field == "stringData") {
+					continue
+				}
+				for idx := 0; idx+1 < len(entries.Content); idx += 2 {
+					k, v := entries.Content[idx].Value, entries.Content[idx+1]
+					if v.Kind != yaml.ScalarNode || (kind == "ConfigMap" && !SuspiciousKeyPattern.MatchString(k)) {
+						continue
+					}
+					value := v.Value
+					if kind == "Secret" && field == "data" {
+						datab, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+						if err != nil {
+							continue // not base64, kubernetes would refuse it
+						}
+						value = string(datab)
+					}
+					values = append(values, kubeValue{line: v.Line - 1, path: kind + "/" + name + "." + field + "." + k, value: value, secret: kind == "Secret"})
+				}
+			}
+		}
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		doc := yaml.Node{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		walk(&doc)
+	}
+}
+
+// mappingValue return the value of the key of a yaml mapping, nil if n is not a mapping or has not the key
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for idx := 0; idx+1 < len(n.Content); idx += 2 {
+		if n.Content[idx].Value == key {
+			return n.Content[idx+1]
+		}
+	}
+	return nil
+}
+
+// isKubeManifest tell if the yaml file may be a kubernetes manifest worth parsing
+func isKubeManifest(data []byte) bool {
+	return bytes.Contains(data, []byte("kind:")) && (bytes.Contains(data, []byte("Secret")) || bytes.Contains(data, []byte("ConfigMap")) || bytes.Contains(data, []byte("List")))
+}
+
+// matchKubernetes report the values of the kubernetes Secrets and ConfigMaps of a yaml file. A template of a helm
+// chart is rendered first with the values.yaml of the chart, then its findings are at the line of the template with
+// the key, and name the key of the values it comes from if any. A file that does not parse or render only gets the
+// line scan. It returns false if the context is done.
+func (m *fileMatcher) matchKubernetes(data []byte) bool {
+	s, fpath := m.s, m.fpath
+	lines := strings.Split(string(data), "\n")
+	chart := s.chartOf(fpath)
+	if chart != nil {
+		rendered, err := chart.render(fpath)
+		if err != nil {
+			s.Logger().Debug("can not render helm template, line scan only", "path", fpath, "error", err)
+			return true
+		}
+		data = []byte(rendered)
+	}
+	if !isKubeManifest(data) {
+		return true
+	}
+	values, err := kubeValues(data)
+	if err != nil {
+		s.Logger().Debug("can not parse kubernetes manifest, line scan only", "path", fpath, "error", err)
+	}
+	var o *OutputFmt
+	for _, v := range values {
+		value, name, lineNo := strings.TrimSpace(v.value), v.path, v.line
+		if chart != nil {
+			lineNo = templateLine(lines, v.path[strings.LastIndex(v.path, ".")+1:])
+			if key := chart.valuesKey(value); key != "" {
+				name += " (.Values." + key + ")"
+			}
+		}
+		literal := strings.TrimSpace(literalText(value))
+		if literal == "" || m.hitLines[lineNo] || lineNo >= len(lines) || s.isPlaceholder(literal) ||
+			(!v.secret && !ag.IsLikelyPasswordOrToken(literal, s.cfg.CheckMode, s.cfg.WordsFile, 4, s.cfg.EntropyThreshold)) {
+			continue
+		}
+		line, prev := strings.TrimSuffix(lines[lineNo], "\r"), ""
+		if lineNo > 0 {
+			prev = lines[lineNo-1]
+		}
+		if chart == nil {
+			m.hitLines[lineNo] = true // not reported again by the structured scan
+		}
+		pairs := []string{name, value}
+		confidence := scoreConfidence(ConfidenceMedium, fpath, false, pairs)
+		fingerprint := Fingerprint(fpath, KubernetesRuleID, value, line)
+		if suppressedBy(line, prev) {
+			m.addSuppressed(OutputFmt{File: fpath, Line_no: []int{lineNo}, Matches: pairs, RuleID: KubernetesRuleID, Severity: SeverityMedium,
+				Confidence: confidence, Fingerprint: fingerprint})
+			continue
+		}
+		if m.inProfile("", fingerprint) {
+			s.Logger().Info("fingerprints exist in profile, skipping", "path", fpath, "line", lineNo)
+			continue
+		}
+		if o == nil {
+			o = &OutputFmt{File: fpath, Line_no: []int{}, Matches: []string{}, RuleID: KubernetesRuleID, Severity: SeverityMedium, Fingerprint: fingerprint,
+				SecretID: s.secretID(value), Remediation: KubernetesRemediation, DocURL: kubernetesDocURL}
+		}
+		o.Confidence = maxLevel(o.Confidence, confidence)
+		o.Line_no = append(o.Line_no, lineNo)
+		o.Matches = append(o.Matches, pairs...)
+		if m.inProfile(o.Matches[0]+o.Matches[1], "") {
+			s.Logger().Info("matches exist in profile, skipping", "path", fpath, "signature", o.Matches[0]+o.Matches[1])
+			continue
+		}
+		s.redact(o.Matches)
+		found := *o
+		found.Line_no = append([]int{}, o.Line_no...)
+		found.Matches = append([]string{}, o.Matches...)
+		if !m.send(KubernetesRuleID, found) {
+			return false
+		}
+	}
+	return true
+}
+
+// templateLine return the first line of a helm template with the key, or 0
+func templateLine(lines []string, key string) int {
+	ptn := regexp.MustCompile(`^\s*['"]?` + regexp.QuoteMeta(key) + `['"]?\s*:`)
+	for idx, line := range lines {
+		if ptn.MatchString(line) {
+			return idx
+		}
+	}
+	return 0
+}
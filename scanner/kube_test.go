@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKubernetes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"deploy/secret.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\ndata:\n  conn: " +
+			base64.StdEncoding.EncodeToString([]byte("Xk9dLq2ZmP7wR4")) + "\n  empty: \"\"\nstringData:\n  user: admin\n---\n" +
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  log_level: debug\n  api_token: Qw8rTy5UiO3pAs\n",
+		"chart/Chart.yaml":             "apiVersion: v2\nname: app\nversion: 0.1.0\n",
+		"chart/values.yaml":            "db:\n  conn: Zx9kQ2mP4vLw8q\n  host: db\n",
+		"chart/templates/_helpers.tpl": "{{- define \"app.fullname\" -}}{{ .Release.Name }}-{{ .Chart.Name }}{{- end -}}\n",
+		"chart/templates/secret.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: {{ include \"app.fullname\" . }}\n" +
+			"data:\n  host: {{ .Values.db.host | b64enc }}\n  conn: {{ .Values.db.conn | b64enc | quote }}\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Patterns = nil // only the kubernetes scan
+	cfg.Detectors = nil
+	cfg.Kubernetes = true
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]int{}
+	for _, o := range Collect(s.Scan(context.Background(), dir)).List() {
+		rel, _ := filepath.Rel(dir, o.File)
+		for idx := 0; idx < len(o.Matches); idx += 2 {
+			found[fmt.Sprintf("%s:%d %s", filepath.ToSlash(rel), o.Line_no[idx/2]+1, o.Matches[idx])] = 1
+		}
+		if o.RuleID != KubernetesRuleID || o.Remediation != KubernetesRemediation {
+			t.Errorf("unexpected rule %+v", o)
+		}
+	}
+	want := map[string]int{
+		"deploy/secret.yaml:6 Secret/db.data.conn":                                          1,
+		"deploy/secret.yaml:9 Secret/db.stringData.user":                                    1,
+		"deploy/secret.yaml:17 ConfigMap/app.data.api_token":                                1,
+		"chart/templates/secret.yaml:6 Secret/release-name-app.data.host (.Values.db.host)": 1,
+		"chart/templates/secret.yaml:7 Secret/release-name-app.data.conn (.Values.db.conn)": 1,
+	}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("expect the kubernetes values %v, got %v", want, found)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package scanner
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile is not supported, the files are read as streams
+func mmapFile(f *os.File, size int64) (data []byte, unmap func() error, err error) {
+	return nil, nil, errors.ErrUnsupported
+}
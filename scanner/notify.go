@@ -0,0 +1,117 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Notification is the data of the message template of a Notifier
+type Notification struct {
+	Root     string
+	Count    int            // the number of new findings
+	Findings []FindingEvent // the first MaxNotifyFindings, by file and line
+	More     int            // the findings not listed
+	Time     time.Time
+}
+
+// MaxNotifyFindings bound the findings listed in a notification, a chat message has a size limit
+var MaxNotifyFindings = 50
+
+// DefaultNotifyTemplate is the message of a notification; the line func gives the 1 based first line of a finding
+const DefaultNotifyTemplate = `cred-detect found {{.Count}} new finding(s) in {{.Root}}
+{{range .Findings}}- {{.File}}:{{line .}} {{.RuleID}} '{{.Name}}' severity {{.Severity}}
+{{end}}{{if .More}}and {{.More}} more
+{{end}}`
+
+// Notifier post one message per scan with its new findings, never the values: to a webhook as json, or to a slack or
+// teams incoming webhook as a chat message
+type Notifier struct {
+	url      string
+	format   string // webhook, slack or teams
+	template *template.Template
+	client   *http.Client
+}
+
+// NewNotifier create a notifier posting to the url. format is webhook, slack or teams, empty guesses it from the url
+// host. tmpl is a text/template of the message over a Notification, empty for DefaultNotifyTemplate.
+func NewNotifier(notifyURL, format, tmpl string) (*Notifier, error) {
+	u, err := url.Parse(notifyURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid notify url '%s', expect http(s)://", notifyURL)
+	}
+	if format == "" {
+		switch host := u.Hostname(); {
+		case host == "hooks.slack.com":
+			format = "slack"
+		case strings.HasSuffix(host, ".webhook.office.com") || host == "outlook.office.com" || strings.HasSuffix(host, ".logic.azure.com"):
+			format = "teams"
+		default:
+			format = "webhook"
+		}
+	}
+	if format != "webhook" && format != "slack" && format != "teams" {
+		return nil, fmt.Errorf("unknown notify format '%s', expect webhook, slack or teams", format)
+	}
+	if tmpl == "" {
+		tmpl = DefaultNotifyTemplate
+	}
+	t, err := template.New("notify").Funcs(template.FuncMap{
+		"line": func(ev FindingEvent) int { return firstLine(OutputFmt{Line_no: ev.Line_no}) + 1 },
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify template - %w", err)
+	}
+	return &Notifier{url: notifyURL, format: format, template: t, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// Notify post the findings of a scan of root; nothing is sent without findings
+func (n *Notifier) Notify(root string, findings ProjectOutputFmt) error {
+	list := findings.List()
+	if len(list) == 0 {
+		return nil
+	}
+	data := Notification{Root: root, Count: len(list), Time: time.Now()}
+	for idx, o := range list {
+		if idx == MaxNotifyFindings {
+			data.More = len(list) - idx
+			break
+		}
+		data.Findings = append(data.Findings, NewFindingEvent(root, o))
+	}
+	var text bytes.Buffer
+	if err := n.template.Execute(&text, data); err != nil {
+		return fmt.Errorf("notify template - %w", err)
+	}
+	var payload any
+	switch n.format {
+	case "slack":
+		payload = map[string]string{"text": text.String()}
+	case "teams":
+		payload = map[string]string{"@type": "MessageCard", "@context": "https://schema.org/extensions",
+			"summary": fmt.Sprintf("cred-detect: %d new finding(s)", data.Count), "text": strings.ReplaceAll(text.String(), "\n", "\n\n")}
+	default:
+		payload = struct {
+			Notification
+			Text string
+		}{data, text.String()}
+	}
+	datab, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(datab))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify %s returned %s", n.format, resp.Status)
+	}
+	return nil
+}
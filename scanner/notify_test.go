@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = map[string]any{}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	findings := ProjectOutputFmt{}
+	findings.Add(OutputFmt{File: "a.txt", Line_no: []int{2}, Matches: []string{"password", "Xk9dLq2ZmP7wR4"}})
+	findings.Add(OutputFmt{File: "b.txt", Line_no: []int{0}, Matches: []string{"token", "Qw8rTy5UiO3pAs"}})
+
+	n, err := NewNotifier(srv.URL, "slack", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(".", findings); err != nil {
+		t.Fatal(err)
+	}
+	text, _ := got["text"].(string)
+	if !strings.Contains(text, "2 new finding(s)") || !strings.Contains(text, "a.txt:3") || strings.Contains(text, "Xk9dLq2ZmP7wR4") {
+		t.Errorf("unexpected slack message %q", text)
+	}
+
+	defer func(n int) { MaxNotifyFindings = n }(MaxNotifyFindings)
+	MaxNotifyFindings = 1
+	n, err = NewNotifier(srv.URL, "", "{{.Count}} {{range .Findings}}{{.File}}{{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(".", findings); err != nil {
+		t.Fatal(err)
+	}
+	if got["Count"] != 2.0 || got["More"] != 1.0 || got["Text"] != "2 a.txt" {
+		t.Errorf("unexpected webhook payload %v", got)
+	}
+
+	got = nil
+	if err := n.Notify(".", ProjectOutputFmt{}); err != nil || got != nil {
+		t.Errorf("expect nothing sent without findings, got %v %v", got, err)
+	}
+	if _, err := NewNotifier(srv.URL, "mail", ""); err == nil {
+		t.Error("expect error for an unknown format")
+	}
+	if _, err := NewNotifier(srv.URL, "", "{{.Count"); err == nil {
+		t.Error("expect error for a bad template")
+	}
+}
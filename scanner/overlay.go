@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LocalConfigName is the config file of a sub directory, see LocalConfig
+const LocalConfigName = "cred-detect-config.yaml"
+
+// LocalConfig is the part of a LocalConfigName file of a sub directory applied to its subtree, merged over the config
+// of the parent directory, eg so a monorepo project has its own excludes and rules. The keys are the ones of the
+// command line options; the other keys of the file are ignored, so a config saved by cred-detect can be used as is.
+type LocalConfig struct {
+	FilenamePattern  *string   `yaml:"fptn"`
+	Exclude          *string   `yaml:"exclude"`
+	PathExclude      *string   `yaml:"path-exclude"`
+	Detectors        *[]string `yaml:"detectors"`
+	EntropyThreshold *float64  `yaml:"entropy-threshold"`
+	Rules            []string  `yaml:"rules"` // rule files relative to the directory, added to the rules of the parent
+}
+
+// withLocalConfig return the scanner of the subtree dir: a scanner with the LocalConfigName file of dir merged over
+// the config of s, or s if dir has none. It only matches, the scan state stays on the scanner running the scan. A file
+// that can not be read or is invalid is logged and ignored.
+func (s *Scanner) withLocalConfig(dir string) *Scanner {
+	fpath := filepath.Join(dir, LocalConfigName)
+	datab, err := os.ReadFile(fpath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s
+	}
+	if err == nil {
+		var sub *Scanner
+		if sub, err = s.localScanner(dir, datab); err == nil {
+			s.Logger().Info("local config", "path", fpath)
+			return sub
+		}
+	}
+	s.Logger().Warn("can not use local config", "path", fpath, "error", err)
+	return s
+}
+
+func (s *Scanner) localScanner(dir string, datab []byte) (*Scanner, error) {
+	local := LocalConfig{}
+	if err := yaml.Unmarshal(datab, &local); err != nil {
+		return nil, err
+	}
+	cfg := s.cfg
+	cfg.ProfilePath, cfg.CachePath = "", "" // the scan state is not on the sub scanner
+	if local.FilenamePattern != nil {
+		cfg.FilenamePattern = *local.FilenamePattern
+	}
+	if local.Exclude != nil {
+		cfg.Exclude = *local.Exclude
+	}
+	if local.PathExclude != nil {
+		cfg.PathExclude = *local.PathExclude
+	}
+	if local.Detectors != nil {
+		cfg.Detectors = *local.Detectors
+	}
+	if local.EntropyThreshold != nil {
+		cfg.EntropyThreshold = *local.EntropyThreshold
+	}
+	cfg.Rules = append([]Rule{}, cfg.Rules...)
+	loaded := map[string]bool{}
+	for _, r := range cfg.Rules {
+		if abs, err := filepath.Abs(r.Source); err == nil {
+			loaded[abs] = true
+		}
+	}
+	for _, rf := range local.Rules {
+		if !filepath.IsAbs(rf) {
+			rf = filepath.Join(dir, rf)
+		}
+		if abs, err := filepath.Abs(rf); err == nil && loaded[abs] { // the parent has it already
+			continue
+		}
+		rules, err := LoadRules(rf)
+		if err != nil {
+			return nil, fmt.Errorf("rules %s - %w", rf, err)
+		}
+		cfg.Rules = append(cfg.Rules, rules...)
+	}
+	sub, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sub.logger = s.logger
+	return sub, nil
+}
+
+// rulesKey identify the config of the scanner sub of s in the cache entries, "" for s itself
+func (s *Scanner) rulesKey(sub *Scanner) string {
+	if sub == s {
+		return ""
+	}
+	return sub.cfg.Hash()
+}
@@ -0,0 +1,51 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLocalConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app/app.conf":               "key = acme_0123456789abcdef0123456789abcdef\n",
+		"svc/" + LocalConfigName:     "exclude: '\\.conf$'\nrules: [rules.yaml]\nprofile: ignored.json\n",
+		"svc/rules.yaml":             "rules:\n  - id: acme-api-key\n    regex: '\\b(acme_[0-9a-f]{32})\\b'\n",
+		"svc/app.conf":               "password=\"Xk9dLq2ZmP7wR4\"\n",
+		"svc/api/settings.txt":       "key = acme_0123456789abcdef0123456789abcdef\n",
+		"svc/api/" + LocalConfigName: "fptn: '\\.ini$'\n",
+		"svc/api/db.ini":             "key = acme_fedcba9876543210fedcba9876543210\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := func() map[string][]string {
+		o := map[string][]string{}
+		for file, matches := range Collect(s.Scan(context.Background(), dir)) {
+			rel, _ := filepath.Rel(dir, file)
+			for _, m := range matches {
+				o[filepath.ToSlash(rel)] = append(o[filepath.ToSlash(rel)], m.RuleID)
+			}
+		}
+		return o
+	}
+	got := findings()
+	// the rules of svc apply to its subtree only, the nested config is merged over it
+	if len(got["app/app.conf"]) != 0 || len(got["svc/app.conf"]) != 0 || len(got["svc/api/settings.txt"]) != 0 ||
+		!reflect.DeepEqual(got["svc/api/db.ini"], []string{"acme-api-key"}) {
+		t.Errorf("expect the local configs applied to their subtree, got %v", got)
+	}
+	cfg.NoLocalConfig = true
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	got = findings()
+	if _, ok := got["svc/app.conf"]; !ok || len(got["svc/api/db.ini"]) != 0 {
+		t.Errorf("expect the local configs ignored, got %v", got)
+	}
+}
@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PlaceholderPatterns match the values that are placeholders or references to a secret rather than a secret, eg
+// changeme, <password>, ${DB_PASSWORD}, {{ vault_pw }} or the keys of the example.com docs. They are not reported;
+// Config.Placeholders adds to them.
+var PlaceholderPatterns = []string{
+	`^\$\{[^}]*\}?$`,             // ${VAR}
+	`^\$[A-Za-z_][A-Za-z0-9_]*$`, // $VAR
+	`^%[A-Za-z_][A-Za-z0-9_]*%$`, // %VAR%
+	`^%\(.*\)s$`,                 // %(name)s
+	`^\{[{%]`,                    // the jinja, go and helm templates, the value may be cut at the first space
+	`^<[A-Za-z_][^<>]*>?$`,       // <password>
+	`^[*xX.#-]+$`,                // ****, xxxxxx
+	`(?i)^(change[_-]?(me|it|this)|replace[_-]?me|(your|my)[_-]?(password|passwd|secret|token|api[_-]?key|key)\w*|password\d*|passwd|secret|token|api[_-]?key|example|sample|placeholder|dummy|fake|test(ing)?\d*|todo|tbd|fixme|redacted|hidden|none|null|nil|undefined|empty)$`,
+	`(?i)(\bexample\.(com|org|net)\b|xxxxx)`, // a key of user@example.com, sk_live_xxxxx
+}
+
+// templateExprPtn match the expressions of the jinja2, ansible, helm and shell templates: {{ ... }}, {% ... %},
+// {# ... #} and ${...}. Their values are rendered at deploy time, eg. password: "{{ vault_db_password }}", they are
+// not secrets.
+var templateExprPtn = regexp.MustCompile(`\{\{.*?\}\}|\{%.*?%\}|\{#.*?#\}|\$\{[^}]*\}`)
+
+// literalText replace the template expressions of the text by a space, only its literal parts are matched:
+// pre_${TOKEN}_post is "pre_ _post"
+func literalText(text string) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	return templateExprPtn.ReplaceAllString(text, " ")
+}
+
+// compilePlaceholders compile the built-in placeholder patterns and the extra ones
+func compilePlaceholders(extra []string) ([]*regexp.Regexp, error) {
+	ptns := []*regexp.Regexp{}
+	for _, ptn := range append(append([]string{}, PlaceholderPatterns...), extra...) {
+		re, err := regexp.Compile(ptn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid placeholder pattern '%s' - %w", ptn, err)
+		}
+		ptns = append(ptns, re)
+	}
+	return ptns, nil
+}
+
+// isPlaceholder tell if the value is a placeholder, see PlaceholderPatterns
+func (s *Scanner) isPlaceholder(value string) bool {
+	for _, ptn := range s.placeholders {
+		if ptn.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPlaceholders(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.conf": strings.Join([]string{
+			"password=changeme",
+			"password='<db-password>'",
+			"token=${API_TOKEN}",
+			`secret: "{{ vault_secret }}"`,
+			"api_key=xxxxxxxxxxxx",
+			"secret=your_api_key_here",
+			"token=Zx9cVb7nMq2wE.example.com",
+			"password=vault:Qw8eRt5yUi3oP",
+			"password=Zx9cVb7nMq2wE",
+		}, "\n") + "\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Redact = RedactNone // the values are not masked
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := func() []string {
+		found := []string{}
+		for _, o := range Collect(s.Scan(context.Background(), dir)).List() {
+			for idx := 1; idx < len(o.Matches); idx += 2 {
+				found = append(found, o.Matches[idx])
+			}
+		}
+		sort.Strings(found)
+		return found
+	}
+	if found := values(); !reflect.DeepEqual(found, []string{"Zx9cVb7nMq2wE", "vault:Qw8eRt5yUi3oP"}) {
+		t.Errorf("expect the placeholders not reported, got %v", found)
+	}
+	cfg.Placeholders = []string{`^vault:`}
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if found := values(); !reflect.DeepEqual(found, []string{"Zx9cVb7nMq2wE"}) {
+		t.Errorf("expect the extra placeholder not reported, got %v", found)
+	}
+	cfg.Placeholders = []string{`(`}
+	if err := s.Configure(cfg); err == nil {
+		t.Error("expect an invalid placeholder regex refused")
+	}
+}
+
+func TestTemplateExpressions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"vars.yml": strings.Join([]string{
+			`db_password: "{{ vault_db_password }}"`,
+			`api_token: "{{ lookup('env', 'API_TOKEN') | default('none') }}"`,
+			`token: pre_${API_TOKEN_VALUE}_post`,
+			`secret: "{% if prod %}{{ prod_secret }}{% else %}{{ dev_secret }}{% endif %}"`,
+			`password: "{{ db_user }}:Zx9cVb7nMq2wE"`,
+		}, "\n") + "\n",
+		"app.conf": "token=Qw8eRt5yUi3oP${SUFFIX}\npassword=${PREFIX}_{{ name }}\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Redact = RedactNone
+	cfg.Structured = true // the yaml value after an expression is not a line match
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := []string{}
+	for _, o := range Collect(s.Scan(context.Background(), dir)).List() {
+		for idx := 1; idx < len(o.Matches); idx += 2 {
+			found = append(found, filepath.Base(o.File)+":"+o.Matches[idx])
+		}
+	}
+	sort.Strings(found)
+	want := []string{"app.conf:Qw8eRt5yUi3oP", "vars.yml:{{ db_user }}:Zx9cVb7nMq2wE"}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("expect only the literal secrets next to the template expressions, got %v", found)
+	}
+}
@@ -0,0 +1,154 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ag "github.com/sunshine69/automation-go/lib"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy decide if a scan fails from its findings, instead of FailOn: the scan fails if one of its rules fails. eg.
+//
+//	rules:
+//	  - name: no verified secret
+//	    fail_when: findings | selectattr('verified', 'eq', 'verified') | list | length > 0
+//	  - name: few high severity findings outside the tests
+//	    exclude_paths: [test/, "*_test.go"]
+//	    fail_when: by_severity.high > 5
+type Policy struct {
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule is a jinja2 condition, like an ansible failed_when, over the findings of the files it selects. Its
+// variables are findings, the list of the findings, each with file, lines, rule_id, severity, confidence, verified,
+// owners, fingerprint and test (in a test file, see IsTestPath); count, their number; and by_severity, their number
+// by severity.
+type PolicyRule struct {
+	Name         string   `yaml:"name"`
+	Paths        []string `yaml:"paths,omitempty"`         // only the findings of the files matching one of these gitignore patterns, relative to the scan root
+	ExcludePaths []string `yaml:"exclude_paths,omitempty"` // not the findings of the files matching one of these
+	FailWhen     string   `yaml:"fail_when"`
+	paths        []ignoreRule
+	exclude      []ignoreRule
+}
+
+// PolicyResult is the result of a rule of the policy
+type PolicyResult struct {
+	Name     string
+	Failed   bool
+	Findings int    // the findings the rule selected
+	Error    string `json:",omitempty"` // the expression failed, the rule fails
+}
+
+// LoadPolicy read a policy yaml file and check its rules
+func LoadPolicy(fpath string) (*Policy, error) {
+	datab, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParsePolicy(datab)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %s - %w", fpath, err)
+	}
+	return p, nil
+}
+
+// ParsePolicy parse a policy and check its rules: each has a name and a fail_when expression evaluating without
+// findings
+func ParsePolicy(datab []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.Unmarshal(datab, p); err != nil {
+		return nil, err
+	}
+	if len(p.Rules) == 0 {
+		return nil, fmt.Errorf("no rules")
+	}
+	for idx := range p.Rules {
+		r := &p.Rules[idx]
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", idx+1)
+		}
+		if strings.TrimSpace(r.FailWhen) == "" {
+			return nil, fmt.Errorf("rule '%s' has no fail_when", r.Name)
+		}
+		r.paths = parseIgnore(strings.Join(r.Paths, "\n"))
+		r.exclude = parseIgnore(strings.Join(r.ExcludePaths, "\n"))
+		if _, err := ag.EvalBool(r.FailWhen, policyVars(nil)); err != nil {
+			return nil, fmt.Errorf("rule '%s': %w", r.Name, err)
+		}
+	}
+	return p, nil
+}
+
+// Evaluate run the rules over the findings of a scan of root and tell if the scan fails
+func (p *Policy) Evaluate(findings []OutputFmt, root string) ([]PolicyResult, bool) {
+	results := make([]PolicyResult, 0, len(p.Rules))
+	failed := false
+	for _, r := range p.Rules {
+		selected := []OutputFmt{}
+		for _, o := range findings {
+			rel := policyPath(o.File, root)
+			if (len(r.Paths) == 0 || pathMatches(r.paths, rel)) && !pathMatches(r.exclude, rel) {
+				selected = append(selected, o)
+			}
+		}
+		res := PolicyResult{Name: r.Name, Findings: len(selected)}
+		ok, err := ag.EvalBool(r.FailWhen, policyVars(selected))
+		if err != nil {
+			res.Error = err.Error()
+		}
+		res.Failed = ok || err != nil
+		failed = failed || res.Failed
+		results = append(results, res)
+	}
+	return results, failed
+}
+
+// policyVars return the variables of the fail_when expressions, see PolicyRule
+func policyVars(findings []OutputFmt) map[string]any {
+	list := make([]any, 0, len(findings))
+	bySeverity := map[string]any{SeverityHigh: 0, SeverityMedium: 0, SeverityLow: 0}
+	for _, o := range findings {
+		owners := make([]any, 0, len(o.Owners))
+		for _, owner := range o.Owners {
+			owners = append(owners, owner)
+		}
+		lines := make([]any, 0, len(o.Line_no))
+		for _, l := range o.Line_no {
+			lines = append(lines, l+1)
+		}
+		severity := firstNonEmpty(o.Severity, SeverityMedium)
+		list = append(list, map[string]any{"file": o.File, "lines": lines, "rule_id": firstNonEmpty(o.RuleID, PatternRuleID(o.Pattern)), "severity": severity,
+			"confidence": o.Confidence, "verified": o.Verified, "owners": owners, "fingerprint": o.Fingerprint, "test": IsTestPath(o.File)})
+		bySeverity[severity] = bySeverity[severity].(int) + 1
+	}
+	return map[string]any{"findings": list, "count": len(findings), "by_severity": bySeverity}
+}
+
+// policyPath return the file of a finding relative to the scan root with slashes, the archive of a file in one
+func policyPath(file, root string) string {
+	file, _, _ = strings.Cut(file, ArchiveSep)
+	if root != "" {
+		if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+	}
+	return filepath.ToSlash(file)
+}
+
+// pathMatches tell if the path or one of its directories matches one of the rules
+func pathMatches(rules []ignoreRule, rel string) bool {
+	parts := strings.Split(rel, "/")
+	for idx := len(parts); idx > 0; idx-- {
+		sub := strings.Join(parts[:idx], "/")
+		for _, rule := range rules {
+			if (!rule.dirOnly || idx < len(parts)) && rule.re.MatchString(sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package scanner
+
+import (
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	p, err := ParsePolicy([]byte(`rules:
+  - name: no verified secret
+    fail_when: findings | selectattr('verified', 'eq', 'verified') | list | length > 0
+  - name: few high outside the tests
+    exclude_paths: [test/]
+    fail_when: by_severity.high > 1
+  - name: no secret in deploy
+    paths: [/deploy/]
+    fail_when: count > 0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := []OutputFmt{
+		{File: "/repo/app/a.conf", Line_no: []int{1}, Severity: SeverityHigh},
+		{File: "/repo/test/b.conf", Line_no: []int{2}, Severity: SeverityHigh},
+		{File: "/repo/test/c.conf", Line_no: []int{3}, Severity: SeverityHigh},
+	}
+	results, failed := p.Evaluate(findings, "/repo")
+	if failed || len(results) != 3 || results[1].Findings != 1 || results[2].Findings != 0 {
+		t.Errorf("expect the policy to pass, got %+v", results)
+	}
+	findings = append(findings, OutputFmt{File: "/repo/deploy/prod/d.conf", Line_no: []int{4}, Severity: SeverityLow, Verified: StatusVerified})
+	results, failed = p.Evaluate(findings, "/repo")
+	want := []bool{true, false, true}
+	for idx, res := range results {
+		if res.Failed != want[idx] || res.Error != "" {
+			t.Errorf("%s: expect failed %v, got %+v", res.Name, want[idx], res)
+		}
+	}
+	if !failed {
+		t.Error("expect the policy to fail")
+	}
+	for _, bad := range []string{"rules: []", "rules:\n  - name: x\n", "rules:\n  - fail_when: count > 0\n", "rules:\n  - name: x\n    fail_when: count >\n"} {
+		if _, err := ParsePolicy([]byte(bad)); err == nil {
+			t.Errorf("expect an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package scanner
+
+import (
+	"regexp/syntax"
+	"slices"
+	"strings"
+)
+
+// maxKeywords bound the keywords derived from a pattern, past it the pattern always runs
+const maxKeywords = 64
+
+// minKeywordLength is the length of the shortest keyword worth filtering on, a shorter one is in most lines
+const minKeywordLength = 3
+
+// keywordFilter is the Aho-Corasick automaton of the keywords of the patterns: one pass over a line, case insensitive,
+// tells which patterns may match it so the regexes of the others are not run. The keywords of a detector are its
+// Keywords, else they are derived from its regex, see patternKeywords; a pattern without keywords always runs.
+type keywordFilter struct {
+	patterns []string   // the patterns in a stable order, the hits of match are by index
+	always   []bool     // the patterns without keywords
+	class    [256]byte  // the class of each byte, 0 for the bytes of no keyword; a letter and its upper case share one
+	classes  int        // number of classes
+	delta    []int32    // the next state of each state and class
+	out      [][]uint16 // the patterns having a keyword ending at each state
+}
+
+// newKeywordFilter build the filter of the patterns, keywords are the keywords of some of them, the others are derived
+func newKeywordFilter(patterns []string, keywords map[string][]string) *keywordFilter {
+	f := &keywordFilter{patterns: slices.Clone(patterns), always: make([]bool, len(patterns)), classes: 1}
+	slices.Sort(f.patterns)
+	type node struct {
+		next map[byte]int32
+		out  []uint16
+	}
+	trie := []node{{next: map[byte]int32{}}}
+	for idx, ptn := range f.patterns {
+		words, ok := keywords[ptn]
+		if !ok {
+			words = patternKeywords(ptn)
+		}
+		if len(words) == 0 {
+			f.always[idx] = true
+			continue
+		}
+		for _, w := range words {
+			state := int32(0)
+			for _, b := range []byte(strings.ToLower(w)) {
+				if f.class[b] == 0 {
+					f.class[b] = byte(f.classes)
+					if 'a' <= b && b <= 'z' {
+						f.class[b-'a'+'A'] = byte(f.classes)
+					}
+					f.classes++
+				}
+				next, ok := trie[state].next[b]
+				if !ok {
+					next = int32(len(trie))
+					trie = append(trie, node{next: map[byte]int32{}})
+					trie[state].next[b] = next
+				}
+				state = next
+			}
+			if !slices.Contains(trie[state].out, uint16(idx)) {
+				trie[state].out = append(trie[state].out, uint16(idx))
+			}
+		}
+	}
+	// the transitions of a state without the byte are the ones of its failure state, breadth first
+	f.delta = make([]int32, len(trie)*f.classes)
+	f.out = make([][]uint16, len(trie))
+	fail := make([]int32, len(trie))
+	queue := []int32{0}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		row := f.delta[int(state)*f.classes : int(state+1)*f.classes]
+		if state != 0 {
+			f.out[state] = append(slices.Clone(trie[state].out), f.out[fail[state]]...)
+			copy(row, f.delta[int(fail[state])*f.classes:int(fail[state]+1)*f.classes])
+		}
+		for b, next := range trie[state].next {
+			if state != 0 {
+				fail[next] = f.delta[int(fail[state])*f.classes+int(f.class[b])]
+			}
+			row[f.class[b]] = next
+			queue = append(queue, next)
+		}
+	}
+	return f
+}
+
+// match return the patterns to run on the line, by index in patterns
+func (f *keywordFilter) match(line string) []bool {
+	hits := slices.Clone(f.always)
+	state := int32(0)
+	for idx := 0; idx < len(line); idx++ {
+		state = f.delta[int(state)*f.classes+int(f.class[line[idx]])]
+		for _, p := range f.out[state] {
+			hits[p] = true
+		}
+	}
+	return hits
+}
+
+// patternKeywords return strings one of which is in every match of the pattern, lower case, or nil if there are none
+// long enough to filter the lines. For (?i)(password|token)\s*= they are password and token.
+func patternKeywords(ptn string) []string {
+	re, err := syntax.Parse(ptn, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	words := requiredWords(re.Simplify())
+	for _, w := range words {
+		if len(w) < minKeywordLength {
+			return nil
+		}
+	}
+	return words
+}
+
+// requiredWords return strings one of which is in every match of re, nil if not known
+func requiredWords(re *syntax.Regexp) []string {
+	if words, ok := exactWords(re); ok {
+		if slices.Contains(words, "") {
+			return nil
+		}
+		return words
+	}
+	switch re.Op {
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredWords(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredWords(re.Sub[0])
+		}
+	case syntax.OpAlternate:
+		words := []string{}
+		for _, sub := range re.Sub {
+			w := requiredWords(sub)
+			if w == nil {
+				return nil
+			}
+			words = appendNew(words, w...)
+		}
+		if len(words) > maxKeywords {
+			return nil
+		}
+		return words
+	case syntax.OpConcat:
+		// each run of exact parts is a candidate, the words of the run are contiguous in the match, and so are the
+		// required words of each other part; the candidate with the longest shortest word is kept. An optional part,
+		// eg a quote, ends the run rather than doubling its words.
+		var best []string
+		consider := func(words []string) {
+			if len(words) == 0 || slices.Contains(words, "") {
+				return
+			}
+			if best == nil || shortest(words) > shortest(best) || (shortest(words) == shortest(best) && len(words) < len(best)) {
+				best = words
+			}
+		}
+		run := []string{""}
+		for _, sub := range re.Sub {
+			if words, ok := exactWords(sub); ok && (len(words) == 1 || !slices.Contains(words, "")) {
+				if product := productWords(run, words); product != nil {
+					run = product
+					continue
+				}
+				consider(run)
+				run = words
+				continue
+			}
+			consider(run)
+			run = []string{""}
+			consider(requiredWords(sub))
+		}
+		consider(run)
+		return best
+	}
+	return nil
+}
+
+// exactWords return all the strings re matches, lower case, if there are a few of ascii
+func exactWords(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r >= 0x80 {
+				return nil, false
+			}
+		}
+		return []string{strings.ToLower(string(re.Rune))}, true
+	case syntax.OpCharClass:
+		words := []string{}
+		for idx := 0; idx+1 < len(re.Rune); idx += 2 {
+			if re.Rune[idx+1] >= 0x80 || int(re.Rune[idx+1]-re.Rune[idx]) >= maxKeywords {
+				return nil, false
+			}
+			for r := re.Rune[idx]; r <= re.Rune[idx+1]; r++ {
+				words = appendNew(words, strings.ToLower(string(r)))
+			}
+		}
+		return words, len(words) > 0 && len(words) <= 10
+	case syntax.OpCapture:
+		return exactWords(re.Sub[0])
+	case syntax.OpQuest:
+		words, ok := exactWords(re.Sub[0])
+		return appendNew(words, ""), ok && len(words) < maxKeywords
+	case syntax.OpAlternate:
+		words := []string{}
+		for _, sub := range re.Sub {
+			w, ok := exactWords(sub)
+			if !ok {
+				return nil, false
+			}
+			words = appendNew(words, w...)
+		}
+		return words, len(words) <= maxKeywords
+	case syntax.OpConcat:
+		words := []string{""}
+		for _, sub := range re.Sub {
+			w, ok := exactWords(sub)
+			if !ok {
+				return nil, false
+			}
+			if words = productWords(words, w); words == nil {
+				return nil, false
+			}
+		}
+		return words, true
+	}
+	return nil, false
+}
+
+// productWords return each word of a followed by each word of b, nil if there are more than maxKeywords
+func productWords(a, b []string) []string {
+	if len(a)*len(b) > maxKeywords {
+		return nil
+	}
+	words := make([]string, 0, len(a)*len(b))
+	for _, x := range a {
+		for _, y := range b {
+			words = appendNew(words, x+y)
+		}
+	}
+	return words
+}
+
+func appendNew(words []string, more ...string) []string {
+	for _, w := range more {
+		if !slices.Contains(words, w) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+func shortest(words []string) int {
+	n := len(words[0])
+	for _, w := range words[1:] {
+		n = min(n, len(w))
+	}
+	return n
+}
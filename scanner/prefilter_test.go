@@ -0,0 +1,35 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeywordFilter(t *testing.T) {
+	if words := patternKeywords(CredentialPatterns[0]); !reflect.DeepEqual(words, []string{"password", "passwd", "token", "api_key", "secret"}) {
+		t.Errorf("unexpected keywords of the default pattern %v", words)
+	}
+	if words := patternKeywords(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`); !reflect.DeepEqual(words, []string{"akia", "asia"}) {
+		t.Errorf("unexpected keywords of the aws pattern %v", words)
+	}
+	if words := patternKeywords(`[a-z]{32}`); words != nil {
+		t.Errorf("expect no keywords for a pattern without literal, got %v", words)
+	}
+	// overlapping keywords are all found, whatever their case
+	f := newKeywordFilter([]string{"he", "she", "hers", "any"}, map[string][]string{"he": {"he"}, "she": {"she"}, "hers": {"hers"}, "any": nil})
+	for line, want := range map[string][]string{
+		"uSHErs": {"any", "he", "hers", "she"},
+		"ahe":    {"any", "he"},
+		"s h e":  {"any"},
+	} {
+		got := []string{}
+		for idx, run := range f.match(line) {
+			if run {
+				got = append(got, f.patterns[idx])
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: expect the patterns %v, got %v", line, want, got)
+		}
+	}
+}
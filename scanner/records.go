@@ -0,0 +1,161 @@
+package scanner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Record is one line of a finding, a row of the csv and jsonl formats. The values are not in it, only the names.
+type Record struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"` // 1 based
+	RuleID      string `json:"rule_id"`
+	Severity    string `json:"severity,omitempty"`
+	Confidence  string `json:"confidence,omitempty"`
+	Names       string `json:"names"` // token names, comma separated
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Commit      string `json:"commit,omitempty"` // git-history only
+	Author      string `json:"author,omitempty"`
+	Date        string `json:"date,omitempty"`
+	Owners      string `json:"owners,omitempty"` // the owners of the file, space separated, see CodeOwners
+	ID          string `json:"id,omitempty"`     // see FindingID
+}
+
+// RecordHeader is the header of the csv format
+var RecordHeader = []string{"file", "line", "rule_id", "severity", "confidence", "names", "fingerprint", "commit", "author", "date", "owners", "id"}
+
+func (r Record) csvRow() []string {
+	return []string{r.File, strconv.Itoa(r.Line), r.RuleID, r.Severity, r.Confidence, r.Names, r.Fingerprint, r.Commit, r.Author, r.Date, r.Owners, r.ID}
+}
+
+// NewRecords return the records of the lines of a finding
+func NewRecords(o OutputFmt) []Record {
+	return newRecords(o, o.Line_no, o.Matches)
+}
+
+func newRecords(o OutputFmt, lines []int, pairs []string) []Record {
+	names := []string{}
+	for idx := 0; idx < len(pairs); idx += 2 {
+		if !slices.Contains(names, pairs[idx]) {
+			names = append(names, pairs[idx])
+		}
+	}
+	o.RuleID = firstNonEmpty(o.RuleID, PatternRuleID(o.Pattern))
+	records := make([]Record, 0, len(lines))
+	for _, line := range lines {
+		records = append(records, Record{File: o.File, Line: line + 1, RuleID: o.RuleID, Severity: o.Severity, Confidence: o.Confidence,
+			Names: strings.Join(names, ","), Fingerprint: o.Fingerprint, Owners: strings.Join(o.Owners, " "), ID: o.ID})
+	}
+	return records
+}
+
+// RecordsOf return the records of all findings, sorted by file and line
+func RecordsOf(output ProjectOutputFmt) []Record {
+	records := []Record{}
+	for _, matches := range output {
+		for _, o := range matches {
+			records = append(records, NewRecords(o)...)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].File != records[j].File {
+			return records[i].File < records[j].File
+		}
+		return records[i].Line < records[j].Line
+	})
+	return records
+}
+
+// GitRecords return the records of the git history findings
+func GitRecords(findings []GitFinding) []Record {
+	records := []Record{}
+	for _, f := range findings {
+		for _, r := range NewRecords(f.OutputFmt) {
+			r.Commit, r.Author, r.Date = f.Commit, f.Author, f.Date
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// RecordStream turn the findings sent by Scan into records without collecting them. Scan sends a finding again each
+// time a line is added to it; the stream only returns the records of the new lines.
+type RecordStream struct {
+	sent map[string][2]int // file + pattern => number of lines and matches already returned
+}
+
+func NewRecordStream() *RecordStream {
+	return &RecordStream{sent: map[string][2]int{}}
+}
+
+// Next return the records of the lines of the finding not returned yet
+func (rs *RecordStream) Next(o OutputFmt) []Record {
+	if o.File == "" || len(o.Matches) < 2 {
+		return nil
+	}
+	key := o.File + "\x00" + o.Pattern
+	sent := rs.sent[key]
+	if sent[0] >= len(o.Line_no) {
+		return nil
+	}
+	rs.sent[key] = [2]int{len(o.Line_no), len(o.Matches)}
+	return newRecords(o, o.Line_no[sent[0]:], o.Matches[min(sent[1], len(o.Matches)):])
+}
+
+// RecordWriter write records in a line oriented format
+type RecordWriter interface {
+	Write(r Record) error
+	Flush() error
+}
+
+// NewRecordWriter return a writer of the format csv (with a header) or jsonl (one json object per line)
+func NewRecordWriter(w io.Writer, format string) (RecordWriter, error) {
+	switch format {
+	case "csv":
+		return &csvRecordWriter{w: csv.NewWriter(w)}, nil
+	case "jsonl":
+		je := json.NewEncoder(w)
+		je.SetEscapeHTML(false)
+		return &jsonlRecordWriter{je: je}, nil
+	}
+	return nil, fmt.Errorf("unknown record format %q, expect csv or jsonl", format)
+}
+
+type csvRecordWriter struct {
+	w          *csv.Writer
+	headerDone bool
+}
+
+func (c *csvRecordWriter) Write(r Record) error {
+	if !c.headerDone {
+		c.headerDone = true
+		if err := c.w.Write(RecordHeader); err != nil {
+			return err
+		}
+	}
+	return c.w.Write(r.csvRow())
+}
+
+// Flush write the header if no record was written, so the output is always a valid csv
+func (c *csvRecordWriter) Flush() error {
+	if !c.headerDone {
+		c.headerDone = true
+		c.w.Write(RecordHeader)
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlRecordWriter struct {
+	je *json.Encoder
+}
+
+func (j *jsonlRecordWriter) Write(r Record) error { return j.je.Encode(r) }
+
+func (j *jsonlRecordWriter) Flush() error { return nil }
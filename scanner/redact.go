@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The redact modes of the values, see Config.Redact
+const (
+	RedactMask    = "mask"    // the values are *****
+	RedactPartial = "partial" // the values are PartialValue, their first and last 2 chars
+	RedactHash    = "hash"    // the values are HashValue, their sha256 anyone can compute from a known secret
+	RedactHmac    = "hmac"    // the values are HmacValue, the same secret has the same value across files and runs
+	RedactNone    = "none"    // the values are in plain text, eg to save a profile; never on CI/CD
+)
+
+// RedactModes are the valid Config.Redact, the empty default is RedactMask
+var RedactModes = []string{RedactMask, RedactPartial, RedactHash, RedactHmac, RedactNone}
+
+// HmacPrefix start the values redacted with RedactHmac
+const HmacPrefix = "hmac:"
+
+// HashPrefix start the values redacted with RedactHash
+const HashPrefix = "sha256:"
+
+var (
+	hmacValuePtn = regexp.MustCompile(`^hmac:[0-9a-f]{32}$`)
+	hashValuePtn = regexp.MustCompile(`^sha256:[0-9a-f]{32}$`)
+)
+
+// redactValue return the value hidden as the mode says; a value already redacted, eg a pair of a growing finding,
+// is kept
+func redactValue(mode, key, value string) string {
+	switch mode {
+	case RedactNone:
+		return value
+	case RedactHmac:
+		if hmacValuePtn.MatchString(value) {
+			return value
+		}
+		return HmacValue(key, value)
+	case RedactHash:
+		if hashValuePtn.MatchString(value) {
+			return value
+		}
+		return HashValue(value)
+	case RedactPartial:
+		return PartialValue(value)
+	default:
+		return "*****"
+	}
+}
+
+// PartialValue return the first and last 2 chars of the value around *****, enough to tell which secret leaked. A
+// value shorter than 8 chars is all *****, else half of it would show.
+func PartialValue(value string) string {
+	r := []rune(value)
+	if len(r) < 8 {
+		return "*****"
+	}
+	return string(r[:2]) + "*****" + string(r[len(r)-2:])
+}
+
+// HashValue return sha256: and the first 32 hex chars of the SHA-256 of the value, eg to check a known secret
+// leaked with echo -n <secret> | sha256sum. A short password can be guessed from it, HmacValue can not.
+func HashValue(value string) string {
+	return HashPrefix + fmt.Sprintf("%x", sha256.Sum256([]byte(value)))[:32]
+}
+
+// secretID identify a secret value across the files and rules: its hmac with the hmac redact mode, so it can not be
+// guessed without the key, else its hash like in Fingerprint. The whitespaces are removed.
+func (s *Scanner) secretID(value string) string {
+	value = strings.Join(strings.Fields(value), "")
+	if s.cfg.Redact == RedactHmac {
+		return strings.TrimPrefix(HmacValue(s.cfg.RedactKey, value), HmacPrefix)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte("secret\x00"+value)))[:32]
+}
+
+// HmacValue return the keyed hash of a secret value: hmac: and the first 32 hex chars of its HMAC-SHA256. Without the
+// key the value can not be guessed, even for a short password, and the plain text is never written.
+func HmacValue(key, value string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(value))
+	return HmacPrefix + hex.EncodeToString(mac.Sum(nil))[:32]
+}
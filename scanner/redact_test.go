@@ -0,0 +1,73 @@
+package scanner
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactHmac(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.conf": "password=\"Xk9dLq2ZmP7wR4\"\npassword=\"Qw8rTy5UiO3pAs\"\n",
+		"b.conf": "password=\"Xk9dLq2ZmP7wR4\"\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Redact = RedactHmac
+	if _, err := New(cfg); err == nil {
+		t.Error("expect error for hmac without a key")
+	}
+	cfg.RedactKey = "k1"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := Collect(s.Scan(context.Background(), dir)).List()
+	if len(list) != 2 {
+		t.Fatalf("expect a finding per file, got %v", list)
+	}
+	want := []string{"password", HmacValue("k1", "Xk9dLq2ZmP7wR4"), "password", HmacValue("k1", "Qw8rTy5UiO3pAs")}
+	if !reflect.DeepEqual(list[0].Matches, want) {
+		t.Errorf("expect the values hashed once, got %v", list[0].Matches)
+	}
+	if list[1].Matches[1] != want[1] {
+		t.Errorf("expect the same secret to have the same value, got %v", list[1].Matches)
+	}
+	if HmacValue("k2", "Xk9dLq2ZmP7wR4") == want[1] || !strings.HasPrefix(want[1], HmacPrefix) {
+		t.Errorf("unexpected hmac value %s", want[1])
+	}
+}
+
+func TestMaskModes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.conf": "password=\"Xk9dLq2ZmP7wR4\"\npassword=\"Qw8rTy5UiO3pAs\"\n"})
+	for mode, want := range map[string][]string{
+		"":            {"*****", "*****"},
+		RedactPartial: {"Xk*****R4", "Qw*****As"},
+		RedactHash:    {HashValue("Xk9dLq2ZmP7wR4"), HashValue("Qw8rTy5UiO3pAs")},
+		RedactNone:    {"Xk9dLq2ZmP7wR4", "Qw8rTy5UiO3pAs"},
+	} {
+		cfg := DefaultConfig()
+		cfg.CheckMode = "letter"
+		cfg.Debug = true // the values are still redacted
+		cfg.Redact = mode
+		s, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := Collect(s.Scan(context.Background(), dir)).List()
+		if len(list) != 1 || !reflect.DeepEqual(list[0].Matches, []string{"password", want[0], "password", want[1]}) {
+			t.Errorf("mask mode %q: expect the values %v redacted once, got %v", mode, want, list)
+		}
+	}
+	if PartialValue("short") != "*****" {
+		t.Errorf("expect a short value fully masked, got %s", PartialValue("short"))
+	}
+	cfg := DefaultConfig()
+	cfg.Redact = "clear"
+	if _, err := New(cfg); err == nil {
+		t.Error("expect error for an unknown mask mode")
+	}
+}
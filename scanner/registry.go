@@ -0,0 +1,236 @@
+package scanner
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// manifestMediaTypes are the manifest formats accepted from a registry, the image manifests and the indexes
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ParseImageRef split an image reference into the registry host, the repository and the tag or digest, with the
+// docker defaults: docker.io, library/ and latest.
+func ParseImageRef(ref string) (registry, repo, reference string, err error) {
+	name, digest, hasDigest := strings.Cut(ref, "@")
+	tag := ""
+	if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		name, tag = name[:idx], name[idx+1:]
+	}
+	if name == "" {
+		return "", "", "", fmt.Errorf("invalid image reference %q", ref)
+	}
+	registry, repo = "docker.io", name
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		registry, repo = first, rest
+	}
+	if registry == "docker.io" || registry == "index.docker.io" {
+		registry = "registry-1.docker.io"
+		if !strings.Contains(repo, "/") {
+			repo = "library/" + repo
+		}
+	}
+	switch {
+	case hasDigest:
+		reference = digest
+	case tag != "":
+		reference = tag
+	default:
+		reference = "latest"
+	}
+	return registry, repo, reference, nil
+}
+
+// registryClient get the manifests and blobs of one repository with the registry v2 API
+type registryClient struct {
+	client     *http.Client
+	base       string // eg https://ghcr.io/v2/org/app
+	opt        ImageOpt
+	authHeader string
+}
+
+// get a path of the repository, eg manifests/latest. On 401 it gets a token as asked by the registry and retries.
+func (c *registryClient) get(ctx context.Context, p string, accept ...string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", c.base+"/"+p, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		if c.authHeader != "" {
+			req.Header.Set("Authorization", c.authHeader)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if err := c.authenticate(ctx, challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: %s", req.URL, resp.Status)
+		}
+		return resp, nil
+	}
+}
+
+var challengeParamPtn = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate answer a WWW-Authenticate challenge: basic auth with the credentials, or a bearer token from the
+// realm, anonymous without credentials
+func (c *registryClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.opt.Username == "" {
+			return fmt.Errorf("registry asks for basic auth, no credentials set")
+		}
+		c.authHeader = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.opt.Username+":"+c.opt.Password))
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+	values := url.Values{}
+	realm := ""
+	for _, m := range challengeParamPtn.FindAllStringSubmatch(params, -1) {
+		if m[1] == "realm" {
+			realm = m[2]
+		} else {
+			values.Set(m[1], m[2])
+		}
+	}
+	if realm == "" {
+		return fmt.Errorf("registry auth challenge without realm %q", challenge)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", realm+"?"+values.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if c.opt.Username != "" {
+		req.SetBasicAuth(c.opt.Username, c.opt.Password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry token %s: %s", realm, resp.Status)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxImageMetadata)).Decode(&token); err != nil {
+		return fmt.Errorf("registry token %s: %w", realm, err)
+	}
+	c.authHeader = "Bearer " + firstNonEmpty(token.Token, token.AccessToken)
+	return nil
+}
+
+// getJSON get a manifest or a config blob
+func (c *registryClient) getJSON(ctx context.Context, p string, v any, accept ...string) error {
+	resp, err := c.get(ctx, p, accept...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxImageMetadata)).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	return nil
+}
+
+// pickManifest return the manifest of the platform from an index, os/arch[/variant], default linux and the arch
+// of the host
+func pickManifest(index imageManifest, platform string) (imageDescriptor, error) {
+	if platform == "" {
+		platform = "linux/" + runtime.GOARCH
+	}
+	parts := strings.SplitN(platform, "/", 3)
+	for _, d := range index.Manifests {
+		p := d.Platform
+		if p == nil || p.OS != parts[0] || (len(parts) > 1 && p.Architecture != parts[1]) || (len(parts) > 2 && p.Variant != parts[2]) {
+			continue
+		}
+		return d, nil
+	}
+	if len(index.Manifests) == 1 {
+		return index.Manifests[0], nil
+	}
+	return imageDescriptor{}, fmt.Errorf("no image for platform %s", platform)
+}
+
+// scanRegistryImage pull the manifest and the config of the image from its registry, then stream and scan each
+// layer. Nothing is written to disk.
+func (s *Scanner) scanRegistryImage(ctx context.Context, ref string, opt ImageOpt) ([]ImageFinding, error) {
+	registry, repo, reference, err := ParseImageRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "https"
+	if host, _, _ := strings.Cut(registry, ":"); host == "localhost" || host == "127.0.0.1" {
+		scheme = "http" // like docker, a local registry is plain http
+	}
+	c := &registryClient{client: http.DefaultClient, base: scheme + "://" + registry + "/v2/" + repo, opt: opt}
+	m := imageManifest{}
+	for depth := 0; ; depth++ {
+		if err := c.getJSON(ctx, "manifests/"+reference, &m, manifestMediaTypes...); err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		if len(m.Manifests) == 0 {
+			break
+		}
+		if depth >= 2 {
+			return nil, fmt.Errorf("%s: too many nested indexes", ref)
+		}
+		d, err := pickManifest(m, opt.Platform)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		reference, m = d.Digest, imageManifest{}
+	}
+	config := imageConfig{}
+	if err := c.getJSON(ctx, "blobs/"+m.Config.Digest, &config); err != nil {
+		return nil, fmt.Errorf("%s: config: %w", ref, err)
+	}
+	digests := make([]string, 0, len(m.Layers))
+	for _, l := range m.Layers {
+		digests = append(digests, l.Digest)
+	}
+	findings := []ImageFinding{}
+	for _, l := range imageLayers(digests, config) {
+		resp, err := c.get(ctx, "blobs/"+l.path)
+		if err != nil {
+			return findings, fmt.Errorf("%s: layer: %w", ref, err)
+		}
+		layerFindings, err := s.scanLayer(ctx, l, resp.Body)
+		resp.Body.Close()
+		findings = append(findings, layerFindings...)
+		if err != nil {
+			return findings, err
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a detector loaded from a rule pack file, so the rules of an organization can be shipped and versioned apart
+// from the binary. Like a built-in Detector the value is the capture group SecretGroup, default 1, of Regex if it has
+// one, else the whole match, and it is not filtered by the password heuristic.
+type Rule struct {
+	ID          string   `yaml:"id"`
+	Description string   `yaml:"description"`
+	Regex       string   `yaml:"regex"`
+	SecretGroup int      `yaml:"secret_group,omitempty"`
+	Keywords    []string `yaml:"keywords,omitempty"` // the regex only runs on the lines having one of them, case insensitive
+	Entropy     float64  `yaml:"entropy,omitempty"`  // the minimum entropy of the value, 0 for no check; see Config.RuleEntropy
+	Severity    string   `yaml:"severity,omitempty"` // default medium
+	Confidence  string   `yaml:"confidence,omitempty"`
+	Match       []string `yaml:"match,omitempty"`       // lines the rule must find, see TestRule
+	NoMatch     []string `yaml:"no_match,omitempty"`    // lines the rule must not find
+	Remediation string   `yaml:"remediation,omitempty"` // how to revoke or rotate the secret, default GenericRemediation
+	DocURL      string   `yaml:"doc_url,omitempty"`
+	Source      string   `yaml:"-"` // the file it was loaded from
+}
+
+// RulePack is the format of a rule pack file:
+//
+//	rules:
+//	  - id: acme-api-key
+//	    description: ACME api key
+//	    regex: '\b(acme_[0-9a-f]{32})\b'
+//	    keywords: [acme_]
+//	    entropy: 3.5
+//	    severity: high
+//	    match: ['key = "acme_0123456789abcdef0123456789abcdef"']
+//	    remediation: revoke the key at https://acme.example/settings/keys
+type RulePack struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules read and check the rules of a rule file: a RulePack, or the rules of gitleaks or trufflehog converted to
+// rules, see ruleFormat. The ids of the converted rules are prefixed by gitleaks- or trufflehog-.
+func LoadRules(fpath string) ([]Rule, error) {
+	datab, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	pack := RulePack{}
+	if format := ruleFormat(fpath, datab); format != RuleFormatPack {
+		if pack.Rules, err = importRules(format, datab); err != nil {
+			return nil, fmt.Errorf("invalid %s rules %s - %w", format, fpath, err)
+		}
+	} else if err := yaml.Unmarshal(datab, &pack); err != nil {
+		return nil, fmt.Errorf("invalid rule pack %s - %w", fpath, err)
+	}
+	for idx := range pack.Rules {
+		pack.Rules[idx].Source = fpath
+		if err := pack.Rules[idx].check(); err != nil {
+			return nil, fmt.Errorf("%s: %w", fpath, err)
+		}
+	}
+	return pack.Rules, nil
+}
+
+// check validate the rule and set the default severity and confidence
+func (r *Rule) check() error {
+	if r.ID == "" {
+		return fmt.Errorf("rule without id, regex '%s'", r.Regex)
+	}
+	if r.Regex == "" {
+		return fmt.Errorf("rule '%s' has no regex", r.ID)
+	}
+	re, err := regexp.Compile(r.Regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex of rule '%s' - %w", r.ID, err)
+	}
+	if r.SecretGroup < 0 || r.SecretGroup > re.NumSubexp() {
+		return fmt.Errorf("rule '%s' has no secret group %d", r.ID, r.SecretGroup)
+	}
+	if r.Severity == "" {
+		r.Severity = SeverityMedium
+	}
+	if r.Confidence == "" {
+		r.Confidence = ConfidenceMedium
+	}
+	for _, level := range []string{r.Severity, r.Confidence} {
+		if level != "high" && level != "medium" && level != "low" {
+			return fmt.Errorf("invalid level '%s' of rule '%s', expect high, medium or low", level, r.ID)
+		}
+	}
+	return nil
+}
+
+// Detector return the rule as a detector
+func (r Rule) Detector() Detector {
+	keywords := make([]string, 0, len(r.Keywords))
+	for _, k := range r.Keywords {
+		keywords = append(keywords, strings.ToLower(k))
+	}
+	return Detector{ID: r.ID, Name: firstNonEmpty(r.Description, r.ID), Pattern: r.Regex, Severity: r.Severity, Confidence: r.Confidence, Keywords: keywords, SecretGroup: r.SecretGroup,
+		Remediation: r.Remediation, DocURL: r.DocURL}
+}
+
+// TestRule scan the Match and NoMatch lines of the rule with the rule alone and return a message per line where it
+// does not do what is expected
+func TestRule(r Rule) ([]string, error) {
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	s, err := New(Config{Rules: []Rule{r}, CheckMode: "letter"})
+	if err != nil {
+		return nil, err
+	}
+	failures := []string{}
+	for _, lines := range []struct {
+		want  bool
+		lines []string
+	}{{true, r.Match}, {false, r.NoMatch}} {
+		for _, line := range lines.lines {
+			found := len(Collect(s.ScanReader(context.Background(), "rule-test", strings.NewReader(line)))) > 0
+			switch {
+			case lines.want && !found:
+				failures = append(failures, fmt.Sprintf("rule '%s' does not match %q", r.ID, line))
+			case !lines.want && found:
+				failures = append(failures, fmt.Sprintf("rule '%s' matches %q", r.ID, line))
+			}
+		}
+	}
+	return failures, nil
+}
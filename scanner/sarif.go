@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SARIF 2.1.0 log, only the parts GitHub code scanning and Azure DevOps use.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type SarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SarifRun `json:"runs"`
+}
+
+type SarifRun struct {
+	Tool    SarifTool     `json:"tool"`
+	Results []SarifResult `json:"results"`
+}
+
+type SarifTool struct {
+	Driver SarifDriver `json:"driver"`
+}
+
+type SarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []SarifRule `json:"rules"`
+}
+
+type SarifRule struct {
+	ID               string        `json:"id"`
+	Name             string        `json:"name,omitempty"`
+	ShortDescription SarifMessage  `json:"shortDescription"`
+	FullDescription  SarifMessage  `json:"fullDescription"`
+	Help             *SarifMessage `json:"help,omitempty"` // the remediation
+	HelpURI          string        `json:"helpUri,omitempty"`
+}
+
+type SarifMessage struct {
+	Text string `json:"text"`
+}
+
+type SarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   SarifMessage    `json:"message"`
+	Locations []SarifLocation `json:"locations"`
+}
+
+type SarifLocation struct {
+	PhysicalLocation SarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type SarifPhysicalLocation struct {
+	ArtifactLocation SarifArtifactLocation `json:"artifactLocation"`
+	Region           SarifRegion           `json:"region"`
+}
+
+type SarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type SarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// PatternRuleID return the rule id of a credential pattern, stable across runs as long as the pattern is the same
+func PatternRuleID(pattern string) string {
+	return fmt.Sprintf("cred-detect/%x", sha256.Sum256([]byte(pattern)))[:20]
+}
+
+// ToSarif convert the scan output to a SARIF log. The file paths are made relative to root so the results map to
+// the files of the repository; root is the %SRCROOT% base. Each line of a finding is one result.
+func ToSarif(output ProjectOutputFmt, root, toolVersion string) SarifLog {
+	rules := map[string]SarifRule{}
+	results := []SarifResult{}
+	files := make([]string, 0, len(output))
+	for file := range output {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		uri := file
+		if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			uri = rel
+		}
+		uri = filepath.ToSlash(uri)
+		sigs := make([]string, 0, len(output[file]))
+		for sig := range output[file] {
+			sigs = append(sigs, sig)
+		}
+		sort.Strings(sigs)
+		for _, sig := range sigs {
+			o := output[file][sig]
+			ruleID := firstNonEmpty(o.RuleID, PatternRuleID(o.Pattern))
+			if _, ok := rules[ruleID]; !ok {
+				rule := SarifRule{
+					ID:               ruleID,
+					Name:             "HardcodedCredential",
+					ShortDescription: SarifMessage{Text: "Possible hardcoded credential"},
+					FullDescription:  SarifMessage{Text: "Value matching the credential pattern " + o.Pattern},
+					HelpURI:          o.DocURL,
+				}
+				if o.Remediation != "" {
+					rule.Help = &SarifMessage{Text: o.Remediation}
+				}
+				if d, ok := detectorByID(ruleID); ok {
+					rule.ShortDescription.Text = d.Name
+					rule.FullDescription.Text = d.Name + " matching " + d.Pattern
+				} else if ruleID == StructuredRuleID {
+					rule.FullDescription.Text = "Value of a key matching " + SuspiciousKeyPattern.String() + " in a yaml, json, toml or .env file"
+				}
+				rules[ruleID] = rule
+			}
+			level := sarifLevel(o)
+			names := []string{}
+			for idx := 0; idx < len(o.Matches); idx += 2 {
+				if !slices.Contains(names, o.Matches[idx]) {
+					names = append(names, o.Matches[idx])
+				}
+			}
+			msg := SarifMessage{Text: fmt.Sprintf("Possible credential in '%s'", strings.Join(names, "', '"))}
+			for _, line := range o.Line_no {
+				region := SarifRegion{StartLine: line + 1} // Line_no is 0 based
+				if o.End_line > line {
+					region.EndLine = o.End_line + 1
+				}
+				results = append(results, SarifResult{
+					RuleID:  ruleID,
+					Level:   level,
+					Message: msg,
+					Locations: []SarifLocation{{PhysicalLocation: SarifPhysicalLocation{
+						ArtifactLocation: SarifArtifactLocation{URI: uri, URIBaseID: "%SRCROOT%"},
+						Region:           region,
+					}}},
+				})
+			}
+		}
+	}
+	ruleList := make([]SarifRule, 0, len(rules))
+	for _, r := range rules {
+		ruleList = append(ruleList, r)
+	}
+	sort.Slice(ruleList, func(i, j int) bool { return ruleList[i].ID < ruleList[j].ID })
+	return SarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []SarifRun{{
+			Tool:    SarifTool{Driver: SarifDriver{Name: "cred-detect", Version: toolVersion, InformationURI: "https://github.com/sunshine69/automation-go", Rules: ruleList}},
+			Results: results,
+		}},
+	}
+}
+
+// sarifLevel map the severity to the SARIF level, a low confidence lowers error to warning; a verified live token is
+// always an error
+func sarifLevel(o OutputFmt) string {
+	switch {
+	case o.Verified == StatusVerified:
+		return "error"
+	case o.Severity == SeverityLow:
+		return "note"
+	case o.Severity == SeverityMedium || o.Confidence == ConfidenceLow:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
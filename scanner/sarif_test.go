@@ -0,0 +1,26 @@
+package scanner
+
+import (
+	"testing"
+)
+
+func TestToSarif(t *testing.T) {
+	output := ProjectOutputFmt{
+		"/src/app/a.txt": {"password*****": {File: "/src/app/a.txt", Line_no: []int{0, 4}, Pattern: CredentialPatterns[0], Matches: []string{"password", "*****"}}},
+	}
+	log := ToSarif(output, "/src", "1.0")
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Tool.Driver.Rules) != 1 {
+		t.Fatalf("unexpected sarif log %+v", log)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("expect one result per line, got %+v", results)
+	}
+	loc := results[1].Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "app/a.txt" || loc.Region.StartLine != 5 {
+		t.Errorf("unexpected location %+v", loc)
+	}
+	if results[0].RuleID != log.Runs[0].Tool.Driver.Rules[0].ID {
+		t.Errorf("result rule %s not in the rules", results[0].RuleID)
+	}
+}
@@ -0,0 +1,1332 @@
+// Package scanner is the credential detection engine of cred-detect. It walks a directory tree, matches the
+// credential patterns line by line, filters the matches with the password heuristic and skips the findings
+// already accepted in a profile (baseline) from a previous run.
+//
+//	s, err := scanner.New(scanner.DefaultConfig())
+//	findings := s.Scan(ctx, ".")
+//	output := scanner.Collect(findings)
+//	if err := s.Err(); err != nil { ... }
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ag "github.com/sunshine69/automation-go/lib"
+	u "github.com/sunshine69/golang-tools/utils"
+)
+
+var (
+	// Default credential patterns. Capture group 1 is the token name and group 2 the value
+	CredentialPatterns = []string{
+		`(?i)['"]?(password|passwd|token|api_key|secret)['"]?[=:\s][\s]*?['"]?([^'"\s]+)['"]?`,
+	}
+	// Default exclude pattern for file and directory names
+	DefaultExclude = `^(\.git|.*\.zip|.*\.gz|.*\.xz|.*\.bz2|.*\.zstd|.*\.7z|.*\.dll|.*\.iso|.*\.bin|.*\.tar|.*\.exe)$`
+	// DefaultExclude without the executables and the binary blobs, for ScanBinaries
+	DefaultBinaryExclude = `^(\.git|.*\.zip|.*\.gz|.*\.xz|.*\.bz2|.*\.zstd|.*\.7z|.*\.iso|.*\.tar)$`
+)
+
+// Output format of each line. A file may have many lines; each line may have more than 1 creds pair matches
+type OutputFmt struct {
+	File       string
+	Line_no    []int
+	Pattern    string
+	Matches    []string
+	RuleID     string `json:",omitempty"` // the detector id, or derived from the pattern for the generic ones
+	Confidence string `json:",omitempty"` // high, medium or low; how likely it is a real secret
+	Severity   string `json:",omitempty"` // high, medium or low; the impact if it is a real secret
+	End_line   int    `json:",omitempty"` // last line of a multi-line finding, Line_no is the first
+	// hash of the file, rule, secret and line content; unlike the line numbers it does not change when lines move.
+	// See Fingerprint.
+	Fingerprint string      `json:",omitempty"`
+	SecretID    string      `json:",omitempty"` // identify the first secret of the finding across the files, see secretID and GroupSecrets
+	Verified    string      `json:",omitempty"` // with Verify, verified, invalid or unknown; empty if the rule has no Verifier
+	Blame       []LineBlame `json:",omitempty"` // the commit of each line, see Scanner.Blame
+	Remediation string      `json:",omitempty"` // how to revoke or rotate the secret, see Detector
+	DocURL      string      `json:",omitempty"`
+	FirstSeen   string      `json:",omitempty"` // in a profile, when the finding was accepted, RFC 3339; see TouchProfile
+	LastSeen    string      `json:",omitempty"` // in a profile, the last scan that found it, RFC 3339; see StaleFindings
+	Offset      int64       `json:",omitempty"` // with TailBytes, the byte offset of the line 0 in the file, only its tail was scanned
+	Owners      []string    `json:",omitempty"` // the owners of the file in the CODEOWNERS of the scanned repository, see CodeOwners
+	ID          string      `json:",omitempty"` // stable id of the finding in the reports and the history, see FindingID
+}
+
+// Fingerprint identify a finding across runs: the hash of the file path, the rule id, the secret and the line it is
+// in. The whitespaces are removed from the secret and the line, and the secret from the line, so reformatting or
+// moving the line keeps the fingerprint.
+func Fingerprint(file, ruleID, secret, line string) string {
+	secret = strings.Join(strings.Fields(secret), "")
+	line = strings.Join(strings.Fields(line), "")
+	if secret != "" {
+		line = strings.ReplaceAll(line, secret, "")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(file+"\x00"+ruleID+"\x00"+secret+"\x00"+line)))[:32]
+}
+
+// FindingID is the id of a finding, like the ids of a vulnerability database: CRED- and the start of its Fingerprint,
+// eg. CRED-7f06-3109-d64c. It is the same in every run and report while the fingerprint is; empty without one.
+func FindingID(fingerprint string) string {
+	if len(fingerprint) < 12 {
+		return ""
+	}
+	return "CRED-" + fingerprint[:4] + "-" + fingerprint[4:8] + "-" + fingerprint[8:12]
+}
+
+// The output format of the program
+// map of filename => map of TokenName+TokenValue => OutputFmt
+// Design like this so we can lookup by file name and line number quickly using hash map (O1 lookup) to compare between runs
+type ProjectOutputFmt map[string]map[string]OutputFmt
+
+// LoadProfile to load a existing previous run output into map and used it to compare this run against.
+func LoadProfile(filename string) (output ProjectOutputFmt, err error) {
+	datab, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(datab, &output)
+	return output, err
+}
+
+// Config of a scan. Empty Exclude, DefaultExclude and PathExclude mean no exclusion.
+type Config struct {
+	Patterns         []string // credential regex patterns
+	Detectors        []string // ids of the built-in detectors to run, see BuiltinDetectors
+	FilenamePattern  string   // only scan file names matching this
+	Exclude          string   // exclude file and directory names
+	DefaultExclude   string   // exclude file and directory names; meant for the archives and binaries
+	PathExclude      string   // exclude full paths
+	ProfilePath      string   // profile of a previous run; its findings are not reported again
+	SkipBinary       bool
+	ScanBinaries     string   // BinaryStrings: match the printable strings of the binary files instead of skipping them or reading them as text
+	MaxBinarySize    int64    // skip the binaries larger than this many bytes with ScanBinaries, 0 means DefaultMaxBinarySize
+	CheckMode        string   // password check mode, see lib.IsLikelyPasswordOrToken
+	WordsFile        string   // words file used by check modes having 'word'
+	EntropyThreshold float64  // 0 means the lib default
+	Debug            bool     // log every match, with its value; the findings are still redacted, see Redact
+	Concurrency      int      // number of files processed at the same time, 0 means the number of CPUs
+	MaxFileSize      int64    // skip the files larger than this many bytes, 0 means no limit
+	TailBytes        int64    // only scan the last lines, up to this many bytes, of the larger files, even past MaxFileSize; 0 disables it
+	MaxLineLength    int      // the bytes of a line past this are ignored, 0 means DefaultMaxLineLength
+	MmapThreshold    int64    // the files at least this large are memory mapped instead of read, 0 means DefaultMmapThreshold, negative disables it
+	ProfileRules     bool     // time each pattern and file, see Timings; it slows the scan a little
+	CachePath        string   // cache of the findings per file, only the changed files are scanned again; empty disables it
+	CheckpointPath   string   // progress of the scan, saved every CheckpointInterval and removed at the end; an interrupted scan of the same root resumes from it
+	ScanArchives     bool     // scan the files in the zip, jar, tar and gz archives, see ArchiveSep; else they are plain files
+	NoIgnoreFiles    bool     // do not read the .credignore files, see IgnoreFileName
+	NoLocalConfig    bool     // do not read the config files of the sub directories, see LocalConfig
+	NoCodeOwners     bool     // do not set the Owners of the findings, see CodeOwners
+	CodeOwnersPath   string   // the CODEOWNERS file of the Owners of the findings, empty looks for it in the scanned directory, see CodeOwnersFiles
+	Kubernetes       bool     // report the values of the kubernetes Secrets and render the helm charts, see matchKubernetes
+	Structured       bool     // also parse the yaml, json, toml and .env files and check the values of the suspicious keys
+	SourceAware      bool     // in the go, python and js source files only scan the string literals, see sourceLexer
+	Verify           bool     // ask the provider apis if the tokens found are live, see Verifiers
+	DecodeDepth      int      // decode the base64 and hex blobs of the lines and scan the result, nested to this depth; 0 disables it
+	Rules            []Rule   `json:",omitempty"` // the detectors of the rule packs, see LoadRules
+	Placeholders     []string `json:",omitempty"` // extra regexes of the placeholder values, not reported; see PlaceholderPatterns
+	IncludeGlobs     []string `json:",omitempty"` // only scan the files matching one of these globs, see isIncluded
+	FollowSymlinks   bool     // walk the symlinked files and directories; the targets already scanned are skipped, see followSymlink
+	Redact           string   // how the values are hidden: mask (default), partial, hash, hmac or none, see RedactModes and RedactKey
+	RedactKey        string   `json:"-"` // the key of the hmac of the values; not in Hash, the cache key has its own hash
+	OwnFiles         []string `json:"-"` // the files written while scanning, eg the log file, they are not scanned
+	// entropy threshold per rule id, overriding EntropyThreshold for a generic pattern; a detector of a structured
+	// token has no entropy check unless set here
+	RuleEntropy map[string]float64 `json:",omitempty"`
+	// the ansible vault password files, or label@file like ansible --vault-id: the encrypted files and the inline
+	// !vault values are scanned decrypted, else they are skipped, see Stats.VaultEncrypted
+	VaultPasswordFiles []string `json:",omitempty"`
+}
+
+// DefaultMaxLineLength is the MaxLineLength used when not set
+const DefaultMaxLineLength = 1024 * 1024
+
+// DefaultMmapThreshold is the MmapThreshold used when not set
+const DefaultMmapThreshold = 16 * 1024 * 1024
+
+// DefaultConfig return the config cred-detect uses when no option is given
+func DefaultConfig() Config {
+	return Config{
+		Patterns:        append([]string{}, CredentialPatterns...),
+		Detectors:       DetectorIDs(),
+		FilenamePattern: ".*",
+		DefaultExclude:  DefaultExclude,
+		SkipBinary:      true,
+		CheckMode:       "letter+word",
+	}
+}
+
+// Stats of a scan
+type Stats struct {
+	FilesScanned       int64            // files seen by the walker
+	FilesProcessed     int64            // files read and matched against the patterns
+	FilesCached        int64            // files unchanged since the last scan, their findings come from the cache
+	FilesSkipped       map[string]int64 // files seen but not matched, by reason, see SkipBinary etc.
+	BytesProcessed     int64            // bytes read from the files processed
+	DurationSeconds    float64
+	Findings           int            // set by CountFindings, the scanner does not know the findings kept
+	FindingsBySeverity map[string]int // set by CountFindings
+	VaultEncrypted     []string       `json:",omitempty"` // the ansible vault encrypted files and the files with inline !vault values, see Config.VaultPasswordFiles
+}
+
+// Scanner detect credentials in files. Configure it once, it can then run many scans but not concurrently.
+type Scanner struct {
+	cfg               Config
+	patterns          map[string]*regexp.Regexp
+	detectors         map[string]Detector // by pattern
+	entropy           map[string]float64  // RuleEntropy by pattern
+	placeholders      []*regexp.Regexp    // see isPlaceholder
+	blockDetectors    []*compiledBlockDetector
+	filenamePtn       *regexp.Regexp
+	excludePtn        *regexp.Regexp
+	defaultExcludePtn *regexp.Regexp
+	pathExcludePtn    *regexp.Regexp
+	profile           ProjectOutputFmt
+	prefilter         *keywordFilter    // the patterns worth running on a line
+	profileFps        map[string]string // the key of the profile findings by fingerprint, see profileKey
+	profileSeen       map[string]bool   // the keys of the profile findings matched by the scan, guarded by mu
+	timings           *timings          // the times of the scan with ProfileRules, else nil
+	logger            *slog.Logger
+	filesScanned      atomic.Int64
+	filesProcessed    atomic.Int64
+	filesCached       atomic.Int64
+	filesPending      atomic.Int64 // the files walked and not matched yet, see Progress
+	bytesProcessed    atomic.Int64
+	skipped           map[string]int64 // files not matched by reason, guarded by mu
+	started, finished time.Time        // guarded by mu
+	cache             *Cache
+	checkpoint        *Cache                // the files done by an interrupted scan of the root, see CheckpointPath
+	ignore            *credIgnore           // the .credignore files of the scan, nil with NoIgnoreFiles
+	owners            *CodeOwners           // the CODEOWNERS of the scan, nil if none
+	charts            map[string]*helmChart // the helm charts of the scan by directory, guarded by mu
+	vaultPasswords    []ag.VaultSecret      // see Config.VaultPasswordFiles
+	vaults            map[string]bool       // the files of Stats.VaultEncrypted, guarded by mu
+	err               error
+	mu                sync.Mutex
+	suppressed        []OutputFmt
+	verified          sync.Map // the status of the values verified, by rule id, value and related value
+}
+
+// New create a scanner with the config
+func New(cfg Config) (*Scanner, error) {
+	s := &Scanner{}
+	if err := s.Configure(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// compileOptional compile the pattern, return nil if it is empty
+func compileOptional(ptn, name string) (*regexp.Regexp, error) {
+	if ptn == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(ptn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s pattern '%s' - %w", name, ptn, err)
+	}
+	return re, nil
+}
+
+// Configure compile the patterns and load the profile. Invalid patterns are returned as errors. A profile that can
+// not be loaded is logged and ignored.
+func (s *Scanner) Configure(cfg Config) error {
+	if cfg.FilenamePattern == "" {
+		cfg.FilenamePattern = ".*"
+	}
+	if cfg.MaxLineLength <= 0 {
+		cfg.MaxLineLength = DefaultMaxLineLength
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = runtime.NumCPU()
+	}
+	if cfg.MaxBinarySize <= 0 {
+		cfg.MaxBinarySize = DefaultMaxBinarySize
+	}
+	if cfg.MmapThreshold == 0 {
+		cfg.MmapThreshold = DefaultMmapThreshold
+	}
+	patterns := map[string]*regexp.Regexp{}
+	for _, ptn := range cfg.Patterns {
+		re, err := regexp.Compile(ptn)
+		if err != nil {
+			return fmt.Errorf("invalid credential pattern '%s' - %w", ptn, err)
+		}
+		patterns[ptn] = re
+	}
+	detectors := map[string]Detector{}
+	blockDetectors := []*compiledBlockDetector{}
+	for _, id := range cfg.Detectors {
+		if d, ok := detectorByID(id); ok {
+			patterns[d.Pattern] = regexp.MustCompile(d.Pattern)
+			detectors[d.Pattern] = d
+		} else if d, ok := blockDetectorByID(id); ok {
+			cd := &compiledBlockDetector{BlockDetector: d, start: regexp.MustCompile(d.Start)}
+			if d.End != "" {
+				cd.end = regexp.MustCompile(d.End)
+			}
+			blockDetectors = append(blockDetectors, cd)
+		} else {
+			return fmt.Errorf("unknown detector '%s', expect one of %s", id, strings.Join(DetectorIDs(), ", "))
+		}
+	}
+	ruleEntropy := map[string]float64{}
+	ruleIDs := map[string]string{} // source by id
+	for _, r := range cfg.Rules {
+		if _, ok := detectorByID(r.ID); ok {
+			return fmt.Errorf("rule '%s' of %s has the id of a built-in detector", r.ID, r.Source)
+		}
+		if _, ok := blockDetectorByID(r.ID); ok {
+			return fmt.Errorf("rule '%s' of %s has the id of a built-in detector", r.ID, r.Source)
+		}
+		if src, ok := ruleIDs[r.ID]; ok {
+			return fmt.Errorf("rule '%s' is defined in %s and %s", r.ID, src, r.Source)
+		}
+		ruleIDs[r.ID] = r.Source
+		if err := r.check(); err != nil {
+			return err
+		}
+		d := r.Detector()
+		if _, ok := detectors[d.Pattern]; ok {
+			return fmt.Errorf("rule '%s' has the same regex as the detector '%s'", r.ID, detectors[d.Pattern].ID)
+		}
+		patterns[d.Pattern] = regexp.MustCompile(d.Pattern)
+		detectors[d.Pattern] = d
+		if r.Entropy > 0 {
+			ruleEntropy[r.ID] = r.Entropy
+		}
+	}
+	for id, threshold := range cfg.RuleEntropy {
+		ruleEntropy[id] = threshold
+	}
+	entropy := map[string]float64{}
+	for id, threshold := range ruleEntropy {
+		found := id == StructuredRuleID // read by matchStructured
+		for ptnStr := range patterns {
+			if d, ok := detectors[ptnStr]; (ok && d.ID == id) || (!ok && PatternRuleID(ptnStr) == id) {
+				entropy[ptnStr], found = threshold, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown rule '%s' in the rule entropy, expect a detector or a pattern rule id", id)
+		}
+	}
+	for _, glob := range cfg.IncludeGlobs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid include glob '%s' - %w", glob, err)
+		}
+	}
+	switch cfg.Redact {
+	case "", RedactMask, RedactPartial, RedactHash, RedactNone:
+	case RedactHmac:
+		if cfg.RedactKey == "" {
+			return fmt.Errorf("redact hmac needs a key")
+		}
+	default:
+		return fmt.Errorf("unknown redact mode '%s', expect one of %s", cfg.Redact, strings.Join(RedactModes, ", "))
+	}
+	if cfg.ScanBinaries != "" && cfg.ScanBinaries != BinaryStrings {
+		return fmt.Errorf("unknown scan binaries mode '%s', expect %s", cfg.ScanBinaries, BinaryStrings)
+	}
+	var err error
+	if s.filenamePtn, err = compileOptional(cfg.FilenamePattern, "filename"); err != nil {
+		return err
+	}
+	if s.excludePtn, err = compileOptional(cfg.Exclude, "exclude"); err != nil {
+		return err
+	}
+	if s.defaultExcludePtn, err = compileOptional(cfg.DefaultExclude, "default exclude"); err != nil {
+		return err
+	}
+	if s.pathExcludePtn, err = compileOptional(cfg.PathExclude, "path exclude"); err != nil {
+		return err
+	}
+	if s.placeholders, err = compilePlaceholders(cfg.Placeholders); err != nil {
+		return err
+	}
+	if s.vaultPasswords, err = ag.LoadVaultSecrets(cfg.VaultPasswordFiles); err != nil {
+		return err
+	}
+	s.cfg, s.patterns, s.detectors, s.blockDetectors, s.entropy = cfg, patterns, detectors, blockDetectors, entropy
+	keywords := map[string][]string{}
+	for ptnStr, d := range detectors {
+		if len(d.Keywords) > 0 {
+			keywords[ptnStr] = d.Keywords
+		}
+	}
+	ptns := make([]string, 0, len(patterns))
+	for ptnStr := range patterns {
+		ptns = append(ptns, ptnStr)
+	}
+	s.prefilter = newKeywordFilter(ptns, keywords)
+	s.profile = ProjectOutputFmt{}
+	s.profileFps = map[string]string{}
+	if cfg.ProfilePath != "" {
+		profile, err := LoadProfile(cfg.ProfilePath)
+		if err != nil {
+			s.Logger().Warn("can not load profile", "profile", cfg.ProfilePath, "error", err)
+		} else {
+			s.profile = profile
+		}
+		for file, matches := range s.profile {
+			for sig, o := range matches {
+				if o.Fingerprint != "" {
+					s.profileFps[o.Fingerprint] = file + "\x00" + sig
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Config return the current config
+func (s *Scanner) Config() Config {
+	return s.cfg
+}
+
+// SetLogger set the logger used by the scanner, the default is lib.Logger()
+func (s *Scanner) SetLogger(l *slog.Logger) {
+	s.logger = l
+}
+
+func (s *Scanner) Logger() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
+	}
+	return ag.Logger()
+}
+
+// Stats of the last scan, without its findings. Complete once the findings channel is closed.
+func (s *Scanner) Stats() Stats {
+	st := Stats{FilesScanned: s.filesScanned.Load(), FilesProcessed: s.filesProcessed.Load(), FilesCached: s.filesCached.Load(),
+		BytesProcessed: s.bytesProcessed.Load(), FilesSkipped: map[string]int64{}}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for reason, n := range s.skipped {
+		st.FilesSkipped[reason] = n
+	}
+	if len(s.vaults) > 0 {
+		st.VaultEncrypted = s.vaultFiles()
+	}
+	end := s.finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if !s.started.IsZero() {
+		st.DurationSeconds = end.Sub(s.started).Seconds()
+	}
+	return st
+}
+
+// Suppressed return the findings of the last scan suppressed by an inline comment, see IsSuppressed. Complete once
+// the findings channel is closed.
+func (s *Scanner) Suppressed() []OutputFmt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := append([]OutputFmt{}, s.suppressed...)
+	sort.Slice(o, func(i, j int) bool {
+		if o[i].File != o[j].File {
+			return o[i].File < o[j].File
+		}
+		return firstLine(o[i]) < firstLine(o[j])
+	})
+	return o
+}
+
+func (s *Scanner) addSuppressed(o OutputFmt) {
+	s.redact(o.Matches)
+	s.Logger().Info("finding suppressed by inline comment", "path", o.File, "line", firstLine(o), "rule", o.RuleID)
+	s.mu.Lock()
+	s.suppressed = append(s.suppressed, o)
+	s.mu.Unlock()
+}
+
+// suppressPtn is the inline annotation suppressing the findings of a line
+var suppressPtn = regexp.MustCompile(`cred-detect:ignore|nosec-cred`)
+
+// commentPrefixes start the lines having only a comment
+var commentPrefixes = []string{"#", "//", "--", ";", "/*", "*", "<!--", "'", "REM ", "rem "}
+
+// IsSuppressed return true if the line idx has a '# cred-detect:ignore' or '// nosec-cred' annotation, or the line
+// above is a comment having one.
+func IsSuppressed(lines []string, idx int) bool {
+	prev := ""
+	if idx > 0 {
+		prev = lines[idx-1]
+	}
+	return suppressedBy(lines[idx], prev)
+}
+
+// suppressedBy tell if the line is suppressed by an annotation in it or in prev, the line above
+func suppressedBy(line, prev string) bool {
+	if suppressPtn.MatchString(line) {
+		return true
+	}
+	if !suppressPtn.MatchString(prev) {
+		return false
+	}
+	prev = strings.TrimSpace(prev)
+	for _, p := range commentPrefixes {
+		if strings.HasPrefix(prev, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Err return the error that stopped the last scan, if any, eg the context cancelled. Valid once the findings channel is closed.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// isExcludedName return true if the file or directory name matches the exclude or default exclude pattern
+func (s *Scanner) isExcludedName(name string) bool {
+	return (s.excludePtn != nil && s.excludePtn.MatchString(name)) || (s.defaultExcludePtn != nil && s.defaultExcludePtn.MatchString(name))
+}
+
+// fileJob is a file found by the walker, to be processed by a worker
+type fileJob struct {
+	fpath string
+	info  fs.FileInfo
+	rules *Scanner // the scanner of its directory, see withLocalConfig
+}
+
+// Scan walk the root path and return a channel of findings. The files are processed by Concurrency workers; the
+// walker blocks while they are all busy and they block while the findings are not read, so the memory use does not
+// grow with the number of files. The channel is closed when the scan is done or the context is cancelled; check
+// Err() afterward.
+func (s *Scanner) Scan(ctx context.Context, root string) <-chan OutputFmt {
+	output_chan := make(chan OutputFmt)
+	s.resetStats()
+	s.err = nil
+	s.suppressed = nil
+	s.cache = nil
+	s.ignore = s.newCredIgnore(root)
+	s.owners = s.loadOwners(root)
+	if s.cfg.CachePath != "" {
+		cache, err := LoadCache(s.cfg.CachePath, s.cacheKey())
+		if err != nil {
+			s.Logger().Warn("can not load cache, scanning all files", "cache", s.cfg.CachePath, "error", err)
+		}
+		s.cache = cache
+	}
+	s.checkpoint = nil
+	if s.cfg.CheckpointPath != "" {
+		s.checkpoint = s.loadCheckpoint(root)
+	}
+	jobs := make(chan fileJob, s.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for range s.cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() == nil {
+					s.processFile(ctx, job.fpath, job.info, job.rules, output_chan)
+				}
+				s.filesPending.Add(-1)
+			}
+		}()
+	}
+	go func() {
+		defer close(output_chan)
+		defer s.finishStats()
+		saved := make(chan struct{})
+		if s.checkpoint != nil {
+			go s.saveCheckpoints(saved)
+		}
+		links := newSymlinkWalker(root)
+		dirs := map[string]*Scanner{} // the scanner of each directory walked, see withLocalConfig
+		var dirsMu sync.Mutex         // the directories are walked concurrently, see walkTree
+		var visit filepath.WalkFunc
+		visit = func(fpath string, info fs.FileInfo, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				s.Logger().Warn("walk error", "path", fpath, "error", err)
+				return nil
+			}
+			if info.Mode()&fs.ModeSymlink != 0 {
+				return s.followSymlink(fpath, fpath == root, links, visit)
+			}
+			// the names and paths are checked with the config of the parent directory
+			dirsMu.Lock()
+			rules := dirs[filepath.Dir(fpath)]
+			dirsMu.Unlock()
+			if rules == nil {
+				rules = s
+			}
+			if rules.pathExcludePtn != nil && rules.pathExcludePtn.MatchString(fpath) {
+				s.Logger().Info("skip path", "path", fpath)
+				if !info.IsDir() {
+					s.filesScanned.Add(1)
+					s.skip(SkipExcluded)
+				}
+				return nil
+			}
+			if rel, err := filepath.Rel(root, fpath); err == nil && rel != "." && s.ignore != nil && s.ignore.match(filepath.ToSlash(rel), info.IsDir()) {
+				s.Logger().Info("skip path in "+IgnoreFileName, "path", fpath)
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				s.filesScanned.Add(1)
+				s.skip(SkipIgnored)
+				return nil
+			}
+			fname := info.Name()
+			if info.IsDir() && rules.isExcludedName(fname) {
+				s.Logger().Info("skip dir", "path", fpath)
+				return filepath.SkipDir
+			}
+			// Check if the file matches the pattern
+			if info.IsDir() {
+				if fpath != root && !s.cfg.NoLocalConfig {
+					rules = rules.withLocalConfig(fpath)
+				}
+				dirsMu.Lock()
+				dirs[fpath] = rules
+				dirsMu.Unlock()
+				return nil
+			}
+			s.filesScanned.Add(1)
+			// the file name pattern and the default exclude apply to the files in the archives instead
+			archive := s.cfg.ScanArchives && archiveKind(fname) != ""
+			if fpath == s.cfg.ProfilePath || s.isOwnFile(fpath) || (rules.excludePtn != nil && rules.excludePtn.MatchString(fname)) {
+				s.skip(SkipExcluded)
+				return nil
+			}
+			if !archive && rules.isExcludedName(fname) {
+				s.skip(SkipExcluded)
+				return nil
+			}
+			if !archive && !rules.filenamePtn.MatchString(fname) {
+				s.skip(SkipFilename)
+				return nil
+			}
+			if rel, err := filepath.Rel(root, fpath); err == nil && !s.isIncluded(filepath.ToSlash(rel)) {
+				s.skip(SkipFilename)
+				return nil
+			}
+			if !info.Mode().IsRegular() { // a fifo, socket or device; reading it could block
+				s.Logger().Info("skip special file", "path", fpath, "mode", info.Mode().String())
+				s.skip(SkipNotRegular)
+				return nil
+			}
+			if s.cfg.SkipBinary && s.cfg.ScanBinaries == "" && !archive {
+				isbin, err := isBinaryFile(fpath)
+				if (err == nil) && isbin {
+					s.Logger().Info("skip binary", "path", fpath)
+					s.skip(SkipBinary)
+					return nil
+				}
+			}
+			s.Logger().Debug("add file", "path", fpath)
+			s.filesPending.Add(1)
+			select {
+			case jobs <- fileJob{fpath, info, rules}:
+			case <-ctx.Done():
+				s.filesPending.Add(-1)
+				return ctx.Err()
+			}
+			return nil
+		}
+		err := walkTree(root, WalkConcurrency, visit)
+		close(jobs)
+		wg.Wait()
+		if err == nil {
+			err = ctx.Err() // the walk ended but files were not matched
+		}
+		s.err = err
+		close(saved)
+		if s.cache != nil && err == nil && ctx.Err() == nil {
+			if err := s.cache.Save(); err != nil {
+				s.Logger().Warn("can not save cache", "cache", s.cfg.CachePath, "error", err)
+			}
+		}
+		if s.checkpoint != nil {
+			s.endCheckpoint(err == nil && ctx.Err() == nil)
+		}
+	}()
+	return output_chan
+}
+
+// StdinName is the file name of the findings of the data read from stdin
+const StdinName = "<stdin>"
+
+// ScanReader scan a stream, eg stdin, as one file named name and return the channel of findings like Scan. The
+// detectors, the profile and the masking apply as for a file; the file name patterns do not, the stream is scanned
+// because it was asked for. The channel is closed at the end of the stream or when the context is cancelled.
+func (s *Scanner) ScanReader(ctx context.Context, name string, r io.Reader) <-chan OutputFmt {
+	output_chan := make(chan OutputFmt)
+	s.resetStats()
+	s.filesScanned.Store(1)
+	s.err = nil
+	s.suppressed = nil
+	s.cache, s.checkpoint = nil, nil
+	s.owners = nil
+	go func() {
+		defer close(output_chan)
+		defer s.finishStats()
+		br := bufio.NewReaderSize(r, 64*1024)
+		head, _ := br.Peek(8000)
+		enc, _ := detectEncoding(head[:min(len(head), encodingHead)])
+		var data io.Reader = decodeReader(br, enc)
+		if !isUTF16(enc) && bytes.IndexByte(head, 0) >= 0 {
+			switch {
+			case s.cfg.ScanBinaries == BinaryStrings: // the size is not known, only the first MaxBinarySize bytes
+				data = newStringsReader(io.LimitReader(br, s.cfg.MaxBinarySize))
+			case s.cfg.SkipBinary:
+				s.Logger().Info("skip binary", "path", name)
+				s.skip(SkipBinary)
+				return
+			}
+		}
+		s.filesProcessed.Add(1)
+		if !s.newFileMatcher(ctx, s, name, output_chan).matchAll(data) && ctx.Err() == nil {
+			s.err = fmt.Errorf("can not read %s", name)
+		}
+		if ctx.Err() != nil {
+			s.err = ctx.Err()
+		}
+	}()
+	return output_chan
+}
+
+// processFile detect the credentials in one file and send the findings to output_chan. The file is read line by
+// line, or memory mapped past MmapThreshold; the bytes of a line past MaxLineLength are ignored.
+func (s *Scanner) processFile(ctx context.Context, fpath string, finfo fs.FileInfo, rules *Scanner, output_chan chan<- OutputFmt) {
+	if s.cfg.ScanArchives && archiveKind(finfo.Name()) != "" {
+		s.processArchive(ctx, fpath, rules, output_chan)
+		return
+	}
+	defer s.timings.file(fpath, finfo.Size(), s.timings.start())
+	tail := s.cfg.TailBytes > 0 && finfo.Size() > s.cfg.TailBytes
+	if !tail && s.cfg.MaxFileSize > 0 && finfo.Size() > s.cfg.MaxFileSize {
+		s.Logger().Info("skip file larger than max-file-size", "path", fpath, "size", finfo.Size())
+		s.skip(SkipTooLarge)
+		return
+	}
+	f, err := os.Open(fpath)
+	if err != nil {
+		s.Logger().Warn("can not read file", "path", fpath, "error", err)
+		s.skip(SkipUnreadable)
+		return
+	}
+	defer f.Close()
+	if !tail && strings.HasSuffix(path.Ext(finfo.Name()), "js") && finfo.Size() >= 1000 && fewLines(f, 10) { // Skip as it is likely js minified file
+		s.skip(SkipMinified)
+		return
+	}
+	// the findings of a helm template also depend on the values of its chart, it is always matched
+	if (s.cache != nil || s.checkpoint != nil) && !(s.cfg.Kubernetes && s.chartOf(fpath) != nil) {
+		if entry, ok := s.lookup(fpath, finfo, rules); ok {
+			s.replayCached(ctx, fpath, entry, output_chan)
+			return
+		}
+	}
+	head := make([]byte, encodingHead)
+	n, _ := f.ReadAt(head, 0)
+	enc, bom := detectEncoding(head[:n])
+	if enc == "" && isVault(string(head[:n])) {
+		s.processVault(ctx, fpath, f, rules, output_chan)
+		return
+	}
+	if enc != "" {
+		s.Logger().Info("transcode file to utf-8", "path", fpath, "encoding", enc)
+	}
+	var offset int64
+	if tail {
+		if offset, err = seekTail(f, finfo.Size(), s.cfg.TailBytes); err == nil && isUTF16(enc) && (offset-int64(bom))%2 == 1 {
+			// the tail starts after the first byte of a new line in utf-16
+			offset, err = f.Seek(offset+1, io.SeekStart)
+		}
+		if err != nil {
+			s.Logger().Warn("can not read file", "path", fpath, "error", err)
+			s.skip(SkipUnreadable)
+			return
+		}
+		s.Logger().Info("scan the tail of the file", "path", fpath, "size", finfo.Size(), "offset", offset)
+	}
+	var mapped []byte
+	if !tail && enc == "" && s.cfg.MmapThreshold > 0 && finfo.Size() >= s.cfg.MmapThreshold && s.cfg.ScanBinaries != BinaryStrings {
+		data, unmap, err := mmapFile(f, finfo.Size())
+		if err == nil {
+			defer unmap()
+			mapped = data
+		} else {
+			s.Logger().Debug("can not map file, reading it", "path", fpath, "error", err)
+		}
+	}
+	hash := sha256.New()
+	var data io.Reader = decodeReader(io.TeeReader(f, hash), enc)
+	if s.cfg.ScanBinaries == BinaryStrings && !isUTF16(enc) {
+		if isbin, err := u.IsBinaryFileSimple(fpath); err == nil && isbin {
+			if !s.binaryFits(fpath, finfo.Size()) {
+				return
+			}
+			data = newStringsReader(data)
+		}
+	}
+	s.filesProcessed.Add(1)
+	m := s.newFileMatcher(ctx, rules, fpath, output_chan)
+	m.offset = offset
+	if mapped != nil {
+		matched := s.readMapped(fpath, func() bool {
+			hash.Write(mapped)
+			return m.matchMapped(mapped)
+		})
+		if !matched || (s.cache == nil && s.checkpoint == nil) {
+			return
+		}
+	} else if !m.matchAll(data) || (s.cache == nil && s.checkpoint == nil) {
+		return
+	}
+	findings := make([]OutputFmt, 0, len(m.sent))
+	for _, o := range m.sent {
+		findings = append(findings, o)
+	}
+	sortFindings(findings)
+	s.store(fpath, CacheEntry{Size: finfo.Size(), ModTime: finfo.ModTime(), Hash: fmt.Sprintf("%x", hash.Sum(nil)), Findings: findings, Suppressed: m.suppressed,
+		Rules: s.rulesKey(rules), Baselined: m.baselined, Vault: m.hasVault})
+}
+
+// seekTail move f to the first full line of its last n bytes and return that offset. The line cut by the seek is
+// skipped, a line longer than n leaves nothing to scan.
+func seekTail(f *os.File, size, n int64) (int64, error) {
+	offset := size - n
+	if _, err := f.Seek(offset-1, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(io.LimitReader(f, n+1))
+	skipped, err := r.ReadSlice('\n')
+	for err == bufio.ErrBufferFull {
+		offset += int64(len(skipped))
+		skipped, err = r.ReadSlice('\n')
+	}
+	offset += int64(len(skipped)) - 1 // the byte before the tail, a new line if the tail starts a line
+	if err == io.EOF {
+		offset = size
+	} else if err != nil {
+		return 0, err
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	return offset, err
+}
+
+// lookup return the entry of an unchanged file from the checkpoint or the cache, and keep it in both
+func (s *Scanner) lookup(fpath string, finfo fs.FileInfo, rules *Scanner) (CacheEntry, bool) {
+	for _, c := range []*Cache{s.checkpoint, s.cache} {
+		if c == nil {
+			continue
+		}
+		if entry, ok := c.Lookup(fpath, finfo); ok && entry.Rules == s.rulesKey(rules) {
+			s.store(fpath, entry)
+			return entry, true
+		}
+	}
+	return CacheEntry{}, false
+}
+
+// store put the entry of a file in the cache and the checkpoint
+func (s *Scanner) store(fpath string, entry CacheEntry) {
+	for _, c := range []*Cache{s.checkpoint, s.cache} {
+		if c != nil {
+			c.Put(fpath, entry)
+		}
+	}
+}
+
+// replayCached send the findings of an unchanged file from the cache
+func (s *Scanner) replayCached(ctx context.Context, fpath string, entry CacheEntry, output_chan chan<- OutputFmt) {
+	s.filesCached.Add(1)
+	if entry.Vault {
+		s.addVault(fpath)
+	}
+	for _, o := range entry.Suppressed {
+		s.addSuppressed(o)
+	}
+	for _, key := range entry.Baselined {
+		s.markSeen(key)
+	}
+	for _, o := range entry.Findings {
+		s.setOwners(&o) // the CODEOWNERS may have changed since
+		select {
+		case output_chan <- o:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// isOwnFile tell if the path is a file the scan writes: the cache or the checkpoint file or their temporary file, or
+// one of OwnFiles
+func (s *Scanner) isOwnFile(fpath string) bool {
+	fpath = filepath.Clean(fpath)
+	for _, cache := range append([]string{s.cfg.CachePath, s.cfg.CheckpointPath}, s.cfg.OwnFiles...) {
+		if cache == "" {
+			continue
+		}
+		cache = filepath.Clean(cache)
+		if fpath == cache || fpath == cache+".tmp" {
+			return true
+		}
+	}
+	return false
+}
+
+// readLine read a line without the end of line; the bytes past max are read and dropped. It returns io.EOF at the
+// end of the data.
+func readLine(r *bufio.Reader, max int) (string, error) {
+	var buf []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		if len(buf) < max {
+			buf = append(buf, chunk[:min(len(chunk), max-len(buf))]...)
+		}
+		if !isPrefix {
+			return string(buf), nil
+		}
+	}
+}
+
+// fewLines tell if the file has less than n lines, reading up to the n-th line. The file offset is put back at 0.
+func fewLines(f *os.File, n int) bool {
+	defer f.Seek(0, io.SeekStart)
+	buf, count := make([]byte, 32*1024), 0
+	for {
+		nr, err := f.Read(buf)
+		count += bytes.Count(buf[:nr], []byte("\n"))
+		if count >= n-1 {
+			return false
+		}
+		if err != nil {
+			return true
+		}
+	}
+}
+
+// fileMatcher match the lines of one file as they are read
+type fileMatcher struct {
+	s           *Scanner
+	rules       *Scanner // the scanner of the directory of the file, see withLocalConfig
+	ctx         context.Context
+	fpath       string
+	output_chan chan<- OutputFmt
+	outputs     map[string]*OutputFmt // one finding per pattern so each keeps its rule id
+	blocks      []*blockFinder
+	prev        string               // the previous line
+	sent        map[string]OutputFmt // the last findings sent, by pattern or block start, for the cache
+	suppressed  []OutputFmt
+	hitLines    map[int]bool      // lines with a finding of a pattern or the start of a block, the structured scan skips them
+	src         *sourceLexer      // the string literals of a source file with SourceAware, else nil
+	related     map[string]string // the last value of each detector in the file, for the verifiers needing two
+	offset      int64             // the offset of the first line read, see TailBytes
+	baselined   []string          // the keys of the profile findings matched, for the cache
+	vault       *vaultValue       // the inline !vault value being read, see vaultLine
+	hasVault    bool              // the file has an inline !vault value
+}
+
+// newFileMatcher return the matcher of a file, matching with the patterns and detectors of rules
+func (s *Scanner) newFileMatcher(ctx context.Context, rules *Scanner, fpath string, output_chan chan<- OutputFmt) *fileMatcher {
+	m := &fileMatcher{s: s, rules: rules, ctx: ctx, fpath: fpath, output_chan: output_chan, outputs: map[string]*OutputFmt{}, sent: map[string]OutputFmt{},
+		hitLines: map[int]bool{}, related: map[string]string{}}
+	if lang := sourceLang(fpath); s.cfg.SourceAware && lang != "" {
+		m.src = &sourceLexer{lang: lang}
+	}
+	for _, d := range rules.blockDetectors {
+		m.blocks = append(m.blocks, &blockFinder{d: d})
+	}
+	return m
+}
+
+// matchReader match all lines of the reader. It returns false if the context is done or the read failed.
+func (m *fileMatcher) matchReader(r *bufio.Reader) bool {
+	for idx := 0; ; idx++ {
+		line, err := readLine(r, m.s.cfg.MaxLineLength)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			m.s.Logger().Warn("can not read file", "path", m.fpath, "error", err)
+			return false
+		}
+		if !m.matchLine(idx, line) {
+			return false
+		}
+	}
+	return m.finish()
+}
+
+// matchLines match the lines of data like matchReader, only the line matched is copied
+func (m *fileMatcher) matchLines(data []byte) bool {
+	for idx := 0; len(data) > 0; idx++ {
+		line := data
+		if end := bytes.IndexByte(data, '\n'); end >= 0 {
+			line, data = data[:end], data[end+1:]
+		} else {
+			data = nil
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if !m.matchLine(idx, string(line[:min(len(line), m.s.cfg.MaxLineLength)])) {
+			return false
+		}
+	}
+	return m.finish()
+}
+
+// matchLine run all patterns and the multi-line detectors over the line idx. For a source file with SourceAware the
+// patterns only run over its string literals; with DecodeDepth they also run over its decoded blobs. It returns false
+// if the context is done.
+func (m *fileMatcher) matchLine(idx int, data string) bool {
+	if inVault, ok := m.vaultLine(idx, data); !ok {
+		return false
+	} else if inVault {
+		m.prev = data
+		return true
+	}
+	if m.src != nil {
+		for _, seg := range m.src.next(idx, data) {
+			if !m.matchPatterns(seg.line, seg.text, seg.raw, seg.prev) {
+				return false
+			}
+		}
+	} else if !m.matchPatterns(idx, data, data, m.prev) {
+		return false
+	}
+	if m.s.cfg.DecodeDepth > 0 && !m.matchDecoded(idx, data, data, 0) {
+		return false
+	}
+	for _, f := range m.blocks {
+		if b := f.next(idx, data, m.prev); b != nil && !m.matchBlock(f.d, b) {
+			return false
+		}
+	}
+	m.prev = data
+	return true
+}
+
+// matchPatterns run all patterns over text, the line idx or the part of it to scan. raw is the line and prev the line
+// before, for the fingerprint and the inline suppression. It returns false if the context is done.
+func (m *fileMatcher) matchPatterns(idx int, text, raw, prev string) bool {
+	s, fpath := m.s, m.fpath
+	suppressed := suppressedBy(raw, prev)
+	for _, ptnStr := range m.rules.linePatterns(text) {
+		start := s.timings.start()
+		matched, pairs := m.rules.lineMatches(ptnStr, m.rules.patterns[ptnStr], text, fpath, idx)
+		s.timings.rule(m.rules, ptnStr, start, matched)
+		if !matched {
+			continue
+		}
+		if len(pairs) > 0 {
+			m.hitLines[idx] = true
+		}
+		ruleID, severity, confidence := m.rules.ruleOf(ptnStr, fpath, pairs)
+		if suppressed {
+			if len(pairs) > 0 {
+				m.addSuppressed(OutputFmt{File: fpath, Line_no: []int{idx}, Pattern: ptnStr, Matches: pairs, RuleID: ruleID, Severity: severity, Confidence: confidence,
+					Fingerprint: Fingerprint(fpath, ruleID, pairs[1], raw)})
+			}
+			continue
+		}
+		pairs, fingerprint := m.skipKnownFingerprints(ruleID, pairs, raw)
+		if pairs == nil {
+			s.Logger().Info("fingerprints exist in profile, skipping", "path", fpath, "line", idx)
+			continue
+		}
+		o, ok := m.outputs[ptnStr]
+		if !ok {
+			o = &OutputFmt{File: fpath, Line_no: []int{}, Matches: []string{}, Pattern: ptnStr, RuleID: ruleID, Severity: severity}
+			o.Remediation, o.DocURL = m.rules.remediationOf(ptnStr)
+			m.outputs[ptnStr] = o
+		}
+		if len(pairs) > 0 {
+			o.Confidence = maxLevel(o.Confidence, confidence)
+			if o.Fingerprint == "" {
+				o.Fingerprint, o.SecretID = fingerprint, s.secretID(pairs[1])
+			}
+			if s.cfg.Verify {
+				o.Verified = mergeStatus(o.Verified, m.verify(ruleID, pairs))
+			}
+			if n := len(o.Line_no); n == 0 || o.Line_no[n-1] != idx { // a source line may have many string literals
+				o.Line_no = append(o.Line_no, idx)
+			}
+		}
+		o.Matches = append(o.Matches, pairs...)
+		if len(o.Matches) == 0 {
+			continue
+		}
+		match_Sig := o.Matches[0] + o.Matches[1]
+		if m.inProfile(match_Sig, "") {
+			s.Logger().Info("matches exist in profile, skipping", "path", fpath, "signature", match_Sig)
+			continue
+		}
+		s.redact(o.Matches)
+		// Send a copy, o keeps growing while we go through the next lines
+		found := *o
+		found.Line_no = append([]int{}, o.Line_no...)
+		found.Matches = append([]string{}, o.Matches...)
+		if !m.send(ptnStr, found) {
+			return false
+		}
+	}
+	return true
+}
+
+// finish report the multi-line blocks still open at the end of the file. It returns false if the context is done.
+func (m *fileMatcher) finish() bool {
+	if m.vault != nil && !m.matchVault() {
+		return false
+	}
+	for _, f := range m.blocks {
+		if b := f.flush(); b != nil && !m.matchBlock(f.d, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchBlock report a block of a multi-line detector; its value is the block content. It returns false if the
+// context is done.
+func (m *fileMatcher) matchBlock(d *compiledBlockDetector, b *foundBlock) bool {
+	s, fpath := m.s, m.fpath
+	m.hitLines[b.start] = true
+	value := strings.Join(b.lines, "\n")
+	if s.cfg.Debug {
+		s.Logger().Debug("block match", "path", fpath, "detector", d.ID, "start", b.start, "end", b.end)
+	}
+	o := OutputFmt{File: fpath, Line_no: []int{b.start}, Pattern: d.Start, Matches: []string{d.ID, value}, RuleID: d.ID, Severity: d.Severity, Confidence: scoreConfidence(d.Confidence, fpath, false, nil), End_line: b.end,
+		Fingerprint: Fingerprint(fpath, d.ID, value, b.lines[0]), SecretID: s.secretID(value), Remediation: d.Remediation, DocURL: d.DocURL}
+	if suppressedBy(b.lines[0], b.prev) {
+		m.addSuppressed(o)
+		return true
+	}
+	if m.inProfile(o.Matches[0]+o.Matches[1], o.Fingerprint) {
+		s.Logger().Info("matches exist in profile, skipping", "path", fpath, "detector", d.ID, "line", b.start)
+		return true
+	}
+	s.redact(o.Matches)
+	return m.send(fmt.Sprintf("%s:%d", d.ID, b.start), o)
+}
+
+// send a finding, key identify the finding for the cache. It returns false if the context is done.
+func (m *fileMatcher) send(key string, o OutputFmt) bool {
+	o.Offset = m.offset
+	o.ID = FindingID(o.Fingerprint)
+	m.s.setOwners(&o)
+	select {
+	case m.output_chan <- o:
+		m.sent[key] = o
+		return true
+	case <-m.ctx.Done():
+		return false
+	}
+}
+
+func (m *fileMatcher) addSuppressed(o OutputFmt) {
+	m.s.addSuppressed(o)
+	m.suppressed = append(m.suppressed, o)
+}
+
+// lineMatches run one pattern over a line. matched is true if the pattern matched at all, pairs are the token
+// name and value of the matches that look like a password. For a detector the name is the detector id.
+func (s *Scanner) lineMatches(ptnStr string, ptn *regexp.Regexp, data, fpath string, lineNo int) (matched bool, pairs []string) {
+	detector, isDetector := s.detectors[ptnStr]
+	if !isDetector {
+		data = literalText(data) // the value of a generic pattern is not in a template expression
+	}
+	matches := ptn.FindAllStringSubmatch(data, -1)
+	threshold, tuned := s.entropy[ptnStr]
+	if !tuned {
+		threshold = s.cfg.EntropyThreshold
+	}
+	for _, match := range matches {
+		if s.cfg.Debug {
+			s.Logger().Debug("match", "path", fpath, "line", lineNo, "groups", match[1:])
+		}
+		if isDetector {
+			value := match[0]
+			if g := max(detector.SecretGroup, 1); len(match) > g && match[g] != "" {
+				value = match[g]
+			}
+			if (tuned && ag.CalculateEntropy(value) <= threshold) || s.isPlaceholder(value) {
+				continue
+			}
+			pairs = append(pairs, detector.ID, value)
+			continue
+		}
+		if len(match) > 2 && !s.isPlaceholder(match[2]) && ag.IsLikelyPasswordOrToken(match[2], s.cfg.CheckMode, s.cfg.WordsFile, 4, threshold) {
+			pairs = append(pairs, match[1], match[2])
+		}
+	}
+	return len(matches) > 0, pairs
+}
+
+// skipKnownFingerprints remove the token name, value pairs whose fingerprint is in the profile. It returns nil if all
+// are known, else the remaining pairs and the fingerprint of the first one.
+func (m *fileMatcher) skipKnownFingerprints(ruleID string, pairs []string, line string) ([]string, string) {
+	if len(pairs) == 0 {
+		return []string{}, ""
+	}
+	o, fingerprint := []string{}, ""
+	for idx := 0; idx+1 < len(pairs); idx += 2 {
+		fp := Fingerprint(m.fpath, ruleID, pairs[idx+1], line)
+		if m.inProfile("", fp) {
+			continue
+		}
+		if fingerprint == "" {
+			fingerprint = fp
+		}
+		o = append(o, pairs[idx], pairs[idx+1])
+	}
+	if len(o) == 0 {
+		return nil, ""
+	}
+	return o, fingerprint
+}
+
+// ruleOf return the rule id, the severity and the confidence of a finding of a pattern in the file, pairs are its
+// unmasked token name, value pairs
+func (s *Scanner) ruleOf(ptnStr, fpath string, pairs []string) (string, string, string) {
+	if d, ok := s.detectors[ptnStr]; ok {
+		return d.ID, d.Severity, scoreConfidence(d.Confidence, fpath, false, pairs)
+	}
+	return PatternRuleID(ptnStr), SeverityMedium, scoreConfidence(ConfidenceLow, fpath, true, pairs)
+}
+
+// remediationOf return the remediation and its documentation url of the findings of a pattern
+func (s *Scanner) remediationOf(ptnStr string) (string, string) {
+	if d, ok := s.detectors[ptnStr]; ok {
+		return firstNonEmpty(d.Remediation, GenericRemediation), d.DocURL
+	}
+	return GenericRemediation, ""
+}
+
+// linePatterns return the patterns to run on the line, the ones having a keyword in it or no keyword, see keywordFilter
+func (s *Scanner) linePatterns(line string) []string {
+	ptns := []string{}
+	for idx, run := range s.prefilter.match(line) {
+		if run {
+			ptns = append(ptns, s.prefilter.patterns[idx])
+		}
+	}
+	return ptns
+}
+
+// redact hide the values of the token name, value pairs as the Redact mode says, see redactValue
+func (s *Scanner) redact(pairs []string) {
+	for idx := 1; idx < len(pairs); idx += 2 {
+		pairs[idx] = redactValue(s.cfg.Redact, s.cfg.RedactKey, pairs[idx])
+	}
+}
+
+// Collect read all findings from the channel into the profile format, keyed by file then by token signature.
+// It returns once the channel is closed.
+func Collect(findings <-chan OutputFmt) ProjectOutputFmt {
+	output := ProjectOutputFmt{}
+	for out := range findings {
+		output.Add(out)
+	}
+	return output
+}
+
+// Add a finding sent by Scan, replacing the previous one of the same signature
+func (output ProjectOutputFmt) Add(out OutputFmt) {
+	if out.File == "" || len(out.Matches) < 2 {
+		return
+	}
+	tokenSig := out.Matches[0] + out.Matches[1]
+	if _, ok := output[out.File]; !ok {
+		output[out.File] = map[string]OutputFmt{}
+	}
+	output[out.File][tokenSig] = out
+}
+
+// Hash return a short hash of the config so runs with the same settings can be grouped
+func (c Config) Hash() string {
+	datab, _ := json.Marshal(c)
+	return fmt.Sprintf("%x", sha256.Sum256(datab))[:16]
+}
+
+// DiffResult is the comparison of two scans. Findings are the same if they have the same file and token signature
+// or the same Fingerprint, so moved lines are not reported as removed and added.
+type DiffResult struct {
+	Added     []OutputFmt
+	Removed   []OutputFmt
+	Unchanged []OutputFmt
+}
+
+// Diff compare the findings of an old and a new scan. The lists are sorted by file then line.
+func Diff(old, new ProjectOutputFmt) DiffResult {
+	res := DiffResult{Added: []OutputFmt{}, Removed: []OutputFmt{}, Unchanged: []OutputFmt{}}
+	oldFps, newFps := fingerprintsOf(old), fingerprintsOf(new)
+	for file, matches := range new {
+		for sig, o := range matches {
+			if _, ok := old[file][sig]; ok || oldFps[o.Fingerprint] {
+				res.Unchanged = append(res.Unchanged, o)
+			} else {
+				res.Added = append(res.Added, o)
+			}
+		}
+	}
+	for file, matches := range old {
+		for sig, o := range matches {
+			if _, ok := new[file][sig]; !ok && !newFps[o.Fingerprint] {
+				res.Removed = append(res.Removed, o)
+			}
+		}
+	}
+	for _, l := range [][]OutputFmt{res.Added, res.Removed, res.Unchanged} {
+		sortFindings(l)
+	}
+	return res
+}
+
+// sortFindings sort by file then line
+func sortFindings(l []OutputFmt) {
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].File != l[j].File {
+			return l[i].File < l[j].File
+		}
+		return firstLine(l[i]) < firstLine(l[j])
+	})
+}
+
+// fingerprintsOf return the set of the non empty fingerprints of the findings
+func fingerprintsOf(output ProjectOutputFmt) map[string]bool {
+	o := map[string]bool{}
+	for _, matches := range output {
+		for _, f := range matches {
+			if f.Fingerprint != "" {
+				o[f.Fingerprint] = true
+			}
+		}
+	}
+	return o
+}
+
+func firstLine(o OutputFmt) int {
+	if len(o.Line_no) == 0 {
+		return -1
+	}
+	return o.Line_no[0]
+}
@@ -0,0 +1,356 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fpath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fpath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fpath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.conf":        "host=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\n",
+		"clean.txt":       "nothing to see here\n",
+		"vendor/lib.conf": "token=Ab3dEf9hIj2kLm\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Exclude = "^vendor$"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := Collect(s.Scan(context.Background(), dir))
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(output) != 1 {
+		t.Fatalf("expect findings in 1 file, got %v", output)
+	}
+	found, ok := output[filepath.Join(dir, "app.conf")]
+	if !ok {
+		t.Fatalf("expect app.conf in findings, got %v", output)
+	}
+	for _, o := range found {
+		if o.Matches[0] != "password" || o.Matches[1] != "*****" {
+			t.Errorf("expect masked password match, got %v", o.Matches)
+		}
+		if len(o.Line_no) != 1 || o.Line_no[0] != 1 {
+			t.Errorf("expect line 1, got %v", o.Line_no)
+		}
+	}
+	if st := s.Stats(); st.FilesScanned != 2 || st.FilesProcessed != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	// The findings of an unmasked run saved as profile are not reported again
+	cfg.Redact = RedactNone
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	output = Collect(s.Scan(context.Background(), dir))
+	profile := filepath.Join(t.TempDir(), "profile.json")
+	datab, _ := json.Marshal(output)
+	if err := os.WriteFile(profile, datab, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.ProfilePath = profile
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if output = Collect(s.Scan(context.Background(), dir)); len(output) != 0 {
+		t.Errorf("expect no new findings with the profile, got %v", output)
+	}
+}
+
+func TestConfigureInvalidPattern(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Patterns = []string{"(unclosed"}
+	if _, err := New(cfg); err == nil {
+		t.Error("expect error for an invalid pattern")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := ProjectOutputFmt{
+		"a.txt": {"password*****": {File: "a.txt", Line_no: []int{1}, Matches: []string{"password", "*****"}}},
+		"b.txt": {"token*****": {File: "b.txt", Line_no: []int{2}, Matches: []string{"token", "*****"}}},
+	}
+	new := ProjectOutputFmt{
+		"a.txt": old["a.txt"],
+		"c.txt": {"secret*****": {File: "c.txt", Line_no: []int{3}, Matches: []string{"secret", "*****"}}},
+	}
+	res := Diff(old, new)
+	if len(res.Added) != 1 || res.Added[0].File != "c.txt" {
+		t.Errorf("unexpected added %v", res.Added)
+	}
+	if len(res.Removed) != 1 || res.Removed[0].File != "b.txt" {
+		t.Errorf("unexpected removed %v", res.Removed)
+	}
+	if len(res.Unchanged) != 1 || res.Unchanged[0].File != "a.txt" {
+		t.Errorf("unexpected unchanged %v", res.Unchanged)
+	}
+}
+
+func TestInlineSuppression(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"fixture.py": "password='Xk9dLq2ZmP7wR4'  # cred-detect:ignore\n# nosec-cred test fixture\ntoken='Ab3dEf9hIj2kLm'\nsecret='Qw8eRt5yUi3oP'\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := Collect(s.Scan(context.Background(), dir))
+	found := output[filepath.Join(dir, "fixture.py")]
+	if len(found) != 1 {
+		t.Fatalf("expect only the secret line reported, got %v", output)
+	}
+	for _, o := range found {
+		if o.Matches[0] != "secret" || o.Line_no[len(o.Line_no)-1] != 3 {
+			t.Errorf("unexpected finding %v", o)
+		}
+	}
+	if suppressed := s.Suppressed(); len(suppressed) != 2 || suppressed[0].Line_no[0] != 0 || suppressed[1].Line_no[0] != 2 {
+		t.Errorf("expect the lines 0 and 2 suppressed, got %v", suppressed)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fp := Fingerprint("a.conf", "rule", "Xk9dLq2ZmP7wR4", `password="Xk9dLq2ZmP7wR4"`)
+	if fp != Fingerprint("a.conf", "rule", "Xk9dLq2ZmP7wR4", `  password = "Xk9dLq2ZmP7wR4"`) {
+		t.Error("expect the same fingerprint when only the whitespaces change")
+	}
+	if fp == Fingerprint("b.conf", "rule", "Xk9dLq2ZmP7wR4", `password="Xk9dLq2ZmP7wR4"`) {
+		t.Error("expect a different fingerprint for another file")
+	}
+
+	// A masked profile still matches after the lines moved
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"app.conf": "host=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\n"})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := Collect(s.Scan(context.Background(), dir))
+	profile := filepath.Join(t.TempDir(), "profile.json")
+	datab, _ := json.Marshal(old)
+	if err := os.WriteFile(profile, datab, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"app.conf": "# app config\n\nhost=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\n",
+		"new.conf": "token=\"Ab3dEf9hIj2kLm\"\n",
+	})
+	new := Collect(s.Scan(context.Background(), dir))
+	if res := Diff(old, new); len(res.Removed) != 0 || len(res.Unchanged) != 1 || len(res.Added) != 1 {
+		t.Errorf("expect the moved password unchanged and the token added, got %+v", res)
+	}
+	cfg.ProfilePath = profile
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if output := Collect(s.Scan(context.Background(), dir)); len(output) != 1 || output[filepath.Join(dir, "new.conf")] == nil {
+		t.Errorf("expect only the new token reported, got %v", output)
+	}
+}
+
+func TestScanConcurrency(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{}
+	for i := range 50 {
+		files[fmt.Sprintf("d%d/app%d.conf", i%5, i)] = "password='Xk9dLq2ZmP7wR4'\n"
+	}
+	writeFiles(t, dir, files)
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Concurrency = 2
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := Collect(s.Scan(context.Background(), dir)); len(output) != 50 {
+		t.Errorf("expect findings in 50 files, got %d", len(output))
+	}
+
+	// cancelled after the first finding, the channel is closed without the rest being read
+	ctx, cancel := context.WithCancel(context.Background())
+	findings := s.Scan(ctx, dir)
+	<-findings
+	cancel()
+	for range findings {
+	}
+	if err := s.Err(); err == nil {
+		t.Error("expect the context error")
+	}
+}
+
+func TestScanLimits(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"long.txt":   strings.Repeat("x", 100) + " password='Xk9dLq2ZmP7wR4'\ntoken='Ab3dEf9hIj2kLm'\n",
+		"big.conf":   "password='Xk9dLq2ZmP7wR4'\n" + strings.Repeat("# padding\n", 100),
+		"app.min.js": "var password='Xk9dLq2ZmP7wR4';" + strings.Repeat("a", 1000),
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.MaxLineLength = 50
+	cfg.MaxFileSize = 500
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := Collect(s.Scan(context.Background(), dir))
+	if len(output) != 1 {
+		t.Fatalf("expect findings only in long.txt, got %v", output)
+	}
+	for _, o := range output[filepath.Join(dir, "long.txt")] {
+		if o.Matches[0] != "token" || o.Line_no[0] != 1 {
+			t.Errorf("expect only the token of line 1, got %v", o)
+		}
+	}
+
+	r := bufio.NewReaderSize(strings.NewReader("abcdefghijklmnopqrstuvwxyz\r\nnext"), 16)
+	for _, want := range []string{"abcdefghij", "next"} {
+		if line, err := readLine(r, 10); err != nil || line != want {
+			t.Errorf("expect %q, got %q %v", want, line, err)
+		}
+	}
+	if _, err := readLine(r, 10); err != io.EOF {
+		t.Errorf("expect EOF, got %v", err)
+	}
+}
+
+func TestScanReader(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := "apiVersion: v1\ndata:\n  password: Xk9dLq2ZmP7wR4\n"
+	output := Collect(s.ScanReader(context.Background(), "secret.yaml", strings.NewReader(data)))
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	found := output["secret.yaml"]
+	if len(output) != 1 || len(found) != 1 {
+		t.Fatalf("expect one finding in secret.yaml, got %v", output)
+	}
+	for _, o := range found {
+		if o.Matches[1] != "*****" || !slices.Equal(o.Line_no, []int{2}) {
+			t.Errorf("expect the value masked at line 2, got %+v", o)
+		}
+	}
+	if stats := s.Stats(); stats.FilesScanned != 1 || stats.FilesProcessed != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	// the lines whose values are dropped are not reported
+	mixed := "password: changeme\nhost: localhost\npassword: Xk9dLq2ZmP7wR4\nport: 80\nsecret=short\n"
+	found = Collect(s.ScanReader(context.Background(), "mixed.yaml", strings.NewReader(mixed)))["mixed.yaml"]
+	if len(found) != 1 {
+		t.Fatalf("expect one finding in mixed.yaml, got %v", found)
+	}
+	for _, o := range found {
+		if !slices.Equal(o.Line_no, []int{2}) {
+			t.Errorf("expect line 2 only, got %+v", o)
+		}
+	}
+	// the profile applies to the stream name
+	profile := filepath.Join(t.TempDir(), "profile.json")
+	if err := SaveProfile(profile, output); err != nil {
+		t.Fatal(err)
+	}
+	cfg.ProfilePath = profile
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if output := Collect(s.ScanReader(context.Background(), "secret.yaml", strings.NewReader(data))); len(output) != 0 {
+		t.Errorf("expect the finding in the profile skipped, got %v", output)
+	}
+	if output := Collect(s.ScanReader(context.Background(), StdinName, strings.NewReader("x\x00"+data))); len(output) != 0 {
+		t.Errorf("expect binary data skipped, got %v", output)
+	}
+}
+
+func TestOwnFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.conf": "password='Zx9cVb7nMq2wE'\n",
+		"scan.log": "level=DEBUG msg=match groups=\"[password Zx9cVb7nMq2wE]\" password='Zx9cVb7nMq2wE'\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.OwnFiles = []string{filepath.Join(dir, "scan.log")}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := Collect(s.Scan(context.Background(), dir))
+	if _, ok := output[filepath.Join(dir, "scan.log")]; ok || len(output) != 1 {
+		t.Errorf("expect the log file of the scan not scanned, got %v", output)
+	}
+}
+
+func TestTailBytes(t *testing.T) {
+	dir := t.TempDir()
+	head := "password='Zx9cVb7nMq2wE'\n" + strings.Repeat("GET /health 200\n", 100)
+	tail := "GET /login 200\ntoken='Qw8eRt5yUi3oP'\n"
+	writeFiles(t, dir, map[string]string{
+		"app.log":   head + tail,
+		"app.conf":  "password='Zx9cVb7nMq2wE'\n",
+		"small.log": "secret='Po4iUy6tRe8wQ'\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.MaxFileSize = 200 // app.log is larger, only its tail is scanned
+	cfg.TailBytes = int64(len(tail) + 5)
+	cfg.IncludeGlobs = []string{"*.log"}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := Collect(s.Scan(context.Background(), dir))
+	if len(output[filepath.Join(dir, "app.conf")]) != 0 {
+		t.Errorf("expect the files not matching the include glob skipped, got %v", output)
+	}
+	if len(output[filepath.Join(dir, "small.log")]) != 1 {
+		t.Errorf("expect the small log scanned whole, got %v", output)
+	}
+	found := output[filepath.Join(dir, "app.log")]
+	if len(found) != 1 {
+		t.Fatalf("expect only the finding of the tail of app.log, got %v", found)
+	}
+	for _, o := range found {
+		if o.Offset != int64(len(head)) || o.Line_no[0] != 1 {
+			t.Errorf("expect the second line of the tail at offset %d, got line %d at offset %d", len(head), o.Line_no[0], o.Offset)
+		}
+	}
+	if !s.isExcludedPath("var/app.conf") || s.isExcludedPath("var/app.log") {
+		t.Error("expect the include globs applied to the repository paths")
+	}
+}
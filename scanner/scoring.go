@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	ag "github.com/sunshine69/automation-go/lib"
+)
+
+// HighEntropy is the entropy (bits per character) above which a value of a generic pattern looks random enough to
+// raise the confidence of the finding
+const HighEntropy = 3.5
+
+// testPathPtn match the paths of tests, fixtures and examples; their findings are often fake secrets
+var testPathPtn = regexp.MustCompile(`(?i)(^|[/\\])(tests?|testdata|__tests__|spec|fixtures?|examples?|mocks?)([/\\]|$)|_test\.|\.(test|spec)\.|(^|[/\\])test_[^/\\]*$`)
+
+var levels = map[string]int{"low": 1, "medium": 2, "high": 3}
+
+// LevelAtLeast tell if a severity or confidence level is min or above. An empty or unknown level is below all.
+func LevelAtLeast(level, min string) bool {
+	return levels[level] > 0 && levels[level] >= levels[min]
+}
+
+// maxLevel return the higher of the two levels
+func maxLevel(a, b string) string {
+	if levels[b] > levels[a] {
+		return b
+	}
+	return a
+}
+
+// lowerLevel return the level one step lower, low stays low
+func lowerLevel(level string) string {
+	switch level {
+	case ConfidenceHigh:
+		return ConfidenceMedium
+	default:
+		return ConfidenceLow
+	}
+}
+
+// IsTestPath tell if the path looks like a test, fixture or example file
+func IsTestPath(fpath string) bool {
+	return testPathPtn.MatchString(fpath)
+}
+
+// scoreConfidence adjust the confidence of a rule for a finding: a generic pattern with a high entropy value is
+// raised to medium, a finding in a test path is lowered by one level.
+func scoreConfidence(confidence, fpath string, generic bool, pairs []string) string {
+	if generic && confidence == ConfidenceLow {
+		for idx := 1; idx < len(pairs); idx += 2 {
+			if ag.CalculateEntropy(pairs[idx]) >= HighEntropy {
+				confidence = ConfidenceMedium
+				break
+			}
+		}
+	}
+	if IsTestPath(fpath) {
+		confidence = lowerLevel(confidence)
+	}
+	return confidence
+}
+
+// FailOn select the findings failing the scan: those at or above both levels. Empty levels select all.
+type FailOn struct {
+	Severity   string
+	Confidence string
+}
+
+// ParseFailOn parse a comma separated list of severity=<level> and confidence=<level>, eg 'severity=high' or
+// 'severity=medium,confidence=high'. The levels are low, medium and high.
+func ParseFailOn(spec string) (FailOn, error) {
+	f := FailOn{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, level, _ := strings.Cut(item, "=")
+		if _, ok := levels[level]; !ok {
+			return f, fmt.Errorf("invalid level %q in %q, expect low, medium or high", level, item)
+		}
+		switch key {
+		case "severity":
+			f.Severity = level
+		case "confidence":
+			f.Confidence = level
+		default:
+			return f, fmt.Errorf("invalid fail-on %q, expect severity=<level> or confidence=<level>", item)
+		}
+	}
+	return f, nil
+}
+
+// Match tell if the finding fails the scan
+func (f FailOn) Match(o OutputFmt) bool {
+	return (f.Severity == "" || LevelAtLeast(o.Severity, f.Severity)) && (f.Confidence == "" || LevelAtLeast(o.Confidence, f.Confidence))
+}
+
+// Failing return the number of findings failing the scan
+func (f FailOn) Failing(output ProjectOutputFmt) int {
+	n := 0
+	for _, matches := range output {
+		for _, o := range matches {
+			if f.Match(o) {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// SignaturePrefix start the detached signatures of the reports, the algorithm
+const SignaturePrefix = "hmac-sha256:"
+
+// ErrBadSignature is returned by VerifyReport when the report does not match its signature
+var ErrBadSignature = errors.New("the signature does not match, the report was modified or signed with another key")
+
+// ReportSigner compute the detached signature of a report as it is written, eg from an io.MultiWriter of the output
+type ReportSigner struct {
+	mac hash.Hash
+}
+
+// NewReportSigner create a signer keyed by key
+func NewReportSigner(key string) *ReportSigner {
+	return &ReportSigner{mac: hmac.New(sha256.New, []byte(key))}
+}
+
+func (rs *ReportSigner) Write(p []byte) (int, error) {
+	return rs.mac.Write(p)
+}
+
+// Signature return the signature of the data written so far, hmac-sha256: and the hex HMAC-SHA256 of the report
+func (rs *ReportSigner) Signature() string {
+	return SignaturePrefix + hex.EncodeToString(rs.mac.Sum(nil))
+}
+
+// SignReport return the detached signature of the report, see ReportSigner
+func SignReport(report []byte, key string) string {
+	rs := NewReportSigner(key)
+	rs.Write(report)
+	return rs.Signature()
+}
+
+// VerifyReport check the report against its detached signature with the key it was signed with. The signature may
+// end with a new line, like in a .sig file.
+func VerifyReport(report []byte, signature, key string) error {
+	signature = strings.TrimSpace(signature)
+	if !strings.HasPrefix(signature, SignaturePrefix) {
+		return fmt.Errorf("unknown signature algorithm, expect %s<hex>", SignaturePrefix)
+	}
+	if !hmac.Equal([]byte(signature), []byte(SignReport(report, key))) {
+		return ErrBadSignature
+	}
+	return nil
+}
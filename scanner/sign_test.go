@@ -0,0 +1,30 @@
+package scanner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignReport(t *testing.T) {
+	report := []byte(`{"app.conf": {}}` + "\n")
+	rs := NewReportSigner("k3y")
+	rs.Write(report[:5])
+	rs.Write(report[5:])
+	signature := rs.Signature()
+	if signature != SignReport(report, "k3y") || !strings.HasPrefix(signature, SignaturePrefix) {
+		t.Fatalf("expect the streamed and the one shot signatures equal, got %s and %s", signature, SignReport(report, "k3y"))
+	}
+	if err := VerifyReport(report, signature+"\n", "k3y"); err != nil {
+		t.Errorf("expect the report verified, got %v", err)
+	}
+	if err := VerifyReport([]byte(`{}`), signature, "k3y"); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("expect a modified report not verified, got %v", err)
+	}
+	if err := VerifyReport(report, signature, "other"); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("expect another key not verified, got %v", err)
+	}
+	if err := VerifyReport(report, "md5:abc", "k3y"); err == nil || errors.Is(err, ErrBadSignature) {
+		t.Errorf("expect an unknown algorithm error, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package scanner
+
+import (
+	"path"
+	"strings"
+)
+
+// sourceLang return go, python or js for the source files scanned with SourceAware, else an empty string. The
+// typescript files are read as js.
+func sourceLang(fpath string) string {
+	switch strings.ToLower(path.Ext(strings.ReplaceAll(fpath, `\`, "/"))) {
+	case ".go":
+		return "go"
+	case ".py":
+		return "python"
+	case ".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx":
+		return "js"
+	}
+	return ""
+}
+
+// sourceLexer split the lines of a source file into the string literals to scan, skipping the comments and the
+// code. A literal assigned to a name or a key, eg token := "..." or {"password": "..."}, is scanned as name="value"
+// so the generic patterns see the name; the other literals are scanned alone. It is a tokenizer, not a parser: the
+// js regexp literals and the expressions in the template strings are read as code and text.
+type sourceLexer struct {
+	lang    string
+	comment bool        // in a /* */ comment
+	str     *srcString  // the open string literal, nil if none
+	last    [2]srcToken // the last two tokens, to find name = "value"
+	prev    string      // the previous line
+}
+
+type srcToken struct {
+	kind byte // 'i' identifier, 's' string literal, 'o' operator, 'p' other punctuation or number
+	text string
+}
+
+// srcString is a string literal being read; a multi-line one is scanned line by line
+type srcString struct {
+	key       string // the name the literal is assigned to, until its first non blank line is sent
+	quote     string // the closing quote
+	raw       bool   // no escapes, the go raw strings
+	multiLine bool
+	text      strings.Builder // the content on the current line
+	value     string          // the content of the previous lines
+}
+
+// srcSegment is the text of a line to scan; raw is the line and prev the line before
+type srcSegment struct {
+	line      int
+	text      string
+	raw, prev string
+}
+
+// assignOps are the operators between a name and the literal assigned to it
+var assignOps = map[string]bool{"=": true, ":=": true, ":": true}
+
+// next return the segments of the line idx to scan
+func (l *sourceLexer) next(idx int, line string) []srcSegment {
+	segs := []srcSegment{}
+	emit := func(text string) {
+		segs = append(segs, srcSegment{line: idx, text: text, raw: line, prev: l.prev})
+	}
+	for i := 0; i < len(line); {
+		if l.comment {
+			end := strings.Index(line[i:], "*/")
+			if end < 0 {
+				break
+			}
+			i, l.comment = i+end+2, false
+			continue
+		}
+		if l.str != nil {
+			closed := false
+			if i, closed = l.readString(line, i); closed {
+				l.closeString(emit)
+			}
+			continue
+		}
+		c, rest := line[i], line[i:]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case l.lang == "python" && c == '#', l.lang != "python" && strings.HasPrefix(rest, "//"):
+			i = len(line)
+		case l.lang != "python" && strings.HasPrefix(rest, "/*"):
+			i, l.comment = i+2, true
+		case c == '"' || c == '\'' || c == '`':
+			i += l.openString(rest)
+		case isIdentByte(c) && !isDigit(c):
+			end := i + 1
+			for end < len(line) && isIdentByte(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			i = end
+			// the prefix of a python string, eg r"..." or f'...', is not a name
+			if l.lang == "python" && i < len(line) && (line[i] == '"' || line[i] == '\'') && len(word) <= 2 && strings.Trim(strings.ToLower(word), "rbuf") == "" {
+				continue
+			}
+			l.push(srcToken{kind: 'i', text: word})
+		case strings.IndexByte(opBytes, c) >= 0:
+			end := i + 1
+			for end < len(line) && strings.IndexByte(opBytes, line[end]) >= 0 && !strings.HasPrefix(line[end:], "//") && !strings.HasPrefix(line[end:], "/*") {
+				end++
+			}
+			l.push(srcToken{kind: 'o', text: line[i:end]})
+			i = end
+		default:
+			end := i + 1
+			for isDigit(c) && end < len(line) && (isIdentByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			l.push(srcToken{kind: 'p', text: line[i:end]})
+			i = end
+		}
+	}
+	if l.str != nil {
+		if l.str.multiLine {
+			l.sendLine(emit)
+			l.str.value += "\n"
+		} else {
+			l.closeString(emit) // unterminated, or continued with a backslash
+		}
+	}
+	l.prev = line
+	return segs
+}
+
+const opBytes = "=:!<>+-*/%&|^~?"
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// openString start the literal at the quote rest begins with, it returns the length of the opening quote
+func (l *sourceLexer) openString(rest string) int {
+	s := &srcString{quote: rest[:1]}
+	switch {
+	case l.lang == "python" && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)):
+		s.quote, s.multiLine = rest[:3], true
+	case rest[0] == '`' && l.lang == "go":
+		s.raw, s.multiLine = true, true
+	case rest[0] == '`' && l.lang == "js":
+		s.multiLine = true
+	}
+	if l.last[1].kind == 'o' && assignOps[l.last[1].text] && (l.last[0].kind == 'i' || l.last[0].kind == 's') {
+		s.key = l.last[0].text
+	}
+	l.str = s
+	return len(s.quote)
+}
+
+// readString read the open literal from line[i:], it returns where it stopped and if the literal is closed
+func (l *sourceLexer) readString(line string, i int) (int, bool) {
+	s := l.str
+	for i < len(line) {
+		if !s.raw && line[i] == '\\' && i+1 < len(line) {
+			s.text.WriteString(line[i : i+2])
+			i += 2
+			continue
+		}
+		if strings.HasPrefix(line[i:], s.quote) {
+			return i + len(s.quote), true
+		}
+		s.text.WriteByte(line[i])
+		i++
+	}
+	return i, false
+}
+
+// sendLine emit the content of the open literal on the current line
+func (l *sourceLexer) sendLine(emit func(string)) {
+	s := l.str
+	text := s.text.String()
+	s.text.Reset()
+	s.value += text
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	if s.key != "" {
+		text = s.key + `="` + text + `"`
+		s.key = ""
+	}
+	emit(text)
+}
+
+func (l *sourceLexer) closeString(emit func(string)) {
+	l.sendLine(emit)
+	l.push(srcToken{kind: 's', text: l.str.value})
+	l.str = nil
+}
+
+func (l *sourceLexer) push(t srcToken) {
+	l.last[0], l.last[1] = l.last[1], t
+}
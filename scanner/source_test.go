@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSourceAware(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go": "package main\n\n// password: Hk4jRt7yUq2wZ in a comment\nvar password = os.Getenv(\"PASSWORD\")\n\n" +
+			"func main() {\n\ttoken := \"Zx9kQ2mP4vLw8\"\n\tconnect(map[string]string{\"password\": \"Wq7rT5yUi3oP1\"})\n" +
+			"\tdsn := `postgres://u@h/db\npassword=Mn6bV4cXz2aS8`\n}\n",
+		"app.py":    "# secret: Pq8wE6rT4yUi2\ndef f(password=None):\n    return connect(password=\"Lk5jH3gFd1sQ9\", user='''\nadmin''')\n",
+		"notes.txt": "password: Hk4jRt7yUq2wZ\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Detectors = nil
+	cfg.SourceAware = true
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := func() map[string][]int {
+		got := map[string][]int{}
+		for file, matches := range Collect(s.Scan(context.Background(), dir)) {
+			for _, o := range matches {
+				got[filepath.Base(file)] = o.Line_no
+			}
+		}
+		return got
+	}
+	want := map[string][]int{"main.go": {6, 7, 9}, "app.py": {2}, "notes.txt": {0}}
+	if got := lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expect findings at %v, got %v", want, got)
+	}
+	cfg.SourceAware = false
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string][]int{"main.go": {2, 7, 9}, "app.py": {0, 1, 2}, "notes.txt": {0}}
+	if got := lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expect findings at %v without SourceAware, got %v", want, got)
+	}
+}
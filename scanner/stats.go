@@ -0,0 +1,213 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The reasons a file seen by a scan is not matched, the keys of Stats.FilesSkipped
+const (
+	SkipExcluded   = "excluded"    // by the exclude patterns, or the profile or cache file
+	SkipIgnored    = "ignored"     // by a .credignore file
+	SkipFilename   = "filename"    // not matching the file name pattern
+	SkipBinary     = "binary"      // a binary file, see Config.SkipBinary
+	SkipTooLarge   = "too_large"   // larger than Config.MaxFileSize
+	SkipMinified   = "minified"    // a minified js file
+	SkipUnreadable = "unreadable"  // can not be read
+	SkipTooDeep    = "too_deep"    // a nested archive too deep
+	SkipNotRegular = "not_regular" // a fifo, socket or device
+	SkipSymlink    = "symlink"     // a symlink not followed, or broken
+	SkipDuplicate  = "duplicate"   // a symlink to a file or directory scanned already, or a loop
+	SkipVault      = "vault"       // an ansible vault encrypted file no vault password decrypts
+)
+
+// resetStats start the stats of a scan
+func (s *Scanner) resetStats() {
+	s.filesScanned.Store(0)
+	s.filesProcessed.Store(0)
+	s.filesCached.Store(0)
+	s.filesPending.Store(0)
+	s.bytesProcessed.Store(0)
+	s.mu.Lock()
+	s.skipped = map[string]int64{}
+	s.charts = nil
+	s.vaults = nil
+	s.profileSeen = map[string]bool{}
+	s.timings = nil
+	if s.cfg.ProfileRules {
+		s.timings = &timings{}
+	}
+	s.started, s.finished = time.Now(), time.Time{}
+	s.mu.Unlock()
+}
+
+// finishStats record the end of a scan
+func (s *Scanner) finishStats() {
+	s.mu.Lock()
+	s.finished = time.Now()
+	s.mu.Unlock()
+}
+
+// Progress return the number of files of the running scan walked and done with, to compare with CountFiles. The
+// files of the archives count too.
+func (s *Scanner) Progress() int64 {
+	return max(s.filesScanned.Load()-s.filesPending.Load(), 0)
+}
+
+// CountFiles count the files a scan of the root walks, for a progress bar. The excluded directories and the ones of
+// the .credignore of the root are not walked, like in Scan.
+func (s *Scanner) CountFiles(ctx context.Context, root string) int64 {
+	ignore := s.newCredIgnore(root)
+	var n int64
+	filepath.WalkDir(root, func(fpath string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			n++
+			return nil
+		}
+		if fpath == root {
+			return nil
+		}
+		if s.isExcludedName(d.Name()) {
+			return filepath.SkipDir
+		}
+		if rel, err := filepath.Rel(root, fpath); err == nil && ignore != nil && ignore.match(filepath.ToSlash(rel), true) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return n
+}
+
+// skip count a file not matched for the reason
+func (s *Scanner) skip(reason string) {
+	s.mu.Lock()
+	if s.skipped == nil {
+		s.skipped = map[string]int64{}
+	}
+	s.skipped[reason]++
+	s.mu.Unlock()
+}
+
+// countingReader add the bytes read to n
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// CountFindings set the findings of the stats: their number and their number by severity
+func (st *Stats) CountFindings(findings []OutputFmt) {
+	st.Findings = len(findings)
+	st.FindingsBySeverity = map[string]int{}
+	for _, o := range findings {
+		st.FindingsBySeverity[firstNonEmpty(o.Severity, SeverityMedium)]++
+	}
+}
+
+// List return the findings of all files, sorted by file then line
+func (output ProjectOutputFmt) List() []OutputFmt {
+	list := []OutputFmt{}
+	for _, matches := range output {
+		for _, o := range matches {
+			list = append(list, o)
+		}
+	}
+	sortFindings(list)
+	return list
+}
+
+// WritePrometheus write the stats in the Prometheus text format, eg for the textfile collector of the node exporter.
+// The labels are added to every metric.
+func (st Stats) WritePrometheus(w io.Writer, labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	common := []string{}
+	for _, k := range keys {
+		common = append(common, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	var b strings.Builder
+	metric := func(name, help string, values map[string]float64, label string) {
+		fmt.Fprintf(&b, "# HELP cred_detect_%s %s\n# TYPE cred_detect_%s gauge\n", name, help, name)
+		names := make([]string, 0, len(values))
+		for k := range values {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			l := common
+			if label != "" {
+				l = append(append([]string{}, common...), fmt.Sprintf("%s=%q", label, k))
+			}
+			set := ""
+			if len(l) > 0 {
+				set = "{" + strings.Join(l, ",") + "}"
+			}
+			fmt.Fprintf(&b, "cred_detect_%s%s %v\n", name, set, values[k])
+		}
+	}
+	one := func(v float64) map[string]float64 { return map[string]float64{"": v} }
+	byKey := func(m map[string]int64) map[string]float64 {
+		values := map[string]float64{}
+		for k, v := range m {
+			values[k] = float64(v)
+		}
+		return values
+	}
+	severities := map[string]int64{SeverityHigh: 0, SeverityMedium: 0, SeverityLow: 0}
+	for k, v := range st.FindingsBySeverity {
+		severities[k] = int64(v)
+	}
+	metric("files_scanned", "Files seen by the last scan.", one(float64(st.FilesScanned)), "")
+	metric("files_processed", "Files matched against the rules by the last scan.", one(float64(st.FilesProcessed)), "")
+	metric("files_cached", "Files of the last scan unchanged since the scan before, their findings come from the cache.", one(float64(st.FilesCached)), "")
+	metric("files_skipped", "Files of the last scan not matched, by reason.", byKey(st.FilesSkipped), "reason")
+	metric("bytes_processed", "Bytes read from the files matched by the last scan.", one(float64(st.BytesProcessed)), "")
+	metric("findings", "Findings of the last scan, by severity.", byKey(severities), "severity")
+	metric("scan_duration_seconds", "Duration of the last scan.", one(st.DurationSeconds), "")
+	metric("last_scan_timestamp_seconds", "Unix time of the end of the last scan.", one(float64(time.Now().Unix())), "")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMetricsFile write the stats in the Prometheus text format to fpath. The file is replaced at once so a collector
+// never reads a partial file.
+func (st Stats) WriteMetricsFile(fpath string, labels map[string]string) error {
+	f, err := os.CreateTemp(filepath.Dir(fpath), "."+filepath.Base(fpath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := st.WritePrometheus(f, labels); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fpath)
+}
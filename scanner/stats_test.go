@@ -0,0 +1,76 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.conf":   "password=\"Xk9dLq2ZmP7wR4\"\n",
+		"skip.log":   "password=\"Xk9dLq2ZmP7wR4\"\n",
+		"image.conf": "\x00\x01\x02",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Exclude = `\.log$`
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := Collect(s.Scan(context.Background(), dir))
+	st := s.Stats()
+	st.CountFindings(output.List())
+	if st.FilesScanned != 3 || st.FilesProcessed != 1 || st.BytesProcessed != 26 || st.DurationSeconds <= 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if !reflect.DeepEqual(st.FilesSkipped, map[string]int64{SkipExcluded: 1, SkipBinary: 1}) {
+		t.Errorf("unexpected skipped files %v", st.FilesSkipped)
+	}
+	if st.Findings != 1 || st.FindingsBySeverity[SeverityMedium] != 1 {
+		t.Errorf("unexpected findings %d %v", st.Findings, st.FindingsBySeverity)
+	}
+	metrics := filepath.Join(t.TempDir(), "cred-detect.prom")
+	if err := st.WriteMetricsFile(metrics, map[string]string{"root": "/src"}); err != nil {
+		t.Fatal(err)
+	}
+	datab, _ := os.ReadFile(metrics)
+	for _, want := range []string{
+		"# TYPE cred_detect_files_scanned gauge\ncred_detect_files_scanned{root=\"/src\"} 3\n",
+		"cred_detect_files_skipped{root=\"/src\",reason=\"binary\"} 1\n",
+		"cred_detect_findings{root=\"/src\",severity=\"high\"} 0\n",
+		"cred_detect_bytes_processed{root=\"/src\"} 26\n",
+	} {
+		if !strings.Contains(string(datab), want) {
+			t.Errorf("expect %q in the metrics, got\n%s", want, datab)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.conf":      "password='Zx9cVb7nMq2wE'\n",
+		"b.conf":      "name=app\n",
+		"sub/c.conf":  "token='Qw8eRt5yUi3oP'\n",
+		".git/config": "password='Zx9cVb7nMq2wE'\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.CountFiles(context.Background(), dir); n != 3 {
+		t.Errorf("expect 3 files to scan, got %d", n)
+	}
+	Collect(s.Scan(context.Background(), dir))
+	if done := s.Progress(); done != 3 || done != s.Stats().FilesScanned {
+		t.Errorf("expect the progress at the files scanned, got %d of %d", done, s.Stats().FilesScanned)
+	}
+}
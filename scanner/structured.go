@@ -0,0 +1,304 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"runtime/debug"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	ag "github.com/sunshine69/automation-go/lib"
+	"gopkg.in/yaml.v3"
+)
+
+// StructuredRuleID is the rule of the values found under a suspicious key by the structured scan, see
+// Config.Structured
+const StructuredRuleID = "structured-secret"
+
+// SuspiciousKeyPattern match the keys whose values are checked by the structured scan
+var SuspiciousKeyPattern = regexp.MustCompile(`(?i)(passw(or)?d|pwd|secret|token|credential|key)$|^(password|passwd|secret|token)`)
+
+// maxStructuredSize bound the size of a file parsed by the structured scan, larger files only get the line scan
+const maxStructuredSize = 16 * 1024 * 1024
+
+// structuredValue is a value under a suspicious key; line is 0 based
+type structuredValue struct {
+	line       int
+	key, value string
+}
+
+// structuredKind return yaml, toml or env for the files the structured scan parses, else an empty string. The json
+// files are parsed as yaml, which keeps the line numbers.
+func structuredKind(fpath string) string {
+	name := strings.ToLower(path.Base(strings.ReplaceAll(fpath, `\`, "/")))
+	switch {
+	case strings.HasSuffix(name, ".yaml"), strings.HasSuffix(name, ".yml"), strings.HasSuffix(name, ".json"):
+		return "yaml"
+	case strings.HasSuffix(name, ".toml"):
+		return "toml"
+	case name == ".env", strings.HasPrefix(name, ".env."), strings.HasSuffix(name, ".env"):
+		return "env"
+	}
+	return ""
+}
+
+// structuredValues return the scalar values under the suspicious keys of the document
+func structuredValues(kind string, data []byte) ([]structuredValue, error) {
+	switch kind {
+	case "yaml":
+		return yamlValues(data)
+	case "toml":
+		return tomlValues(data)
+	case "env":
+		return envValues(data), nil
+	}
+	return nil, nil
+}
+
+func yamlValues(data []byte) ([]structuredValue, error) {
+	values := []structuredValue{}
+	var walk func(n *yaml.Node, key string, suspicious bool)
+	walk = func(n *yaml.Node, key string, suspicious bool) {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, c := range n.Content {
+				walk(c, key, suspicious)
+			}
+		case yaml.MappingNode:
+			for idx := 0; idx+1 < len(n.Content); idx += 2 {
+				k := n.Content[idx].Value
+				walk(n.Content[idx+1], k, SuspiciousKeyPattern.MatchString(k))
+			}
+		case yaml.ScalarNode:
+			if suspicious && n.Tag != "!!bool" && n.Tag != "!!null" {
+				values = append(values, structuredValue{line: n.Line - 1, key: key, value: n.Value})
+			}
+		}
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		doc := yaml.Node{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		walk(&doc, "", false)
+	}
+}
+
+func tomlValues(data []byte) ([]structuredValue, error) {
+	values := []structuredValue{}
+	p := unstable.Parser{}
+	p.Reset(data)
+	var walk func(n *unstable.Node, key string, suspicious bool)
+	walk = func(n *unstable.Node, key string, suspicious bool) {
+		switch n.Kind {
+		case unstable.KeyValue:
+			it := n.Key()
+			for it.Next() {
+				key = string(it.Node().Data)
+			}
+			walk(n.Value(), key, SuspiciousKeyPattern.MatchString(key))
+		case unstable.Array, unstable.InlineTable:
+			it := n.Children()
+			for it.Next() {
+				walk(it.Node(), key, suspicious)
+			}
+		case unstable.String, unstable.Integer:
+			if suspicious {
+				values = append(values, structuredValue{line: p.Shape(n.Raw).Start.Line - 1, key: key, value: string(n.Data)})
+			}
+		}
+	}
+	for p.NextExpression() {
+		if e := p.Expression(); e.Kind == unstable.KeyValue {
+			walk(e, "", false)
+		}
+	}
+	return values, p.Error()
+}
+
+// envValues parse the KEY=value lines of a .env file; a quoted value may span lines
+func envValues(data []byte) []structuredValue {
+	values := []structuredValue{}
+	lines := strings.Split(string(data), "\n")
+	for idx := 0; idx < len(lines); idx++ {
+		line := strings.TrimSpace(strings.TrimSuffix(lines[idx], "\r"))
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		start := idx
+		value = strings.TrimSpace(value)
+		if q := value; len(q) > 0 && (q[0] == '"' || q[0] == '\'') {
+			quote, rest := q[0], q[1:]
+			for !strings.Contains(rest, string(quote)) && idx+1 < len(lines) {
+				idx++
+				rest += "\n" + strings.TrimSuffix(lines[idx], "\r")
+			}
+			value, _, _ = strings.Cut(rest, string(quote))
+		} else if before, _, found := strings.Cut(value, " #"); found {
+			value = strings.TrimSpace(before)
+		}
+		if SuspiciousKeyPattern.MatchString(key) {
+			values = append(values, structuredValue{line: start, key: key, value: value})
+		}
+	}
+	return values
+}
+
+// cappedBuffer keep the first max bytes written to it, overflow tells if there were more
+type cappedBuffer struct {
+	bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow || b.Len()+len(p) > b.max {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// matchAll match the lines of the reader and, with Structured, the key value tree of the yaml, json, toml and .env
+// files, with Kubernetes the kubernetes manifests and helm templates. It returns false if the context is done or the read failed.
+func (m *fileMatcher) matchAll(r io.Reader) bool {
+	r = countingReader{r, &m.s.bytesProcessed}
+	if kind, kube := m.treeKinds(); kind == "" && !kube {
+		return m.matchReader(bufio.NewReaderSize(r, 64*1024))
+	}
+	buf := &cappedBuffer{max: maxStructuredSize}
+	if !m.matchReader(bufio.NewReaderSize(io.TeeReader(r, buf), 64*1024)) {
+		return false
+	}
+	return m.matchTree(buf.Bytes(), buf.overflow)
+}
+
+// matchMapped match the memory mapped content of the file like matchAll: the lines are copied one at a time and the
+// structured scan parses the mapping, the file is not copied in memory. Call it from readMapped.
+func (m *fileMatcher) matchMapped(data []byte) bool {
+	m.s.bytesProcessed.Add(int64(len(data)))
+	if !m.matchLines(data) {
+		return false
+	}
+	return m.matchTree(data, len(data) > maxStructuredSize)
+}
+
+// readMapped call read, reading a memory mapped file. A file truncated while it is read faults; it is logged and
+// false returned instead of crashing the scan.
+func (s *Scanner) readMapped(fpath string, read func() bool) (ok bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, fault := r.(interface{ Addr() uintptr }); !fault {
+				panic(r)
+			}
+			s.Logger().Warn("can not read file, it changed while read", "path", fpath, "error", r)
+			ok = false
+		}
+	}()
+	return read()
+}
+
+// treeKinds return the structured kind of the file if it is parsed, see structuredKind, and whether it is a yaml
+// scanned for the kubernetes manifests
+func (m *fileMatcher) treeKinds() (kind string, kube bool) {
+	if m.s.cfg.Structured {
+		kind = structuredKind(m.fpath)
+	}
+	return kind, m.s.cfg.Kubernetes && structuredKind(m.fpath) == "yaml"
+}
+
+// matchTree run the kubernetes and the structured scans on the content of the file, after its lines. Too large a
+// content, overflow, only gets the line scan. It returns false if the context is done.
+func (m *fileMatcher) matchTree(data []byte, overflow bool) bool {
+	kind, kube := m.treeKinds()
+	if kind == "" && !kube {
+		return true
+	}
+	if overflow {
+		m.s.Logger().Info("skip structured scan, file too large", "path", m.fpath)
+		return true
+	}
+	if kube && !m.matchKubernetes(data) {
+		return false
+	}
+	if kind == "" {
+		return true
+	}
+	return m.matchStructured(kind, data)
+}
+
+// matchStructured report the values under the suspicious keys of the parsed file that look like a password and
+// were not found by the line patterns on the same line. A file that does not parse only gets the line scan. It
+// returns false if the context is done.
+func (m *fileMatcher) matchStructured(kind string, data []byte) bool {
+	s, fpath := m.s, m.fpath
+	values, err := structuredValues(kind, data)
+	if err != nil {
+		s.Logger().Debug("can not parse, line scan only", "path", fpath, "error", err)
+	}
+	if len(values) == 0 {
+		return true
+	}
+	threshold, ok := s.cfg.RuleEntropy[StructuredRuleID]
+	if !ok {
+		threshold = s.cfg.EntropyThreshold
+	}
+	lines := strings.Split(string(data), "\n")
+	var o *OutputFmt
+	for _, v := range values {
+		value := strings.TrimSpace(v.value)
+		literal := strings.TrimSpace(literalText(value))
+		if m.hitLines[v.line] || v.line < 0 || v.line >= len(lines) || isVault(value) || s.isPlaceholder(literal) ||
+			!ag.IsLikelyPasswordOrToken(literal, s.cfg.CheckMode, s.cfg.WordsFile, 4, threshold) {
+			continue
+		}
+		line, prev := strings.TrimSuffix(lines[v.line], "\r"), ""
+		if v.line > 0 {
+			prev = lines[v.line-1]
+		}
+		pairs := []string{v.key, value}
+		confidence := scoreConfidence(ConfidenceMedium, fpath, false, pairs)
+		fingerprint := Fingerprint(fpath, StructuredRuleID, value, line)
+		if suppressedBy(line, prev) {
+			m.addSuppressed(OutputFmt{File: fpath, Line_no: []int{v.line}, Matches: pairs, RuleID: StructuredRuleID, Severity: SeverityMedium,
+				Confidence: confidence, Fingerprint: fingerprint})
+			continue
+		}
+		if m.inProfile("", fingerprint) {
+			s.Logger().Info("fingerprints exist in profile, skipping", "path", fpath, "line", v.line)
+			continue
+		}
+		if o == nil {
+			o = &OutputFmt{File: fpath, Line_no: []int{}, Matches: []string{}, RuleID: StructuredRuleID, Severity: SeverityMedium, Fingerprint: fingerprint,
+				SecretID: s.secretID(value), Remediation: GenericRemediation}
+		}
+		o.Confidence = maxLevel(o.Confidence, confidence)
+		o.Line_no = append(o.Line_no, v.line)
+		o.Matches = append(o.Matches, pairs...)
+		if m.inProfile(o.Matches[0]+o.Matches[1], "") {
+			s.Logger().Info("matches exist in profile, skipping", "path", fpath, "signature", o.Matches[0]+o.Matches[1])
+			continue
+		}
+		s.redact(o.Matches)
+		found := *o
+		found.Line_no = append([]int{}, o.Line_no...)
+		found.Matches = append([]string{}, o.Matches...)
+		if !m.send(StructuredRuleID, found) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStructured(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		// the value is on the next line, the line patterns miss it
+		"app.json": "{\n  \"auth\": {\n    \"clientSecret\":\n      \"Zx3cVb6nMq9wE\",\n    \"apiKey\": \"${API_KEY}\"\n  },\n  \"title\": \"Zx3cVb6nMq9wE\"\n}\n",
+		"app.toml": "[server]\nname = \"Qw8eRt5yUi3oP\"\nsigning_key = \"\"\"\nMn7bVc4xZa1sD\"\"\"\n",
+		"app.yaml": "db:\n  user: Pq8wEr5tYu2iO\n  creds: [{password: Lk5jHg2fDs9aQ}]\n  # cred-detect:ignore\n  token: Hj4kLm7nBv2cX\n",
+		".env":     "export DB_PASS='Rt6yUi9oPa3sD'\nDB_PASSWORD=\"Gh2jKl5zXc8vB\n\"\nNAME=Qw8eRt5yUi3oP\n",
+		"bad.yaml": "a: [\n  password Xk9dLq2ZmP7wR4\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Patterns = nil // only the structured scan
+	cfg.Detectors = nil
+	cfg.Structured = true
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]int{}
+	for file, matches := range Collect(s.Scan(context.Background(), dir)) {
+		for _, o := range matches {
+			if o.RuleID != StructuredRuleID || o.Matches[1] != "*****" {
+				t.Errorf("expect masked %s findings, got %+v", StructuredRuleID, o)
+			}
+			got[filepath.Base(file)] = o.Line_no
+		}
+	}
+	want := map[string][]int{"app.json": {3}, "app.toml": {2}, "app.yaml": {2}, ".env": {1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expect findings at %v, got %v", want, got)
+	}
+	if len(s.Suppressed()) != 1 {
+		t.Errorf("expect the ignored token suppressed, got %v", s.Suppressed())
+	}
+	cfg.Structured = false
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if output := Collect(s.Scan(context.Background(), dir)); len(output) != 0 {
+		t.Errorf("expect no finding without Structured, got %v", output)
+	}
+}
@@ -0,0 +1,262 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OrgRepo is a repository of a sweep, see ListOrgRepos
+type OrgRepo struct {
+	Name     string
+	URL      string // without credentials
+	cloneURL string // with the token of the api, if any
+}
+
+// RepoResult is the result of the scan of a repository by Sweep. The files are relative to the repository.
+type RepoResult struct {
+	Repo     string
+	Error    string `json:",omitempty"` // the clone or the scan failed
+	Stats    Stats
+	Failing  int // the findings matching SweepOpt.FailOn
+	Findings []OutputFmt
+}
+
+// SweepReport is the consolidated report of a sweep
+type SweepReport struct {
+	Repos    []RepoResult
+	Scanned  int // the repositories scanned
+	Errors   int // the repositories not cloned or not scanned
+	Findings int
+	Failing  int
+}
+
+// NewSweepReport sum the results of a sweep
+func NewSweepReport(results []RepoResult) SweepReport {
+	report := SweepReport{Repos: results}
+	for _, res := range results {
+		if res.Error != "" {
+			report.Errors++
+			continue
+		}
+		report.Scanned++
+		report.Findings += len(res.Findings)
+		report.Failing += res.Failing
+	}
+	return report
+}
+
+// SweepOpt is the options of Sweep
+type SweepOpt struct {
+	CloneOpt
+	Concurrency int    // the repositories cloned and scanned at a time, default 4
+	FailOn      FailOn // see RepoResult.Failing
+}
+
+// ListOrgRepos return the repositories of a source: github:<org or user> and gitlab:<group> list them with the api
+// (GITHUB_TOKEN and GITLAB_TOKEN for the private ones, GITHUB_API_URL and GITLAB_URL for a self-hosted server), else
+// the source is a file with one url per line, # for comments.
+func ListOrgRepos(ctx context.Context, source string) ([]OrgRepo, error) {
+	kind, name, found := strings.Cut(source, ":")
+	if found && (kind == "github" || kind == "gitlab") && !strings.HasPrefix(name, "//") {
+		if kind == "github" {
+			return listGithubRepos(ctx, name)
+		}
+		return listGitlabRepos(ctx, name)
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	repos := []OrgRepo{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		repos = append(repos, OrgRepo{Name: repoName(line), URL: line, cloneURL: line})
+	}
+	return repos, sc.Err()
+}
+
+// repoName return the name of a repository from its url, eg org/repo for https://github.com/org/repo.git
+func repoName(repoURL string) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git")
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" {
+		return strings.TrimPrefix(u.Path, "/")
+	}
+	if _, after, found := strings.Cut(name, ":"); found { // scp like
+		return after
+	}
+	return filepath.Base(name)
+}
+
+// withToken add the token to an https clone url, as the user for GitHub or oauth2 for GitLab
+func withToken(cloneURL, user, token string) string {
+	u, err := url.Parse(cloneURL)
+	if token == "" || err != nil || u.Scheme != "https" {
+		return cloneURL
+	}
+	u.User = url.UserPassword(user, token)
+	return u.String()
+}
+
+// getPages get the json list pages of an api until an empty page, decoding each item with add
+func getPages(ctx context.Context, pageURL func(page int) string, header http.Header, add func(json.RawMessage) error) error {
+	client := &http.Client{Timeout: 60 * time.Second}
+	for page := 1; ; page++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", pageURL(page), nil)
+		if err != nil {
+			return err
+		}
+		req.Header = header
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		items := []json.RawMessage{}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("GET %s returned %s", req.URL.Redacted(), resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&items)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for _, item := range items {
+			if err := add(item); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func listGithubRepos(ctx context.Context, org string) ([]OrgRepo, error) {
+	api := strings.TrimSuffix(firstNonEmpty(os.Getenv("GITHUB_API_URL"), "https://api.github.com"), "/")
+	token := os.Getenv("GITHUB_TOKEN")
+	header := http.Header{"Accept": {"application/vnd.github+json"}}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	repos := []OrgRepo{}
+	add := func(item json.RawMessage) error {
+		r := struct {
+			FullName string `json:"full_name"`
+			CloneURL string `json:"clone_url"`
+		}{}
+		if err := json.Unmarshal(item, &r); err != nil {
+			return err
+		}
+		repos = append(repos, OrgRepo{Name: r.FullName, URL: r.CloneURL, cloneURL: withToken(r.CloneURL, "x-access-token", token)})
+		return nil
+	}
+	err := getPages(ctx, func(page int) string {
+		return fmt.Sprintf("%s/orgs/%s/repos?per_page=100&page=%d", api, url.PathEscape(org), page)
+	}, header, add)
+	if err != nil && strings.Contains(err.Error(), "404") { // a user, not an org
+		err = getPages(ctx, func(page int) string {
+			return fmt.Sprintf("%s/users/%s/repos?per_page=100&page=%d", api, url.PathEscape(org), page)
+		}, header, add)
+	}
+	return repos, err
+}
+
+func listGitlabRepos(ctx context.Context, group string) ([]OrgRepo, error) {
+	api := strings.TrimSuffix(firstNonEmpty(os.Getenv("GITLAB_URL"), "https://gitlab.com"), "/") + "/api/v4"
+	token := os.Getenv("GITLAB_TOKEN")
+	header := http.Header{}
+	if token != "" {
+		header.Set("PRIVATE-TOKEN", token)
+	}
+	repos := []OrgRepo{}
+	err := getPages(ctx, func(page int) string {
+		return fmt.Sprintf("%s/groups/%s/projects?include_subgroups=true&archived=false&per_page=100&page=%d", api, url.PathEscape(group), page)
+	}, header, func(item json.RawMessage) error {
+		p := struct {
+			Path     string `json:"path_with_namespace"`
+			CloneURL string `json:"http_url_to_repo"`
+		}{}
+		if err := json.Unmarshal(item, &p); err != nil {
+			return err
+		}
+		repos = append(repos, OrgRepo{Name: p.Path, URL: p.CloneURL, cloneURL: withToken(p.CloneURL, "oauth2", token)})
+		return nil
+	})
+	return repos, err
+}
+
+// Sweep clone and scan the repositories, Concurrency at a time, each with a scanner of the config. The profile and the
+// cache of the config are not used. The results are in the order of the repositories; done is called after each, eg
+// to log the progress.
+func Sweep(ctx context.Context, cfg Config, repos []OrgRepo, opt SweepOpt, done func(RepoResult)) []RepoResult {
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 4
+	}
+	cfg.ProfilePath, cfg.CachePath = "", ""
+	results := make([]RepoResult, len(repos))
+	for idx, repo := range repos { // the ones left when the context is done
+		results[idx] = RepoResult{Repo: repo.Name, Error: "not scanned, the sweep was cancelled", Findings: []OutputFmt{}}
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for range opt.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] = sweepRepo(ctx, cfg, repos[idx], opt)
+				if done != nil {
+					mu.Lock()
+					done(results[idx])
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for idx := range repos {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// sweepRepo clone and scan a repository
+func sweepRepo(ctx context.Context, cfg Config, repo OrgRepo, opt SweepOpt) RepoResult {
+	res := RepoResult{Repo: repo.Name, Findings: []OutputFmt{}}
+	fail := func(err error) RepoResult {
+		res.Error = err.Error() // CloneRepo redacts the token of the url
+		return res
+	}
+	s, err := New(cfg)
+	if err != nil {
+		return fail(err)
+	}
+	output, err := s.ScanRepo(ctx, firstNonEmpty(repo.cloneURL, repo.URL), opt.CloneOpt)
+	if err != nil {
+		return fail(err)
+	}
+	res.Findings = output.List()
+	res.Failing = opt.FailOn.Failing(output)
+	res.Stats = s.Stats()
+	res.Stats.CountFindings(res.Findings)
+	return res
+}
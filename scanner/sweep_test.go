@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSweep(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFiles(t, dir, map[string]string{"app.conf": "password=\"Xk9dLq2ZmP7wR4\"\n"})
+	git("add", "-A")
+	git("commit", "-q", "-m", "add config")
+	repoURL := "file://" + filepath.ToSlash(dir)
+	missing := "file://" + filepath.ToSlash(filepath.Join(dir, "nothing"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != "/orgs/acme/repos":
+			http.NotFound(w, r)
+		case r.URL.Query().Get("page") == "1":
+			fmt.Fprintf(w, `[{"full_name": "acme/app", "clone_url": %q}, {"full_name": "acme/gone", "clone_url": %q}]`, repoURL, missing)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	repos, err := ListOrgRepos(context.Background(), "github:acme")
+	if err != nil || len(repos) != 2 || repos[0].Name != "acme/app" {
+		t.Fatalf("expect the repos of the org, got %+v %v", repos, err)
+	}
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	failOn, _ := ParseFailOn("severity=medium")
+	report := NewSweepReport(Sweep(context.Background(), cfg, repos, SweepOpt{CloneOpt: CloneOpt{Depth: 1}, Concurrency: 2, FailOn: failOn}, nil))
+	if report.Scanned != 1 || report.Errors != 1 || report.Failing != 1 || report.Repos[1].Error == "" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if f := report.Repos[0].Findings; len(f) != 1 || f[0].File != "app.conf" {
+		t.Errorf("expect the finding relative to the repo, got %+v", f)
+	}
+	list := filepath.Join(t.TempDir(), "repos.txt")
+	if err := os.WriteFile(list, []byte("# repos\n"+repoURL+"\n\ngit@github.com:acme/other.git\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if repos, err := ListOrgRepos(context.Background(), list); err != nil || len(repos) != 2 || repos[1].Name != "acme/other" {
+		t.Errorf("expect the repos of the file, got %+v %v", repos, err)
+	}
+}
@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// symlinkWalker follow the symlinks met by the walker of a scan, see Config.FollowSymlinks
+type symlinkWalker struct {
+	mu    sync.Mutex
+	roots []string        // the real paths of the trees walked: the scan root and the followed directories
+	files map[string]bool // the real paths of the followed files outside the roots
+}
+
+func newSymlinkWalker(root string) *symlinkWalker {
+	w := &symlinkWalker{files: map[string]bool{}}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		w.roots = append(w.roots, real)
+	}
+	return w
+}
+
+// walked return true if the real path is in a tree already walked, or has one, or is a followed file
+func (w *symlinkWalker) walked(real string) bool {
+	sep := string(filepath.Separator)
+	for _, root := range w.roots {
+		if real == root || strings.HasPrefix(real, root+sep) || strings.HasPrefix(root, strings.TrimSuffix(real, sep)+sep) {
+			return true
+		}
+	}
+	return w.files[real]
+}
+
+// claim record the real path as walked and return true, or return false if it is walked already. The walker is
+// concurrent, of two links to the same target the first claiming it is followed.
+func (w *symlinkWalker) claim(real string, isDir bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.walked(real) {
+		return false
+	}
+	if isDir {
+		w.roots = append(w.roots, real)
+	} else {
+		w.files[real] = true
+	}
+	return true
+}
+
+// followSymlink visit the target of the symlink fpath as if it was at fpath: the files of a directory are walked
+// with their paths under fpath. A target in a tree already walked, or having one, is skipped: it is scanned already
+// or it is a loop, eg a link to a parent directory. Without FollowSymlinks the symlinks are skipped, except the scan root.
+func (s *Scanner) followSymlink(fpath string, isRoot bool, w *symlinkWalker, visit filepath.WalkFunc) error {
+	if !s.cfg.FollowSymlinks && !isRoot {
+		s.Logger().Info("skip symlink", "path", fpath)
+		s.filesScanned.Add(1)
+		s.skip(SkipSymlink)
+		return nil
+	}
+	real, err := filepath.EvalSymlinks(fpath)
+	if err != nil {
+		s.Logger().Warn("skip broken symlink", "path", fpath, "error", err)
+		s.filesScanned.Add(1)
+		s.skip(SkipSymlink)
+		return nil
+	}
+	info, err := os.Stat(fpath)
+	if err != nil {
+		return visit(fpath, nil, err)
+	}
+	if !w.claim(real, info.IsDir()) && !isRoot {
+		s.Logger().Info("skip symlink, its target is scanned already", "path", fpath, "target", real)
+		if !info.IsDir() {
+			s.filesScanned.Add(1)
+		}
+		s.skip(SkipDuplicate)
+		return nil
+	}
+	if !info.IsDir() {
+		return visit(fpath, info, nil)
+	}
+	return walkTree(real, WalkConcurrency, func(p string, info fs.FileInfo, err error) error {
+		rel, relErr := filepath.Rel(real, p)
+		if relErr != nil {
+			return relErr
+		}
+		return visit(filepath.Join(fpath, rel), info, err)
+	})
+}
@@ -0,0 +1,67 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFollowSymlinks(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/app.conf": "password=\"Xk9dLq2ZmP7wR4\"\n"})
+	writeFiles(t, outside, map[string]string{"shared/db.conf": "password=\"Qw8rTy5UiO3pAs\"\n"})
+	for link, target := range map[string]string{
+		"src/loop":      dir,                                // a parent directory
+		"src/copy.conf": filepath.Join(dir, "src/app.conf"), // a file of the tree
+		"shared":        filepath.Join(outside, "shared"),
+		"shared2":       filepath.Join(outside, "shared"), // the same target twice
+		"broken":        filepath.Join(dir, "nothing"),
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skip("symlinks not supported: ", err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := func() []string {
+		o := []string{}
+		for file := range Collect(s.Scan(context.Background(), dir)) {
+			rel, _ := filepath.Rel(dir, file)
+			o = append(o, filepath.ToSlash(rel))
+		}
+		sort.Strings(o)
+		return o
+	}
+	if got := files(); !reflect.DeepEqual(got, []string{"src/app.conf"}) {
+		t.Errorf("expect the symlinks skipped, got %v", got)
+	}
+	if st := s.Stats(); st.FilesSkipped[SkipSymlink] != 5 {
+		t.Errorf("expect 5 symlinks skipped, got %v", st.FilesSkipped)
+	}
+	cfg.FollowSymlinks = true
+	if err := s.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	got := files()
+	if len(got) != 2 || got[1] != "src/app.conf" || (got[0] != "shared/db.conf" && got[0] != "shared2/db.conf") {
+		t.Errorf("expect the outside files once and no loop, got %v", got)
+	}
+	if st := s.Stats(); st.FilesSkipped[SkipDuplicate] != 3 || st.FilesSkipped[SkipSymlink] != 1 {
+		t.Errorf("expect 3 duplicates and a broken link, got %v", st.FilesSkipped)
+	}
+	// the root itself may be a link
+	root := filepath.Join(t.TempDir(), "root")
+	if err := os.Symlink(filepath.Join(dir, "src"), root); err != nil {
+		t.Fatal(err)
+	}
+	if output := Collect(s.Scan(context.Background(), root)); len(output) != 1 {
+		t.Errorf("expect the findings of a symlinked root once, got %v", output)
+	}
+}
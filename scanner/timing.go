@@ -0,0 +1,147 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// TimingFiles is the number of the slowest files kept by the timings, see Config.ProfileRules
+const TimingFiles = 20
+
+// RuleTiming is the time spent running a pattern over the lines of a scan
+type RuleTiming struct {
+	RuleID   string
+	Pattern  string
+	Lines    int64 // the lines the pattern ran on, after the keyword prefilter
+	Matches  int64 // the lines it matched
+	Duration time.Duration
+}
+
+// FileTiming is the time spent matching a file, its lines and its structured content
+type FileTiming struct {
+	File     string
+	Size     int64
+	Duration time.Duration
+}
+
+// Timings of the last scan with ProfileRules: the patterns by time spent, and the slowest files
+type Timings struct {
+	Rules []RuleTiming
+	Files []FileTiming
+	Total time.Duration // the duration of the scan
+}
+
+// ruleTimes is the running time of one pattern, updated by the concurrent matchers
+type ruleTimes struct {
+	ruleID         string
+	lines, matches atomic.Int64
+	nanos          atomic.Int64
+}
+
+// timings collect the times of a scan with ProfileRules; a nil timings records nothing
+type timings struct {
+	rules sync.Map // *ruleTimes by pattern
+	mu    sync.Mutex
+	files []FileTiming // the slowest, sorted from the slowest
+}
+
+// start return the start time of a measure, zero when not timing
+func (t *timings) start() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// rule add a run of the pattern of the rules started at start
+func (t *timings) rule(rules *Scanner, ptnStr string, start time.Time, matched bool) {
+	if t == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	v, ok := t.rules.Load(ptnStr)
+	if !ok {
+		v, _ = t.rules.LoadOrStore(ptnStr, &ruleTimes{ruleID: firstNonEmpty(rules.detectors[ptnStr].ID, PatternRuleID(ptnStr))})
+	}
+	rt := v.(*ruleTimes)
+	rt.lines.Add(1)
+	rt.nanos.Add(int64(elapsed))
+	if matched {
+		rt.matches.Add(1)
+	}
+}
+
+// file add the time of the file started at start, only the TimingFiles slowest are kept
+func (t *timings) file(fpath string, size int64, start time.Time) {
+	if t == nil {
+		return
+	}
+	ft := FileTiming{File: fpath, Size: size, Duration: time.Since(start)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.files) == TimingFiles && t.files[len(t.files)-1].Duration >= ft.Duration {
+		return
+	}
+	idx := sort.Search(len(t.files), func(i int) bool { return t.files[i].Duration < ft.Duration })
+	t.files = append(t.files, FileTiming{})
+	copy(t.files[idx+1:], t.files[idx:])
+	t.files[idx] = ft
+	if len(t.files) > TimingFiles {
+		t.files = t.files[:TimingFiles]
+	}
+}
+
+// Timings return the timings of the last scan, empty without ProfileRules. Complete once the findings channel is
+// closed.
+func (s *Scanner) Timings() Timings {
+	res := Timings{Rules: []RuleTiming{}, Files: []FileTiming{}, Total: time.Duration(s.Stats().DurationSeconds * float64(time.Second))}
+	if s.timings == nil {
+		return res
+	}
+	s.timings.rules.Range(func(k, v any) bool {
+		rt := v.(*ruleTimes)
+		res.Rules = append(res.Rules, RuleTiming{RuleID: rt.ruleID, Pattern: k.(string), Lines: rt.lines.Load(), Matches: rt.matches.Load(), Duration: time.Duration(rt.nanos.Load())})
+		return true
+	})
+	sort.Slice(res.Rules, func(i, j int) bool {
+		if res.Rules[i].Duration != res.Rules[j].Duration {
+			return res.Rules[i].Duration > res.Rules[j].Duration
+		}
+		return res.Rules[i].RuleID < res.Rules[j].RuleID
+	})
+	s.timings.mu.Lock()
+	res.Files = append(res.Files, s.timings.files...)
+	s.timings.mu.Unlock()
+	return res
+}
+
+// Write print the timings as two tables, the rules then the slowest files, with the share of the time of each rule
+func (t Timings) Write(w io.Writer) error {
+	var sum time.Duration
+	for _, r := range t.Rules {
+		sum += r.Duration
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "RULE\tTIME\tSHARE\tLINES\tMATCHES\tPER LINE\tPATTERN\n")
+	for _, r := range t.Rules {
+		share, perLine := 0.0, time.Duration(0)
+		if sum > 0 {
+			share = float64(r.Duration) * 100 / float64(sum)
+		}
+		if r.Lines > 0 {
+			perLine = r.Duration / time.Duration(r.Lines)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t%d\t%d\t%s\t%s\n", r.RuleID, r.Duration.Round(time.Microsecond), share, r.Lines, r.Matches, perLine, r.Pattern)
+	}
+	fmt.Fprintf(tw, "\nFILE\tTIME\tSIZE\n")
+	for _, f := range t.Files {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", f.File, f.Duration.Round(time.Microsecond), f.Size)
+	}
+	fmt.Fprintf(tw, "\nrules %s of the scan %s\n", sum.Round(time.Microsecond), t.Total.Round(time.Microsecond))
+	return tw.Flush()
+}
@@ -0,0 +1,47 @@
+package scanner
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProfileRules(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.conf": "name=app\npassword=\"Xk9dLq2ZmP7wR4\"\n",
+		"b.conf": "nothing here\n",
+	})
+	scan := func(profile bool) Timings {
+		cfg := DefaultConfig()
+		cfg.CheckMode = "letter"
+		cfg.ProfileRules = profile
+		s, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		Collect(s.Scan(context.Background(), dir))
+		return s.Timings()
+	}
+	if timings := scan(false); len(timings.Rules) != 0 || len(timings.Files) != 0 {
+		t.Errorf("expect no timings without ProfileRules, got %+v", timings)
+	}
+	timings := scan(true)
+	generic := PatternRuleID(CredentialPatterns[0])
+	var rule *RuleTiming
+	for idx := range timings.Rules {
+		if timings.Rules[idx].RuleID == generic {
+			rule = &timings.Rules[idx]
+		}
+	}
+	if rule == nil || rule.Lines != 1 || rule.Matches != 1 || rule.Duration <= 0 {
+		t.Errorf("expect the generic pattern run on the password line only, got %+v", rule)
+	}
+	if len(timings.Files) != 2 {
+		t.Errorf("expect the times of the 2 files, got %+v", timings.Files)
+	}
+	var b strings.Builder
+	if err := timings.Write(&b); err != nil || !strings.Contains(b.String(), generic) || !strings.Contains(b.String(), "a.conf") {
+		t.Errorf("unexpected report %q %v", b.String(), err)
+	}
+}
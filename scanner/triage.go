@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TriageResult are the decisions of a triage session
+type TriageResult struct {
+	FalsePositives []OutputFmt // added to the profile
+	Real           []OutputFmt
+	Aborted        bool // ctrl-c, the profile is not changed
+}
+
+// triageItem is a finding to triage with its key in the scan output
+type triageItem struct {
+	file, sig string
+	o         OutputFmt
+	decision  string // f or r, empty if skipped
+}
+
+// Triage step through the findings not already in the profile, showing the lines around each with the values
+// masked, and read a key per finding from in: f false positive, r real, s skip, b back, q quit. Other bytes, eg the
+// new lines of a line buffered input, are ignored. On quit, at the end of the findings or at the end of in, the false
+// positives are added to the profile; ctrl-c aborts without changing it. With clear the screen is cleared for each
+// finding.
+func Triage(in io.Reader, out io.Writer, profile, findings ProjectOutputFmt, clear bool) (TriageResult, error) {
+	items := []*triageItem{}
+	for file, matches := range findings {
+		for sig, o := range matches {
+			if _, ok := profile[file][sig]; ok || len(o.Line_no) == 0 {
+				continue
+			}
+			items = append(items, &triageItem{file: file, sig: sig, o: o})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].file != items[j].file {
+			return items[i].file < items[j].file
+		}
+		return firstLine(items[i].o) < firstLine(items[j].o)
+	})
+	lines := map[string][]string{} // masked lines by file
+	ptns := map[string]*regexp.Regexp{}
+	r := bufio.NewReader(in)
+	result := TriageResult{}
+	for idx := 0; idx < len(items); {
+		it := items[idx]
+		if _, ok := lines[it.file]; !ok {
+			fileFindings := make([]OutputFmt, 0, len(findings[it.file]))
+			for _, o := range findings[it.file] {
+				fileFindings = append(fileFindings, o)
+			}
+			lines[it.file] = maskedLines(it.file, fileFindings, ptns)
+		}
+		if clear {
+			fmt.Fprint(out, "\033[H\033[2J")
+		}
+		writeTriageItem(out, it, idx, len(items), lines[it.file])
+		key, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		switch key {
+		case 'f', 'r':
+			it.decision = string(key)
+			idx++
+		case 's':
+			it.decision = ""
+			idx++
+		case 'b':
+			idx = max(0, idx-1)
+		case 'q':
+			idx = len(items)
+		case 3: // ctrl-c in raw mode
+			result.Aborted = true
+			fmt.Fprintln(out, "\naborted, the profile is not changed")
+			return result, nil
+		}
+	}
+	accepted := ProjectOutputFmt{}
+	for _, it := range items {
+		switch it.decision {
+		case "f":
+			if _, ok := accepted[it.file]; !ok {
+				accepted[it.file] = map[string]OutputFmt{}
+			}
+			accepted[it.file][it.sig] = it.o
+		case "r":
+			result.Real = append(result.Real, it.o)
+		}
+	}
+	result.FalsePositives = AddToProfile(profile, accepted, nil)
+	return result, nil
+}
+
+// writeTriageItem print the finding idx of total and its context lines, the finding lines marked with >
+func writeTriageItem(out io.Writer, it *triageItem, idx, total int, lines []string) {
+	o := it.o
+	names := []string{}
+	for i := 0; i < len(o.Matches); i += 2 {
+		names = append(names, o.Matches[i])
+	}
+	decision := map[string]string{"f": " [false positive]", "r": " [real]"}[it.decision]
+	fmt.Fprintf(out, "\n[%d/%d] %s:%d %s severity %s, confidence %s%s\n  %s\n\n", idx+1, total, it.file, o.Line_no[0]+1,
+		firstNonEmpty(o.RuleID, PatternRuleID(o.Pattern)), o.Severity, o.Confidence, decision, strings.Join(names, ", "))
+	if lines == nil {
+		fmt.Fprintln(out, "  (can not read the file)")
+	}
+	hit := map[int]bool{}
+	for _, line := range o.Line_no {
+		for no := line; no <= max(line, o.End_line); no++ {
+			hit[no] = true
+		}
+	}
+	shown := map[int]bool{}
+	for _, line := range o.Line_no {
+		for no := max(0, line-ReportContextLines); no <= max(line, o.End_line)+ReportContextLines && no < len(lines); no++ {
+			if shown[no] {
+				continue
+			}
+			shown[no] = true
+			mark := " "
+			if hit[no] {
+				mark = ">"
+			}
+			fmt.Fprintf(out, "%s %5d  %s\n", mark, no+1, lines[no])
+		}
+	}
+	fmt.Fprint(out, "\n[f]alse positive  [r]eal  [s]kip  [b]ack  [q]uit: ")
+}
@@ -0,0 +1,51 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTriage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.conf": "host=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\n",
+		"b.conf": "token=Ab3dEf9hIj2kLm\n",
+		"c.conf": "secret=Qw8eRt5yUi3oP\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := Collect(s.Scan(context.Background(), dir))
+	if len(findings) != 3 {
+		t.Fatalf("expect 3 findings, got %v", findings)
+	}
+	// a: false positive, b: real then back and false positive, c: skip
+	profile := ProjectOutputFmt{}
+	out := &bytes.Buffer{}
+	res, err := Triage(strings.NewReader("f\nr\nb\nf\ns\n"), out, profile, findings, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Aborted || len(res.FalsePositives) != 2 || len(res.Real) != 0 || len(profile) != 2 {
+		t.Errorf("expect a and b added to the profile, got %+v, profile %v", res, profile)
+	}
+	if strings.Contains(out.String(), "Xk9dLq2ZmP7wR4") || !strings.Contains(out.String(), ">     2  password=") {
+		t.Errorf("expect the masked context lines, got\n%s", out)
+	}
+	// the findings in the profile are not shown again
+	res, err = Triage(strings.NewReader("r"), io.Discard, profile, findings, false)
+	if err != nil || len(res.Real) != 1 || filepath.Base(res.Real[0].File) != "c.conf" {
+		t.Errorf("expect only c left and marked real, got %+v %v", res, err)
+	}
+	res, _ = Triage(strings.NewReader("f\x03"), io.Discard, ProjectOutputFmt{}, findings, false)
+	if !res.Aborted || len(res.FalsePositives) != 0 {
+		t.Errorf("expect ctrl-c to abort, got %+v", res)
+	}
+}
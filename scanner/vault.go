@@ -0,0 +1,137 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	ag "github.com/sunshine69/automation-go/lib"
+)
+
+// VaultHeader starts the ansible vault encrypted files and the inline !vault values, eg. $ANSIBLE_VAULT;1.1;AES256
+const VaultHeader = ag.VaultHeader
+
+// ErrNoVaultPassword is returned decrypting a vault without Config.VaultPasswordFiles
+var ErrNoVaultPassword = errors.New("no vault password")
+
+// vaultKeyPtn match the key of an inline yaml value, eg. "db_password: !vault |"; its indented lines are the vault
+var vaultKeyPtn = regexp.MustCompile(`^(\s*)(?:-\s+)?([^\s:#][^:#]*?)\s*:\s*!vault\s*[|>][-+0-9]*\s*$`)
+
+// isVault tell if the text is ansible vault encrypted
+func isVault(text string) bool {
+	return ag.IsVault(text)
+}
+
+// vaultDecrypt return the plain text of an ansible vault, trying each vault password, see ag.VaultDecrypt
+func (s *Scanner) vaultDecrypt(data []byte) ([]byte, error) {
+	if len(s.vaultPasswords) == 0 {
+		return nil, ErrNoVaultPassword
+	}
+	plain, err := ag.VaultDecrypt(string(data), s.vaultPasswords...)
+	return []byte(plain), err
+}
+
+// addVault record a file encrypted with ansible vault or with inline !vault values, see Stats.VaultEncrypted
+func (s *Scanner) addVault(fpath string) {
+	s.mu.Lock()
+	if s.vaults == nil {
+		s.vaults = map[string]bool{}
+	}
+	s.vaults[fpath] = true
+	s.mu.Unlock()
+}
+
+// vaultFiles return the files of addVault, sorted
+func (s *Scanner) vaultFiles() []string {
+	files := make([]string, 0, len(s.vaults))
+	for fpath := range s.vaults {
+		files = append(files, fpath)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// processVault scan an ansible vault encrypted file: its cipher text is not matched, the plain text is when a vault
+// password decrypts it. The file is always in Stats.VaultEncrypted.
+func (s *Scanner) processVault(ctx context.Context, fpath string, f *os.File, rules *Scanner, output_chan chan<- OutputFmt) {
+	s.addVault(fpath)
+	datab, err := io.ReadAll(f)
+	if err != nil {
+		s.Logger().Warn("can not read file", "path", fpath, "error", err)
+		s.skip(SkipUnreadable)
+		return
+	}
+	plain, err := s.vaultDecrypt(datab)
+	if err != nil {
+		if errors.Is(err, ErrNoVaultPassword) {
+			s.Logger().Info("skip ansible vault encrypted file", "path", fpath)
+		} else {
+			s.Logger().Warn("can not decrypt ansible vault file", "path", fpath, "error", err)
+		}
+		s.skip(SkipVault)
+		return
+	}
+	s.Logger().Info("scan decrypted ansible vault file", "path", fpath)
+	s.filesProcessed.Add(1)
+	m := s.newFileMatcher(ctx, rules, fpath, output_chan)
+	m.matchAll(bytes.NewReader(plain))
+}
+
+// vaultValue is an inline !vault value of a yaml file being read, see fileMatcher.vaultLine
+type vaultValue struct {
+	line      int // the line of the key
+	key       string
+	raw, prev string // the line of the key and the one before
+	indent    int
+	lines     []string
+}
+
+// vaultLine follow the inline !vault values: it returns true for a line of a value, it is not matched. A value is
+// matched once read, see matchVault. It returns false in ok if the context is done.
+func (m *fileMatcher) vaultLine(idx int, data string) (inVault, ok bool) {
+	if v := m.vault; v != nil {
+		trimmed := strings.TrimLeft(data, " \t")
+		if strings.TrimSpace(data) == "" || len(data)-len(trimmed) > v.indent {
+			v.lines = append(v.lines, data)
+			return true, true
+		}
+		if !m.matchVault() {
+			return false, false
+		}
+	}
+	if match := vaultKeyPtn.FindStringSubmatch(data); match != nil {
+		m.vault = &vaultValue{line: idx, key: match[2], raw: data, prev: m.prev, indent: len(match[1])}
+	}
+	return false, true
+}
+
+// matchVault match the plain text of the inline vault value just read, each of its lines as the value of its key
+// on the line of the key. It returns false if the context is done.
+func (m *fileMatcher) matchVault() bool {
+	v := m.vault
+	m.vault = nil
+	text := strings.Join(v.lines, "\n")
+	if !isVault(text) {
+		return true
+	}
+	m.s.addVault(m.fpath)
+	m.hasVault = true
+	plain, err := m.s.vaultDecrypt([]byte(text))
+	if err != nil {
+		if !errors.Is(err, ErrNoVaultPassword) {
+			m.s.Logger().Warn("can not decrypt inline vault value", "path", m.fpath, "line", v.line, "key", v.key, "error", err)
+		}
+		return true
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(plain), "\n"), "\n") {
+		if !m.matchPatterns(v.line, v.key+": "+strings.TrimSuffix(line, "\r"), v.raw, v.prev) {
+			return false
+		}
+	}
+	return true
+}
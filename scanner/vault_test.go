@@ -0,0 +1,74 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	ag "github.com/sunshine69/automation-go/lib"
+)
+
+// vaultEncrypt encrypt like ansible-vault encrypt_string, indented by indent
+func vaultEncrypt(t *testing.T, password, plain, indent string) string {
+	t.Helper()
+	vaultText, err := ag.VaultEncrypt(plain, ag.VaultSecret{Password: []byte(password)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(vaultText, "\n"), "\n")
+	return indent + strings.Join(lines, "\n"+indent) + "\n"
+}
+
+func TestAnsibleVault(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"secrets.yml":        vaultEncrypt(t, "s3cret-pass", "db_password: Zx9cVb7nMq2wE\n", ""),
+		"group_vars/all.yml": "api_user: deploy\napi_token: !vault |\n" + vaultEncrypt(t, "s3cret-pass", "Qw8eRt5yUi3oP", "  ") + "api_url: https://api.internal\n",
+		"pass.txt":           "s3cret-pass\n",
+		"wrong-pass.txt":     "not-the-pass\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	cfg.Redact = RedactNone
+	cfg.Structured = true
+	cfg.PathExclude = `pass\.txt$`
+	values := func(vaultPass string) ([]string, Stats) {
+		t.Helper()
+		if vaultPass != "" {
+			cfg.VaultPasswordFiles = []string{filepath.Join(dir, vaultPass)}
+		}
+		s, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := []string{}
+		for _, o := range Collect(s.Scan(context.Background(), dir)).List() {
+			for idx := 1; idx < len(o.Matches); idx += 2 {
+				found = append(found, fmt.Sprintf("%s:%d:%s", filepath.Base(o.File), o.Line_no[0]+1, o.Matches[idx]))
+			}
+		}
+		sort.Strings(found)
+		return found, s.Stats()
+	}
+	vaults := []string{filepath.Join(dir, "group_vars", "all.yml"), filepath.Join(dir, "secrets.yml")}
+	found, stats := values("")
+	if len(found) != 0 || !reflect.DeepEqual(stats.VaultEncrypted, vaults) || stats.FilesSkipped[SkipVault] != 1 {
+		t.Errorf("expect the vaults skipped and listed, got %v %v %v", found, stats.VaultEncrypted, stats.FilesSkipped)
+	}
+	found, stats = values("wrong-pass.txt")
+	if len(found) != 0 || stats.FilesSkipped[SkipVault] != 1 {
+		t.Errorf("expect the vaults skipped with a wrong password, got %v %v", found, stats.FilesSkipped)
+	}
+	found, stats = values("pass.txt")
+	if want := []string{"all.yml:2:Qw8eRt5yUi3oP", "secrets.yml:1:Zx9cVb7nMq2wE"}; !reflect.DeepEqual(found, want) || !reflect.DeepEqual(stats.VaultEncrypted, vaults) {
+		t.Errorf("expect the decrypted secrets, got %v %v", found, stats.VaultEncrypted)
+	}
+	cfg.VaultPasswordFiles = []string{filepath.Join(dir, "missing.txt")}
+	if _, err := New(cfg); err == nil {
+		t.Errorf("expect an error for a missing vault password file")
+	}
+}
@@ -0,0 +1,177 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	ag "github.com/sunshine69/automation-go/lib"
+)
+
+// The verification status of a finding, see Config.Verify
+const (
+	StatusVerified = "verified" // the provider accepted the token, it is live
+	StatusInvalid  = "invalid"  // the provider rejected the token
+	StatusUnknown  = "unknown"  // the provider could not be asked or gave no clear answer
+)
+
+// Verifier test if a token found by a detector is currently valid with the api of its provider
+type Verifier struct {
+	// the rule id of the other half of the credential in the same file, eg the aws access key id of a secret
+	// access key; without it the status is unknown
+	Related string
+	Verify  func(ctx context.Context, value, related string) (string, error)
+}
+
+// Verifiers by detector id, see Config.Verify
+var Verifiers = map[string]Verifier{
+	"aws-access-key-id":     {Related: "aws-secret-access-key", Verify: verifyAws},
+	"aws-secret-access-key": {Related: "aws-access-key-id", Verify: func(ctx context.Context, secret, id string) (string, error) { return verifyAws(ctx, id, secret) }},
+	"github-pat":            {Verify: verifyGithub},
+	"gitlab-pat":            {Verify: verifyGitlab},
+	"slack-token":           {Verify: verifySlack},
+}
+
+// verifyURLs are the provider apis the verifiers call
+var verifyURLs = map[string]string{
+	"aws":    "https://sts.amazonaws.com/",
+	"github": "https://api.github.com/user",
+	"gitlab": "https://gitlab.com/api/v4/user",
+	"slack":  "https://slack.com/api/auth.test",
+}
+
+var verifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// verifyRequest send the request and map the http status: 200 is verified, 401 and 403 invalid
+func verifyRequest(req *http.Request) (string, *http.Response, error) {
+	resp, err := verifyClient.Do(req)
+	if err != nil {
+		return StatusUnknown, nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return StatusVerified, resp, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return StatusInvalid, resp, nil
+	}
+	return StatusUnknown, resp, nil
+}
+
+// verifyAws call sts GetCallerIdentity, which any valid key may call. A temporary key (ASIA...) needs its session
+// token, so it is unknown.
+func verifyAws(ctx context.Context, id, secret string) (string, error) {
+	if strings.HasPrefix(id, "ASIA") {
+		return StatusUnknown, nil
+	}
+	body := []byte("Action=GetCallerIdentity&Version=2011-06-15")
+	req, err := http.NewRequestWithContext(ctx, "POST", verifyURLs["aws"], strings.NewReader(string(body)))
+	if err != nil {
+		return StatusUnknown, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	ag.SignAwsRequestV4(req, body, ag.AwsCredentials{AccessKeyID: id, SecretAccessKey: secret}, "us-east-1", "sts", time.Now())
+	status, resp, err := verifyRequest(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return status, err
+}
+
+func verifyBearer(ctx context.Context, url, header, value string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return StatusUnknown, err
+	}
+	req.Header.Set(header, value)
+	status, resp, err := verifyRequest(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return status, err
+}
+
+func verifyGithub(ctx context.Context, token, _ string) (string, error) {
+	return verifyBearer(ctx, verifyURLs["github"], "Authorization", "Bearer "+token)
+}
+
+// verifyGitlab only knows gitlab.com, a token of a self-hosted instance is invalid there
+func verifyGitlab(ctx context.Context, token, _ string) (string, error) {
+	return verifyBearer(ctx, verifyURLs["gitlab"], "PRIVATE-TOKEN", token)
+}
+
+// verifySlack call auth.test, which answers 200 with ok false for a bad token
+func verifySlack(ctx context.Context, token, _ string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", verifyURLs["slack"], nil)
+	if err != nil {
+		return StatusUnknown, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	status, resp, err := verifyRequest(req)
+	if resp == nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if status != StatusVerified {
+		return status, nil
+	}
+	result := struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return StatusUnknown, err
+	}
+	switch {
+	case result.OK:
+		return StatusVerified, nil
+	case result.Error == "invalid_auth" || result.Error == "account_inactive" || result.Error == "token_revoked":
+		return StatusInvalid, nil
+	}
+	return StatusUnknown, nil
+}
+
+// mergeStatus return the status of a finding having values of both status: verified if one is live, else unknown if
+// one is unknown
+func mergeStatus(a, b string) string {
+	rank := map[string]int{StatusInvalid: 1, StatusUnknown: 2, StatusVerified: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// verify return the status of the token name, value pairs of the rule, "" if the rule has no verifier. The status of
+// a value is asked once by the scanner.
+func (m *fileMatcher) verify(ruleID string, pairs []string) string {
+	for idx := 0; idx+1 < len(pairs); idx += 2 {
+		m.related[pairs[idx]] = pairs[idx+1]
+	}
+	v, ok := Verifiers[ruleID]
+	if !ok {
+		return ""
+	}
+	status := ""
+	for idx := 1; idx < len(pairs); idx += 2 {
+		related := ""
+		if v.Related != "" {
+			if related = m.related[v.Related]; related == "" {
+				status = mergeStatus(status, StatusUnknown)
+				continue
+			}
+		}
+		key := ruleID + "\x00" + pairs[idx] + "\x00" + related
+		got, ok := m.s.verified.Load(key)
+		if !ok {
+			result, err := v.Verify(m.ctx, pairs[idx], related)
+			if err != nil {
+				m.s.Logger().Info("can not verify", "path", m.fpath, "rule", ruleID, "error", err)
+			}
+			got, _ = m.s.verified.LoadOrStore(key, result)
+		}
+		status = mergeStatus(status, got.(string))
+	}
+	return status
+}
@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// WalkConcurrency is the number of goroutines reading the directories and stating the files of a scan. They mostly
+// wait on the file system, eg NFS, so there are more than the CPUs.
+var WalkConcurrency = 16
+
+// treeWalker walk a tree with up to workers goroutines, see walkTree
+type treeWalker struct {
+	fn      filepath.WalkFunc
+	slots   chan struct{}
+	wg      sync.WaitGroup
+	stopped atomic.Bool
+	err     error
+	errOnce sync.Once
+}
+
+// walkTree walk the tree of root like filepath.Walk, but the directories are read and their entries stated by up to
+// workers goroutines, so a slow file system or a very wide tree does not serialize the scan behind the stat calls.
+// fn is called concurrently and in no particular order, except that a directory comes before its entries. It
+// returns filepath.SkipDir to skip a directory, ignored for a file, or an error to stop the walk, returned by walkTree.
+func walkTree(root string, workers int, fn filepath.WalkFunc) error {
+	w := &treeWalker{fn: fn, slots: make(chan struct{}, max(workers, 1)-1)}
+	info, err := os.Lstat(root)
+	w.visit(root, info, err)
+	w.wg.Wait()
+	return w.err
+}
+
+// stop record the first error and end the walk
+func (w *treeWalker) stop(err error) {
+	w.errOnce.Do(func() { w.err = err })
+	w.stopped.Store(true)
+}
+
+// visit call fn on the path and read it if it is a directory
+func (w *treeWalker) visit(fpath string, info fs.FileInfo, err error) {
+	if w.stopped.Load() {
+		return
+	}
+	if err != nil {
+		if err := w.fn(fpath, nil, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+			w.stop(err)
+		}
+		return
+	}
+	if err := w.fn(fpath, info, nil); err != nil {
+		if !errors.Is(err, filepath.SkipDir) {
+			w.stop(err)
+		}
+		return
+	}
+	if !info.IsDir() {
+		return
+	}
+	entries, err := os.ReadDir(fpath)
+	if err != nil {
+		if err := w.fn(fpath, info, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+			w.stop(err)
+		}
+		return
+	}
+	for _, e := range entries {
+		p := filepath.Join(fpath, e.Name())
+		w.spawn(func() {
+			info, err := os.Lstat(p)
+			w.visit(p, info, err)
+		})
+	}
+}
+
+// spawn run the task in a new goroutine if a worker is free, else in this one; the walk never waits for a worker
+func (w *treeWalker) spawn(task func()) {
+	select {
+	case w.slots <- struct{}{}:
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			defer func() { <-w.slots }()
+			task()
+		}()
+	default:
+		task()
+	}
+}
@@ -0,0 +1,51 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWalkTree(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{}
+	for i := range 50 {
+		files[fmt.Sprintf("d%d/sub/f%d.conf", i%5, i)] = "name=app\n"
+	}
+	files["skip/a.conf"] = "name=app\n"
+	writeFiles(t, dir, files)
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	err := walkTree(dir, 4, func(fpath string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, fpath)
+		mu.Lock()
+		defer mu.Unlock()
+		if parent := filepath.Dir(rel); rel != "." && parent != "." && !seen[filepath.ToSlash(parent)] {
+			t.Errorf("expect %s visited before %s", parent, rel)
+		}
+		seen[filepath.ToSlash(rel)] = true
+		if info.IsDir() && info.Name() == "skip" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range files {
+		if seen[name] != !strings.HasPrefix(name, "skip/") {
+			t.Errorf("unexpected visit of %s: %v", name, seen[name])
+		}
+	}
+	stop := errors.New("stop")
+	if err := walkTree(dir, 4, func(string, fs.FileInfo, error) error { return stop }); err != stop {
+		t.Errorf("expect the error of the walk function, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchDebounce is how long the events of the files are gathered before they are scanned; an editor writes a file
+// in several steps
+var WatchDebounce = 200 * time.Millisecond
+
+// Watch scan the files under root again whenever they are written and send the findings that were not there before,
+// eg a secret just pasted. A finding is known by its rule and the content of its line, so the findings on lines that
+// only moved are not sent again; a finding sent has only its new lines in Line_no. The tree is scanned first to know
+// the findings already there, they are not sent. The directories created later are watched too. The channel is
+// closed when the context is done.
+func (s *Scanner) Watch(ctx context.Context, root string) (<-chan OutputFmt, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	ignore := s.newCredIgnore(root)
+	excluded := func(fpath string, isDir bool) bool {
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil || rel == "." {
+			return err != nil
+		}
+		rel = filepath.ToSlash(rel)
+		for _, name := range strings.Split(rel, "/") {
+			if s.isExcludedName(name) {
+				return true
+			}
+		}
+		if s.pathExcludePtn != nil && s.pathExcludePtn.MatchString(fpath) {
+			return true
+		}
+		if isDir {
+			return ignore != nil && ignore.match(rel, true)
+		}
+		return ignore != nil && ignore.Ignored(rel)
+	}
+	// addTree watch the directories of the tree and return its files
+	addTree := func(dir string) []string {
+		files := []string{}
+		filepath.WalkDir(dir, func(fpath string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				s.Logger().Warn("walk error", "path", fpath, "error", err)
+			case d.IsDir() && excluded(fpath, true):
+				return filepath.SkipDir
+			case d.IsDir():
+				if err := w.Add(fpath); err != nil {
+					s.Logger().Warn("can not watch", "path", fpath, "error", err)
+				}
+			case d.Type().IsRegular():
+				files = append(files, fpath)
+			}
+			return nil
+		})
+		return files
+	}
+	addTree(root)
+	known := map[string]map[string]bool{} // keys of the findings by file, see findingKeys
+	for file, matches := range Collect(s.Scan(ctx, root)) {
+		lines := readLines(file)
+		known[file] = map[string]bool{}
+		for _, o := range matches {
+			for _, key := range findingKeys(o, lines) {
+				known[file][key] = true
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		w.Close()
+		return nil, err
+	}
+	output_chan := make(chan OutputFmt)
+	go func() {
+		defer close(output_chan)
+		defer w.Close()
+		pending := map[string]bool{}
+		timer := time.NewTimer(WatchDebounce)
+		timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				s.Logger().Warn("watch error", "error", err)
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) { // a renamed file comes back as Create
+					delete(known, ev.Name)
+					continue
+				}
+				info, err := os.Stat(ev.Name)
+				switch {
+				case err != nil || excluded(ev.Name, info.IsDir()):
+					continue
+				case info.IsDir() && ev.Has(fsnotify.Create):
+					for _, file := range addTree(ev.Name) {
+						pending[file] = true
+					}
+				case info.Mode().IsRegular() && (ev.Has(fsnotify.Write) || ev.Has(fsnotify.Create)):
+					pending[ev.Name] = true
+				default:
+					continue
+				}
+				timer.Reset(WatchDebounce)
+			case <-timer.C:
+				for file := range pending {
+					if !s.rescan(ctx, file, known, output_chan) {
+						return
+					}
+				}
+				pending = map[string]bool{}
+			}
+		}
+	}()
+	return output_chan, nil
+}
+
+// rescan scan a changed file and send its new findings. It returns false if the context is done.
+func (s *Scanner) rescan(ctx context.Context, file string, known map[string]map[string]bool, output_chan chan<- OutputFmt) bool {
+	output := Collect(s.Scan(ctx, file))
+	lines := readLines(file)
+	keys := map[string]bool{}
+	for _, matches := range output {
+		for _, o := range matches {
+			found := o
+			found.Line_no = []int{}
+			for idx, key := range findingKeys(o, lines) {
+				keys[key] = true
+				if !known[file][key] {
+					found.Line_no = append(found.Line_no, o.Line_no[idx])
+				}
+			}
+			if len(found.Line_no) == 0 {
+				continue
+			}
+			select {
+			case output_chan <- found:
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+	known[file] = keys
+	return true
+}
+
+// readLines return the lines of the file, nil if it can not be read, eg a file in an archive
+func readLines(file string) []string {
+	datab, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(datab), "\n")
+}
+
+// findingKeys return a key per line of the finding: its rule and the content of the line without the spaces. Without
+// the lines the key is the fingerprint and the line number.
+func findingKeys(o OutputFmt, lines []string) []string {
+	keys := make([]string, 0, len(o.Line_no))
+	for _, no := range o.Line_no {
+		if no < len(lines) {
+			keys = append(keys, o.RuleID+"\x00"+strings.Join(strings.Fields(lines[no]), ""))
+		} else {
+			keys = append(keys, o.Fingerprint+"\x00"+strconv.Itoa(no))
+		}
+	}
+	return keys
+}
@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.conf":           "host=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\n",
+		"testdata/.keep":     "",
+		IgnoreFileName:       "testdata/\n",
+		"testdata/fake.conf": "token=Ab3dEf9hIj2kLm\n",
+	})
+	cfg := DefaultConfig()
+	cfg.CheckMode = "letter"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	findings, err := s.Watch(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func() (OutputFmt, bool) {
+		select {
+		case o := <-findings:
+			return o, true
+		case <-time.After(WatchDebounce + 2*time.Second):
+			return OutputFmt{}, false
+		}
+	}
+	// the existing finding moves down a line and a new one is added: only the new line is sent
+	writeFiles(t, dir, map[string]string{"app.conf": "# config\nhost=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\nsecret=Qw8eRt5yUi3oP\n"})
+	if o, ok := next(); !ok || filepath.Base(o.File) != "app.conf" || !reflect.DeepEqual(o.Line_no, []int{3}) {
+		t.Fatalf("expect the new line of app.conf, got %+v %v", o, ok)
+	}
+	// the ignored directory is not scanned, a new directory is watched
+	writeFiles(t, dir, map[string]string{"testdata/other.conf": "token=Zx9kQ2mP4vLw8\n"})
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(WatchDebounce * 2)
+	writeFiles(t, dir, map[string]string{"sub/new.conf": "token=Zx9kQ2mP4vLw8\n"})
+	if o, ok := next(); !ok || filepath.Base(o.File) != "new.conf" {
+		t.Fatalf("expect the finding of the new directory, got %+v %v", o, ok)
+	}
+	cancel()
+	for range findings {
+	}
+}
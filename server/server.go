@@ -0,0 +1,320 @@
+// Package server serve a rest api to run scans, so other tools and the CI systems can call cred-detect over http
+// instead of running it.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunshine69/automation-go/scanner"
+)
+
+// The status of a scan
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// MaxContentSize bound the body of a content scan
+var MaxContentSize int64 = 16 * 1024 * 1024
+
+// MaxScans is the number of scans kept, the oldest finished ones are dropped
+var MaxScans = 100
+
+// MaxGitScans is the number of git scans running at the same time, the next ones are answered 429
+var MaxGitScans = 4
+
+// AllowPlainGit accept the http:// and git:// git urls; off by default, a plain http or git server is reached without
+// tls and the server could be made to call any host of its network
+var AllowPlainGit = false
+
+// GitTimeout bound the clone and the scan of a git repository
+var GitTimeout = 10 * time.Minute
+
+// ShutdownTimeout is how long ListenAndServe waits for the requests in flight when it stops
+var ShutdownTimeout = 10 * time.Second
+
+// Scan is a scan submitted to the api; Findings are set when it is done
+type Scan struct {
+	ID       string
+	Status   string
+	Source   string // the name of the content, or the git url and ref
+	Error    string `json:",omitempty"`
+	Started  time.Time
+	Finished *time.Time               `json:",omitempty"`
+	Findings scanner.ProjectOutputFmt `json:",omitempty"`
+}
+
+// ScanRequest is the body of POST /api/scans: Content named Name, or the repository at GitURL. Ref is a branch or
+// a tag, default the remote HEAD.
+type ScanRequest struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	GitURL  string `json:"git_url"`
+	Ref     string `json:"ref"`
+}
+
+// Server run the scans with a config and keep their results in memory
+type Server struct {
+	cfg     scanner.Config
+	version string
+	token   string // the bearer token the requests need, none if empty
+	mu      sync.Mutex
+	scans   map[string]*Scan
+	gitRuns int             // the git scans running, guarded by mu; see MaxGitScans
+	ctx     context.Context // cancel the git scans running, see ListenAndServe
+}
+
+// New return a server scanning with the config; with a token the requests need 'Authorization: Bearer <token>'
+func New(cfg scanner.Config, token, version string) (*Server, error) {
+	cfg.CachePath = ""                          // the cache is of one tree
+	cfg.CheckpointPath = ""                     // the scans run concurrently, they would share the checkpoint
+	if _, err := scanner.New(cfg); err != nil { // check the config once, each scan has its own scanner
+		return nil, err
+	}
+	return &Server{cfg: cfg, version: version, token: token, scans: map[string]*Scan{}, ctx: context.Background()}, nil
+}
+
+// Handler return the api:
+//   - POST /api/scans with a ScanRequest json body, or with the content to scan as the body and ?name=<file name>.
+//     A content scan answers 200 with the Scan done, a git scan 202 with the Scan running, or 429 when MaxGitScans
+//     are running
+//   - GET /api/scans list the scans without their findings
+//   - GET /api/scans/{id} get a scan, ?format=sarif for the findings as SARIF
+func (srv *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/scans", srv.submit)
+	mux.HandleFunc("GET /api/scans", func(w http.ResponseWriter, r *http.Request) {
+		srv.mu.Lock()
+		list := make([]Scan, 0, len(srv.scans))
+		for _, sc := range srv.scans {
+			summary := *sc
+			summary.Findings = nil
+			list = append(list, summary)
+		}
+		srv.mu.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Started.After(list[j].Started) })
+		writeJson(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("GET /api/scans/{id}", func(w http.ResponseWriter, r *http.Request) {
+		srv.mu.Lock()
+		sc, ok := srv.scans[r.PathValue("id")]
+		var found Scan
+		if ok {
+			found = *sc
+		}
+		srv.mu.Unlock()
+		switch {
+		case !ok:
+			http.Error(w, "scan not found", http.StatusNotFound)
+		case r.URL.Query().Get("format") == "sarif":
+			writeJson(w, http.StatusOK, scanner.ToSarif(found.Findings, ".", srv.version))
+		default:
+			writeJson(w, http.StatusOK, found)
+		}
+	})
+	return srv.authorized(mux)
+}
+
+// authorized check the bearer token of the requests
+func (srv *Server) authorized(next http.Handler) http.Handler {
+	if srv.token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(srv.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (srv *Server) submit(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxContentSize)
+	req := ScanRequest{Name: r.URL.Query().Get("name")}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid scan request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		datab, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		req.Content = string(datab)
+	}
+	switch {
+	case req.GitURL != "":
+		if err := checkGitURL(req.GitURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		source := req.GitURL
+		if req.Ref != "" {
+			source += "@" + req.Ref
+		}
+		if !srv.startGit() {
+			http.Error(w, fmt.Sprintf("too many git scans running, the limit is %d", MaxGitScans), http.StatusTooManyRequests)
+			return
+		}
+		sc := srv.add(source)
+		go srv.scanGit(sc, req.GitURL, req.Ref)
+		writeJson(w, http.StatusAccepted, srv.snapshot(sc))
+	case req.Name == "":
+		http.Error(w, "the content needs a name, eg ?name=app.yaml; the file name patterns apply to it", http.StatusBadRequest)
+	default:
+		sc := srv.add(req.Name)
+		findings, err := srv.scanContent(r.Context(), req.Name, req.Content)
+		srv.finish(sc, findings, err)
+		writeJson(w, http.StatusOK, srv.snapshot(sc))
+	}
+}
+
+// scanContent scan the content as a file named name
+func (srv *Server) scanContent(ctx context.Context, name, content string) (scanner.ProjectOutputFmt, error) {
+	s, err := scanner.New(srv.cfg)
+	if err != nil {
+		return nil, err
+	}
+	findings := scanner.Collect(s.ScanReader(ctx, name, strings.NewReader(content)))
+	return findings, s.Err()
+}
+
+// checkGitURL only accept the remote repositories: a local path or file:// url would scan the disk of the server.
+// http:// and git:// need AllowPlainGit.
+func checkGitURL(url string) error {
+	prefixes := []string{"https://", "ssh://", "git@"}
+	if AllowPlainGit {
+		prefixes = append(prefixes, "http://", "git://")
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(url, prefix) {
+			return nil
+		}
+	}
+	if AllowPlainGit {
+		return fmt.Errorf("unsupported git url %q, expect https://, ssh://, http://, git:// or git@host:repo", url)
+	}
+	return fmt.Errorf("unsupported git url %q, expect https://, ssh:// or git@host:repo", url)
+}
+
+// add a running scan, dropping the oldest finished scans over MaxScans
+func (srv *Server) add(source string) *Scan {
+	id := make([]byte, 8)
+	rand.Read(id)
+	sc := &Scan{ID: hex.EncodeToString(id), Status: StatusRunning, Source: source, Started: time.Now()}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for len(srv.scans) >= MaxScans {
+		var oldest *Scan
+		for _, s := range srv.scans {
+			if s.Status != StatusRunning && (oldest == nil || s.Started.Before(oldest.Started)) {
+				oldest = s
+			}
+		}
+		if oldest == nil {
+			break
+		}
+		delete(srv.scans, oldest.ID)
+	}
+	srv.scans[sc.ID] = sc
+	return sc
+}
+
+func (srv *Server) finish(sc *Scan, findings scanner.ProjectOutputFmt, err error) {
+	now := time.Now()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	sc.Finished, sc.Status, sc.Findings = &now, StatusDone, findings
+	if err != nil {
+		sc.Status, sc.Error = StatusFailed, err.Error()
+	}
+}
+
+// snapshot return a copy of the scan, safe to encode while it runs
+func (srv *Server) snapshot(sc *Scan) Scan {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return *sc
+}
+
+// startGit count a git scan starting, false if MaxGitScans are already running
+func (srv *Server) startGit() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.gitRuns >= MaxGitScans {
+		return false
+	}
+	srv.gitRuns++
+	return true
+}
+
+// scanGit clone the repository without its history and scan the files; the file paths are relative to the top of
+// the repository
+func (srv *Server) scanGit(sc *Scan, url, ref string) {
+	defer func() {
+		srv.mu.Lock()
+		srv.gitRuns--
+		srv.mu.Unlock()
+	}()
+	ctx, cancel := context.WithTimeout(srv.ctx, GitTimeout)
+	defer cancel()
+	findings, err := srv.cloneAndScan(ctx, url, ref)
+	srv.finish(sc, findings, err)
+}
+
+func (srv *Server) cloneAndScan(ctx context.Context, url, ref string) (scanner.ProjectOutputFmt, error) {
+	s, err := scanner.New(srv.cfg)
+	if err != nil {
+		return nil, err
+	}
+	findings, err := s.ScanRepo(ctx, url, scanner.CloneOpt{Branch: ref, Depth: 1})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, errors.New("scan timed out after " + GitTimeout.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	je := json.NewEncoder(w)
+	je.SetEscapeHTML(false)
+	je.Encode(v)
+}
+
+// ListenAndServe serve the api on addr until it fails or the context is done; then the git scans running are
+// cancelled and the requests in flight have ShutdownTimeout to finish
+func (srv *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv.ctx = ctx
+	hs := &http.Server{Addr: addr, Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- hs.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	return hs.Shutdown(shutdownCtx)
+}
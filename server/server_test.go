@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sunshine69/automation-go/scanner"
+)
+
+func newServer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	cfg := scanner.DefaultConfig()
+	cfg.CheckMode = "letter"
+	srv, err := New(cfg, token, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestContentScan(t *testing.T) {
+	ts := newServer(t, "s3cret")
+	post := func(url, contentType, body, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := post(ts.URL+"/api/scans?name=app.conf", "text/plain", "x", "bad"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expect 401 with a bad token, got %s", resp.Status)
+	}
+	resp := post(ts.URL+"/api/scans?name=app.conf", "text/plain", "host=localhost\npassword=\"Xk9dLq2ZmP7wR4\"\n", "s3cret")
+	sc := Scan{}
+	json.NewDecoder(resp.Body).Decode(&sc)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || sc.Status != StatusDone || len(sc.Findings["app.conf"]) != 1 {
+		t.Fatalf("expect the finding of app.conf, got %s %+v", resp.Status, sc)
+	}
+	resp = post(ts.URL+"/api/scans", "application/json", `{"name": "a.env", "content": "token=Ab3dEf9hIj2kLm"}`, "s3cret")
+	json.NewDecoder(resp.Body).Decode(&sc)
+	resp.Body.Close()
+	if len(sc.Findings["a.env"]) != 1 {
+		t.Errorf("expect the finding of the json content, got %+v", sc)
+	}
+	if resp := post(ts.URL+"/api/scans", "application/json", `{"git_url": "/etc"}`, "s3cret"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expect a local git url rejected, got %s", resp.Status)
+	}
+
+	req, _ := http.NewRequest("GET", ts.URL+"/api/scans/"+sc.ID+"?format=sarif", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	log := scanner.SarifLog{}
+	if err := json.NewDecoder(resp.Body).Decode(&log); err != nil || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Errorf("expect a sarif log with one result, got %+v %v", log, err)
+	}
+}
+
+func TestCloneAndScan(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.conf"), []byte("password=\"Xk9dLq2ZmP7wR4\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}, {"commit", "-q", "-m", "init"}} {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=tester", "-c", "user.email=tester@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, out)
+		}
+	}
+	cfg := scanner.DefaultConfig()
+	cfg.CheckMode = "letter"
+	srv, err := New(cfg, "", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := srv.cloneAndScan(context.Background(), "file://"+dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := findings["app.conf"]["password*****"]; !ok || o.File != "app.conf" {
+		t.Errorf("expect the finding of app.conf relative to the repository, got %v", findings)
+	}
+}
+
+func TestListenAndServeShutdown(t *testing.T) {
+	srv, err := New(scanner.DefaultConfig(), "", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.ListenAndServe(ctx, "127.0.0.1:0") }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expect a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect the server stopped when the context is done")
+	}
+}
+
+func TestGitScanLimit(t *testing.T) {
+	cfg := scanner.DefaultConfig()
+	cfg.CheckpointPath = filepath.Join(t.TempDir(), "checkpoint")
+	srv, err := New(cfg, "", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.cfg.CheckpointPath != "" {
+		t.Error("expect the checkpoint disabled, the scans run concurrently")
+	}
+	srv.gitRuns = MaxGitScans
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	resp, err := http.Post(ts.URL+"/api/scans", "application/json", strings.NewReader(`{"git_url": "https://git.invalid/repo.git"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || len(srv.scans) != 0 {
+		t.Errorf("expect 429 and no scan added when MaxGitScans are running, got %s %v", resp.Status, srv.scans)
+	}
+}
+
+func TestCheckGitURL(t *testing.T) {
+	srv, err := New(scanner.DefaultConfig(), "", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	for _, url := range []string{"file:///etc", "/etc", "repo", "http://10.0.0.1/repo.git", "git://10.0.0.1/repo.git"} {
+		resp, err := http.Post(ts.URL+"/api/scans", "application/json", strings.NewReader(`{"git_url": "`+url+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || len(srv.scans) != 0 {
+			t.Errorf("expect %s rejected with 400, got %s", url, resp.Status)
+		}
+	}
+	for _, url := range []string{"https://git.example.com/repo.git", "ssh://git@git.example.com/repo.git", "git@git.example.com:repo.git"} {
+		if err := checkGitURL(url); err != nil {
+			t.Errorf("expect %s accepted, got %v", url, err)
+		}
+	}
+	AllowPlainGit = true
+	defer func() { AllowPlainGit = false }()
+	for _, url := range []string{"http://git.example.com/repo.git", "git://git.example.com/repo.git"} {
+		if err := checkGitURL(url); err != nil {
+			t.Errorf("expect %s accepted with AllowPlainGit, got %v", url, err)
+		}
+	}
+	if err := checkGitURL("file:///etc"); err == nil {
+		t.Error("expect file:// rejected with AllowPlainGit")
+	}
+}